@@ -22,6 +22,7 @@ import math "math"
 import _ "github.com/mwitkow/go-proto-validators"
 import  kedge_config_grpc_backends "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/backends"
 import  kedge_config_http_backends "github.com/mwitkow/kedge/_protogen/kedge/config/http/backends"
+import  kedge_config_tcp_backends "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/backends"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -39,6 +40,7 @@ type BackendPoolConfig struct {
 	TlsServerConfigs []*TlsServerConfig      `protobuf:"bytes,1,rep,name=tls_server_configs,json=tlsServerConfigs" json:"tls_server_configs,omitempty"`
 	Grpc             *BackendPoolConfig_Grpc `protobuf:"bytes,2,opt,name=grpc" json:"grpc,omitempty"`
 	Http             *BackendPoolConfig_Http `protobuf:"bytes,3,opt,name=http" json:"http,omitempty"`
+	Tcp              *BackendPoolConfig_Tcp  `protobuf:"bytes,4,opt,name=tcp" json:"tcp,omitempty"`
 }
 
 func (m *BackendPoolConfig) Reset()                    { *m = BackendPoolConfig{} }
@@ -67,6 +69,13 @@ func (m *BackendPoolConfig) GetHttp() *BackendPoolConfig_Http {
 	return nil
 }
 
+func (m *BackendPoolConfig) GetTcp() *BackendPoolConfig_Tcp {
+	if m != nil {
+		return m.Tcp
+	}
+	return nil
+}
+
 type BackendPoolConfig_Grpc struct {
 	Backends []*kedge_config_grpc_backends.Backend `protobuf:"bytes,1,rep,name=backends" json:"backends,omitempty"`
 }
@@ -99,6 +108,22 @@ func (m *BackendPoolConfig_Http) GetBackends() []*kedge_config_http_backends.Bac
 	return nil
 }
 
+type BackendPoolConfig_Tcp struct {
+	Backends []*kedge_config_tcp_backends.Backend `protobuf:"bytes,1,rep,name=backends" json:"backends,omitempty"`
+}
+
+func (m *BackendPoolConfig_Tcp) Reset()                    { *m = BackendPoolConfig_Tcp{} }
+func (m *BackendPoolConfig_Tcp) String() string            { return proto.CompactTextString(m) }
+func (*BackendPoolConfig_Tcp) ProtoMessage()               {}
+func (*BackendPoolConfig_Tcp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 2} }
+
+func (m *BackendPoolConfig_Tcp) GetBackends() []*kedge_config_tcp_backends.Backend {
+	if m != nil {
+		return m.Backends
+	}
+	return nil
+}
+
 type TlsServerConfig struct {
 	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 }
@@ -119,31 +144,35 @@ func init() {
 	proto.RegisterType((*BackendPoolConfig)(nil), "kedge.config.BackendPoolConfig")
 	proto.RegisterType((*BackendPoolConfig_Grpc)(nil), "kedge.config.BackendPoolConfig.Grpc")
 	proto.RegisterType((*BackendPoolConfig_Http)(nil), "kedge.config.BackendPoolConfig.Http")
+	proto.RegisterType((*BackendPoolConfig_Tcp)(nil), "kedge.config.BackendPoolConfig.Tcp")
 	proto.RegisterType((*TlsServerConfig)(nil), "kedge.config.TlsServerConfig")
 }
 
 func init() { proto.RegisterFile("kedge/config/backendpool.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 318 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0x4d, 0x4b, 0xc3, 0x30,
-	0x18, 0xc7, 0xe9, 0x56, 0x44, 0x33, 0x61, 0x2e, 0x20, 0x94, 0x82, 0x5a, 0xe6, 0x0e, 0x15, 0x6c,
-	0x0a, 0x55, 0x86, 0x07, 0x41, 0x98, 0x87, 0x09, 0x5e, 0xa4, 0x7a, 0x13, 0x2d, 0x7d, 0x89, 0x5d,
-	0xe9, 0x4b, 0x42, 0x12, 0x37, 0x50, 0xfc, 0xac, 0x82, 0x07, 0x3f, 0x87, 0x24, 0xb5, 0xc3, 0xfa,
-	0x7e, 0x0b, 0xc9, 0xff, 0xf7, 0xfb, 0x3f, 0x4f, 0x0b, 0xb6, 0x73, 0x9c, 0xa4, 0xd8, 0x8d, 0x49,
-	0x75, 0x97, 0xa5, 0x6e, 0x14, 0xc6, 0x39, 0xae, 0x12, 0x4a, 0x48, 0x81, 0x28, 0x23, 0x82, 0xc0,
-	0x75, 0xf5, 0x8e, 0xea, 0x77, 0x73, 0x9c, 0x66, 0x62, 0x76, 0x1f, 0xa1, 0x98, 0x94, 0x6e, 0xb9,
-	0xc8, 0x44, 0x4e, 0x16, 0x6e, 0x4a, 0x1c, 0x15, 0x75, 0xe6, 0x61, 0x91, 0x25, 0xa1, 0x20, 0x8c,
-	0xbb, 0xcb, 0x63, 0x6d, 0x31, 0xed, 0x56, 0x4b, 0xca, 0x68, 0xdc, 0x54, 0xf1, 0xe6, 0xf0, 0x6d,
-	0x72, 0x26, 0x04, 0xfd, 0x21, 0x39, 0x7c, 0xed, 0x80, 0xc1, 0xa4, 0xbe, 0xb9, 0x20, 0xa4, 0x38,
-	0x55, 0x04, 0x3c, 0x07, 0x50, 0x14, 0x3c, 0xe0, 0x98, 0xcd, 0x31, 0x0b, 0x6a, 0x0d, 0x37, 0x34,
-	0xab, 0x6b, 0xf7, 0xbc, 0x2d, 0xf4, 0x71, 0x19, 0x74, 0x55, 0xf0, 0x4b, 0x15, 0xab, 0x51, 0x7f,
-	0x43, 0xb4, 0x2f, 0x38, 0x3c, 0x02, 0xba, 0x9c, 0xd5, 0xe8, 0x58, 0x9a, 0xdd, 0xf3, 0x46, 0x6d,
-	0xfc, 0x4b, 0x37, 0x9a, 0x32, 0x1a, 0xfb, 0x8a, 0x90, 0xa4, 0x9c, 0xdd, 0xe8, 0xfe, 0x8f, 0x3c,
-	0x13, 0x82, 0xfa, 0x8a, 0x30, 0xa7, 0x40, 0x97, 0x1e, 0x78, 0x02, 0x56, 0x9b, 0xc5, 0xdf, 0xc7,
-	0xdf, 0x6d, 0x5b, 0x64, 0x0f, 0x6a, 0x22, 0x8d, 0xd3, 0x5f, 0x42, 0x52, 0x24, 0xb5, 0x7f, 0x8b,
-	0x64, 0xed, 0x2f, 0xa2, 0xe1, 0x31, 0xe8, 0x7f, 0xfa, 0x54, 0x70, 0x0f, 0xe8, 0x55, 0x58, 0x62,
-	0x43, 0xb3, 0x34, 0x7b, 0x6d, 0xb2, 0xf9, 0xf2, 0xbc, 0x33, 0x00, 0xfd, 0xdb, 0xeb, 0xd0, 0x79,
-	0x08, 0xd0, 0xcd, 0xa3, 0xb7, 0x3f, 0x3e, 0x7c, 0x1a, 0xf9, 0x2a, 0x12, 0xad, 0xa8, 0xbf, 0x75,
-	0xf0, 0x16, 0x00, 0x00, 0xff, 0xff, 0x85, 0x9f, 0x6a, 0xa2, 0x69, 0x02, 0x00, 0x00,
+	// 354 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x92, 0x51, 0x4b, 0xf3, 0x30,
+	0x14, 0x86, 0xe9, 0xd7, 0xf2, 0xa1, 0x99, 0x30, 0x17, 0x10, 0xca, 0x40, 0x1d, 0x73, 0x60, 0x05,
+	0x97, 0xc2, 0xd4, 0xe1, 0x85, 0x28, 0x4c, 0x64, 0x82, 0x37, 0x52, 0x77, 0x27, 0x3a, 0xba, 0xac,
+	0x76, 0x65, 0x5d, 0x13, 0x92, 0xe3, 0x06, 0x8a, 0xbf, 0x55, 0x10, 0x7f, 0x88, 0x24, 0xb5, 0xc3,
+	0x6e, 0x6e, 0xf3, 0x2e, 0xe4, 0xbc, 0xcf, 0x73, 0xce, 0x69, 0x83, 0x76, 0x86, 0x41, 0x3f, 0x0c,
+	0x5c, 0xca, 0x92, 0xa7, 0x28, 0x74, 0x7b, 0x3e, 0x1d, 0x06, 0x49, 0x9f, 0x33, 0x16, 0x13, 0x2e,
+	0x18, 0x30, 0xbc, 0xa1, 0xeb, 0x24, 0xad, 0x97, 0x9b, 0x61, 0x04, 0x83, 0xe7, 0x1e, 0xa1, 0x6c,
+	0xe4, 0x8e, 0x26, 0x11, 0x0c, 0xd9, 0xc4, 0x0d, 0x59, 0x5d, 0x47, 0xeb, 0x63, 0x3f, 0x8e, 0xfa,
+	0x3e, 0x30, 0x21, 0xdd, 0xe9, 0x31, 0xb5, 0x94, 0x9d, 0x5c, 0x97, 0x50, 0x70, 0x9a, 0xb5, 0x92,
+	0xd9, 0xe1, 0xd7, 0xe4, 0x00, 0x80, 0x2f, 0x4a, 0xee, 0xe7, 0x92, 0x40, 0x17, 0x05, 0xab, 0x9f,
+	0x26, 0x2a, 0xb5, 0xd2, 0x9b, 0x5b, 0xc6, 0xe2, 0x4b, 0x0d, 0xe0, 0x1b, 0x84, 0x21, 0x96, 0x5d,
+	0x19, 0x88, 0x71, 0x20, 0xba, 0xa9, 0x45, 0xda, 0x46, 0xc5, 0x74, 0x0a, 0x8d, 0x6d, 0xf2, 0x73,
+	0x6b, 0xd2, 0x89, 0xe5, 0x9d, 0x8e, 0xa5, 0xa8, 0xb7, 0x09, 0xf9, 0x0b, 0x89, 0x4f, 0x91, 0xa5,
+	0x96, 0xb2, 0xff, 0x55, 0x0c, 0xa7, 0xd0, 0xa8, 0xe5, 0xf1, 0xb9, 0xde, 0xa4, 0x2d, 0x38, 0xf5,
+	0x34, 0xa1, 0x48, 0xb5, 0xa4, 0x6d, 0xfe, 0x8d, 0xbc, 0x06, 0xe0, 0x9e, 0x26, 0xf0, 0x09, 0x32,
+	0x81, 0x72, 0xdb, 0xd2, 0xe0, 0xde, 0x2a, 0xb0, 0x43, 0xb9, 0xa7, 0xf2, 0xe5, 0x36, 0xb2, 0x54,
+	0x7b, 0x7c, 0x81, 0xd6, 0xb2, 0xef, 0xf5, 0xbd, 0xf5, 0x8c, 0x43, 0x8d, 0x47, 0xb2, 0x48, 0x66,
+	0xf4, 0xa6, 0x90, 0x12, 0xa9, 0x69, 0x56, 0x8b, 0xd4, 0xb4, 0xcb, 0x44, 0x57, 0xc8, 0xec, 0x50,
+	0x8e, 0xcf, 0xe7, 0x3c, 0xd5, 0xbc, 0x07, 0xe8, 0x32, 0x4d, 0xf5, 0x0c, 0x15, 0x67, 0x7e, 0x14,
+	0x3e, 0x40, 0x56, 0xe2, 0x8f, 0x02, 0xdb, 0xa8, 0x18, 0xce, 0x7a, 0x6b, 0xeb, 0xe3, 0x7d, 0xb7,
+	0x84, 0x8a, 0x8f, 0xf7, 0x7e, 0xfd, 0xa5, 0x4b, 0x1e, 0x5e, 0x1b, 0x87, 0xcd, 0xe3, 0xb7, 0x9a,
+	0xa7, 0x23, 0xbd, 0xff, 0xfa, 0xad, 0x1c, 0x7d, 0x0d, 0x00, 0xf4, 0x7f, 0xcd, 0x9f, 0x10, 0x03,
+	0x00, 0x00,
 }
@@ -64,6 +64,15 @@ type K8SResolver struct {
 	// to resolve by this resolver using endpoints API.
 	// e.g ":backend1.namespace1:http_port1"
 	DnsPortName string `protobuf:"bytes,1,opt,name=dns_port_name,json=dnsPortName" json:"dns_port_name,omitempty"`
+	// use_endpoint_slices makes the resolver watch EndpointSlice API (discovery.k8s.io/v1) instead of Endpoints API.
+	// Recommended for large services, as changes do not require transferring all the endpoints of the service.
+	UseEndpointSlices bool `protobuf:"varint,2,opt,name=use_endpoint_slices,json=useEndpointSlices" json:"use_endpoint_slices,omitempty"`
+	// include_not_ready makes the resolver resolve addresses of endpoints that are not ready as well. By default these
+	// are excluded. Readiness is passed to load balancer in update metadata.
+	IncludeNotReady bool `protobuf:"varint,3,opt,name=include_not_ready,json=includeNotReady" json:"include_not_ready,omitempty"`
+	// cluster is a name of the cluster to resolve the service in, one of kubeconfig contexts from k8sresolver_clusters
+	// flag. If empty, the cluster configured by other k8sresolver_* flags (usually the local one) is used.
+	Cluster string `protobuf:"bytes,4,opt,name=cluster" json:"cluster,omitempty"`
 }
 
 func (m *K8SResolver) Reset()                    { *m = K8SResolver{} }
@@ -78,6 +87,27 @@ func (m *K8SResolver) GetDnsPortName() string {
 	return ""
 }
 
+func (m *K8SResolver) GetUseEndpointSlices() bool {
+	if m != nil {
+		return m.UseEndpointSlices
+	}
+	return false
+}
+
+func (m *K8SResolver) GetIncludeNotReady() bool {
+	if m != nil {
+		return m.IncludeNotReady
+	}
+	return false
+}
+
+func (m *K8SResolver) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*SrvResolver)(nil), "kedge.config.common.resolvers.SrvResolver")
 	proto.RegisterType((*K8SResolver)(nil), "kedge.config.common.resolvers.K8sResolver")
@@ -86,16 +116,21 @@ func init() {
 func init() { proto.RegisterFile("kedge/config/common/resolvers/resolvers.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 176 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x8e, 0xcb, 0x0a, 0x82, 0x50,
-	0x10, 0x40, 0xb1, 0x45, 0x8f, 0x6b, 0x6e, 0x5c, 0xd9, 0x22, 0x10, 0xdb, 0xb8, 0xe9, 0x4a, 0xb4,
-	0xe9, 0x1f, 0xa2, 0x07, 0xf6, 0x01, 0x62, 0xde, 0x49, 0xa4, 0xee, 0x8c, 0xcc, 0x88, 0xdf, 0x1f,
-	0x3e, 0xb0, 0x76, 0x33, 0x87, 0x33, 0x87, 0x51, 0xfb, 0x37, 0x98, 0x12, 0x92, 0x82, 0xf0, 0x55,
-	0x95, 0x49, 0x41, 0xd6, 0x12, 0x26, 0x0c, 0x42, 0x9f, 0x16, 0x58, 0x7e, 0x93, 0xae, 0x99, 0x1a,
-	0xf2, 0xb7, 0xbd, 0xae, 0x07, 0x5d, 0x0f, 0xba, 0x9e, 0xa4, 0xe8, 0xa2, 0xdc, 0x07, 0xb7, 0xe9,
-	0xb8, 0xfb, 0x1b, 0xb5, 0x34, 0x28, 0x19, 0xe6, 0x16, 0x02, 0x27, 0x74, 0xe2, 0x55, 0xba, 0x30,
-	0x28, 0xd7, 0xdc, 0x82, 0xbf, 0x53, 0x5e, 0x4d, 0xdc, 0x64, 0xd4, 0x02, 0x73, 0x65, 0x20, 0x98,
-	0x85, 0x4e, 0xec, 0xa5, 0xeb, 0x0e, 0xde, 0x46, 0x16, 0x1d, 0x94, 0x7b, 0x3e, 0xc9, 0x94, 0x8b,
-	0x94, 0xd7, 0xe5, 0xfa, 0xbb, 0xbf, 0xa6, 0x6b, 0x50, 0xee, 0xc4, 0x4d, 0xd7, 0x7d, 0xce, 0xfb,
-	0x3f, 0x8f, 0xdf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x82, 0x8a, 0x4e, 0x97, 0xd8, 0x00, 0x00, 0x00,
+	// 246 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x90, 0xc1, 0x4a, 0xc4, 0x30,
+	0x10, 0x86, 0xa9, 0x8a, 0xbb, 0x66, 0x2d, 0xb2, 0xf5, 0x52, 0x0f, 0xc2, 0x52, 0x2f, 0x8b, 0x60,
+	0x7b, 0xf0, 0xe2, 0x0b, 0x78, 0x12, 0x57, 0xe9, 0x3e, 0x40, 0xa8, 0xc9, 0xb8, 0x04, 0xdb, 0x99,
+	0x32, 0x93, 0x16, 0x7c, 0x22, 0x5f, 0x53, 0x9a, 0x86, 0xd5, 0x5b, 0xfe, 0xff, 0xff, 0xf2, 0x41,
+	0xa2, 0x1e, 0xbe, 0xc0, 0x1e, 0xa0, 0x32, 0x84, 0x9f, 0xee, 0x50, 0x19, 0xea, 0x3a, 0xc2, 0x8a,
+	0x41, 0xa8, 0x1d, 0x81, 0xe5, 0xef, 0x54, 0xf6, 0x4c, 0x9e, 0xb2, 0xdb, 0x80, 0x97, 0x33, 0x5e,
+	0xce, 0x78, 0x79, 0x84, 0x8a, 0x57, 0xb5, 0xda, 0xf3, 0x58, 0xc7, 0x9c, 0xdd, 0xa8, 0xa5, 0x45,
+	0xd1, 0xd8, 0x74, 0x90, 0x27, 0x9b, 0x64, 0x7b, 0x51, 0x2f, 0x2c, 0xca, 0xae, 0xe9, 0x20, 0xbb,
+	0x53, 0x69, 0x4f, 0xec, 0x35, 0x8d, 0xc0, 0xec, 0x2c, 0xe4, 0x27, 0x9b, 0x64, 0x9b, 0xd6, 0x97,
+	0x53, 0xf9, 0x16, 0xbb, 0xe2, 0x27, 0x51, 0xab, 0x97, 0x27, 0x39, 0xfa, 0x0a, 0x95, 0x4e, 0xbe,
+	0x70, 0xf1, 0x9f, 0x74, 0x65, 0x51, 0xde, 0x89, 0x7d, 0x10, 0x97, 0xea, 0x7a, 0x10, 0xd0, 0x80,
+	0xb6, 0x27, 0x87, 0x5e, 0x4b, 0xeb, 0x0c, 0x48, 0xd0, 0x2f, 0xeb, 0xf5, 0x20, 0xf0, 0x1c, 0x97,
+	0x7d, 0x18, 0xb2, 0x7b, 0xb5, 0x76, 0x68, 0xda, 0xc1, 0x82, 0x46, 0xf2, 0x9a, 0xa1, 0xb1, 0xdf,
+	0xf9, 0x69, 0xa0, 0xaf, 0xe2, 0xb0, 0x23, 0x5f, 0x4f, 0x75, 0x96, 0xab, 0x85, 0x69, 0x07, 0xf1,
+	0xc0, 0xf9, 0xd9, 0xfc, 0x9c, 0x18, 0x3f, 0xce, 0xc3, 0xf7, 0x3c, 0xfe, 0x0e, 0x00, 0x18, 0xf5,
+	0x1b, 0xdf, 0x4f, 0x01, 0x00, 0x00,
 }
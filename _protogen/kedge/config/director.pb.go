@@ -11,6 +11,7 @@ import _ "github.com/mwitkow/go-proto-validators"
 import  kedge_config_grpc_routes "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/routes"
 import  kedge_config_http_routes "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
 import  kedge_config_http_routes1 "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+import  kedge_config_tcp_routes "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/routes"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -21,6 +22,8 @@ var _ = math.Inf
 type DirectorConfig struct {
 	Grpc *DirectorConfig_Grpc `protobuf:"bytes,1,opt,name=grpc" json:"grpc,omitempty"`
 	Http *DirectorConfig_Http `protobuf:"bytes,2,opt,name=http" json:"http,omitempty"`
+	// / tcp is optional, as TCP (L4) proxying is only enabled with server_tcp_ports.
+	Tcp *DirectorConfig_Tcp `protobuf:"bytes,3,opt,name=tcp" json:"tcp,omitempty"`
 }
 
 func (m *DirectorConfig) Reset()                    { *m = DirectorConfig{} }
@@ -42,6 +45,13 @@ func (m *DirectorConfig) GetHttp() *DirectorConfig_Http {
 	return nil
 }
 
+func (m *DirectorConfig) GetTcp() *DirectorConfig_Tcp {
+	if m != nil {
+		return m.Tcp
+	}
+	return nil
+}
+
 type DirectorConfig_Grpc struct {
 	Routes []*kedge_config_grpc_routes.Route `protobuf:"bytes,1,rep,name=routes" json:"routes,omitempty"`
 }
@@ -82,32 +92,51 @@ func (m *DirectorConfig_Http) GetAdhocRules() []*kedge_config_http_routes.Adhoc
 	return nil
 }
 
+type DirectorConfig_Tcp struct {
+	Routes []*kedge_config_tcp_routes.Route `protobuf:"bytes,1,rep,name=routes" json:"routes,omitempty"`
+}
+
+func (m *DirectorConfig_Tcp) Reset()                    { *m = DirectorConfig_Tcp{} }
+func (m *DirectorConfig_Tcp) String() string            { return proto.CompactTextString(m) }
+func (*DirectorConfig_Tcp) ProtoMessage()               {}
+func (*DirectorConfig_Tcp) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{0, 2} }
+
+func (m *DirectorConfig_Tcp) GetRoutes() []*kedge_config_tcp_routes.Route {
+	if m != nil {
+		return m.Routes
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*DirectorConfig)(nil), "kedge.config.DirectorConfig")
 	proto.RegisterType((*DirectorConfig_Grpc)(nil), "kedge.config.DirectorConfig.Grpc")
 	proto.RegisterType((*DirectorConfig_Http)(nil), "kedge.config.DirectorConfig.Http")
+	proto.RegisterType((*DirectorConfig_Tcp)(nil), "kedge.config.DirectorConfig.Tcp")
 }
 
 func init() { proto.RegisterFile("kedge/config/director.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 282 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x90, 0xcf, 0x4a, 0xc3, 0x40,
-	0x10, 0xc6, 0x49, 0x0c, 0x39, 0x6c, 0xc4, 0x43, 0x4e, 0x21, 0x1e, 0x1a, 0x45, 0xa1, 0x97, 0xee,
-	0x42, 0x05, 0x3d, 0x89, 0x7f, 0x41, 0xcf, 0x79, 0x01, 0x49, 0x37, 0x31, 0x59, 0xda, 0x3a, 0xcb,
-	0x66, 0x62, 0xcf, 0x3e, 0xa3, 0x0f, 0x20, 0xf8, 0x24, 0x32, 0x9b, 0x55, 0x13, 0x28, 0xb4, 0xa7,
-	0x0d, 0xcc, 0xef, 0xfb, 0x7d, 0x99, 0x61, 0xc7, 0xcb, 0xaa, 0xac, 0x2b, 0x21, 0xe1, 0xed, 0x55,
-	0xd5, 0xa2, 0x54, 0xa6, 0x92, 0x08, 0x86, 0x6b, 0x03, 0x08, 0xf1, 0xa1, 0x1d, 0xf2, 0x7e, 0x98,
-	0x5e, 0xd6, 0x0a, 0x9b, 0x6e, 0xc1, 0x25, 0xac, 0xc5, 0x7a, 0xa3, 0x70, 0x09, 0x1b, 0x51, 0xc3,
-	0xcc, 0xa2, 0xb3, 0xf7, 0x62, 0xa5, 0xca, 0x02, 0xc1, 0xb4, 0xe2, 0xef, 0xb3, 0xb7, 0xa4, 0xe7,
-	0xa3, 0x8a, 0xda, 0x68, 0x29, 0x0c, 0x74, 0x58, 0xb5, 0xee, 0x71, 0xd8, 0xd9, 0x08, 0x6b, 0x10,
-	0xf5, 0x2f, 0x56, 0x94, 0x0d, 0xc8, 0xad, 0xb2, 0x21, 0x35, 0x94, 0x9d, 0x7e, 0xfa, 0xec, 0xe8,
-	0xd1, 0x2d, 0xf3, 0x60, 0xd9, 0xf8, 0x9a, 0x05, 0xd4, 0x9d, 0x78, 0x99, 0x37, 0x8d, 0xe6, 0x27,
-	0x7c, 0xb8, 0x1b, 0x1f, 0xb3, 0xfc, 0xc9, 0x68, 0x79, 0x1f, 0x7e, 0x7f, 0x4d, 0xfc, 0xcc, 0xcb,
-	0x6d, 0x8c, 0xe2, 0xd4, 0x96, 0xf8, 0x7b, 0xc4, 0x9f, 0x11, 0xf5, 0x7f, 0x9c, 0x62, 0xe9, 0x0d,
-	0x0b, 0x48, 0x1a, 0x5f, 0xb1, 0xb0, 0xff, 0xd1, 0xc4, 0xcb, 0x0e, 0xa6, 0xd1, 0x7c, 0x32, 0x16,
-	0x51, 0x15, 0x77, 0x9b, 0xe4, 0xf4, 0xe4, 0x0e, 0x4f, 0x3f, 0x3c, 0x16, 0x90, 0x77, 0x97, 0x81,
-	0xda, 0xb6, 0x1a, 0xe2, 0x5b, 0x16, 0xd9, 0x4b, 0xbe, 0x98, 0x6e, 0x55, 0xb5, 0x89, 0xbf, 0x2b,
-	0x7d, 0x47, 0x70, 0xce, 0x6c, 0x26, 0xa7, 0xc8, 0x22, 0xb4, 0xc7, 0xbd, 0xf8, 0x09, 0x00, 0x00,
-	0xff, 0xff, 0xeb, 0xe8, 0x4c, 0x54, 0x35, 0x02, 0x00, 0x00,
+	// 314 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0x4f, 0x4b, 0xc3, 0x30,
+	0x18, 0xc6, 0xe9, 0x3a, 0x7a, 0x48, 0xc5, 0x43, 0x4e, 0xa5, 0x82, 0xab, 0xa2, 0xb0, 0xcb, 0x12,
+	0xa8, 0x30, 0x4f, 0xc3, 0xbf, 0xa0, 0xe7, 0xb0, 0xbb, 0x74, 0x69, 0x6d, 0xcb, 0x36, 0x13, 0xd2,
+	0xb7, 0xee, 0xec, 0x97, 0xf3, 0x6b, 0x08, 0x7e, 0x12, 0x79, 0xd3, 0xaa, 0xab, 0x94, 0xcd, 0x53,
+	0x0a, 0xf9, 0xfd, 0x9e, 0x3e, 0x6f, 0x5e, 0x72, 0xb4, 0xcc, 0xd2, 0x3c, 0xe3, 0x52, 0xbd, 0x3c,
+	0x97, 0x39, 0x4f, 0x4b, 0x93, 0x49, 0x50, 0x86, 0x69, 0xa3, 0x40, 0xd1, 0x03, 0x7b, 0xc9, 0x9a,
+	0xcb, 0x70, 0x9a, 0x97, 0x50, 0xd4, 0x0b, 0x26, 0xd5, 0x9a, 0xaf, 0x37, 0x25, 0x2c, 0xd5, 0x86,
+	0xe7, 0x6a, 0x62, 0xd1, 0xc9, 0x6b, 0xb2, 0x2a, 0xd3, 0x04, 0x94, 0xa9, 0xf8, 0xcf, 0x67, 0x93,
+	0x12, 0x9e, 0x77, 0x7e, 0x91, 0x1b, 0x2d, 0xb9, 0x51, 0x35, 0x64, 0x55, 0x7b, 0xb4, 0xd8, 0x59,
+	0x07, 0x2b, 0x00, 0xf4, 0x37, 0x96, 0xa4, 0x85, 0x92, 0xbd, 0x61, 0xdb, 0xd4, 0x8e, 0x30, 0x90,
+	0xbd, 0xd4, 0xe9, 0xbb, 0x4b, 0x0e, 0xef, 0xdb, 0x91, 0xef, 0x2c, 0x4a, 0x67, 0x64, 0x88, 0x0d,
+	0x03, 0x27, 0x72, 0xc6, 0x7e, 0x7c, 0xc2, 0xb6, 0x5f, 0x80, 0x75, 0x59, 0xf6, 0x60, 0xb4, 0xbc,
+	0xf5, 0x3e, 0x3f, 0x46, 0x83, 0xc8, 0x11, 0x56, 0x43, 0x1d, 0x3b, 0x05, 0x83, 0x7f, 0xe8, 0x8f,
+	0x00, 0xfa, 0x57, 0x47, 0x8d, 0xc6, 0xc4, 0x05, 0xa9, 0x03, 0xd7, 0xda, 0xd1, 0x4e, 0x7b, 0x2e,
+	0xb5, 0x40, 0x38, 0xbc, 0x22, 0x43, 0x2c, 0x42, 0x2f, 0x89, 0xd7, 0x0c, 0x17, 0x38, 0x91, 0x3b,
+	0xf6, 0xe3, 0x51, 0x57, 0xc7, 0x7a, 0xac, 0x9d, 0x5e, 0xe0, 0x21, 0x5a, 0x3c, 0x7c, 0x73, 0xc8,
+	0x10, 0xbb, 0xec, 0x4b, 0xc0, 0x86, 0xbd, 0x09, 0xf4, 0x9a, 0xf8, 0x76, 0x47, 0x4f, 0xa6, 0x5e,
+	0x65, 0x55, 0x30, 0xd8, 0x67, 0xdf, 0x20, 0x2c, 0x88, 0x75, 0x04, 0x2a, 0xe1, 0x8c, 0xb8, 0x73,
+	0xa9, 0xe9, 0xf4, 0x4f, 0x83, 0xe3, 0x6e, 0x06, 0xc8, 0xfe, 0x02, 0x0b, 0xcf, 0xee, 0xf3, 0xe2,
+	0x6b, 0x00, 0xa5, 0x6f, 0x3e, 0x7d, 0xce, 0x02, 0x00, 0x00,
 }
@@ -12,6 +12,7 @@ import _ "github.com/mwitkow/go-proto-validators"
 import  _ "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/routes"
 import  _ "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
 import  _ "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+import  _ "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/routes"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -35,6 +36,11 @@ func (this *DirectorConfig) Validate() error {
 			return github_com_mwitkow_go_proto_validators.FieldError("Http", err)
 		}
 	}
+	if this.Tcp != nil {
+		if err := github_com_mwitkow_go_proto_validators.CallValidatorIfExists(this.Tcp); err != nil {
+			return github_com_mwitkow_go_proto_validators.FieldError("Tcp", err)
+		}
+	}
 	return nil
 }
 func (this *DirectorConfig_Grpc) Validate() error {
@@ -64,3 +70,13 @@ func (this *DirectorConfig_Http) Validate() error {
 	}
 	return nil
 }
+func (this *DirectorConfig_Tcp) Validate() error {
+	for _, item := range this.Routes {
+		if item != nil {
+			if err := github_com_mwitkow_go_proto_validators.CallValidatorIfExists(item); err != nil {
+				return github_com_mwitkow_go_proto_validators.FieldError("Routes", err)
+			}
+		}
+	}
+	return nil
+}
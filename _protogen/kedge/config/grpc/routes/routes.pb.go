@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Route
+	Cors
 */
 package kedge_config_grpc_routes
 
@@ -51,6 +52,15 @@ type Route struct {
 	// / If a given metadata entry has more than one string value, at least one of them needs to match.
 	// / If none are present, the route skips metadata checks.
 	MetadataMatcher map[string]string `protobuf:"bytes,4,rep,name=metadata_matcher,json=metadataMatcher" json:"metadata_matcher,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// / cors enables Cross-Origin Resource Sharing for gRPC-Web (browser) calls matching this route.
+	// / CORS preflight requests carry no gRPC metadata, so only service_name_matcher and authority_matcher are taken
+	// / into account when picking the CORS policy.
+	// / If not present, cross-origin gRPC-Web calls are not allowed.
+	Cors *Cors `protobuf:"bytes,5,opt,name=cors" json:"cors,omitempty"`
+	// / allow_plaintext allows the route to be served for calls that came over plain-text (insecure, h2c) listeners,
+	// / e.g. server_grpc_plain_port.
+	// / If false, the route matches only calls that came over TLS, which keeps sensitive routes TLS-only.
+	AllowPlaintext bool `protobuf:"varint,6,opt,name=allow_plaintext,json=allowPlaintext" json:"allow_plaintext,omitempty"`
 }
 
 func (m *Route) Reset()                    { *m = Route{} }
@@ -86,32 +96,115 @@ func (m *Route) GetMetadataMatcher() map[string]string {
 	return nil
 }
 
+func (m *Route) GetCors() *Cors {
+	if m != nil {
+		return m.Cors
+	}
+	return nil
+}
+
+func (m *Route) GetAllowPlaintext() bool {
+	if m != nil {
+		return m.AllowPlaintext
+	}
+	return false
+}
+
+// / Cors is a CORS policy for gRPC-Web calls.
+type Cors struct {
+	// / allowed_origins is a list of origins (e.g. 'https://app.example.com') allowed to call the route.
+	// / The matching is done through lower-case string-equality. A single '*' allows any origin.
+	AllowedOrigins []string `protobuf:"bytes,1,rep,name=allowed_origins,json=allowedOrigins" json:"allowed_origins,omitempty"`
+	// / allowed_headers is a list of extra request headers the browser is allowed to send.
+	// / Headers required by gRPC-Web (e.g. 'x-grpc-web', 'content-type', 'grpc-timeout') are always allowed.
+	AllowedHeaders []string `protobuf:"bytes,2,rep,name=allowed_headers,json=allowedHeaders" json:"allowed_headers,omitempty"`
+	// / exposed_headers is a list of extra response headers the browser is allowed to read.
+	// / 'grpc-status' and 'grpc-message' are always exposed.
+	ExposedHeaders []string `protobuf:"bytes,3,rep,name=exposed_headers,json=exposedHeaders" json:"exposed_headers,omitempty"`
+	// / max_age_sec specifies how long the preflight response can be cached by the browser.
+	// / If 0, the header is not sent and browser defaults apply.
+	MaxAgeSec uint32 `protobuf:"varint,4,opt,name=max_age_sec,json=maxAgeSec" json:"max_age_sec,omitempty"`
+	// / allow_credentials allows browsers to send cookies and HTTP auth with the cross-origin calls.
+	// / It applies only to listed origins, calls allowed by the '*' wildcard are never credentialed.
+	AllowCredentials bool `protobuf:"varint,5,opt,name=allow_credentials,json=allowCredentials" json:"allow_credentials,omitempty"`
+}
+
+func (m *Cors) Reset()                    { *m = Cors{} }
+func (m *Cors) String() string            { return proto.CompactTextString(m) }
+func (*Cors) ProtoMessage()               {}
+func (*Cors) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Cors) GetAllowedOrigins() []string {
+	if m != nil {
+		return m.AllowedOrigins
+	}
+	return nil
+}
+
+func (m *Cors) GetAllowedHeaders() []string {
+	if m != nil {
+		return m.AllowedHeaders
+	}
+	return nil
+}
+
+func (m *Cors) GetExposedHeaders() []string {
+	if m != nil {
+		return m.ExposedHeaders
+	}
+	return nil
+}
+
+func (m *Cors) GetMaxAgeSec() uint32 {
+	if m != nil {
+		return m.MaxAgeSec
+	}
+	return 0
+}
+
+func (m *Cors) GetAllowCredentials() bool {
+	if m != nil {
+		return m.AllowCredentials
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterType((*Route)(nil), "kedge.config.grpc.routes.Route")
+	proto.RegisterType((*Cors)(nil), "kedge.config.grpc.routes.Cors")
 }
 
 func init() { proto.RegisterFile("kedge/config/grpc/routes/routes.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 307 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x90, 0x51, 0x4b, 0x3a, 0x41,
-	0x14, 0xc5, 0x51, 0xff, 0xfe, 0xa1, 0x31, 0x50, 0x07, 0x03, 0xf1, 0x25, 0x89, 0x02, 0xa1, 0x9c,
-	0x0d, 0x13, 0x91, 0x1e, 0x85, 0x1e, 0xed, 0x61, 0x5f, 0xa3, 0x96, 0xeb, 0xec, 0x6d, 0x1d, 0xd6,
-	0xd9, 0x91, 0x71, 0x56, 0xd9, 0xa2, 0x8f, 0xd7, 0xe7, 0x08, 0xfa, 0x24, 0xb1, 0x33, 0xd3, 0x42,
-	0x50, 0x4f, 0x73, 0xe7, 0x9e, 0xdf, 0x39, 0x70, 0x0f, 0xb9, 0x48, 0x31, 0x4e, 0x30, 0xe0, 0x2a,
-	0x7b, 0x16, 0x49, 0x90, 0xe8, 0x2d, 0x0f, 0xb4, 0xca, 0x0d, 0xee, 0xfc, 0xc3, 0xb6, 0x5a, 0x19,
-	0x45, 0xfb, 0x16, 0x63, 0x0e, 0x63, 0x25, 0xc6, 0x9c, 0x3e, 0x98, 0x25, 0xc2, 0xac, 0xf3, 0x15,
-	0xe3, 0x4a, 0x06, 0xf2, 0x20, 0x4c, 0xaa, 0x0e, 0x41, 0xa2, 0xc6, 0xd6, 0x36, 0xde, 0xc3, 0x46,
-	0xc4, 0x60, 0x94, 0xde, 0x05, 0xd5, 0xe8, 0x12, 0xcf, 0xde, 0xeb, 0xa4, 0x19, 0x96, 0x11, 0x74,
-	0x4e, 0x8e, 0x57, 0xc0, 0x53, 0xcc, 0xe2, 0x28, 0x03, 0x89, 0xfd, 0xda, 0xb0, 0x36, 0x3a, 0x5a,
-	0x9c, 0x7c, 0x7e, 0x9c, 0x76, 0x49, 0xfb, 0xe9, 0x01, 0xc6, 0x2f, 0x11, 0x7b, 0x7c, 0x9d, 0x5c,
-	0xcd, 0xa6, 0x6f, 0xe7, 0x61, 0xcb, 0xa3, 0xf7, 0x20, 0x91, 0x5e, 0x93, 0xde, 0x0e, 0xf5, 0x5e,
-	0x70, 0xb4, 0xce, 0x48, 0x82, 0xe1, 0x6b, 0xd4, 0xfd, 0x7a, 0x99, 0x10, 0x52, 0xaf, 0x95, 0xe8,
-	0xd2, 0x29, 0xf4, 0x92, 0x74, 0x21, 0x37, 0x6b, 0xa5, 0x85, 0x29, 0x2a, 0xbc, 0x61, 0xf1, 0x4e,
-	0x25, 0x7c, 0xc3, 0x11, 0xe9, 0x48, 0x34, 0x10, 0x83, 0x81, 0x8a, 0xfd, 0x37, 0x6c, 0x8c, 0x5a,
-	0x93, 0x29, 0xfb, 0xab, 0x0f, 0x66, 0x6f, 0x62, 0x4b, 0xef, 0xf3, 0x51, 0x77, 0x99, 0xd1, 0x45,
-	0xd8, 0x96, 0x3f, 0xb7, 0x83, 0x05, 0xe9, 0xfd, 0x06, 0xd2, 0x0e, 0x69, 0xa4, 0x58, 0xb8, 0x22,
-	0xc2, 0x72, 0xa4, 0x3d, 0xd2, 0xdc, 0xc3, 0x26, 0x47, 0x7f, 0x9a, 0xfb, 0xdc, 0xd6, 0xe7, 0xb5,
-	0xd5, 0x7f, 0x5b, 0xe7, 0xcd, 0x57, 0x00, 0x00, 0x00, 0xff, 0xff, 0xa3, 0x54, 0xb2, 0x9f, 0xc9,
-	0x01, 0x00, 0x00,
+	// 452 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x92, 0xdf, 0x8a, 0xd3, 0x40,
+	0x14, 0xc6, 0x49, 0xd3, 0x2e, 0xdb, 0xa9, 0xda, 0x6e, 0xa8, 0x10, 0xf6, 0x62, 0x0d, 0x8b, 0x62,
+	0x40, 0x9b, 0x48, 0x5d, 0x96, 0xc5, 0x3b, 0xb7, 0x08, 0xde, 0xac, 0xca, 0x78, 0x29, 0x1a, 0x4e,
+	0x27, 0xc7, 0x74, 0x68, 0x26, 0x53, 0x66, 0xa6, 0xff, 0x14, 0x5f, 0xc6, 0x07, 0xf2, 0x15, 0x04,
+	0x9f, 0x44, 0x32, 0x93, 0xd6, 0x2e, 0xb8, 0x57, 0x99, 0x7c, 0xdf, 0xef, 0x7c, 0x27, 0xf9, 0x12,
+	0xf2, 0x64, 0x8e, 0x79, 0x81, 0x29, 0x93, 0xd5, 0x57, 0x5e, 0xa4, 0x85, 0x5a, 0xb0, 0x54, 0xc9,
+	0xa5, 0x41, 0xdd, 0x5c, 0x92, 0x85, 0x92, 0x46, 0x06, 0xa1, 0xc5, 0x12, 0x87, 0x25, 0x35, 0x96,
+	0x38, 0xff, 0xf4, 0xb2, 0xe0, 0x66, 0xb6, 0x9c, 0x26, 0x4c, 0x8a, 0x54, 0xac, 0xb9, 0x99, 0xcb,
+	0x75, 0x5a, 0xc8, 0x91, 0x1d, 0x1b, 0xad, 0xa0, 0xe4, 0x39, 0x18, 0xa9, 0x74, 0xba, 0x3f, 0xba,
+	0xc4, 0xf3, 0x9f, 0x3e, 0xe9, 0xd0, 0x3a, 0x22, 0xb8, 0x22, 0xf7, 0xa6, 0xc0, 0xe6, 0x58, 0xe5,
+	0x59, 0x05, 0x02, 0x43, 0x2f, 0xf2, 0xe2, 0xee, 0xf5, 0xc3, 0x3f, 0xbf, 0x1f, 0x9d, 0x90, 0xfe,
+	0x97, 0x4f, 0x30, 0xfa, 0x96, 0x25, 0x9f, 0xbf, 0x8f, 0x9f, 0x5f, 0x5e, 0xfc, 0x78, 0x4c, 0x7b,
+	0x0d, 0xfa, 0x0e, 0x04, 0x06, 0x2f, 0xc8, 0x50, 0xa3, 0x5a, 0x71, 0x86, 0x76, 0x32, 0x13, 0x60,
+	0xd8, 0x0c, 0x55, 0xd8, 0xaa, 0x13, 0x68, 0xd0, 0x78, 0x35, 0x7a, 0xe3, 0x9c, 0xe0, 0x19, 0x39,
+	0x81, 0xa5, 0x99, 0x49, 0xc5, 0xcd, 0x76, 0x8f, 0xfb, 0x16, 0x1f, 0xec, 0x8d, 0x1d, 0x9c, 0x91,
+	0x81, 0x40, 0x03, 0x39, 0x18, 0xd8, 0xb3, 0xed, 0xc8, 0x8f, 0x7b, 0xe3, 0x8b, 0xe4, 0xae, 0x3e,
+	0x12, 0xfb, 0x4e, 0xc9, 0x4d, 0x33, 0xd7, 0x44, 0xbd, 0xa9, 0x8c, 0xda, 0xd2, 0xbe, 0xb8, 0xad,
+	0x06, 0x63, 0xd2, 0x66, 0x52, 0xe9, 0xb0, 0x13, 0x79, 0x71, 0x6f, 0x7c, 0x76, 0x77, 0xe8, 0x44,
+	0x2a, 0x4d, 0x2d, 0x1b, 0x3c, 0x25, 0x7d, 0x28, 0x4b, 0xb9, 0xce, 0x16, 0x25, 0xf0, 0xca, 0xe0,
+	0xc6, 0x84, 0x47, 0x91, 0x17, 0x1f, 0xd3, 0x07, 0x56, 0xfe, 0xb0, 0x53, 0x4f, 0xaf, 0xc9, 0xf0,
+	0x7f, 0x4f, 0x11, 0x0c, 0x88, 0x3f, 0xc7, 0xad, 0x6b, 0x99, 0xd6, 0xc7, 0x60, 0x48, 0x3a, 0x2b,
+	0x28, 0x97, 0xd8, 0xf4, 0xe6, 0x6e, 0x5e, 0xb5, 0xae, 0xbc, 0xf3, 0x5f, 0x1e, 0x69, 0x4f, 0x0e,
+	0xb7, 0x62, 0x9e, 0x49, 0xc5, 0x0b, 0x5e, 0xe9, 0xd0, 0x8b, 0xfc, 0xb8, 0xdb, 0x6c, 0xc5, 0xfc,
+	0xbd, 0x53, 0x0f, 0xc1, 0x19, 0x42, 0x8e, 0x4a, 0x87, 0xad, 0x5b, 0xe0, 0x5b, 0xa7, 0xd6, 0x20,
+	0x6e, 0x16, 0x52, 0x1f, 0x80, 0xbe, 0x03, 0x1b, 0x79, 0x07, 0x9e, 0x91, 0x9e, 0x80, 0x4d, 0x06,
+	0x05, 0x66, 0x1a, 0x59, 0xd8, 0x8e, 0xbc, 0xf8, 0x3e, 0xed, 0x0a, 0xd8, 0xbc, 0x2e, 0xf0, 0x23,
+	0x32, 0xfb, 0x49, 0x6d, 0x21, 0x4c, 0x61, 0x8e, 0x95, 0xe1, 0x50, 0xba, 0x46, 0x8f, 0xe9, 0xc0,
+	0x1a, 0x93, 0x7f, 0xfa, 0xf4, 0xc8, 0xfe, 0x7c, 0x2f, 0xff, 0x0e, 0x00, 0x50, 0xf3, 0x8a, 0x2d,
+	0xf7, 0x02, 0x00, 0x00,
 }
@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Route
+	Cors
 */
 package kedge_config_grpc_routes
 
@@ -32,5 +33,13 @@ func (this *Route) Validate() error {
 		return github_com_mwitkow_go_proto_validators.FieldError("BackendName", fmt.Errorf(`value '%v' must be a string conforming to regex "^[a-z_.]{2,64}$"`, this.BackendName))
 	}
 	// Validation of proto3 map<> fields is unsupported.
+	if this.Cors != nil {
+		if err := github_com_mwitkow_go_proto_validators.CallValidatorIfExists(this.Cors); err != nil {
+			return github_com_mwitkow_go_proto_validators.FieldError("Cors", err)
+		}
+	}
+	return nil
+}
+func (this *Cors) Validate() error {
 	return nil
 }
@@ -12,6 +12,10 @@ It is generated from these files:
 It has these top-level messages:
 	Adhoc
 	Route
+	Hsts
+	Cache
+	Compression
+	RequestLimits
 */
 package kedge_config_http_routes
 
@@ -23,6 +23,7 @@ const (
 	// / Forward Proxy is when the FE serves as an HTTP_PROXY for a browser or an application. The resolution of the
 	// / backend is done by the FE itself, so non-public names can be addressed.
 	// / This may be from the 90s, but it still is very useful.
+	// / CONNECT requests (tunnels) are matched only by FORWARD_PROXY (and ANY) routes, using the host:port they ask for.
 	// /
 	// / IMPORTANT: If you have a PAC file configured in Firefox, the HTTPS rule behaves differently than in Chrome. The
 	// / proxied requests are not FORWARD_PROXY requests but REVERSE_PROXY_REQUESTS.
@@ -66,6 +67,24 @@ type Route struct {
 	ProxyMode ProxyMode `protobuf:"varint,5,opt,name=proxy_mode,json=proxyMode,enum=kedge.config.http.routes.ProxyMode" json:"proxy_mode,omitempty"`
 	// / Optional port matcher. If 0 route will ignore port.
 	PortMatcher uint32 `protobuf:"varint,6,opt,name=port_matcher,json=portMatcher" json:"port_matcher,omitempty"`
+	// / allow_plaintext allows the route to be served on the plain-text (non-TLS) proxy listener, e.g. for pods that
+	// / terminate TLS at a sidecar. If false, the route matches only requests received over TLS.
+	AllowPlaintext bool `protobuf:"varint,7,opt,name=allow_plaintext,json=allowPlaintext" json:"allow_plaintext,omitempty"`
+	// / redirect_to_https makes requests matching the route on the plain-text proxy listener to be redirected to HTTPS
+	// / instead of being proxied. It is not applicable for CONNECT requests. Takes precedence over allow_plaintext.
+	RedirectToHttps bool `protobuf:"varint,8,opt,name=redirect_to_https,json=redirectToHttps" json:"redirect_to_https,omitempty"`
+	// / hsts enables Strict-Transport-Security header on responses from this route.
+	// / It is set only for REVERSE_PROXY requests received over TLS, as only then kedge is the origin for the browser.
+	// / It is usually set on routes with host_matcher, since HSTS applies to the whole host.
+	Hsts *Hsts `protobuf:"bytes,9,opt,name=hsts" json:"hsts,omitempty"`
+	// / cache enables (opt-in) caching of backend responses for this route in kedge memory. Only GET requests are
+	// / served from cache, honouring Cache-Control, Expires and Vary of backend responses. Stale responses with ETag or
+	// / Last-Modified are revalidated with conditional requests.
+	Cache *Cache `protobuf:"bytes,10,opt,name=cache" json:"cache,omitempty"`
+	// / compression enables on-the-fly compression (brotli or gzip, as accepted by the client) of backend responses.
+	Compression *Compression `protobuf:"bytes,11,opt,name=compression" json:"compression,omitempty"`
+	// / request_limits protects backends from too big requests and slow (e.g. slowloris-style) clients.
+	RequestLimits *RequestLimits `protobuf:"bytes,12,opt,name=request_limits,json=requestLimits" json:"request_limits,omitempty"`
 }
 
 func (m *Route) Reset()                    { *m = Route{} }
@@ -115,38 +134,264 @@ func (m *Route) GetPortMatcher() uint32 {
 	return 0
 }
 
+func (m *Route) GetAllowPlaintext() bool {
+	if m != nil {
+		return m.AllowPlaintext
+	}
+	return false
+}
+
+func (m *Route) GetRedirectToHttps() bool {
+	if m != nil {
+		return m.RedirectToHttps
+	}
+	return false
+}
+
+func (m *Route) GetHsts() *Hsts {
+	if m != nil {
+		return m.Hsts
+	}
+	return nil
+}
+
+func (m *Route) GetCache() *Cache {
+	if m != nil {
+		return m.Cache
+	}
+	return nil
+}
+
+func (m *Route) GetCompression() *Compression {
+	if m != nil {
+		return m.Compression
+	}
+	return nil
+}
+
+func (m *Route) GetRequestLimits() *RequestLimits {
+	if m != nil {
+		return m.RequestLimits
+	}
+	return nil
+}
+
+// / Hsts is HTTP Strict Transport Security policy (RFC 6797).
+type Hsts struct {
+	// / max_age_sec is the time browsers remember to access the host only over HTTPS.
+	MaxAgeSec uint32 `protobuf:"varint,1,opt,name=max_age_sec,json=maxAgeSec" json:"max_age_sec,omitempty"`
+	// / include_subdomains applies the policy to all subdomains of the host.
+	IncludeSubdomains bool `protobuf:"varint,2,opt,name=include_subdomains,json=includeSubdomains" json:"include_subdomains,omitempty"`
+	// / preload marks the host as eligible for browsers' HSTS preload lists.
+	Preload bool `protobuf:"varint,3,opt,name=preload" json:"preload,omitempty"`
+}
+
+func (m *Hsts) Reset()                    { *m = Hsts{} }
+func (m *Hsts) String() string            { return proto.CompactTextString(m) }
+func (*Hsts) ProtoMessage()               {}
+func (*Hsts) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
+
+func (m *Hsts) GetMaxAgeSec() uint32 {
+	if m != nil {
+		return m.MaxAgeSec
+	}
+	return 0
+}
+
+func (m *Hsts) GetIncludeSubdomains() bool {
+	if m != nil {
+		return m.IncludeSubdomains
+	}
+	return false
+}
+
+func (m *Hsts) GetPreload() bool {
+	if m != nil {
+		return m.Preload
+	}
+	return false
+}
+
+// / Cache is HTTP response caching policy (RFC 7234) of kedge acting as a shared cache.
+type Cache struct {
+	// / max_ttl_sec caps the freshness lifetime given by the backend. If 0, backend lifetime is used as is.
+	MaxTtlSec uint32 `protobuf:"varint,1,opt,name=max_ttl_sec,json=maxTtlSec" json:"max_ttl_sec,omitempty"`
+	// / default_ttl_sec is freshness lifetime of cacheable responses without explicit expiration (max-age or Expires).
+	// / If 0, such responses are cached only if they can be revalidated (have ETag or Last-Modified).
+	DefaultTtlSec uint32 `protobuf:"varint,2,opt,name=default_ttl_sec,json=defaultTtlSec" json:"default_ttl_sec,omitempty"`
+}
+
+func (m *Cache) Reset()                    { *m = Cache{} }
+func (m *Cache) String() string            { return proto.CompactTextString(m) }
+func (*Cache) ProtoMessage()               {}
+func (*Cache) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
+
+func (m *Cache) GetMaxTtlSec() uint32 {
+	if m != nil {
+		return m.MaxTtlSec
+	}
+	return 0
+}
+
+func (m *Cache) GetDefaultTtlSec() uint32 {
+	if m != nil {
+		return m.DefaultTtlSec
+	}
+	return 0
+}
+
+// / Compression is on-the-fly compression policy of backend responses, based on Accept-Encoding of the request.
+type Compression struct {
+	// / content_types lists media types (without parameters) to compress, e.g. "application/json". Types ending with
+	// / "/*" match whole families, e.g. "text/*". If empty, common text types (text/*, JSON, JavaScript, XML, SVG) are
+	// / compressed.
+	ContentTypes []string `protobuf:"bytes,1,rep,name=content_types,json=contentTypes" json:"content_types,omitempty"`
+	// / min_size_bytes is the minimum Content-Length of responses to be compressed. Streamed responses of unknown
+	// / length are always compressed.
+	MinSizeBytes uint32 `protobuf:"varint,2,opt,name=min_size_bytes,json=minSizeBytes" json:"min_size_bytes,omitempty"`
+	// / upstream_compression makes kedge ask backends for compressed responses (saving bandwidth between kedge and
+	// / the backend). They are decompressed for clients that don't accept the encoding.
+	UpstreamCompression bool `protobuf:"varint,3,opt,name=upstream_compression,json=upstreamCompression" json:"upstream_compression,omitempty"`
+}
+
+func (m *Compression) Reset()                    { *m = Compression{} }
+func (m *Compression) String() string            { return proto.CompactTextString(m) }
+func (*Compression) ProtoMessage()               {}
+func (*Compression) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+
+func (m *Compression) GetContentTypes() []string {
+	if m != nil {
+		return m.ContentTypes
+	}
+	return nil
+}
+
+func (m *Compression) GetMinSizeBytes() uint32 {
+	if m != nil {
+		return m.MinSizeBytes
+	}
+	return 0
+}
+
+func (m *Compression) GetUpstreamCompression() bool {
+	if m != nil {
+		return m.UpstreamCompression
+	}
+	return false
+}
+
+// / RequestLimits are limits of requests matching a route. They apply on top of the server-wide timeouts.
+type RequestLimits struct {
+	// / max_body_bytes is the maximum size of request body. Bigger requests are rejected with 413 Payload Too Large.
+	// / If 0, body size is not limited.
+	MaxBodyBytes uint64 `protobuf:"varint,1,opt,name=max_body_bytes,json=maxBodyBytes" json:"max_body_bytes,omitempty"`
+	// / max_header_bytes is the maximum total size of request header names and values. Requests with bigger headers are
+	// / rejected with 431 Request Header Fields Too Large. If 0, only the server-wide limit applies.
+	MaxHeaderBytes uint32 `protobuf:"varint,2,opt,name=max_header_bytes,json=maxHeaderBytes" json:"max_header_bytes,omitempty"`
+	// / min_upload_rate_bytes_per_sec is the minimum average rate of receiving request body, enforced after
+	// / upload_grace_period_sec. Slower uploads are aborted with 408 Request Timeout. If 0, rate is not enforced.
+	MinUploadRateBytesPerSec uint32 `protobuf:"varint,3,opt,name=min_upload_rate_bytes_per_sec,json=minUploadRateBytesPerSec" json:"min_upload_rate_bytes_per_sec,omitempty"`
+	// / upload_grace_period_sec is the time from the start of the request in which upload rate is not enforced.
+	// / If 0, 10 seconds is used.
+	UploadGracePeriodSec uint32 `protobuf:"varint,4,opt,name=upload_grace_period_sec,json=uploadGracePeriodSec" json:"upload_grace_period_sec,omitempty"`
+}
+
+func (m *RequestLimits) Reset()                    { *m = RequestLimits{} }
+func (m *RequestLimits) String() string            { return proto.CompactTextString(m) }
+func (*RequestLimits) ProtoMessage()               {}
+func (*RequestLimits) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+
+func (m *RequestLimits) GetMaxBodyBytes() uint64 {
+	if m != nil {
+		return m.MaxBodyBytes
+	}
+	return 0
+}
+
+func (m *RequestLimits) GetMaxHeaderBytes() uint32 {
+	if m != nil {
+		return m.MaxHeaderBytes
+	}
+	return 0
+}
+
+func (m *RequestLimits) GetMinUploadRateBytesPerSec() uint32 {
+	if m != nil {
+		return m.MinUploadRateBytesPerSec
+	}
+	return 0
+}
+
+func (m *RequestLimits) GetUploadGracePeriodSec() uint32 {
+	if m != nil {
+		return m.UploadGracePeriodSec
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Route)(nil), "kedge.config.http.routes.Route")
+	proto.RegisterType((*Hsts)(nil), "kedge.config.http.routes.Hsts")
+	proto.RegisterType((*Cache)(nil), "kedge.config.http.routes.Cache")
+	proto.RegisterType((*Compression)(nil), "kedge.config.http.routes.Compression")
+	proto.RegisterType((*RequestLimits)(nil), "kedge.config.http.routes.RequestLimits")
 	proto.RegisterEnum("kedge.config.http.routes.ProxyMode", ProxyMode_name, ProxyMode_value)
 }
 
 func init() { proto.RegisterFile("kedge/config/http/routes/routes.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 397 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x91, 0x5f, 0x8b, 0xd3, 0x40,
-	0x14, 0xc5, 0x4d, 0x62, 0x57, 0x72, 0xb3, 0x5d, 0xbb, 0x83, 0x42, 0x58, 0x10, 0xe3, 0x3f, 0x08,
-	0x62, 0x27, 0x10, 0x65, 0x59, 0xf6, 0xc9, 0x2d, 0x46, 0x7c, 0xe9, 0x1f, 0x46, 0x50, 0x8b, 0x68,
-	0x98, 0x26, 0x63, 0x12, 0xd2, 0x64, 0xc2, 0x64, 0xd2, 0x5a, 0xc5, 0x67, 0x3f, 0xa6, 0xe0, 0x27,
-	0x91, 0x49, 0x62, 0x15, 0x96, 0x3e, 0xe5, 0xe6, 0x37, 0xe7, 0x9e, 0x7b, 0xcf, 0x0c, 0x3c, 0xc9,
-	0x59, 0x9c, 0x30, 0x2f, 0xe2, 0xe5, 0x97, 0x2c, 0xf1, 0x52, 0x29, 0x2b, 0x4f, 0xf0, 0x46, 0xb2,
-	0xba, 0xff, 0xe0, 0x4a, 0x70, 0xc9, 0x91, 0xdd, 0xca, 0x70, 0x27, 0xc3, 0x4a, 0x86, 0xbb, 0xf3,
-	0xb3, 0xf3, 0x24, 0x93, 0x69, 0xb3, 0xc2, 0x11, 0x2f, 0xbc, 0x62, 0x9b, 0xc9, 0x9c, 0x6f, 0xbd,
-	0x84, 0x8f, 0xdb, 0xb6, 0xf1, 0x86, 0xae, 0xb3, 0x98, 0x4a, 0x2e, 0x6a, 0x6f, 0x5f, 0x76, 0x8e,
-	0x0f, 0x7f, 0x1a, 0x30, 0x20, 0xca, 0x02, 0x5d, 0xc0, 0xf1, 0x8a, 0x46, 0x39, 0x2b, 0xe3, 0xb0,
-	0xa4, 0x05, 0xb3, 0x35, 0x47, 0x73, 0xcd, 0xc9, 0xdd, 0xdf, 0xbf, 0xee, 0x9f, 0xc2, 0xed, 0xcf,
-	0x1f, 0xe9, 0xf8, 0x5b, 0x88, 0x3f, 0x7d, 0xf7, 0x9f, 0x9d, 0xbf, 0xf8, 0xf1, 0x98, 0x58, 0xbd,
-	0x74, 0x46, 0x0b, 0x86, 0xee, 0x01, 0x54, 0x54, 0xa6, 0xa1, 0x68, 0xd6, 0xac, 0xb6, 0x75, 0xc7,
-	0x70, 0x4d, 0x62, 0x2a, 0x42, 0x14, 0x40, 0x0f, 0xe0, 0x38, 0xe5, 0xb5, 0x0c, 0x0b, 0x2a, 0xa3,
-	0x94, 0x09, 0xdb, 0x50, 0xc6, 0xc4, 0x52, 0x6c, 0xda, 0x21, 0xb4, 0x84, 0x93, 0x94, 0xd1, 0x98,
-	0x89, 0xbd, 0xe8, 0xa6, 0x63, 0xb8, 0x96, 0xef, 0xe3, 0x43, 0x81, 0x71, 0xbb, 0x34, 0x7e, 0xd3,
-	0x76, 0xf5, 0x36, 0x41, 0x29, 0xc5, 0x8e, 0x0c, 0xd3, 0xff, 0x19, 0x9a, 0x00, 0x54, 0x82, 0x7f,
-	0xdd, 0x85, 0x05, 0x8f, 0x99, 0x3d, 0x70, 0x34, 0xf7, 0xc4, 0x7f, 0x74, 0xd8, 0x76, 0xa1, 0xb4,
-	0x53, 0x1e, 0x33, 0x62, 0x56, 0x7f, 0x4b, 0x95, 0xa0, 0xe2, 0xe2, 0x5f, 0x82, 0x23, 0x47, 0x73,
-	0x87, 0xc4, 0x52, 0xac, 0x1f, 0x73, 0xf6, 0x12, 0xd0, 0xf5, 0x5d, 0xd0, 0x08, 0x8c, 0x9c, 0xed,
-	0xba, 0xab, 0x24, 0xaa, 0x44, 0x77, 0x60, 0xb0, 0xa1, 0xeb, 0x86, 0xd9, 0x7a, 0xcb, 0xba, 0x9f,
-	0x4b, 0xfd, 0x42, 0x7b, 0x7a, 0x09, 0xe6, 0x7e, 0x38, 0xba, 0x05, 0xc6, 0xd5, 0x6c, 0x39, 0xba,
-	0x81, 0x4e, 0x61, 0x48, 0x82, 0x77, 0x01, 0x79, 0x1b, 0x84, 0x0b, 0x32, 0xff, 0xb0, 0x1c, 0x69,
-	0x0a, 0xbd, 0x9e, 0x93, 0xf7, 0x57, 0xe4, 0x55, 0x8f, 0xf4, 0xd5, 0x51, 0xfb, 0x98, 0xcf, 0xff,
-	0x04, 0x00, 0x00, 0xff, 0xff, 0x3c, 0x44, 0x10, 0xae, 0x47, 0x02, 0x00, 0x00,
+	// 824 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x94, 0x5b, 0x6f, 0xdb, 0x36,
+	0x14, 0xc7, 0x2b, 0x5f, 0x92, 0xf8, 0xf8, 0x12, 0x87, 0xcb, 0x30, 0xa1, 0x40, 0x1b, 0xcf, 0xbd,
+	0x19, 0xc5, 0x22, 0x63, 0xde, 0x5a, 0x14, 0x7d, 0xd9, 0xe2, 0x2d, 0x6b, 0x1e, 0xd6, 0xc4, 0xa0,
+	0xb3, 0x4b, 0x30, 0x6c, 0x04, 0x2d, 0x9d, 0x5a, 0x42, 0x24, 0x51, 0x23, 0xa9, 0xc6, 0xce, 0xb0,
+	0x4f, 0xb0, 0x0f, 0xb8, 0xc7, 0x0e, 0xfd, 0x24, 0x03, 0x29, 0xd9, 0xf1, 0x30, 0xa4, 0x4f, 0x26,
+	0xff, 0xe7, 0xf7, 0x3f, 0x87, 0xe7, 0x90, 0x16, 0x3c, 0xba, 0xc4, 0x60, 0x8e, 0x43, 0x5f, 0xa4,
+	0x6f, 0xa2, 0xf9, 0x30, 0xd4, 0x3a, 0x1b, 0x4a, 0x91, 0x6b, 0x54, 0xe5, 0x8f, 0x97, 0x49, 0xa1,
+	0x05, 0x71, 0x2d, 0xe6, 0x15, 0x98, 0x67, 0x30, 0xaf, 0x88, 0xdf, 0x7d, 0x3e, 0x8f, 0x74, 0x98,
+	0xcf, 0x3c, 0x5f, 0x24, 0xc3, 0xe4, 0x2a, 0xd2, 0x97, 0xe2, 0x6a, 0x38, 0x17, 0x87, 0xd6, 0x76,
+	0xf8, 0x96, 0xc7, 0x51, 0xc0, 0xb5, 0x90, 0x6a, 0xb8, 0x5e, 0x16, 0x19, 0xfb, 0xff, 0xd4, 0xa1,
+	0x4e, 0x4d, 0x0a, 0xf2, 0x02, 0x5a, 0x33, 0xee, 0x5f, 0x62, 0x1a, 0xb0, 0x94, 0x27, 0xe8, 0x3a,
+	0x3d, 0x67, 0xd0, 0x18, 0x7f, 0xfc, 0xfe, 0xdd, 0xc1, 0x1e, 0xec, 0xfe, 0xf6, 0x0b, 0x3f, 0xbc,
+	0x66, 0xde, 0xaf, 0x7f, 0x8c, 0x3e, 0x7b, 0xfe, 0xe5, 0x9f, 0x0f, 0x69, 0xb3, 0x44, 0x4f, 0x79,
+	0x82, 0xe4, 0x1e, 0x40, 0xc6, 0x75, 0xc8, 0x64, 0x1e, 0xa3, 0x72, 0x2b, 0xbd, 0xea, 0xa0, 0x41,
+	0x1b, 0x46, 0xa1, 0x46, 0x20, 0x9f, 0x42, 0x2b, 0x14, 0x4a, 0xb3, 0x84, 0x6b, 0x3f, 0x44, 0xe9,
+	0x56, 0x4d, 0x62, 0xda, 0x34, 0xda, 0xeb, 0x42, 0x22, 0x17, 0xd0, 0x09, 0x91, 0x07, 0x28, 0xd7,
+	0x50, 0xad, 0x57, 0x1d, 0x34, 0x47, 0x23, 0xef, 0xb6, 0x86, 0x3d, 0x7b, 0x68, 0xef, 0xc4, 0xba,
+	0xca, 0x34, 0xc7, 0xa9, 0x96, 0x4b, 0xda, 0x0e, 0x37, 0x35, 0x32, 0x06, 0xc8, 0xa4, 0x58, 0x2c,
+	0x59, 0x22, 0x02, 0x74, 0xeb, 0x3d, 0x67, 0xd0, 0x19, 0x3d, 0xb8, 0x3d, 0xed, 0xc4, 0xb0, 0xaf,
+	0x45, 0x80, 0xb4, 0x91, 0xad, 0x96, 0xa6, 0x83, 0x4c, 0xc8, 0x9b, 0x0e, 0xb6, 0x7a, 0xce, 0xa0,
+	0x4d, 0x9b, 0x46, 0x5b, 0x95, 0x79, 0x02, 0xbb, 0x3c, 0x8e, 0xc5, 0x15, 0xcb, 0x62, 0x1e, 0xa5,
+	0x1a, 0x17, 0xda, 0xdd, 0xee, 0x39, 0x83, 0x1d, 0xda, 0xb1, 0xf2, 0x64, 0xa5, 0x92, 0xa7, 0xb0,
+	0x27, 0x31, 0x88, 0x24, 0xfa, 0x9a, 0x69, 0xc1, 0x4c, 0x6d, 0xe5, 0xee, 0x58, 0x74, 0x77, 0x15,
+	0x38, 0x17, 0x27, 0x46, 0x26, 0x23, 0xa8, 0x85, 0x4a, 0x2b, 0xb7, 0xd1, 0x73, 0x06, 0xcd, 0xd1,
+	0xfd, 0xdb, 0x4f, 0x7d, 0xa2, 0xb4, 0xa2, 0x96, 0x25, 0xcf, 0xa0, 0xee, 0x73, 0x3f, 0x44, 0x17,
+	0xac, 0xe9, 0xe0, 0x76, 0xd3, 0x37, 0x06, 0xa3, 0x05, 0x4d, 0x5e, 0x41, 0xd3, 0x17, 0x49, 0x26,
+	0x51, 0xa9, 0x48, 0xa4, 0x6e, 0xd3, 0x9a, 0x1f, 0x7d, 0xc0, 0x7c, 0x03, 0xd3, 0x4d, 0x27, 0x39,
+	0x85, 0x8e, 0xc4, 0xdf, 0x73, 0x54, 0x9a, 0xc5, 0x51, 0x12, 0x69, 0xe5, 0xb6, 0x6c, 0xae, 0x27,
+	0x1f, 0xb8, 0xca, 0x82, 0xff, 0xde, 0xe2, 0xb4, 0x2d, 0x37, 0xb7, 0x77, 0xbf, 0x06, 0xf2, 0xff,
+	0x4b, 0x26, 0x5d, 0xa8, 0x5e, 0xe2, 0xb2, 0x78, 0xa3, 0xd4, 0x2c, 0xc9, 0x3e, 0xd4, 0xdf, 0xf2,
+	0x38, 0x47, 0xb7, 0x62, 0xb5, 0x62, 0xf3, 0xb2, 0xf2, 0xc2, 0xe9, 0x5f, 0x41, 0xcd, 0xcc, 0x87,
+	0x3c, 0x86, 0x66, 0xc2, 0x17, 0x8c, 0xcf, 0x91, 0x29, 0xf4, 0xad, 0xb7, 0x3d, 0xde, 0x7a, 0xff,
+	0xee, 0xa0, 0xd2, 0xbd, 0x43, 0x1b, 0x09, 0x5f, 0x1c, 0xcd, 0x71, 0x8a, 0x3e, 0x39, 0x04, 0x12,
+	0xa5, 0x7e, 0x9c, 0x07, 0xc8, 0x54, 0x3e, 0x0b, 0x44, 0xc2, 0xa3, 0x54, 0xd9, 0xb4, 0x3b, 0x74,
+	0xaf, 0x8c, 0x4c, 0xd7, 0x01, 0xe2, 0xc2, 0x76, 0x26, 0x31, 0x16, 0x3c, 0xb0, 0x2f, 0x7b, 0x87,
+	0xae, 0xb6, 0xfd, 0x33, 0xa8, 0xdb, 0x19, 0x93, 0xfb, 0x45, 0x65, 0xad, 0xe3, 0x9b, 0xca, 0xb6,
+	0xe2, 0xb9, 0x8e, 0x4d, 0xc5, 0xc7, 0xb0, 0x1b, 0xe0, 0x1b, 0x9e, 0xc7, 0x7a, 0xcd, 0x54, 0x2c,
+	0xd3, 0x2e, 0xe5, 0x82, 0xeb, 0xff, 0xe5, 0x40, 0x73, 0x63, 0xf0, 0xe4, 0x01, 0xb4, 0x7d, 0x91,
+	0x6a, 0x4c, 0x35, 0xd3, 0xcb, 0x0c, 0x95, 0xeb, 0xd8, 0xff, 0x5e, 0xab, 0x14, 0xcf, 0x8d, 0x46,
+	0x1e, 0x42, 0x27, 0x89, 0x52, 0xa6, 0xa2, 0x6b, 0x64, 0xb3, 0xa5, 0x46, 0x55, 0xe6, 0x6e, 0x25,
+	0x51, 0x3a, 0x8d, 0xae, 0x71, 0x6c, 0x34, 0xf2, 0x39, 0xec, 0xe7, 0x99, 0xd2, 0x12, 0x79, 0xc2,
+	0x36, 0x1f, 0x42, 0xd1, 0xd2, 0x47, 0xab, 0xd8, 0x46, 0xf5, 0xfe, 0xdf, 0x0e, 0xb4, 0xff, 0x73,
+	0x75, 0xb6, 0x14, 0x5f, 0xb0, 0x99, 0x08, 0x96, 0x65, 0x29, 0xd3, 0x6a, 0x8d, 0xb6, 0x12, 0xbe,
+	0x18, 0x8b, 0x60, 0x59, 0x94, 0x1a, 0x40, 0xd7, 0x50, 0xe5, 0x1f, 0x7e, 0xf3, 0x48, 0xc6, 0x5d,
+	0x5c, 0x76, 0x41, 0x7e, 0x05, 0xf7, 0xcc, 0xd1, 0xf3, 0xcc, 0x8c, 0x93, 0x49, 0xae, 0xcb, 0x0e,
+	0x58, 0x86, 0xd2, 0x4e, 0xa9, 0x6a, 0x6d, 0x6e, 0x12, 0xa5, 0x3f, 0x58, 0x86, 0x72, 0x5d, 0xf4,
+	0x33, 0x41, 0x69, 0x06, 0xfb, 0x0c, 0x3e, 0x29, 0xcd, 0x73, 0xc9, 0x7d, 0x34, 0xbe, 0x48, 0x04,
+	0xd6, 0x5a, 0xb3, 0xd6, 0xfd, 0x22, 0xfc, 0xca, 0x44, 0x27, 0x36, 0x38, 0x45, 0xff, 0xe9, 0x4b,
+	0x68, 0xac, 0xbf, 0x03, 0x64, 0x1b, 0xaa, 0x47, 0xa7, 0x17, 0xdd, 0x3b, 0x64, 0x0f, 0xda, 0xf4,
+	0xf8, 0xc7, 0x63, 0x3a, 0x3d, 0x66, 0x13, 0x7a, 0xf6, 0xf3, 0x45, 0xd7, 0x31, 0xd2, 0x77, 0x67,
+	0xf4, 0xa7, 0x23, 0xfa, 0x6d, 0x29, 0x55, 0x66, 0x5b, 0xf6, 0xbb, 0xfa, 0xc5, 0xbf, 0x03, 0x00,
+	0x38, 0xef, 0x18, 0xdd, 0xd2, 0x05, 0x00, 0x00,
 }
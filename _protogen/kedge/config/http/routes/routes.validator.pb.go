@@ -23,5 +23,40 @@ func (this *Route) Validate() error {
 		return github_com_mwitkow_go_proto_validators.FieldError("BackendName", fmt.Errorf(`value '%v' must be a string conforming to regex "^[a-z_.]{2,64}$"`, this.BackendName))
 	}
 	// Validation of proto3 map<> fields is unsupported.
+	if this.Hsts != nil {
+		if err := github_com_mwitkow_go_proto_validators.CallValidatorIfExists(this.Hsts); err != nil {
+			return github_com_mwitkow_go_proto_validators.FieldError("Hsts", err)
+		}
+	}
+	if this.Cache != nil {
+		if err := github_com_mwitkow_go_proto_validators.CallValidatorIfExists(this.Cache); err != nil {
+			return github_com_mwitkow_go_proto_validators.FieldError("Cache", err)
+		}
+	}
+	if this.Compression != nil {
+		if err := github_com_mwitkow_go_proto_validators.CallValidatorIfExists(this.Compression); err != nil {
+			return github_com_mwitkow_go_proto_validators.FieldError("Compression", err)
+		}
+	}
+	if this.RequestLimits != nil {
+		if err := github_com_mwitkow_go_proto_validators.CallValidatorIfExists(this.RequestLimits); err != nil {
+			return github_com_mwitkow_go_proto_validators.FieldError("RequestLimits", err)
+		}
+	}
+	return nil
+}
+func (this *Hsts) Validate() error {
+	if !(this.MaxAgeSec > 0) {
+		return github_com_mwitkow_go_proto_validators.FieldError("MaxAgeSec", fmt.Errorf(`value '%v' must be greater than '0'`, this.MaxAgeSec))
+	}
+	return nil
+}
+func (this *Cache) Validate() error {
+	return nil
+}
+func (this *Compression) Validate() error {
+	return nil
+}
+func (this *RequestLimits) Validate() error {
 	return nil
 }
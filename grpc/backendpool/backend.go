@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/mwitkow/go-conntrack"
 	"github.com/mwitkow/grpc-proxy/proxy"
@@ -123,8 +124,9 @@ func chooseSecurityOpt(cnf *pb.Backend) grpc.DialOption {
 }
 
 func chooseInterceptors(cnf *pb.Backend) []grpc.DialOption {
-	unary := []grpc.UnaryClientInterceptor{}
-	stream := []grpc.StreamClientInterceptor{}
+	// Client spans are always created, they are no-op if tracing is disabled.
+	unary := []grpc.UnaryClientInterceptor{grpc_opentracing.UnaryClientInterceptor()}
+	stream := []grpc.StreamClientInterceptor{grpc_opentracing.StreamClientInterceptor()}
 	for _, i := range cnf.GetInterceptors() {
 		if prom := i.GetPrometheus(); prom {
 			unary = append(unary, grpc_prometheus.UnaryClientInterceptor)
@@ -5,6 +5,8 @@ import (
 	"github.com/mwitkow/grpc-proxy/proxy"
 	"github.com/mwitkow/kedge/grpc/backendpool"
 	"github.com/mwitkow/kedge/grpc/director/router"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
@@ -12,10 +14,15 @@ import (
 // New builds a StreamDirector based off a backend pool and a router.
 func New(pool backendpool.Pool, router router.Router) proxy.StreamDirector {
 	return func(ctx context.Context, fullMethodName string) (*grpc.ClientConn, error) {
+		span, _ := opentracing.StartSpanFromContext(ctx, "route")
 		beName, err := router.Route(ctx, fullMethodName)
 		if err != nil {
+			ext.Error.Set(span, true)
+			span.Finish()
 			return nil, err
 		}
+		span.SetTag("backend", beName)
+		span.Finish()
 		grpc_ctxtags.Extract(ctx).Set("grpc.proxy.backend", beName)
 		cc, err := pool.Conn(beName)
 		if err != nil {
@@ -12,6 +12,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 var (
@@ -23,6 +24,11 @@ var (
 type Router interface {
 	// Route returns a backend name for a given call, or an error.
 	Route(ctx context.Context, fullMethodName string) (backendName string, err error)
+
+	// CorsPolicy returns CORS policy of the first route matching the authority and service name, or nil if there is
+	// none. Metadata matchers are skipped, since CORS preflight requests have no gRPC metadata. Routes that do not
+	// allow plaintext are skipped for requests received without TLS, as in Route.
+	CorsPolicy(authority string, fullMethodName string, plaintext bool) *pb.Cors
 }
 
 type dynamic struct {
@@ -42,6 +48,13 @@ func (d *dynamic) Route(ctx context.Context, fullMethodName string) (backendName
 	return staticRouter.Route(ctx, fullMethodName)
 }
 
+func (d *dynamic) CorsPolicy(authority string, fullMethodName string, plaintext bool) *pb.Cors {
+	d.mu.RLock()
+	staticRouter := d.staticRouter
+	d.mu.RUnlock()
+	return staticRouter.CorsPolicy(authority, fullMethodName, plaintext)
+}
+
 // Update sets the routing table to the provided set of routes.
 func (d *dynamic) Update(routes []*pb.Route) {
 	staticRouter := NewStatic(routes)
@@ -60,10 +73,14 @@ func NewStatic(routes []*pb.Route) *static {
 
 func (r *static) Route(ctx context.Context, fullMethodName string) (backendName string, err error) {
 	md := metautils.ExtractIncoming(ctx)
+	plaintext := isPlaintext(ctx)
 	if strings.HasPrefix(fullMethodName, "/") {
 		fullMethodName = fullMethodName[1:]
 	}
 	for _, route := range r.routes {
+		if plaintext && !route.AllowPlaintext {
+			continue
+		}
 		if !r.serviceNameMatches(fullMethodName, route.ServiceNameMatcher) {
 			continue
 		}
@@ -78,6 +95,35 @@ func (r *static) Route(ctx context.Context, fullMethodName string) (backendName
 	return "", routeNotFound
 }
 
+func (r *static) CorsPolicy(authority string, fullMethodName string, plaintext bool) *pb.Cors {
+	md := metautils.NiceMD(metadata.Pairs(":authority", authority))
+	if strings.HasPrefix(fullMethodName, "/") {
+		fullMethodName = fullMethodName[1:]
+	}
+	for _, route := range r.routes {
+		if plaintext && !route.AllowPlaintext {
+			continue
+		}
+		if !r.serviceNameMatches(fullMethodName, route.ServiceNameMatcher) {
+			continue
+		}
+		if !r.authorityMatches(md, route.AuthorityMatcher) {
+			continue
+		}
+		return route.Cors
+	}
+	return nil
+}
+
+// isPlaintext returns true if the call came over a connection without any transport security (e.g. h2c).
+func isPlaintext(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	return p.AuthInfo == nil
+}
+
 func (r *static) serviceNameMatches(fullMethodName string, matcher string) bool {
 	if matcher == "" || matcher == "*" {
 		return true
@@ -6,7 +6,9 @@ import (
 	pb "github.com/mwitkow/kedge/_protogen/kedge/config"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
 	"github.com/stretchr/testify/assert"
@@ -111,3 +113,93 @@ func TestRouteMatches(t *testing.T) {
 
 	}
 }
+
+func TestCorsPolicy(t *testing.T) {
+	configJson := `
+{ "routes": [
+	{
+		"backendName": "backendWithMetadata",
+		"serviceNameMatcher": "com.example.a.*",
+		"metadataMatcher": {
+			"keyOne": "valueOne"
+		},
+		"cors": {
+			"allowedOrigins": ["https://metadata.example.com"]
+		},
+		"allowPlaintext": true
+	},
+	{
+		"backendName": "backendA",
+		"serviceNameMatcher": "com.example.*",
+		"authorityMatcher": "authority_a.service.local",
+		"cors": {
+			"allowedOrigins": ["https://a.example.com"]
+		},
+		"allowPlaintext": true
+	},
+	{
+		"backendName": "backendTLSOnly",
+		"serviceNameMatcher": "com.example.*",
+		"authorityMatcher": "authority_tls.service.local",
+		"cors": {
+			"allowedOrigins": ["https://tls.example.com"]
+		}
+	},
+	{
+		"backendName": "backendNoCors",
+		"serviceNameMatcher": "*",
+		"allowPlaintext": true
+	}
+]}`
+	config := &pb.DirectorConfig_Grpc{}
+	require.NoError(t, jsonpb.UnmarshalString(configJson, config))
+	r := &static{routes: config.Routes}
+
+	cors := r.CorsPolicy("authority_a.service.local", "/com.example.b.MyService/Method", true)
+	require.NotNil(t, cors)
+	assert.Equal(t, []string{"https://a.example.com"}, cors.AllowedOrigins)
+
+	cors = r.CorsPolicy("authority_b.service.local", "/com.example.a.MyService/Method", true)
+	require.NotNil(t, cors, "metadata matchers must be ignored for CORS")
+	assert.Equal(t, []string{"https://metadata.example.com"}, cors.AllowedOrigins)
+
+	assert.Nil(t, r.CorsPolicy("authority_b.service.local", "/com.example.b.MyService/Method", true), "matched route has no CORS")
+
+	cors = r.CorsPolicy("authority_tls.service.local", "/com.example.b.MyService/Method", false)
+	require.NotNil(t, cors)
+	assert.Equal(t, []string{"https://tls.example.com"}, cors.AllowedOrigins)
+	assert.Nil(t, r.CorsPolicy("authority_tls.service.local", "/com.example.b.MyService/Method", true),
+		"TLS-only route must be skipped for plain-text requests")
+}
+
+func TestRouteMatches_Plaintext(t *testing.T) {
+	configJson := `
+{ "routes": [
+	{
+		"backendName": "backendSensitive",
+		"serviceNameMatcher": "com.example.sensitive.*"
+	},
+	{
+		"backendName": "backendInCluster",
+		"serviceNameMatcher": "com.example.*",
+		"allowPlaintext": true
+	}
+]}`
+	config := &pb.DirectorConfig_Grpc{}
+	require.NoError(t, jsonpb.UnmarshalString(configJson, config))
+	r := &static{routes: config.Routes}
+
+	tlsCtx := peer.NewContext(context.TODO(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	plainCtx := peer.NewContext(context.TODO(), &peer.Peer{})
+
+	be, err := r.Route(tlsCtx, "com.example.sensitive.MyService")
+	require.NoError(t, err)
+	assert.Equal(t, "backendSensitive", be)
+
+	be, err = r.Route(plainCtx, "com.example.sensitive.MyService")
+	require.NoError(t, err)
+	assert.Equal(t, "backendInCluster", be, "TLS-only route must be skipped for plain-text calls")
+
+	_, err = r.Route(plainCtx, "org.example.MyService")
+	assert.Equal(t, routeNotFound, err)
+}
@@ -0,0 +1,283 @@
+// Package grpcweb translates gRPC-Web requests (as sent by browsers) into native gRPC requests served by a gRPC
+// http.Handler (e.g. grpc.Server with the kedge director).
+//
+// Both binary ('application/grpc-web') and base64 ('application/grpc-web-text') variants are supported. CORS is
+// handled according to the policy of the gRPC route matching the call.
+package grpcweb
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/routes"
+	"github.com/mwitkow/kedge/grpc/director/router"
+)
+
+const (
+	grpcContentType        = "application/grpc"
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// trailerFrameFlag marks the gRPC-Web frame that carries trailers instead of a message.
+	trailerFrameFlag byte = 0x80
+)
+
+var (
+	// defaultAllowedHeaders are the request headers required by gRPC-Web clients.
+	defaultAllowedHeaders = []string{"content-type", "x-grpc-web", "x-user-agent", "grpc-timeout"}
+	// defaultExposedHeaders are the response headers gRPC-Web clients need to read.
+	defaultExposedHeaders = []string{"grpc-status", "grpc-message"}
+)
+
+// IsGrpcWebRequest returns true if the request is a gRPC-Web call.
+func IsGrpcWebRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasPrefix(req.Header.Get("content-type"), grpcWebContentType)
+}
+
+// IsGrpcWebPreflight returns true if the request is a CORS preflight request for a gRPC-Web call.
+func IsGrpcWebPreflight(req *http.Request) bool {
+	if req.Method != http.MethodOptions || req.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	for _, h := range strings.Split(req.Header.Get("Access-Control-Request-Headers"), ",") {
+		if strings.EqualFold(strings.TrimSpace(h), "x-grpc-web") {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler serves gRPC-Web calls and their CORS preflight requests.
+type Handler struct {
+	grpcHandler http.Handler
+	router      router.Router
+}
+
+// New constructs gRPC-Web handler that passes the translated calls to grpcHandler. The router is used only to find
+// CORS policies, the actual routing is done by grpcHandler.
+func New(grpcHandler http.Handler, router router.Router) *Handler {
+	return &Handler{grpcHandler: grpcHandler, router: router}
+}
+
+func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	// grpc.Server treats calls without TLS as plaintext the same way, so CORS is answered only for routes the call
+	// can actually take.
+	cors := h.router.CorsPolicy(req.Host, req.URL.Path, req.TLS == nil)
+	if IsGrpcWebPreflight(req) {
+		servePreflight(resp, req, cors)
+		return
+	}
+	if !IsGrpcWebRequest(req) {
+		resp.Header().Set("content-type", "text/plain")
+		resp.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(resp, "not a gRPC-Web request")
+		return
+	}
+	if allowOrigin := allowedOrigin(cors, req.Header.Get("Origin")); allowOrigin != "" {
+		setCorsHeaders(resp.Header(), cors, allowOrigin)
+		resp.Header().Set("Access-Control-Expose-Headers", strings.Join(append(defaultExposedHeaders, cors.ExposedHeaders...), ", "))
+	}
+
+	isText := strings.HasPrefix(req.Header.Get("content-type"), grpcWebTextContentType)
+	grpcReq := translateRequest(req, isText)
+	webResp := newResponseWriter(resp, isText)
+	h.grpcHandler.ServeHTTP(webResp, grpcReq)
+	webResp.finish()
+}
+
+// translateRequest makes a native gRPC request out of the gRPC-Web one, so it can be consumed by grpc.Server.
+func translateRequest(req *http.Request, isText bool) *http.Request {
+	grpcReq := req.WithContext(req.Context()) // shallow copy.
+	grpcReq.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		grpcReq.Header[k] = v
+	}
+	// grpc.Server handles only HTTP/2 requests, however it does not depend on anything HTTP/2 specific.
+	grpcReq.ProtoMajor = 2
+	grpcReq.ProtoMinor = 0
+	grpcReq.Proto = "HTTP/2.0"
+
+	contentType := req.Header.Get("content-type")
+	if isText {
+		grpcReq.Header.Set("content-type", grpcContentType+strings.TrimPrefix(contentType, grpcWebTextContentType))
+		grpcReq.Body = &readCloser{Reader: base64.NewDecoder(base64.StdEncoding, req.Body), Closer: req.Body}
+		grpcReq.Header.Del("content-length")
+		grpcReq.ContentLength = -1
+	} else {
+		grpcReq.Header.Set("content-type", grpcContentType+strings.TrimPrefix(contentType, grpcWebContentType))
+	}
+	return grpcReq
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseWriter translates native gRPC response into gRPC-Web one. HTTP trailers are sent as the last frame of the
+// body, since browsers have no access to them.
+type responseWriter struct {
+	resp        http.ResponseWriter
+	header      http.Header
+	isText      bool
+	wroteHeader bool
+
+	body        io.Writer
+	textEncoder io.WriteCloser
+}
+
+func newResponseWriter(resp http.ResponseWriter, isText bool) *responseWriter {
+	w := &responseWriter{resp: resp, header: make(http.Header), isText: isText, body: resp}
+	if isText {
+		w.textEncoder = base64.NewEncoder(base64.StdEncoding, resp)
+		w.body = w.textEncoder
+	}
+	return w
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	respHeader := w.resp.Header()
+	for k, v := range w.header {
+		if k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		respHeader[k] = v
+	}
+	respHeader.Del("content-length")
+	webContentType := grpcWebContentType
+	if w.isText {
+		webContentType = grpcWebTextContentType
+	}
+	respHeader.Set("content-type", webContentType+strings.TrimPrefix(w.header.Get("content-type"), grpcContentType))
+	w.resp.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.isText {
+		// Each flushed chunk is padded base64 on its own, gRPC-Web clients decode them separately.
+		w.textEncoder.Close()
+		w.textEncoder = base64.NewEncoder(base64.StdEncoding, w.resp)
+		w.body = w.textEncoder
+	}
+	if f, ok := w.resp.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.resp.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	// Never notifies, the gRPC handler relies on request context cancellation anyway.
+	return make(chan bool)
+}
+
+// finish writes the trailers frame. It needs to be called after the gRPC handler returns.
+func (w *responseWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	trailers := make(http.Header)
+	for _, names := range w.header["Trailer"] {
+		for _, name := range strings.Split(names, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if v, ok := w.header[name]; ok {
+				trailers[name] = v
+			}
+		}
+	}
+	for k, v := range w.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			trailers[http.CanonicalHeaderKey(strings.TrimPrefix(k, http.TrailerPrefix))] = v
+		}
+	}
+	w.Write(trailerFrame(trailers))
+	w.Flush()
+}
+
+func trailerFrame(trailers http.Header) []byte {
+	payload := []byte{}
+	for k, vals := range trailers {
+		for _, v := range vals {
+			payload = append(payload, fmt.Sprintf("%s: %s\r\n", strings.ToLower(k), v)...)
+		}
+	}
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = trailerFrameFlag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+func servePreflight(resp http.ResponseWriter, req *http.Request, cors *pb.Cors) {
+	allowOrigin := allowedOrigin(cors, req.Header.Get("Origin"))
+	if allowOrigin == "" {
+		resp.Header().Set("content-type", "text/plain")
+		resp.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(resp, "origin not allowed")
+		return
+	}
+	setCorsHeaders(resp.Header(), cors, allowOrigin)
+	resp.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	resp.Header().Set("Access-Control-Allow-Headers", strings.Join(append(defaultAllowedHeaders, cors.AllowedHeaders...), ", "))
+	if cors.MaxAgeSec > 0 {
+		resp.Header().Set("Access-Control-Max-Age", strconv.FormatUint(uint64(cors.MaxAgeSec), 10))
+	}
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// setCorsHeaders sets CORS headers for the allowOrigin returned by allowedOrigin. Credentials are never allowed for
+// the "*" wildcard, otherwise any site could make credentialed calls.
+func setCorsHeaders(header http.Header, cors *pb.Cors, allowOrigin string) {
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if allowOrigin == "*" {
+		return
+	}
+	header.Add("Vary", "Origin")
+	if cors.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowedOrigin returns the value of Access-Control-Allow-Origin for the origin: the origin itself if it is listed,
+// "*" if only the wildcard matches it, or empty string if the origin is not allowed.
+func allowedOrigin(cors *pb.Cors, origin string) string {
+	if cors == nil || origin == "" {
+		return ""
+	}
+	wildcard := false
+	for _, allowed := range cors.AllowedOrigins {
+		if strings.ToLower(allowed) == strings.ToLower(origin) {
+			return origin
+		}
+		if allowed == "*" {
+			wildcard = true
+		}
+	}
+	if wildcard {
+		return "*"
+	}
+	return ""
+}
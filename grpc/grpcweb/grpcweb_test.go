@@ -0,0 +1,215 @@
+package grpcweb
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/routes"
+	"github.com/mwitkow/kedge/grpc/director/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+func testHandler() *Handler {
+	grpcServer := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("kedge.test", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	routes := []*pb.Route{
+		{
+			BackendName:        "health",
+			ServiceNameMatcher: "grpc.health.*",
+			Cors: &pb.Cors{
+				AllowedOrigins:   []string{"https://app.example.com"},
+				AllowedHeaders:   []string{"x-custom"},
+				MaxAgeSec:        600,
+				AllowCredentials: true,
+			},
+			AllowPlaintext: true,
+		},
+		{
+			BackendName:        "public",
+			ServiceNameMatcher: "public.*",
+			Cors: &pb.Cors{
+				AllowedOrigins:   []string{"*", "https://app.example.com"},
+				AllowCredentials: true,
+			},
+			AllowPlaintext: true,
+		},
+		{
+			BackendName:        "sensitive",
+			ServiceNameMatcher: "sensitive.*",
+			Cors: &pb.Cors{
+				AllowedOrigins: []string{"https://app.example.com"},
+			},
+		},
+	}
+	return New(grpcServer, router.NewStatic(routes))
+}
+
+func startTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(testHandler())
+}
+
+func checkRequestFrame(t *testing.T, service string) []byte {
+	msg, err := proto.Marshal(&healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	frame := make([]byte, 5)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// readFrames splits gRPC-Web response body into message and trailer frames.
+func readFrames(t *testing.T, body []byte) (messages [][]byte, trailers string) {
+	for len(body) > 0 {
+		require.True(t, len(body) >= 5, "frame header too short")
+		length := binary.BigEndian.Uint32(body[1:5])
+		require.True(t, len(body) >= 5+int(length), "frame too short")
+		if body[0]&trailerFrameFlag != 0 {
+			trailers += string(body[5 : 5+length])
+		} else {
+			messages = append(messages, body[5:5+length])
+		}
+		body = body[5+length:]
+	}
+	return messages, trailers
+}
+
+func TestGrpcWeb_BinaryCall(t *testing.T) {
+	srv := startTestServer(t)
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+checkMethod, bytes.NewReader(checkRequestFrame(t, "kedge.test")))
+	require.NoError(t, err)
+	req.Header.Set("content-type", "application/grpc-web+proto")
+	req.Header.Set("x-grpc-web", "1")
+	req.Header.Set("Origin", "https://app.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/grpc-web+proto", resp.Header.Get("content-type"))
+	assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Expose-Headers"), "grpc-status")
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	messages, trailers := readFrames(t, body)
+	require.Len(t, messages, 1)
+	checkResp := &healthpb.HealthCheckResponse{}
+	require.NoError(t, proto.Unmarshal(messages[0], checkResp))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkResp.Status)
+	assert.Contains(t, trailers, "grpc-status: 0\r\n")
+}
+
+func TestGrpcWeb_TextCall_WithError(t *testing.T) {
+	srv := startTestServer(t)
+	defer srv.Close()
+
+	reqBody := base64.StdEncoding.EncodeToString(checkRequestFrame(t, "unknown.service"))
+	req, err := http.NewRequest("POST", srv.URL+checkMethod, strings.NewReader(reqBody))
+	require.NoError(t, err)
+	req.Header.Set("content-type", "application/grpc-web-text")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/grpc-web-text", resp.Header.Get("content-type"))
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"), "no origin, no CORS")
+	encoded, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	// Every flush is padded separately, but each 4 characters group can always be decoded on its own.
+	require.True(t, len(encoded)%4 == 0, "base64 response must be padded")
+	body := []byte{}
+	for i := 0; i < len(encoded); i += 4 {
+		chunk, err := base64.StdEncoding.DecodeString(string(encoded[i : i+4]))
+		require.NoError(t, err)
+		body = append(body, chunk...)
+	}
+	messages, trailers := readFrames(t, body)
+	assert.Len(t, messages, 0)
+	assert.Contains(t, trailers, "grpc-status: 5\r\n", "health service returns NotFound for unknown services")
+}
+
+func TestGrpcWeb_Preflight(t *testing.T) {
+	srv := startTestServer(t)
+	defer srv.Close()
+
+	for _, tcase := range []struct {
+		name                string
+		origin              string
+		path                string
+		expectedStatus      int
+		expectedAllowOrigin string
+		expectedCredentials string
+	}{
+		{name: "AllowedOrigin", origin: "https://app.example.com", path: checkMethod, expectedStatus: http.StatusNoContent,
+			expectedAllowOrigin: "https://app.example.com", expectedCredentials: "true"},
+		{name: "NotAllowedOrigin", origin: "https://evil.example.com", path: checkMethod, expectedStatus: http.StatusForbidden},
+		{name: "RouteWithoutCors", origin: "https://app.example.com", path: "/other.Service/Method", expectedStatus: http.StatusForbidden},
+		{name: "WildcardOrigin_NoCredentials", origin: "https://evil.example.com", path: "/public.Service/Method", expectedStatus: http.StatusNoContent,
+			expectedAllowOrigin: "*", expectedCredentials: ""},
+		{name: "TLSOnlyRoute_OverPlaintext", origin: "https://app.example.com", path: "/sensitive.Service/Method", expectedStatus: http.StatusForbidden},
+		{name: "ListedOriginNextToWildcard", origin: "https://app.example.com", path: "/public.Service/Method", expectedStatus: http.StatusNoContent,
+			expectedAllowOrigin: "https://app.example.com", expectedCredentials: "true"},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			req, err := http.NewRequest("OPTIONS", srv.URL+tcase.path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Origin", tcase.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web,x-custom")
+			require.True(t, IsGrpcWebPreflight(req))
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			require.Equal(t, tcase.expectedStatus, resp.StatusCode)
+			if tcase.expectedStatus != http.StatusNoContent {
+				return
+			}
+			assert.Equal(t, tcase.expectedAllowOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tcase.expectedCredentials, resp.Header.Get("Access-Control-Allow-Credentials"))
+			assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "x-grpc-web")
+			if tcase.path == checkMethod {
+				assert.Equal(t, "600", resp.Header.Get("Access-Control-Max-Age"))
+				assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "x-custom")
+			}
+		})
+	}
+}
+
+func TestGrpcWeb_Preflight_TLSOnlyRouteOverTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(testHandler())
+	defer srv.Close()
+
+	req, err := http.NewRequest("OPTIONS", srv.URL+"/sensitive.Service/Method", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+}
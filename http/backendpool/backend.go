@@ -15,6 +15,7 @@ import (
 	"github.com/mwitkow/kedge/http/lbtransport"
 	"github.com/mwitkow/kedge/lib/resolvers/k8s"
 	"github.com/mwitkow/kedge/lib/resolvers/srv"
+	"github.com/mwitkow/kedge/lib/tracing"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/http2"
@@ -33,6 +34,11 @@ var (
 	})
 )
 
+// lbDialer is implemented by lbtransport's tripper.
+type lbDialer interface {
+	Dial(r *http.Request, dial lbtransport.DialContextFunc) (net.Conn, error)
+}
+
 type backend struct {
 	mu sync.RWMutex
 
@@ -40,6 +46,9 @@ type backend struct {
 	resolver  naming.Resolver
 	transport *http.Transport
 	tripper   http.RoundTripper
+	lb        lbDialer
+	dialFunc  lbtransport.DialContextFunc
+	tlsConfig *tls.Config
 	config    *pb.Backend
 	closed    bool
 }
@@ -56,6 +65,40 @@ func (b *backend) Tripper() http.RoundTripper {
 	return t
 }
 
+// Dial returns a raw connection to one of the load balanced targets of this backend.
+// For secure backends the returned connection is already after the TLS handshake and talks HTTP/1.1.
+func (b *backend) Dial(req *http.Request) (net.Conn, error) {
+	conn, err := b.DialTunnel(req)
+	if err != nil {
+		return nil, err
+	}
+	if b.tlsConfig == nil {
+		return conn, nil
+	}
+
+	tlsConfig := b.tlsConfig.Clone()
+	// Upgrades and tunnels are HTTP/1.1 only.
+	tlsConfig.NextProtos = []string{"http/1.1"}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "failed TLS handshake with backend %s", b.target)
+	}
+	return tlsConn, nil
+}
+
+// DialTunnel returns a plain TCP connection to one of the load balanced targets of this backend.
+func (b *backend) DialTunnel(req *http.Request) (net.Conn, error) {
+	b.mu.RLock()
+	lb := b.lb
+	closed := b.closed
+	b.mu.RUnlock()
+	if closed || lb == nil {
+		return nil, errors.New("backend transport closed")
+	}
+	return lb.Dial(req, b.dialFunc)
+}
+
 // Close is used when backend is removed from configuration dynamically.
 func (b *backend) Close() error {
 	b.mu.Lock()
@@ -88,6 +131,11 @@ func newBackend(cnf *pb.Backend) (*backend, error) {
 	}
 
 	scheme, tlsConfig := buildTls(cnf)
+	b.dialFunc = dialFunc
+	if tlsConfig != nil {
+		// Keep an untouched copy for raw dials. http2.ConfigureTransport mangles the one passed to transport.
+		b.tlsConfig = tlsConfig.Clone()
+	}
 	b.transport = &http.Transport{
 		DialContext:         dialFunc,
 		TLSClientConfig:     tlsConfig,
@@ -100,11 +148,14 @@ func newBackend(cnf *pb.Backend) (*backend, error) {
 		return nil, err
 	}
 
-	b.tripper, err = lbtransport.New(target, b.transport, resolver, chooseBalancerPolicy(cnf))
+	// Tracing and metrics are below the load balancer, so each attempt is reported separately with the chosen target.
+	attemptTripper := tracing.WrapTransport(&metricsTripper{parent: b.transport, backendName: cnf.Name}, cnf.Name)
+	lb, err := lbtransport.New(target, attemptTripper, resolver, chooseBalancerPolicy(cnf))
 	if err != nil {
 		return nil, err
 	}
-	b.tripper = buildTripperMiddlewareChain(cnf, b.tripper)
+	b.lb = lb
+	b.tripper = buildTripperMiddlewareChain(cnf, lb)
 	b.tripper = &schemeTripper{expectedScheme: scheme, parent: b.tripper}
 	return b, nil
 }
@@ -40,6 +40,16 @@ func (s *dynamic) Tripper(backendName string) (http.RoundTripper, error) {
 	return be.Tripper(), nil
 }
 
+func (s *dynamic) Dialer(backendName string) (Dialer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	be, ok := s.backends[backendName]
+	if !ok {
+		return nil, ErrUnknownBackend
+	}
+	return be, nil
+}
+
 // AddOrUpdate checks tries to perform the least destructive operation of adding a new backend.
 //
 // If a backend of a given name already exists, and the configuration hasn't changed, no new work will be done.
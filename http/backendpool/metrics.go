@@ -0,0 +1,68 @@
+package backendpool
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Client metrics are named after the grpc_prometheus ones (grpc_client_handled_total, grpc_client_handling_seconds).
+var (
+	clientHandled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_client",
+			Name:      "handled_total",
+			Help:      "Total number of HTTP requests sent to backend targets, by response code or 'error' for failed round trips.",
+		},
+		[]string{"backend", "code"},
+	)
+	clientHandlingSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "kedge",
+			Subsystem: "http_client",
+			Name:      "handling_seconds",
+			Help:      "Histogram of latency (seconds) until response headers are received from backend targets.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"backend"},
+	)
+	clientInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "http_client",
+			Name:      "in_flight",
+			Help:      "Number of HTTP requests sent to backend targets that are waiting for response headers.",
+		},
+		[]string{"backend"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(clientHandled)
+	prometheus.MustRegister(clientHandlingSeconds)
+	prometheus.MustRegister(clientInFlight)
+}
+
+// metricsTripper reports client metrics of each round trip to a single (already load balanced) backend target.
+type metricsTripper struct {
+	parent      http.RoundTripper
+	backendName string
+}
+
+func (t *metricsTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	inFlight := clientInFlight.WithLabelValues(t.backendName)
+	inFlight.Inc()
+	start := time.Now()
+	resp, err := t.parent.RoundTrip(req)
+	inFlight.Dec()
+	clientHandlingSeconds.WithLabelValues(t.backendName).Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	clientHandled.WithLabelValues(t.backendName, code).Inc()
+	return resp, err
+}
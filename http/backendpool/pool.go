@@ -1,6 +1,7 @@
 package backendpool
 
 import (
+	"net"
 	"net/http"
 
 	"google.golang.org/grpc"
@@ -14,4 +15,19 @@ var (
 type Pool interface {
 	// Tripper returns an already established http.RoundTripper just for this backend.
 	Tripper(backendName string) (http.RoundTripper, error)
+
+	// Dialer returns a Dialer for raw connections to the load balanced targets of this backend.
+	Dialer(backendName string) (Dialer, error)
+}
+
+// Dialer dials raw connections to a backend. It is used for requests that cannot be handled by http.RoundTripper, like
+// HTTP Upgrade (WebSocket, SPDY) or CONNECT tunnels.
+type Dialer interface {
+	// Dial picks a target for the given request using the backend's balancing policy and connects to it.
+	// For secure backends the returned connection is already after the TLS handshake.
+	Dial(req *http.Request) (net.Conn, error)
+
+	// DialTunnel is like Dial, but always returns a plain TCP connection, even for secure backends.
+	// It is used for passing opaque bytes (e.g. TLS passthrough in CONNECT tunnels).
+	DialTunnel(req *http.Request) (net.Conn, error)
 }
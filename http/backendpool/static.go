@@ -35,6 +35,14 @@ func (s *static) Tripper(backendName string) (http.RoundTripper, error) {
 	return be.Tripper(), nil
 }
 
+func (s *static) Dialer(backendName string) (Dialer, error) {
+	be, ok := s.backends[backendName]
+	if !ok {
+		return nil, ErrUnknownBackend
+	}
+	return be, nil
+}
+
 func (s *static) LogTestResolution(logger logrus.FieldLogger) {
 	for k, backend := range s.backends {
 		backend.LogTestResolution(logger.WithField("backend", k))
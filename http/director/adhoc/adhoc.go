@@ -23,9 +23,10 @@ var (
 //
 // Adhoc rules are a way of forwarding requests to services that fall outside of pre-defined Routes and Backends.
 type Addresser interface {
-	// Address decides the ip:port to send the request to, if any. Errors may be returned if permission is denied.
+	// Address decides the ip:port to send the request to, if any, and returns the rule that matched the request.
+	// Errors may be returned if permission is denied.
 	// The returned string must contain contain both ip and port separated by colon.
-	Address(r *http.Request) (string, error)
+	Address(r *http.Request) (string, *pb.Adhoc, error)
 }
 
 type dynamic struct {
@@ -38,7 +39,7 @@ func NewDynamic() *dynamic {
 	return &dynamic{addresser: NewStaticAddresser([]*pb.Adhoc{})}
 }
 
-func (d *dynamic) Address(req *http.Request) (string, error) {
+func (d *dynamic) Address(req *http.Request) (string, *pb.Adhoc, error) {
 	d.mu.RLock()
 	addresser := d.addresser
 	d.mu.RUnlock()
@@ -61,10 +62,10 @@ func NewStaticAddresser(rules []*pb.Adhoc) *static {
 	return &static{rules: rules}
 }
 
-func (a *static) Address(req *http.Request) (string, error) {
+func (a *static) Address(req *http.Request) (string, *pb.Adhoc, error) {
 	hostName, port, err := a.extractHostPort(req.URL.Host)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	for _, rule := range a.rules {
 		if !a.hostMatches(hostName, rule.DnsNameMatcher) {
@@ -79,16 +80,16 @@ func (a *static) Address(req *http.Request) (string, error) {
 			}
 		}
 		if !a.portAllowed(portForRule, rule.Port) {
-			return "", router.NewError(http.StatusBadRequest, fmt.Sprintf("port %d is not allowed", portForRule))
+			return "", nil, router.NewError(http.StatusBadRequest, fmt.Sprintf("port %d is not allowed", portForRule))
 		}
 		ipAddr, err := a.resolveHost(hostName)
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
-		return net.JoinHostPort(ipAddr, strconv.FormatInt(int64(portForRule), 10)), nil
+		return net.JoinHostPort(ipAddr, strconv.FormatInt(int64(portForRule), 10)), rule, nil
 
 	}
-	return "", router.ErrRouteNotFound
+	return "", nil, router.ErrRouteNotFound
 }
 
 func (*static) resolveHost(hostStr string) (string, error) {
@@ -107,7 +107,7 @@ func TestAdhocMatches(t *testing.T) {
 			req, err := http.NewRequest("GET", "/foo", nil)
 			require.NoError(t, err, "parsing the request shouldn't fail")
 			req.URL.Host = tcase.hostPort
-			be, err := a.Address(req)
+			be, _, err := a.Address(req)
 			if tcase.expectedErr != "" {
 				assert.EqualError(t, err, tcase.expectedErr)
 			} else {
@@ -0,0 +1,117 @@
+package director
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mwitkow/kedge/lib/auditlog"
+	"github.com/mwitkow/kedge/lib/requestid"
+)
+
+var (
+	// AuditLogger records authorization decisions of AuthMiddleware. It discards them by default.
+	AuditLogger = &auditlog.Logger{}
+)
+
+type pendingAuditKey struct{}
+
+type pendingAudit struct {
+	record *auditlog.Record
+	logged bool
+}
+
+// newAuditRecord fills the record with the request and the identity of the caller. Decision, route and backend are
+// left for the caller.
+func newAuditRecord(req *http.Request, claims map[string]interface{}) *auditlog.Record {
+	record := &auditlog.Record{
+		Subject:    claimsSubject(claims),
+		PermsClaim: AuditLogger.PermsClaim(),
+		RequestID:  requestid.FromRequest(req),
+		RemoteAddr: req.RemoteAddr,
+		Method:     req.Method,
+		Host:       req.Host,
+		URI:        req.RequestURI,
+	}
+	if record.PermsClaim != "" {
+		record.Perms = claimStrings(claims[record.PermsClaim])
+	}
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		record.CertCommonName = req.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return record
+}
+
+// withPendingAudit defers logging of the allow decision until the request is routed, so the record has route and
+// backend. The returned function logs the decision if it was not logged by then, e.g. for handlers other than the
+// proxy (debug endpoints, flagz).
+func withPendingAudit(req *http.Request, record *auditlog.Record) (*http.Request, func()) {
+	pending := &pendingAudit{record: record}
+	return req.WithContext(context.WithValue(req.Context(), pendingAuditKey{}, pending)), func() {
+		if !pending.logged {
+			pending.logged = true
+			AuditLogger.Log(pending.record)
+		}
+	}
+}
+
+// logPendingAudit logs the allow decision of AuthMiddleware, if any, for the given route and backend.
+func logPendingAudit(req *http.Request, route string, backend string) {
+	pending, ok := req.Context().Value(pendingAuditKey{}).(*pendingAudit)
+	if !ok || pending.logged {
+		return
+	}
+	pending.logged = true
+	pending.record.Route = route
+	pending.record.Backend = backend
+	AuditLogger.Log(pending.record)
+}
+
+// tokenClaims returns claims of the bearer ID token from the given header value, or nil if there is no valid JWT.
+// It does not verify the token.
+func tokenClaims(headerValue string) map[string]interface{} {
+	const bearerPrefix = "bearer "
+	if len(headerValue) < len(bearerPrefix) || !strings.EqualFold(headerValue[:len(bearerPrefix)], bearerPrefix) {
+		return nil
+	}
+	parts := strings.Split(headerValue[len(bearerPrefix):], ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	return claims
+}
+
+// claimsSubject returns email or, if not present, sub claim.
+func claimsSubject(claims map[string]interface{}) string {
+	if email, ok := claims["email"].(string); ok && email != "" {
+		return email
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+func claimStrings(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		var values []string
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package director
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mwitkow/kedge/lib/auditlog"
+	"github.com/mwitkow/kedge/lib/http/tripperware"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testIDToken(payload string) string {
+	return "Bearer eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+}
+
+func TestNewAuditRecord_FromTokenAndCert(t *testing.T) {
+	oldLogger := AuditLogger
+	defer func() { AuditLogger = oldLogger }()
+	var err error
+	AuditLogger, err = auditlog.New(auditlog.Config{Sink: auditlog.SinkNone, PermsClaim: "perms"})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "https://backend.example.com/some/path", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "client.example.com"}}}}
+	record := newAuditRecord(req, tokenClaims(testIDToken(`{"sub":"123","email":"someone@example.com","perms":["admin","dev"]}`)))
+
+	assert.Equal(t, "someone@example.com", record.Subject)
+	assert.Equal(t, "client.example.com", record.CertCommonName)
+	assert.Equal(t, "perms", record.PermsClaim)
+	assert.Equal(t, []string{"admin", "dev"}, record.Perms)
+	assert.Equal(t, "backend.example.com", record.Host)
+}
+
+func TestTokenClaims(t *testing.T) {
+	assert.Equal(t, "123", claimsSubject(tokenClaims(testIDToken(`{"sub":"123"}`))))
+	assert.Equal(t, []string{"single"}, claimStrings(tokenClaims(testIDToken(`{"perms":"single"}`))["perms"]))
+	for _, header := range []string{"", "Basic dXNlcjpwYXNz", "Bearer opaque-token", "Bearer a.!!!.c"} {
+		assert.Nil(t, tokenClaims(header), "header %v", header)
+	}
+}
+
+type allowAllAuthorizer struct{}
+
+func (allowAllAuthorizer) IsAuthorized(_ context.Context, _ string) error {
+	return nil
+}
+
+func TestAuthMiddleware_LogsAllowForHandlersOtherThanProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	oldLogger := AuditLogger
+	defer func() { AuditLogger = oldLogger }()
+	AuditLogger, err = auditlog.New(auditlog.Config{Sink: auditlog.SinkFile, FilePath: filepath.Join(dir, "audit.log")})
+	require.NoError(t, err)
+	defer AuditLogger.Close()
+
+	handler := AuthMiddleware(allowAllAuthorizer{})(sharedflags.FlagzEndpoint(nil))
+	req := httptest.NewRequest("GET", "/debug/flagz", nil)
+	req.Header.Set(tripperware.ProxyAuthHeader, testIDToken(`{"email":"someone@example.com"}`))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "audit.log"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 1, "allow decision should be logged exactly once")
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, auditlog.DecisionAllow, record["decision"])
+	assert.Equal(t, "someone@example.com", record["subject"])
+	assert.Equal(t, "/debug/flagz", record["uri"])
+}
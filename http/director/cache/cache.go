@@ -0,0 +1,407 @@
+// Package cache implements an opt-in, per-route HTTP response cache for backend responses.
+//
+// Kedge acts as a shared cache (RFC 7234): Cache-Control, Expires and Vary of backend responses are honoured,
+// responses that are private, set cookies or are for authorized requests (unless explicitly public) are not stored.
+// Stale responses with validators (ETag, Last-Modified) are revalidated with conditional requests to the backend.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mwitkow/go-httpwares/tags"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	policyMarker = "cache_policy_marker"
+
+	// HeaderName is the response header telling whether the response was served from cache.
+	HeaderName = "x-kedge-cache"
+
+	resultHit         = "hit"
+	resultMiss        = "miss"
+	resultRevalidated = "revalidated"
+	resultBypass      = "bypass"
+)
+
+var (
+	cacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_cache",
+			Name:      "requests_total",
+			Help:      "Total number of requests to backends with response cache enabled, by cache result.",
+		},
+		[]string{"backend", "result"},
+	)
+	cacheSizeBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "http_cache",
+			Name:      "size_bytes",
+			Help:      "Total size of responses stored in the response cache.",
+		},
+	)
+	cacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "http_cache",
+			Name:      "entries",
+			Help:      "Number of responses stored in the response cache.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+	prometheus.MustRegister(cacheSizeBytes)
+	prometheus.MustRegister(cacheEntries)
+}
+
+// WithPolicy marks the request to be served using response cache with the given route policy.
+func WithPolicy(req *http.Request, policy *pb.Cache) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), policyMarker, policy))
+}
+
+func policyFromRequest(req *http.Request) *pb.Cache {
+	policy, _ := req.Context().Value(policyMarker).(*pb.Cache)
+	return policy
+}
+
+// NewTripper returns a RoundTripper serving requests marked with WithPolicy from the store, and passing all the other
+// requests (and cache misses) to next. It assumes the request has been rewritten by the proxy to have the backend as
+// req.URL.Host.
+func NewTripper(next http.RoundTripper, store *Store) http.RoundTripper {
+	return &tripper{next: next, store: store, now: time.Now}
+}
+
+type tripper struct {
+	next  http.RoundTripper
+	store *Store
+	now   func() time.Time
+}
+
+func (t *tripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := policyFromRequest(req)
+	if policy == nil {
+		return t.next.RoundTrip(req)
+	}
+	key := primaryKey(req)
+	if req.Method != http.MethodGet {
+		resp, err := t.next.RoundTrip(req)
+		if err == nil && !isSafeMethod(req.Method) && resp.StatusCode < 400 {
+			// Unsafe methods are likely to change the resource (RFC 7234 section 4.4).
+			t.store.invalidate(key)
+		}
+		t.report(req, resp, resultBypass)
+		return resp, err
+	}
+	reqCacheControl := parseCacheControl(req.Header)
+	if _, ok := reqCacheControl["no-store"]; ok {
+		resp, err := t.next.RoundTrip(req)
+		t.report(req, resp, resultBypass)
+		return resp, err
+	}
+
+	now := t.now()
+	cached := t.store.lookup(key, req)
+	if cached != nil && cached.isFresh(now) && !requiresRevalidation(reqCacheControl) {
+		resp := cached.response(req, now)
+		t.report(req, resp, resultHit)
+		return resp, nil
+	}
+
+	backendReq := req
+	if cached != nil && cached.canRevalidate() {
+		// Client conditionals are answered from the stored response, so only ours are sent to the backend.
+		backendReq = withoutConditionals(req)
+		if etag := cached.header.Get("ETag"); etag != "" {
+			backendReq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.header.Get("Last-Modified"); lastModified != "" {
+			backendReq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	resp, err := t.next.RoundTrip(backendReq)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := t.now()
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		refreshed := cached.refresh(resp.Header, responseTime, policy)
+		t.store.put(req, refreshed)
+		resp := refreshed.response(req, responseTime)
+		t.report(req, resp, resultRevalidated)
+		return resp, nil
+	}
+	if e := t.newEntry(key, req, resp, responseTime, policy); e != nil {
+		resp.Body = &recordingBody{
+			ReadCloser: resp.Body,
+			limit:      t.store.maxObjectBytes,
+			onComplete: func(body []byte) {
+				e.body = body
+				t.store.put(req, e)
+			},
+		}
+	} else if cached != nil {
+		// The resource is not cacheable anymore.
+		t.store.invalidate(key)
+	}
+	t.report(req, resp, resultMiss)
+	return resp, nil
+}
+
+func (t *tripper) report(req *http.Request, resp *http.Response, result string) {
+	http_ctxtags.ExtractInbound(req).Set(ctxtags.TagForProxyCache, result)
+	cacheRequests.WithLabelValues(req.URL.Host, result).Inc()
+	if resp != nil {
+		resp.Header.Set(HeaderName, strings.ToUpper(result))
+	}
+}
+
+// newEntry returns entry (without body) for the backend response, or nil if the response is not cacheable.
+func (t *tripper) newEntry(key string, req *http.Request, resp *http.Response, responseTime time.Time, policy *pb.Cache) *entry {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMultipleChoices, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return nil
+	}
+	cacheControl := parseCacheControl(resp.Header)
+	for _, directive := range []string{"no-store", "private"} {
+		if _, ok := cacheControl[directive]; ok {
+			return nil
+		}
+	}
+	if resp.Header.Get("Vary") == "*" || len(resp.Header["Set-Cookie"]) > 0 {
+		return nil
+	}
+	if req.Header.Get("Authorization") != "" && !allowsAuthorizedSharing(cacheControl) {
+		return nil
+	}
+	if resp.ContentLength > t.store.maxObjectBytes {
+		return nil
+	}
+	e := &entry{
+		primaryKey:   key,
+		statusCode:   resp.StatusCode,
+		header:       cloneHeader(resp.Header),
+		responseTime: responseTime,
+		initialAge:   ageHeader(resp.Header),
+		freshness:    freshness(resp.Header, cacheControl, policy),
+	}
+	if e.freshness <= 0 && !e.canRevalidate() {
+		return nil
+	}
+	return e
+}
+
+// refresh returns a copy of the entry updated with headers of 304 Not Modified response (RFC 7234 section 4.3.4).
+func (e *entry) refresh(notModifiedHeader http.Header, responseTime time.Time, policy *pb.Cache) *entry {
+	header := cloneHeader(e.header)
+	for k, v := range notModifiedHeader {
+		if k == "Content-Length" {
+			continue
+		}
+		header[k] = v
+	}
+	return &entry{
+		primaryKey:   e.primaryKey,
+		statusCode:   e.statusCode,
+		header:       header,
+		body:         e.body,
+		responseTime: responseTime,
+		initialAge:   ageHeader(notModifiedHeader),
+		freshness:    freshness(header, parseCacheControl(header), policy),
+	}
+}
+
+// response builds the response for the request from the entry. Conditional requests are answered with 304 if the
+// entry matches.
+func (e *entry) response(req *http.Request, now time.Time) *http.Response {
+	header := cloneHeader(e.header)
+	header.Set("Age", strconv.Itoa(int(e.age(now).Seconds())))
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", e.statusCode, http.StatusText(e.statusCode)),
+		StatusCode: e.statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Request:    req,
+	}
+	if e.statusCode == http.StatusOK && e.notModified(req) {
+		resp.StatusCode = http.StatusNotModified
+		resp.Status = fmt.Sprintf("%d %s", http.StatusNotModified, http.StatusText(http.StatusNotModified))
+		header.Del("Content-Length")
+		resp.Body = ioutil.NopCloser(bytes.NewReader(nil))
+		return resp
+	}
+	resp.ContentLength = int64(len(e.body))
+	resp.Body = ioutil.NopCloser(bytes.NewReader(e.body))
+	return resp
+}
+
+// notModified evaluates conditional headers of the request against the entry (RFC 7232 section 6).
+func (e *entry) notModified(req *http.Request) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(e.header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		lastModified, err := http.ParseTime(e.header.Get("Last-Modified"))
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
+
+// recordingBody records the body read by the proxy and calls onComplete with it once fully read. Bodies bigger than
+// limit are not recorded.
+type recordingBody struct {
+	io.ReadCloser
+	limit      int64
+	buf        bytes.Buffer
+	exceeded   bool
+	onComplete func(body []byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.exceeded {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.exceeded = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.exceeded && b.onComplete != nil {
+		b.onComplete(b.buf.Bytes())
+		b.onComplete = nil
+	}
+	return n, err
+}
+
+func primaryKey(req *http.Request) string {
+	return req.URL.Host + " " + strings.ToLower(req.Host) + req.URL.RequestURI()
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions || method == http.MethodTrace
+}
+
+func withoutConditionals(req *http.Request) *http.Request {
+	reqCopy := *req
+	reqCopy.Header = cloneHeader(req.Header)
+	reqCopy.Header.Del("If-None-Match")
+	reqCopy.Header.Del("If-Modified-Since")
+	return &reqCopy
+}
+
+func requiresRevalidation(reqCacheControl map[string]string) bool {
+	if _, ok := reqCacheControl["no-cache"]; ok {
+		return true
+	}
+	return reqCacheControl["max-age"] == "0"
+}
+
+// allowsAuthorizedSharing returns true if response to request with Authorization can be stored in shared cache
+// (RFC 7234 section 3.2).
+func allowsAuthorizedSharing(cacheControl map[string]string) bool {
+	for _, directive := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := cacheControl[directive]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// freshness returns freshness lifetime of the response (RFC 7234 section 4.2.1) capped by the policy.
+func freshness(header http.Header, cacheControl map[string]string, policy *pb.Cache) time.Duration {
+	lifetime := time.Duration(policy.GetDefaultTtlSec()) * time.Second
+	if _, ok := cacheControl["no-cache"]; ok {
+		lifetime = 0
+	} else if v, ok := cacheControl["s-maxage"]; ok {
+		lifetime = parseSeconds(v)
+	} else if v, ok := cacheControl["max-age"]; ok {
+		lifetime = parseSeconds(v)
+	} else if expires := header.Get("Expires"); expires != "" {
+		lifetime = 0
+		if expiresTime, err := http.ParseTime(expires); err == nil {
+			date, err := http.ParseTime(header.Get("Date"))
+			if err != nil {
+				date = time.Now()
+			}
+			lifetime = expiresTime.Sub(date)
+		}
+	}
+	if maxTtl := time.Duration(policy.GetMaxTtlSec()) * time.Second; maxTtl > 0 && lifetime > maxTtl {
+		lifetime = maxTtl
+	}
+	return lifetime
+}
+
+func ageHeader(header http.Header) time.Duration {
+	return parseSeconds(header.Get("Age"))
+}
+
+func parseSeconds(v string) time.Duration {
+	seconds, err := strconv.ParseInt(strings.Trim(v, `"`), 10, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// parseCacheControl returns Cache-Control directives with their (optional) values.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, v := range header["Cache-Control"] {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			split := strings.SplitN(directive, "=", 2)
+			value := ""
+			if len(split) == 2 {
+				value = strings.TrimSpace(split[1])
+			}
+			directives[strings.ToLower(strings.TrimSpace(split[0]))] = value
+		}
+	}
+	return directives
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for k, v := range header {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend responds with the headers and body of the resource, answering If-None-Match with 304.
+type fakeBackend struct {
+	header   http.Header
+	body     string
+	requests []*http.Request
+}
+
+func (b *fakeBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.requests = append(b.requests, req)
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     cloneHeader(b.header),
+		Body:       ioutil.NopCloser(strings.NewReader(b.body)),
+		Request:    req,
+	}
+	if etag := b.header.Get("ETag"); etag != "" && req.Header.Get("If-None-Match") == etag {
+		resp.StatusCode = http.StatusNotModified
+		resp.Body = ioutil.NopCloser(strings.NewReader(""))
+	}
+	return resp, nil
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestTripper(backend *fakeBackend) (*tripper, *testClock) {
+	clock := &testClock{now: time.Now()}
+	return &tripper{next: backend, store: NewStore(1024*1024, 1024), now: clock.Now}, clock
+}
+
+func doRequest(t *testing.T, tr http.RoundTripper, method string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(method, "http://backend_a/some/path?q=1", nil)
+	require.NoError(t, err)
+	req.Host = "public.example.com"
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := tr.RoundTrip(WithPolicy(req, &pb.Cache{}))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+func TestTripper_ServesFreshResponseFromCache(t *testing.T) {
+	backend := &fakeBackend{header: http.Header{"Cache-Control": {"max-age=60"}}, body: "hello"}
+	tr, clock := newTestTripper(backend)
+
+	resp, body := doRequest(t, tr, "GET", nil)
+	assert.Equal(t, "MISS", resp.Header.Get(HeaderName))
+	assert.Equal(t, "hello", body)
+
+	clock.now = clock.now.Add(30 * time.Second)
+	resp, body = doRequest(t, tr, "GET", nil)
+	assert.Equal(t, "HIT", resp.Header.Get(HeaderName))
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, "30", resp.Header.Get("Age"))
+	assert.Len(t, backend.requests, 1)
+
+	clock.now = clock.now.Add(31 * time.Second)
+	resp, _ = doRequest(t, tr, "GET", nil)
+	assert.Equal(t, "MISS", resp.Header.Get(HeaderName), "stale response without validators is fetched again")
+	assert.Len(t, backend.requests, 2)
+}
+
+func TestTripper_RevalidatesStaleResponse(t *testing.T) {
+	backend := &fakeBackend{header: http.Header{"Cache-Control": {"max-age=10"}, "Etag": {`"v1"`}}, body: "hello"}
+	tr, clock := newTestTripper(backend)
+
+	doRequest(t, tr, "GET", nil)
+	clock.now = clock.now.Add(11 * time.Second)
+	resp, body := doRequest(t, tr, "GET", nil)
+	assert.Equal(t, "REVALIDATED", resp.Header.Get(HeaderName))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", body)
+	require.Len(t, backend.requests, 2)
+	assert.Equal(t, `"v1"`, backend.requests[1].Header.Get("If-None-Match"))
+
+	resp, _ = doRequest(t, tr, "GET", nil)
+	assert.Equal(t, "HIT", resp.Header.Get(HeaderName), "revalidation should refresh the entry")
+}
+
+func TestTripper_AnswersClientConditionalFromCache(t *testing.T) {
+	backend := &fakeBackend{header: http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"v1"`}}, body: "hello"}
+	tr, _ := newTestTripper(backend)
+
+	resp, _ := doRequest(t, tr, "GET", nil)
+	assert.Equal(t, "MISS", resp.Header.Get(HeaderName))
+
+	resp, body := doRequest(t, tr, "GET", http.Header{"If-None-Match": {`"v1"`}})
+	assert.Equal(t, "HIT", resp.Header.Get(HeaderName))
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Empty(t, body)
+
+	resp, body = doRequest(t, tr, "GET", http.Header{"If-None-Match": {`"v0"`}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", body)
+	assert.Len(t, backend.requests, 1)
+}
+
+func TestTripper_HonoursVary(t *testing.T) {
+	backend := &fakeBackend{header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}}, body: "hello"}
+	tr, _ := newTestTripper(backend)
+
+	doRequest(t, tr, "GET", http.Header{"Accept-Language": {"en"}})
+	resp, _ := doRequest(t, tr, "GET", http.Header{"Accept-Language": {"pl"}})
+	assert.Equal(t, "MISS", resp.Header.Get(HeaderName))
+	resp, _ = doRequest(t, tr, "GET", http.Header{"Accept-Language": {"en"}})
+	assert.Equal(t, "HIT", resp.Header.Get(HeaderName))
+	assert.Len(t, backend.requests, 2)
+}
+
+func TestTripper_DoesNotStoreUncacheableResponses(t *testing.T) {
+	for _, tcase := range []struct {
+		name      string
+		header    http.Header
+		reqHeader http.Header
+		body      string
+	}{
+		{name: "NoStore", header: http.Header{"Cache-Control": {"no-store"}}},
+		{name: "Private", header: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		{name: "SetCookie", header: http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}},
+		{name: "NoFreshnessNorValidators", header: http.Header{}},
+		{name: "Authorized", header: http.Header{"Cache-Control": {"max-age=60"}}, reqHeader: http.Header{"Authorization": {"Bearer x"}}},
+		{name: "TooBig", header: http.Header{"Cache-Control": {"max-age=60"}}, body: strings.Repeat("a", 2048)},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			backend := &fakeBackend{header: tcase.header, body: tcase.body}
+			tr, _ := newTestTripper(backend)
+			doRequest(t, tr, "GET", tcase.reqHeader)
+			resp, _ := doRequest(t, tr, "GET", tcase.reqHeader)
+			assert.Equal(t, "MISS", resp.Header.Get(HeaderName))
+			assert.Len(t, backend.requests, 2)
+		})
+	}
+}
+
+func TestTripper_UnsafeMethodInvalidates(t *testing.T) {
+	backend := &fakeBackend{header: http.Header{"Cache-Control": {"max-age=60"}}, body: "hello"}
+	tr, _ := newTestTripper(backend)
+
+	doRequest(t, tr, "GET", nil)
+	resp, _ := doRequest(t, tr, "POST", nil)
+	assert.Equal(t, "BYPASS", resp.Header.Get(HeaderName))
+	resp, _ = doRequest(t, tr, "GET", nil)
+	assert.Equal(t, "MISS", resp.Header.Get(HeaderName))
+	assert.Len(t, backend.requests, 3)
+}
+
+func TestTripper_PolicyCapsFreshness(t *testing.T) {
+	backend := &fakeBackend{header: http.Header{"Cache-Control": {"max-age=3600"}}, body: "hello"}
+	tr, clock := newTestTripper(backend)
+
+	req, err := http.NewRequest("GET", "http://backend_a/", nil)
+	require.NoError(t, err)
+	resp, err := tr.RoundTrip(WithPolicy(req, &pb.Cache{MaxTtlSec: 10}))
+	require.NoError(t, err)
+	ioutil.ReadAll(resp.Body)
+
+	clock.now = clock.now.Add(11 * time.Second)
+	resp, err = tr.RoundTrip(WithPolicy(req, &pb.Cache{MaxTtlSec: 10}))
+	require.NoError(t, err)
+	assert.Equal(t, "MISS", resp.Header.Get(HeaderName))
+
+	resp, err = tr.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get(HeaderName), "requests without policy are not cached")
+}
+
+func TestStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewStore(300, 300)
+	newReq := func(path string) *http.Request {
+		req, err := http.NewRequest("GET", "http://backend_a"+path, nil)
+		require.NoError(t, err)
+		return req
+	}
+	for _, path := range []string{"/a", "/b", "/c"} {
+		req := newReq(path)
+		require.True(t, store.put(req, &entry{primaryKey: primaryKey(req), header: http.Header{}, body: make([]byte, 80)}))
+		if path == "/b" {
+			require.NotNil(t, store.lookup(primaryKey(newReq("/a")), newReq("/a")), "touch /a")
+		}
+	}
+	assert.NotNil(t, store.lookup(primaryKey(newReq("/a")), newReq("/a")))
+	assert.Nil(t, store.lookup(primaryKey(newReq("/b")), newReq("/b")), "least recently used should be evicted")
+	assert.NotNil(t, store.lookup(primaryKey(newReq("/c")), newReq("/c")))
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is an in-memory LRU store of cached responses capped by total size.
+type Store struct {
+	maxBytes       int64
+	maxObjectBytes int64
+
+	mu        sync.Mutex
+	sizeBytes int64
+	lru       *list.List // of *entry, most recently used at front.
+	primaries map[string]*primaryEntry
+}
+
+// primaryEntry groups all stored variants (see Vary header) of the same resource.
+type primaryEntry struct {
+	vary     []string
+	variants map[string]*list.Element
+}
+
+// NewStore returns a Store holding up to maxBytes of responses. Responses bigger than maxObjectBytes are not stored.
+func NewStore(maxBytes int64, maxObjectBytes int64) *Store {
+	return &Store{
+		maxBytes:       maxBytes,
+		maxObjectBytes: maxObjectBytes,
+		lru:            list.New(),
+		primaries:      make(map[string]*primaryEntry),
+	}
+}
+
+type entry struct {
+	primaryKey string
+	variantKey string
+
+	statusCode int
+	header     http.Header
+	body       []byte
+
+	// responseTime is when the response was received (or revalidated) from the backend.
+	responseTime time.Time
+	// initialAge is the age of the response at responseTime, as reported by the backend Age header.
+	initialAge time.Duration
+	// freshness is the freshness lifetime of the response.
+	freshness time.Duration
+}
+
+func (e *entry) age(now time.Time) time.Duration {
+	return e.initialAge + now.Sub(e.responseTime)
+}
+
+func (e *entry) isFresh(now time.Time) bool {
+	return e.age(now) < e.freshness
+}
+
+func (e *entry) canRevalidate() bool {
+	return e.header.Get("ETag") != "" || e.header.Get("Last-Modified") != ""
+}
+
+func (e *entry) size() int64 {
+	size := len(e.primaryKey) + len(e.variantKey) + len(e.body)
+	for k, vals := range e.header {
+		for _, v := range vals {
+			size += len(k) + len(v)
+		}
+	}
+	return int64(size)
+}
+
+// variantKey returns the key of the request variant, made of the request header values listed by vary.
+func variantKey(vary []string, req *http.Request) string {
+	parts := make([]string, 0, len(vary))
+	for _, name := range vary {
+		parts = append(parts, name+"="+strings.Join(req.Header[name], ","))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// varyHeaders returns sorted, canonical header names from Vary response header.
+func varyHeaders(header http.Header) []string {
+	vary := []string{}
+	for _, v := range header["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary
+}
+
+// lookup returns the stored variant matching the request or nil.
+func (s *Store) lookup(primaryKey string, req *http.Request) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.primaries[primaryKey]
+	if !ok {
+		return nil
+	}
+	elem, ok := p.variants[variantKey(p.vary, req)]
+	if !ok {
+		return nil
+	}
+	s.lru.MoveToFront(elem)
+	return elem.Value.(*entry)
+}
+
+// put stores the entry for the request, replacing the previous one of the same variant. Least recently used entries
+// are evicted if the store is full. It returns false if the entry is too big to be stored.
+func (s *Store) put(req *http.Request, e *entry) bool {
+	vary := varyHeaders(e.header)
+	e.variantKey = variantKey(vary, req)
+	size := e.size()
+	if size > s.maxObjectBytes || size > s.maxBytes {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.primaries[e.primaryKey]
+	if ok && !stringsEqual(p.vary, vary) {
+		// Backend changed its Vary header, previous variants are keyed differently.
+		s.removePrimaryLocked(e.primaryKey)
+		ok = false
+	}
+	if !ok {
+		p = &primaryEntry{vary: vary, variants: make(map[string]*list.Element)}
+		s.primaries[e.primaryKey] = p
+	}
+	if old, ok := p.variants[e.variantKey]; ok {
+		s.removeLocked(old)
+		// Removing the only variant drops the primary entry as well.
+		s.primaries[e.primaryKey] = p
+	}
+	p.variants[e.variantKey] = s.lru.PushFront(e)
+	s.sizeBytes += size
+	for s.sizeBytes > s.maxBytes {
+		s.removeLocked(s.lru.Back())
+	}
+	cacheSizeBytes.Set(float64(s.sizeBytes))
+	cacheEntries.Set(float64(s.lru.Len()))
+	return true
+}
+
+// invalidate removes all variants of the resource.
+func (s *Store) invalidate(primaryKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removePrimaryLocked(primaryKey)
+	cacheSizeBytes.Set(float64(s.sizeBytes))
+	cacheEntries.Set(float64(s.lru.Len()))
+}
+
+func (s *Store) removePrimaryLocked(primaryKey string) {
+	p, ok := s.primaries[primaryKey]
+	if !ok {
+		return
+	}
+	for _, elem := range p.variants {
+		s.removeLocked(elem)
+	}
+}
+
+func (s *Store) removeLocked(elem *list.Element) {
+	e := s.lru.Remove(elem).(*entry)
+	s.sizeBytes -= e.size()
+	p := s.primaries[e.primaryKey]
+	delete(p.variants, e.variantKey)
+	if len(p.variants) == 0 {
+		delete(s.primaries, e.primaryKey)
+	}
+}
+
+func stringsEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+// Package compression implements on-the-fly, per-route compression (brotli or gzip) of backend responses, and
+// decompression of responses that backends compressed for clients that don't accept the encoding.
+package compression
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+)
+
+const (
+	policyMarker = "compression_policy_marker"
+
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+var (
+	// supportedEncodings are in order of preference.
+	supportedEncodings = []string{encodingBrotli, encodingGzip}
+
+	defaultContentTypes = []string{
+		"text/*",
+		"application/json",
+		"application/javascript",
+		"application/xml",
+		"image/svg+xml",
+	}
+)
+
+// WithPolicy marks the request to be served using the given route compression policy.
+func WithPolicy(req *http.Request, policy *pb.Compression) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), policyMarker, policy))
+}
+
+func policyFromRequest(req *http.Request) *pb.Compression {
+	policy, _ := req.Context().Value(policyMarker).(*pb.Compression)
+	return policy
+}
+
+// ResponseWriter compresses responses with the encoding negotiated from the request Accept-Encoding header.
+// Close needs to be called after the response is written.
+type ResponseWriter struct {
+	http.ResponseWriter
+	policy   *pb.Compression
+	encoding string
+
+	wroteHeader bool
+	encoder     encoder
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// NewResponseWriter wraps the response writer, so the response to req is compressed according to the policy.
+func NewResponseWriter(resp http.ResponseWriter, req *http.Request, policy *pb.Compression) *ResponseWriter {
+	return &ResponseWriter{
+		ResponseWriter: resp,
+		policy:         policy,
+		encoding:       negotiateEncoding(req.Header.Get("Accept-Encoding")),
+	}
+}
+
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.Header()
+	if w.isCompressible(code, header) {
+		header.Add("Vary", "Accept-Encoding")
+		if w.encoding != "" {
+			header.Del("Content-Length")
+			header.Set("Content-Encoding", w.encoding)
+			weakenETag(header)
+			w.encoder = newEncoder(w.encoding, w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseWriter) isCompressible(code int, header http.Header) bool {
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified ||
+		code == http.StatusPartialContent {
+		return false
+	}
+	if enc := header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return false
+	}
+	if !contentTypeMatches(header.Get("Content-Type"), w.policy.ContentTypes) {
+		return false
+	}
+	if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && length < int64(w.policy.MinSizeBytes) {
+		return false
+	}
+	return true
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the data buffered by the encoder, so streaming (e.g. reverse proxy FlushInterval) works.
+func (w *ResponseWriter) Flush() {
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *ResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// Close finishes the compressed stream. It does not close the underlying response writer.
+func (w *ResponseWriter) Close() error {
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+func newEncoder(encoding string, w io.Writer) encoder {
+	if encoding == encodingBrotli {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	}
+	return gzip.NewWriter(w)
+}
+
+// weakenETag marks strong ETag as weak, since the compressed representation is not byte-equal to the original one.
+func weakenETag(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+func contentTypeMatches(contentType string, patterns []string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if len(patterns) == 0 {
+		patterns = defaultContentTypes
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == mediaType || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the supported encoding with the highest q-value in Accept-Encoding, or "" if none is
+// acceptable.
+func negotiateEncoding(acceptEncoding string) string {
+	qvalues := parseAcceptEncoding(acceptEncoding)
+	best, bestQ := "", 0.0
+	for _, encoding := range supportedEncodings {
+		if q := encodingQValue(qvalues, encoding); q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// acceptsEncoding returns true if the Accept-Encoding allows given content coding.
+func acceptsEncoding(acceptEncoding string, encoding string) bool {
+	return encodingQValue(parseAcceptEncoding(acceptEncoding), encoding) > 0
+}
+
+func encodingQValue(qvalues map[string]float64, encoding string) float64 {
+	if q, ok := qvalues[encoding]; ok {
+		return q
+	}
+	return qvalues["*"]
+}
+
+func parseAcceptEncoding(acceptEncoding string) map[string]float64 {
+	qvalues := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qvalues[name] = q
+	}
+	return qvalues
+}
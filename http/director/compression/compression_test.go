@@ -0,0 +1,170 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBody = strings.Repeat(`{"some": "json"}`, 100)
+
+func serveCompressed(t *testing.T, acceptEncoding string, policy *pb.Compression, header http.Header, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "http://backend_a/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec, req, policy)
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body))
+	require.NoError(t, w.Close())
+	return rec
+}
+
+func TestResponseWriter_NegotiatesEncoding(t *testing.T) {
+	jsonHeader := http.Header{"Content-Type": {"application/json; charset=utf-8"}, "Etag": {`"v1"`}}
+	for _, tcase := range []struct {
+		acceptEncoding   string
+		expectedEncoding string
+	}{
+		{acceptEncoding: "gzip, deflate", expectedEncoding: "gzip"},
+		{acceptEncoding: "gzip, deflate, br", expectedEncoding: "br"},
+		{acceptEncoding: "br;q=0.5, gzip", expectedEncoding: "gzip"},
+		{acceptEncoding: "*", expectedEncoding: "br"},
+		{acceptEncoding: "gzip;q=0, identity", expectedEncoding: ""},
+		{acceptEncoding: "", expectedEncoding: ""},
+	} {
+		rec := serveCompressed(t, tcase.acceptEncoding, &pb.Compression{}, jsonHeader, testBody)
+		assert.Equal(t, tcase.expectedEncoding, rec.Header().Get("Content-Encoding"), "case %v", tcase)
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"), "case %v", tcase)
+
+		var body []byte
+		var err error
+		switch tcase.expectedEncoding {
+		case "gzip":
+			gzipReader, gzErr := gzip.NewReader(rec.Body)
+			require.NoError(t, gzErr)
+			body, err = ioutil.ReadAll(gzipReader)
+			assert.Equal(t, `W/"v1"`, rec.Header().Get("Etag"))
+		case "br":
+			body, err = ioutil.ReadAll(brotli.NewReader(rec.Body))
+		default:
+			body, err = ioutil.ReadAll(rec.Body)
+			assert.Equal(t, `"v1"`, rec.Header().Get("Etag"))
+		}
+		require.NoError(t, err)
+		assert.Equal(t, testBody, string(body), "case %v", tcase)
+	}
+}
+
+func TestResponseWriter_SkipsNotMatchingResponses(t *testing.T) {
+	for _, tcase := range []struct {
+		name   string
+		policy *pb.Compression
+		header http.Header
+	}{
+		{name: "Image", policy: &pb.Compression{}, header: http.Header{"Content-Type": {"image/png"}}},
+		{name: "NotConfiguredType", policy: &pb.Compression{ContentTypes: []string{"text/*"}}, header: http.Header{"Content-Type": {"application/json"}}},
+		{name: "TooSmall", policy: &pb.Compression{MinSizeBytes: 4096}, header: http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"1600"}}},
+		{name: "AlreadyCompressed", policy: &pb.Compression{}, header: http.Header{"Content-Type": {"text/plain"}, "Content-Encoding": {"gzip"}}},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			rec := serveCompressed(t, "gzip", tcase.policy, tcase.header, testBody)
+			assert.Equal(t, tcase.header.Get("Content-Encoding"), rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, testBody, rec.Body.String())
+		})
+	}
+}
+
+func TestResponseWriter_FlushesStreamedData(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://backend_a/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec, req, &pb.Compression{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Write([]byte("data: first event\n\n"))
+	w.Flush()
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	buf := make([]byte, 64)
+	n, _ := gzipReader.Read(buf)
+	assert.Equal(t, "data: first event\n\n", string(buf[:n]), "flushed data should be decodable before the stream ends")
+	assert.True(t, rec.Flushed)
+	require.NoError(t, w.Close())
+}
+
+type fakeBackend struct {
+	requests []*http.Request
+	// brotli makes the backend respond with brotli instead of gzip.
+	brotli bool
+}
+
+func (b *fakeBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.requests = append(b.requests, req)
+	buf := &bytes.Buffer{}
+	encoding := "gzip"
+	var encoder io.WriteCloser = gzip.NewWriter(buf)
+	if b.brotli {
+		encoding = "br"
+		encoder = brotli.NewWriter(buf)
+	}
+	encoder.Write([]byte(testBody))
+	encoder.Close()
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Encoding": {encoding}, "Content-Length": {"1"}, "Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(buf),
+		ContentLength: int64(buf.Len()),
+		Request:       req,
+	}, nil
+}
+
+func TestTripper_DecompressesForClientsNotAcceptingEncoding(t *testing.T) {
+	backend := &fakeBackend{}
+	tripper := NewTripper(backend)
+	policy := &pb.Compression{UpstreamCompression: true}
+
+	req, err := http.NewRequest("GET", "http://backend_a/", nil)
+	require.NoError(t, err)
+	resp, err := tripper.RoundTrip(WithPolicy(req, policy))
+	require.NoError(t, err)
+	assert.Equal(t, "br, gzip", backend.requests[0].Header.Get("Accept-Encoding"))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, testBody, string(body))
+
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = tripper.RoundTrip(WithPolicy(req, policy))
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"), "clients accepting the encoding get it as it is")
+
+	req.Header.Del("Accept-Encoding")
+	_, err = tripper.RoundTrip(WithPolicy(req, &pb.Compression{}))
+	require.NoError(t, err)
+	assert.Empty(t, backend.requests[2].Header.Get("Accept-Encoding"), "upstream compression is opt-in")
+
+	backend.brotli = true
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = tripper.RoundTrip(WithPolicy(req, policy))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"), "brotli should be decompressed for clients accepting only gzip")
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, testBody, string(body))
+}
@@ -0,0 +1,79 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/pkg/errors"
+)
+
+// NewTripper returns a RoundTripper that, for requests marked with WithPolicy with upstream_compression, asks the
+// backend for a compressed response and decompresses it if the client does not accept the used encoding.
+func NewTripper(next http.RoundTripper) http.RoundTripper {
+	return &tripper{next: next}
+}
+
+type tripper struct {
+	next http.RoundTripper
+}
+
+func (t *tripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := policyFromRequest(req)
+	if !policy.GetUpstreamCompression() || req.Header.Get("Range") != "" {
+		return t.next.RoundTrip(req)
+	}
+	clientAcceptEncoding := req.Header.Get("Accept-Encoding")
+	backendReq := *req
+	backendReq.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		backendReq.Header[k] = v
+	}
+	backendReq.Header.Set("Accept-Encoding", strings.Join(supportedEncodings, ", "))
+
+	resp, err := t.next.RoundTrip(&backendReq)
+	if err != nil {
+		return nil, err
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return resp, nil
+	}
+	// Response depends on Accept-Encoding now, even if the backend does not say so (e.g. for caches).
+	if !strings.Contains(strings.ToLower(strings.Join(resp.Header["Vary"], ",")), "accept-encoding") {
+		resp.Header.Add("Vary", "Accept-Encoding")
+	}
+	if acceptsEncoding(clientAcceptEncoding, encoding) {
+		return resp, nil
+	}
+
+	var decoder io.Reader
+	switch encoding {
+	case encodingGzip:
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, errors.Wrap(err, "failed to decompress gzip response from backend")
+		}
+		decoder = gzipReader
+	case encodingBrotli:
+		decoder = brotli.NewReader(resp.Body)
+	default:
+		// Not something we can decode, pass as it is.
+		return resp, nil
+	}
+	resp.Body = &decodedBody{Reader: decoder, Closer: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	weakenETag(resp.Header)
+	return resp, nil
+}
+
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
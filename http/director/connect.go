@@ -0,0 +1,141 @@
+package director
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/mwitkow/kedge/lib/http/tunnel"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	flagConnectIdleTimeout = sharedflags.Set.Duration("http_connect_idle_timeout", 5*time.Minute,
+		"Maximum time a CONNECT tunnel can stay without any traffic in either direction. If 0, tunnels never time out.")
+
+	connectTunnelsStarted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_connect",
+			Name:      "tunnels_started_total",
+			Help:      "Total number of established CONNECT tunnels.",
+		},
+		[]string{"backend"},
+	)
+	connectTunnelsActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "http_connect",
+			Name:      "tunnels_active",
+			Help:      "Number of currently open CONNECT tunnels.",
+		},
+		[]string{"backend"},
+	)
+	connectBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_connect",
+			Name:      "bytes_total",
+			Help:      "Total number of bytes passed through CONNECT tunnels.",
+		},
+		[]string{"backend", "direction"},
+	)
+	connectTunnelsFailed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_connect",
+			Name:      "failed_total",
+			Help:      "Total number of CONNECT requests that failed to establish a tunnel.",
+		},
+		[]string{"backend"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(connectTunnelsStarted)
+	prometheus.MustRegister(connectTunnelsActive)
+	prometheus.MustRegister(connectBytes)
+	prometheus.MustRegister(connectTunnelsFailed)
+}
+
+// serveConnect dials the destination of an already authorized CONNECT request and tunnels raw bytes between the
+// client and the destination. No TLS is terminated on the way, so TLS sessions are passed through end-to-end.
+//
+// HTTP/1.1 connections are hijacked. For HTTP/2 the request stream itself becomes the tunnel.
+func serveConnect(resp http.ResponseWriter, req *http.Request, backendLabel string, dial upgradeDialFunc) {
+	if req.ProtoMajor == 1 {
+		if _, ok := resp.(http.Hijacker); !ok {
+			connectTunnelsFailed.WithLabelValues(backendLabel).Inc()
+			respondWithError(router.NewError(http.StatusBadRequest, "CONNECT not supported over this connection"), req, resp)
+			return
+		}
+	}
+
+	backendConn, err := dial(req)
+	if err == nil && backendConn == nil {
+		// Never splice a nil connection, even if a dialer does not report that there was nothing to dial.
+		err = errors.Errorf("no connection to %s established", backendLabel)
+	}
+	if err != nil {
+		connectTunnelsFailed.WithLabelValues(backendLabel).Inc()
+		respondWithError(err, req, resp)
+		return
+	}
+
+	var clientConn io.ReadWriteCloser
+	if req.ProtoMajor == 1 {
+		conn, buf, err := tunnel.Hijack(resp, *flagConnectIdleTimeout)
+		if err != nil {
+			backendConn.Close()
+			connectTunnelsFailed.WithLabelValues(backendLabel).Inc()
+			respondWithError(err, req, resp)
+			return
+		}
+		if _, err := buf.WriteString("HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			conn.Close()
+			backendConn.Close()
+			connectTunnelsFailed.WithLabelValues(backendLabel).Inc()
+			return
+		}
+		if err := buf.Flush(); err != nil {
+			conn.Close()
+			backendConn.Close()
+			connectTunnelsFailed.WithLabelValues(backendLabel).Inc()
+			return
+		}
+		clientConn = conn
+	} else {
+		stream, err := tunnel.HTTP2Stream(resp, req)
+		if err != nil {
+			backendConn.Close()
+			connectTunnelsFailed.WithLabelValues(backendLabel).Inc()
+			respondWithError(err, req, resp)
+			return
+		}
+		resp.WriteHeader(http.StatusOK)
+		resp.(http.Flusher).Flush()
+		clientConn = stream
+	}
+
+	connectTunnelsStarted.WithLabelValues(backendLabel).Inc()
+	connectTunnelsActive.WithLabelValues(backendLabel).Inc()
+	defer connectTunnelsActive.WithLabelValues(backendLabel).Dec()
+
+	inbound, outbound := tunnel.Splice(clientConn, tunnel.WithIdleTimeout(backendConn, nil, *flagConnectIdleTimeout))
+	connectBytes.WithLabelValues(backendLabel, "inbound").Add(float64(inbound))
+	connectBytes.WithLabelValues(backendLabel, "outbound").Add(float64(outbound))
+}
+
+func (p *Proxy) backendTunnelDialFunc(backend string) upgradeDialFunc {
+	return func(req *http.Request) (net.Conn, error) {
+		dialer, err := p.pool.Dialer(backend)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialTunnel(req)
+	}
+}
@@ -0,0 +1,131 @@
+package director
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/backendpool"
+	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startEchoTCPServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestConnect_TunnelsToBackend(t *testing.T) {
+	backend := startEchoTCPServer(t)
+	defer backend.Close()
+
+	routes := []*pb.Route{
+		{
+			BackendName: "echo",
+			HostMatcher: "echo.ext.example.com",
+			ProxyMode:   pb.ProxyMode_FORWARD_PROXY,
+		},
+	}
+	p := New(&testDialPool{backendAddr: backend.Addr().String()}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "CONNECT echo.ext.example.com:443 HTTP/1.1\r\nHost: echo.ext.example.com:443\r\n\r\n")
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, msg := range []string{"fake client hello", "some more bytes"} {
+		_, err = io.WriteString(conn, msg)
+		require.NoError(t, err)
+		got := make([]byte, len(msg))
+		_, err = io.ReadFull(reader, got)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(got))
+	}
+}
+
+func TestConnect_UnknownDestination_IsRejected(t *testing.T) {
+	p := New(&testDialPool{}, router.NewStatic(nil), adhoc.NewStaticAddresser(nil))
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "CONNECT unknown.example.com:443 HTTP/1.1\r\nHost: unknown.example.com:443\r\n\r\n")
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, "unknown route to service", resp.Header.Get("x-kedge-error"))
+}
+
+// unresolvedDialPool is a pool of backends without any resolved targets.
+type unresolvedDialPool struct {
+	testDialPool
+	err error
+}
+
+func (p *unresolvedDialPool) Dialer(_ string) (backendpool.Dialer, error) {
+	return p, nil
+}
+
+func (p *unresolvedDialPool) DialTunnel(_ *http.Request) (net.Conn, error) {
+	return nil, p.err
+}
+
+func TestConnect_UnresolvedBackend_IsRejected(t *testing.T) {
+	routes := []*pb.Route{
+		{
+			BackendName: "unresolved",
+			HostMatcher: "unresolved.ext.example.com",
+			ProxyMode:   pb.ProxyMode_FORWARD_PROXY,
+		},
+	}
+	for _, dialErr := range []error{errors.New("lb: no resolution available for unresolved"), nil} {
+		p := New(&unresolvedDialPool{err: dialErr}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+		proxy := httptest.NewServer(p)
+
+		conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+		require.NoError(t, err)
+
+		_, err = io.WriteString(conn, "CONNECT unresolved.ext.example.com:443 HTTP/1.1\r\nHost: unresolved.ext.example.com:443\r\n\r\n")
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode, "dial error: %v", dialErr)
+		conn.Close()
+		proxy.Close()
+	}
+}
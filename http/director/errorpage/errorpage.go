@@ -0,0 +1,185 @@
+// Package errorpage renders error responses of kedge itself (as opposed to the ones returned by backends): plain text,
+// JSON problem details (RFC 7807) or per-host HTML templates, depending on what the client accepts.
+package errorpage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/mwitkow/kedge/lib/requestid"
+	"github.com/pkg/errors"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	formatHTML = "html"
+
+	contentTypeProblemJSON = "application/problem+json"
+	defaultTemplateName    = "default"
+	templateExtension      = ".html"
+)
+
+// Config configures the Renderer.
+type Config struct {
+	// DefaultFormat is used for clients that don't ask for JSON or HTML (FormatText or FormatJSON).
+	DefaultFormat string
+	// TemplatesDir is a directory with HTML templates named <host>.html, used for browsers requesting a given host.
+	// default.html, if present, is used for all other hosts. If empty, no HTML error pages are served.
+	TemplatesDir string
+	// HideInternalErrors replaces messages of internal errors (e.g. failed backend resolution) with the status text.
+	HideInternalErrors bool
+}
+
+// Renderer writes error responses.
+type Renderer struct {
+	defaultFormat      string
+	hideInternalErrors bool
+	templates          map[string]*template.Template
+}
+
+// Problem is the JSON body of error responses, as in RFC 7807 with request ID extension.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// TemplateData is passed to HTML error page templates.
+type TemplateData struct {
+	Status     int
+	StatusText string
+	Message    string
+	Host       string
+	RequestID  string
+}
+
+// Default returns Renderer that responds with plain-text error messages unless JSON is requested.
+func Default() *Renderer {
+	return &Renderer{defaultFormat: FormatText}
+}
+
+// New creates Renderer, parsing all HTML templates from the templates directory.
+func New(conf Config) (*Renderer, error) {
+	r := &Renderer{
+		defaultFormat:      conf.DefaultFormat,
+		hideInternalErrors: conf.HideInternalErrors,
+		templates:          map[string]*template.Template{},
+	}
+	if r.defaultFormat == "" {
+		r.defaultFormat = FormatText
+	}
+	if r.defaultFormat != FormatText && r.defaultFormat != FormatJSON {
+		return nil, errors.Errorf("errorpage: unknown default format %q, expected %q or %q", r.defaultFormat, FormatText, FormatJSON)
+	}
+	if conf.TemplatesDir == "" {
+		return r, nil
+	}
+	files, err := ioutil.ReadDir(conf.TemplatesDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "errorpage: failed to read templates directory %v", conf.TemplatesDir)
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != templateExtension {
+			continue
+		}
+		host := strings.ToLower(strings.TrimSuffix(file.Name(), templateExtension))
+		tmpl, err := template.ParseFiles(filepath.Join(conf.TemplatesDir, file.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "errorpage: failed to parse template %v", file.Name())
+		}
+		r.templates[host] = tmpl
+	}
+	return r, nil
+}
+
+// Respond writes error response with the given status. The error is considered internal, unless public is true,
+// and its message is hidden from the client if Renderer is configured to do so.
+func (r *Renderer) Respond(resp http.ResponseWriter, req *http.Request, status int, err error, public bool) {
+	msg := err.Error()
+	if r.hideInternalErrors && !public {
+		msg = http.StatusText(status)
+	}
+	requestID := requestid.FromRequest(req)
+	resp.Header().Set("x-kedge-error", msg)
+
+	switch r.negotiateFormat(req) {
+	case formatHTML:
+		host := requestHost(req)
+		var buf bytes.Buffer
+		execErr := r.template(host).Execute(&buf, TemplateData{
+			Status:     status,
+			StatusText: http.StatusText(status),
+			Message:    msg,
+			Host:       host,
+			RequestID:  requestID,
+		})
+		if execErr == nil {
+			resp.Header().Set("content-type", "text/html; charset=utf-8")
+			resp.WriteHeader(status)
+			resp.Write(buf.Bytes())
+			return
+		}
+		// Broken template must not hide the actual error, fall back to plain text.
+	case FormatJSON:
+		body, jsonErr := json.Marshal(&Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    msg,
+			RequestID: requestID,
+		})
+		if jsonErr == nil {
+			resp.Header().Set("content-type", contentTypeProblemJSON)
+			resp.WriteHeader(status)
+			resp.Write(body)
+			return
+		}
+	}
+	resp.Header().Set("content-type", "text/plain")
+	resp.WriteHeader(status)
+	fmt.Fprintf(resp, "%v", msg)
+}
+
+func (r *Renderer) negotiateFormat(req *http.Request) string {
+	for _, accepted := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case contentTypeProblemJSON, "application/json":
+			return FormatJSON
+		case "text/html":
+			if r.template(requestHost(req)) != nil {
+				return formatHTML
+			}
+		}
+	}
+	return r.defaultFormat
+}
+
+func (r *Renderer) template(host string) *template.Template {
+	if tmpl, ok := r.templates[host]; ok {
+		return tmpl
+	}
+	return r.templates[defaultTemplateName]
+}
+
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
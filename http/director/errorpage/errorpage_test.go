@@ -0,0 +1,89 @@
+package errorpage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mwitkow/kedge/lib/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInternal = errors.New("lb: no resolution available for backend_a")
+
+func respond(t *testing.T, renderer *Renderer, host string, accept string, public bool) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "https://"+host+"/some/path", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", accept)
+	req.Header.Set(requestid.HeaderName, "some-request-id")
+	rec := httptest.NewRecorder()
+	renderer.Respond(rec, req, http.StatusBadGateway, errInternal, public)
+	return rec
+}
+
+func TestRenderer_PlainTextByDefault(t *testing.T) {
+	rec := respond(t, Default(), "backend.ext.example.com", "", false)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, "text/plain", rec.Header().Get("content-type"))
+	assert.Equal(t, errInternal.Error(), rec.Header().Get("x-kedge-error"))
+	assert.Equal(t, errInternal.Error(), rec.Body.String())
+}
+
+func TestRenderer_ProblemJSON(t *testing.T) {
+	for _, accept := range []string{"application/problem+json", "text/plain, application/json;q=0.9"} {
+		rec := respond(t, Default(), "backend.ext.example.com", accept, false)
+		assert.Equal(t, "application/problem+json", rec.Header().Get("content-type"), "accept %v", accept)
+		problem := &Problem{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), problem))
+		assert.Equal(t, &Problem{
+			Type:      "about:blank",
+			Title:     "Bad Gateway",
+			Status:    http.StatusBadGateway,
+			Detail:    errInternal.Error(),
+			RequestID: "some-request-id",
+		}, problem)
+	}
+}
+
+func TestRenderer_HidesInternalErrors(t *testing.T) {
+	renderer, err := New(Config{DefaultFormat: FormatJSON, HideInternalErrors: true})
+	require.NoError(t, err)
+
+	rec := respond(t, renderer, "backend.ext.example.com", "", false)
+	assert.Equal(t, "Bad Gateway", rec.Header().Get("x-kedge-error"))
+	assert.NotContains(t, rec.Body.String(), "lb:")
+
+	rec = respond(t, renderer, "backend.ext.example.com", "", true)
+	assert.Contains(t, rec.Body.String(), errInternal.Error(), "public errors should not be hidden")
+}
+
+func TestRenderer_HTMLTemplatesPerHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "errorpage_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shop.example.com.html"), []byte(`<h1>Shop: {{.Status}} {{.Message}}</h1>`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "default.html"), []byte(`<h1>{{.StatusText}} ({{.RequestID}})</h1>`), 0644))
+	renderer, err := New(Config{TemplatesDir: dir})
+	require.NoError(t, err)
+
+	rec := respond(t, renderer, "Shop.example.com:443", "text/html,application/xhtml+xml", true)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("content-type"))
+	assert.Equal(t, "<h1>Shop: 502 lb: no resolution available for backend_a</h1>", rec.Body.String())
+
+	rec = respond(t, renderer, "other.example.com", "text/html", true)
+	assert.Equal(t, "<h1>Bad Gateway (some-request-id)</h1>", rec.Body.String())
+
+	rec = respond(t, renderer, "other.example.com", "", true)
+	assert.Equal(t, "text/plain", rec.Header().Get("content-type"), "non-browser clients should not get HTML")
+}
+
+func TestNew_FailsOnUnknownFormat(t *testing.T) {
+	_, err := New(Config{DefaultFormat: "xml"})
+	require.Error(t, err)
+}
@@ -0,0 +1,26 @@
+package errorpage
+
+import (
+	"github.com/mwitkow/kedge/lib/sharedflags"
+)
+
+var (
+	fDefaultFormat = sharedflags.Set.String("http_error_default_format", FormatText,
+		"Format of kedge error responses for clients that don't ask for JSON (Accept: application/problem+json or "+
+			"application/json) or HTML. Either 'text' or 'json' (RFC 7807 problem details).")
+	fTemplatesDir = sharedflags.Set.String("http_error_templates_dir", "",
+		"Directory with HTML templates of kedge error pages served to browsers, named <host>.html, with default.html "+
+			"used for other hosts. If empty, no HTML error pages are served.")
+	fHideInternalErrors = sharedflags.Set.Bool("http_error_hide_internal", false,
+		"If true, messages of internal errors (e.g. failed backend resolution) are replaced with the status text in "+
+			"responses to clients. Full errors are still logged.")
+)
+
+// NewFromFlags returns Renderer configured from sharedflags.Set.
+func NewFromFlags() (*Renderer, error) {
+	return New(Config{
+		DefaultFormat:      *fDefaultFormat,
+		TemplatesDir:       *fTemplatesDir,
+		HideInternalErrors: *fHideInternalErrors,
+	})
+}
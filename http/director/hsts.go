@@ -0,0 +1,55 @@
+package director
+
+import (
+	"fmt"
+	"net/http"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+)
+
+const hstsHeader = "Strict-Transport-Security"
+
+func hstsHeaderValue(policy *pb.Hsts) string {
+	value := fmt.Sprintf("max-age=%d", policy.MaxAgeSec)
+	if policy.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if policy.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// hstsResponseWriter sets the HSTS header just before the response headers are written, so it overrides the one that
+// may have been set by the backend.
+type hstsResponseWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (w *hstsResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.ResponseWriter.Header().Set(hstsHeader, w.value)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *hstsResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *hstsResponseWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *hstsResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
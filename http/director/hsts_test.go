@@ -0,0 +1,58 @@
+package director
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHsts_SetOnlyForConfiguredRoutesOverTLS(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Strict-Transport-Security", "max-age=1")
+		resp.WriteHeader(http.StatusTeapot)
+	}))
+	defer backend.Close()
+	routes := []*pb.Route{
+		{
+			BackendName: "hsts",
+			HostMatcher: "hsts.ext.example.com",
+			Hsts:        &pb.Hsts{MaxAgeSec: 31536000, IncludeSubdomains: true},
+		},
+		{
+			BackendName: "nohsts",
+			HostMatcher: "nohsts.ext.example.com",
+		},
+	}
+	p := New(&testDialPool{backendAddr: backend.Listener.Addr().String()}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+	tlsProxy := httptest.NewTLSServer(p)
+	defer tlsProxy.Close()
+	plainProxy := httptest.NewServer(p)
+	defer plainProxy.Close()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	for _, tcase := range []struct {
+		url          string
+		host         string
+		expectedHsts string
+	}{
+		{url: tlsProxy.URL, host: "hsts.ext.example.com", expectedHsts: "max-age=31536000; includeSubDomains"},
+		{url: tlsProxy.URL, host: "nohsts.ext.example.com", expectedHsts: "max-age=1"},
+		{url: plainProxy.URL, host: "hsts.ext.example.com", expectedHsts: "max-age=1"},
+	} {
+		req, err := http.NewRequest("GET", tcase.url, nil)
+		require.NoError(t, err)
+		req.Host = tcase.host
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusTeapot, resp.StatusCode, "case %v", tcase)
+		assert.Equal(t, []string{tcase.expectedHsts}, resp.Header["Strict-Transport-Security"], "case %v", tcase)
+	}
+}
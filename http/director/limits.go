@@ -0,0 +1,187 @@
+package director
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/director/router"
+)
+
+const (
+	defaultUploadGracePeriod = 10 * time.Second
+
+	limitsMarker = "request_limits_marker"
+)
+
+var (
+	errBodyTooLarge   = router.NewError(http.StatusRequestEntityTooLarge, "request body too large")
+	errHeaderTooLarge = router.NewError(http.StatusRequestHeaderFieldsTooLarge, "request header fields too large")
+	errUploadTooSlow  = router.NewError(http.StatusRequestTimeout, "request body upload too slow")
+
+	// uploadRateCheckInterval is how often the upload rate of request bodies is checked.
+	uploadRateCheckInterval = 1 * time.Second
+)
+
+// checkRequestLimits returns an error if the request is known to violate the limits before reading its body.
+func checkRequestLimits(req *http.Request, limits *pb.RequestLimits) error {
+	if limits.MaxBodyBytes > 0 && req.ContentLength > int64(limits.MaxBodyBytes) {
+		return errBodyTooLarge
+	}
+	if limits.MaxHeaderBytes > 0 && headerSize(req.Header) > int(limits.MaxHeaderBytes) {
+		return errHeaderTooLarge
+	}
+	return nil
+}
+
+func headerSize(header http.Header) int {
+	size := 0
+	for k, vals := range header {
+		for _, v := range vals {
+			size += len(k) + len(v)
+		}
+	}
+	return size
+}
+
+// limitedBody enforces body size and upload rate limits while the request body is read. On violation, the request
+// context is cancelled, so the backend round trip is aborted (also when the client does not send anything at all).
+type limitedBody struct {
+	io.ReadCloser
+	limits *pb.RequestLimits
+	cancel context.CancelFunc
+	start  time.Time
+
+	mu        sync.Mutex
+	read      int64
+	done      bool
+	violation error
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// newLimitedBody wraps the body of the request and returns the request to be proxied, which is cancelled on
+// violation. Stop needs to be called once the request is served.
+func newLimitedBody(req *http.Request, limits *pb.RequestLimits) (*limitedBody, *http.Request) {
+	ctx, cancel := context.WithCancel(req.Context())
+	b := &limitedBody{ReadCloser: req.Body, limits: limits, cancel: cancel, start: time.Now(), stop: make(chan struct{})}
+	limitedReq := req.WithContext(context.WithValue(ctx, limitsMarker, b))
+	if req.Body != nil {
+		limitedReq.Body = b
+	}
+	if limits.MinUploadRateBytesPerSec > 0 && req.Body != nil && req.ContentLength != 0 {
+		go b.watchUploadRate(uploadRateCheckInterval)
+	}
+	return b, limitedReq
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.read += int64(n)
+	if b.limits.MaxBodyBytes > 0 && b.read > int64(b.limits.MaxBodyBytes) {
+		b.violateLocked(errBodyTooLarge)
+		return n, errBodyTooLarge
+	}
+	if b.violation != nil {
+		return n, b.violation
+	}
+	if err == io.EOF {
+		b.done = true
+	}
+	return n, err
+}
+
+// Close does not close the inbound body after a violation, since that would drain the rest of it from the client.
+func (b *limitedBody) Close() error {
+	if b.Violation() != nil {
+		return nil
+	}
+	return b.ReadCloser.Close()
+}
+
+func (b *limitedBody) watchUploadRate(checkInterval time.Duration) {
+	gracePeriod := time.Duration(b.limits.UploadGracePeriodSec) * time.Second
+	if gracePeriod == 0 {
+		gracePeriod = defaultUploadGracePeriod
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-b.stop:
+			return
+		}
+		b.mu.Lock()
+		elapsed := now.Sub(b.start)
+		if b.done || b.violation != nil {
+			b.mu.Unlock()
+			return
+		}
+		if elapsed > gracePeriod && float64(b.read)/elapsed.Seconds() < float64(b.limits.MinUploadRateBytesPerSec) {
+			b.violateLocked(errUploadTooSlow)
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *limitedBody) violateLocked(err error) {
+	if b.violation == nil {
+		b.violation = err
+		b.cancel()
+	}
+}
+
+// Violation returns the limit violated by the request, if any.
+func (b *limitedBody) Violation() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.violation
+}
+
+// limitViolation returns the limit violated by the request, if it was wrapped by newLimitedBody.
+func limitViolation(req *http.Request) error {
+	if b, ok := req.Context().Value(limitsMarker).(*limitedBody); ok {
+		return b.Violation()
+	}
+	return nil
+}
+
+// Stop stops enforcing the limits. It needs to be called after the request is served.
+func (b *limitedBody) Stop() {
+	b.stopOnce.Do(func() { close(b.stop) })
+	b.cancel()
+}
+
+// limitsResponseWriter closes the client connection after a limit is violated, since the rest of the request body is
+// not read. The violation itself is rendered by errorRenderingTripper.
+type limitsResponseWriter struct {
+	http.ResponseWriter
+	body *limitedBody
+}
+
+func (w *limitsResponseWriter) WriteHeader(code int) {
+	if w.body.Violation() != nil {
+		w.Header().Set("Connection", "close")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitsResponseWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *limitsResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
@@ -0,0 +1,120 @@
+package director
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startLimitsTestProxy(t *testing.T) (proxy *httptest.Server, backendCalls *int32, cleanup func()) {
+	calls := int32(0)
+	backend := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if _, err := ioutil.ReadAll(req.Body); err != nil {
+			return
+		}
+		resp.WriteHeader(http.StatusOK)
+	}))
+	routes := []*pb.Route{
+		{
+			BackendName: "limited",
+			HostMatcher: "limited.ext.example.com",
+			RequestLimits: &pb.RequestLimits{
+				MaxBodyBytes:             1024,
+				MaxHeaderBytes:           512,
+				MinUploadRateBytesPerSec: 100,
+				UploadGracePeriodSec:     1,
+			},
+		},
+	}
+	p := New(&testDialPool{backendAddr: backend.Listener.Addr().String()}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+	proxy = httptest.NewServer(p)
+	return proxy, &calls, func() {
+		proxy.Close()
+		backend.Close()
+	}
+}
+
+func TestRequestLimits(t *testing.T) {
+	proxy, backendCalls, cleanup := startLimitsTestProxy(t)
+	defer cleanup()
+
+	for _, tcase := range []struct {
+		name                string
+		body                io.Reader
+		contentLength       int64
+		header              http.Header
+		expectedStatus      int
+		expectedBackendCall bool
+	}{
+		{name: "WithinLimits", body: strings.NewReader(strings.Repeat("a", 1024)), contentLength: 1024, expectedStatus: http.StatusOK, expectedBackendCall: true},
+		{name: "ContentLengthTooLarge", body: strings.NewReader(strings.Repeat("a", 1025)), contentLength: 1025, expectedStatus: http.StatusRequestEntityTooLarge},
+		// Chunked body is discovered to be too large only while it is sent to the backend.
+		{name: "ChunkedBodyTooLarge", body: ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 4096))), contentLength: -1, expectedStatus: http.StatusRequestEntityTooLarge, expectedBackendCall: true},
+		{name: "HeaderTooLarge", header: http.Header{"X-Big": {strings.Repeat("a", 600)}}, expectedStatus: http.StatusRequestHeaderFieldsTooLarge},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			atomic.StoreInt32(backendCalls, 0)
+			req, err := http.NewRequest("POST", proxy.URL, tcase.body)
+			require.NoError(t, err)
+			req.Host = "limited.ext.example.com"
+			req.ContentLength = tcase.contentLength
+			req.Header.Set("Accept", "application/problem+json")
+			for k, v := range tcase.header {
+				req.Header[k] = v
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tcase.expectedStatus, resp.StatusCode)
+			if tcase.expectedStatus != http.StatusOK {
+				assert.Equal(t, "application/problem+json", resp.Header.Get("content-type"), "limit errors should be rendered by ErrorRenderer")
+			}
+			if !tcase.expectedBackendCall {
+				assert.Equal(t, int32(0), atomic.LoadInt32(backendCalls), "request should be rejected before reaching the backend")
+			}
+		})
+	}
+}
+
+func TestRequestLimits_SlowUpload(t *testing.T) {
+	defaultInterval := uploadRateCheckInterval
+	uploadRateCheckInterval = 50 * time.Millisecond
+	defer func() { uploadRateCheckInterval = defaultInterval }()
+	proxy, _, cleanup := startLimitsTestProxy(t)
+	defer cleanup()
+
+	bodyReader, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	go func() {
+		// 10 bytes per second is way below required 100 bytes per second.
+		for i := 0; i < 50; i++ {
+			if _, err := bodyWriter.Write([]byte("a")); err != nil {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	req, err := http.NewRequest("POST", proxy.URL, bodyReader)
+	require.NoError(t, err)
+	req.Host = "limited.ext.example.com"
+	req.Header.Set("Accept", "application/problem+json")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("content-type"))
+	assert.True(t, time.Since(start) < 3*time.Second, "slow upload should be aborted shortly after grace period")
+}
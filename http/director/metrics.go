@@ -0,0 +1,158 @@
+package director
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mwitkow/go-httpwares"
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Server metrics are named after the grpc_prometheus ones (grpc_server_handled_total, grpc_server_handling_seconds).
+var (
+	serverHandled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_server",
+			Name:      "handled_total",
+			Help:      "Total number of HTTP requests completed by the proxy, regardless of success or failure.",
+		},
+		[]string{"backend", "route", "adhoc", "method", "code"},
+	)
+	serverHandlingSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "kedge",
+			Subsystem: "http_server",
+			Name:      "handling_seconds",
+			Help:      "Histogram of response latency (seconds) of HTTP requests handled by the proxy.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"backend", "route", "adhoc"},
+	)
+	serverInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "http_server",
+			Name:      "in_flight",
+			Help:      "Number of HTTP requests currently handled by the proxy. Requests not routed yet have empty labels.",
+		},
+		[]string{"backend", "route", "adhoc"},
+	)
+	serverRequestBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_server",
+			Name:      "request_bytes_total",
+			Help:      "Total number of HTTP request body bytes received by the proxy.",
+		},
+		[]string{"backend", "route", "adhoc"},
+	)
+	serverResponseBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_server",
+			Name:      "response_bytes_total",
+			Help:      "Total number of HTTP response body bytes sent by the proxy.",
+		},
+		[]string{"backend", "route", "adhoc"},
+	)
+	authFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_auth",
+			Name:      "failures_total",
+			Help:      "Total number of HTTP requests rejected by proxy authorization.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(serverHandled)
+	prometheus.MustRegister(serverHandlingSeconds)
+	prometheus.MustRegister(serverInFlight)
+	prometheus.MustRegister(serverRequestBytes)
+	prometheus.MustRegister(serverResponseBytes)
+	prometheus.MustRegister(authFailures)
+}
+
+const inFlightMarker = "http_server_in_flight_marker"
+
+// inFlight holds labels the request is currently counted under in serverInFlight.
+type inFlight struct {
+	labels []string
+}
+
+// MetricsMiddleware reports server metrics of the HTTP proxy, labeled with the backend and route (or "_adhoc" and the
+// matched rule for adhoc rules) the request was routed to.
+//
+// It needs to be placed after http_ctxtags.Middleware.
+func MetricsMiddleware() httpwares.Middleware {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			current := &inFlight{labels: []string{"", "", ""}}
+			serverInFlight.WithLabelValues(current.labels...).Inc()
+			defer func() { serverInFlight.WithLabelValues(current.labels...).Dec() }()
+			req = req.WithContext(context.WithValue(req.Context(), inFlightMarker, current))
+			start := time.Now()
+			var body *countingBody
+			if req.Body != nil {
+				body = &countingBody{ReadCloser: req.Body}
+				req.Body = body
+			}
+			wrapped := httpwares.WrapResponseWriter(resp)
+
+			nextHandler.ServeHTTP(wrapped, req)
+
+			backend, route, adhoc := metricsLabels(req)
+			serverHandled.WithLabelValues(backend, route, adhoc, req.Method, strconv.Itoa(wrapped.StatusCode())).Inc()
+			serverHandlingSeconds.WithLabelValues(backend, route, adhoc).Observe(time.Since(start).Seconds())
+			serverResponseBytes.WithLabelValues(backend, route, adhoc).Add(float64(wrapped.MessageLength()))
+			if body != nil {
+				serverRequestBytes.WithLabelValues(backend, route, adhoc).Add(float64(atomic.LoadInt64(&body.read)))
+			}
+		})
+	}
+}
+
+// markRouted moves the request in serverInFlight under labels of the backend or adhoc rule it was routed to. It is
+// called by the proxy once it tagged the request, and does nothing if MetricsMiddleware is not used.
+func markRouted(req *http.Request) {
+	current, ok := req.Context().Value(inFlightMarker).(*inFlight)
+	if !ok {
+		return
+	}
+	backend, route, adhoc := metricsLabels(req)
+	serverInFlight.WithLabelValues(current.labels...).Dec()
+	current.labels = []string{backend, route, adhoc}
+	serverInFlight.WithLabelValues(current.labels...).Inc()
+}
+
+func metricsLabels(req *http.Request) (backend string, route string, adhoc string) {
+	values := http_ctxtags.ExtractInbound(req).Values()
+	if b, ok := values[ctxtags.TagForProxyBackend].(string); ok {
+		route, _ = values[ctxtags.TagForProxyRoute].(string)
+		return b, route, ""
+	}
+	if rule, ok := values[ctxtags.TagForProxyAdhocRule].(string); ok {
+		// Requested host names and resolved addresses (e.g. pod IPs) would be too many, rules are bounded by config.
+		return "_adhoc", "", rule
+	}
+	return "", "", ""
+}
+
+type countingBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.read, int64(n))
+	return n, err
+}
@@ -0,0 +1,94 @@
+package director
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mwitkow/go-httpwares/tags"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	require.NoError(t, c.Write(m))
+	return m.GetCounter().GetValue()
+}
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	m := &dto.Metric{}
+	require.NoError(t, g.Write(m))
+	return m.GetGauge().GetValue()
+}
+
+func TestMetricsMiddleware_LabelsWithRoutedBackend(t *testing.T) {
+	inFlight := serverInFlight.WithLabelValues("metrics_backend", "metrics.ext.example.com/*", "")
+	var inFlightDuringRequest float64
+	backend := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		inFlightDuringRequest = gaugeValue(t, inFlight)
+		resp.WriteHeader(http.StatusTeapot)
+		resp.Write([]byte("hello"))
+	}))
+	defer backend.Close()
+	routes := []*pb.Route{{BackendName: "metrics_backend", HostMatcher: "metrics.ext.example.com"}}
+	p := New(&testDialPool{backendAddr: backend.Listener.Addr().String()}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+	handler := http_ctxtags.Middleware("proxy")(MetricsMiddleware()(p))
+
+	handled := serverHandled.WithLabelValues("metrics_backend", "metrics.ext.example.com/*", "", "POST", "418")
+	requestBytes := serverRequestBytes.WithLabelValues("metrics_backend", "metrics.ext.example.com/*", "")
+	responseBytes := serverResponseBytes.WithLabelValues("metrics_backend", "metrics.ext.example.com/*", "")
+	handledBefore, requestBefore, responseBefore := counterValue(t, handled), counterValue(t, requestBytes), counterValue(t, responseBytes)
+	notRouted := serverInFlight.WithLabelValues("", "", "")
+	notRoutedBefore := gaugeValue(t, notRouted)
+
+	req := httptest.NewRequest("POST", "http://metrics.ext.example.com/some/path", strings.NewReader("some body"))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusTeapot, resp.Code)
+	assert.Equal(t, float64(1), counterValue(t, handled)-handledBefore)
+	assert.Equal(t, float64(len("some body")), counterValue(t, requestBytes)-requestBefore)
+	assert.Equal(t, float64(len("hello")), counterValue(t, responseBytes)-responseBefore)
+	assert.Equal(t, float64(1), inFlightDuringRequest, "routed request should be in flight under its backend and route")
+	assert.Equal(t, float64(0), gaugeValue(t, inFlight))
+	assert.Equal(t, notRoutedBefore, gaugeValue(t, notRouted))
+}
+
+func TestMetricsMiddleware_LabelsAdhocWithMatchedRule(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusTeapot)
+	}))
+	defer backend.Close()
+	_, port, err := net.SplitHostPort(backend.Listener.Addr().String())
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	defaultLookup := adhoc.DefaultALookup
+	adhoc.DefaultALookup = func(string) ([]string, error) { return []string{"127.0.0.1"}, nil }
+	defer func() { adhoc.DefaultALookup = defaultLookup }()
+	rules := []*pb.Adhoc{{DnsNameMatcher: "*.pods.cluster.local", Port: &pb.Adhoc_Port{Allowed: []uint32{uint32(portNum)}}}}
+	p := New(&testDialPool{}, router.NewStatic(nil), adhoc.NewStaticAddresser(rules))
+	handler := http_ctxtags.Middleware("proxy")(MetricsMiddleware()(p))
+
+	handled := serverHandled.WithLabelValues("_adhoc", "", "*.pods.cluster.local", "GET", "418")
+	handledBefore := counterValue(t, handled)
+
+	for _, pod := range []string{"pod-a", "pod-b"} {
+		req := httptest.NewRequest("GET", "http://"+pod+".pods.cluster.local:"+port+"/some/path", nil)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusTeapot, resp.Code)
+	}
+	assert.Equal(t, float64(2), counterValue(t, handled)-handledBefore, "requests to different hosts should be counted under the same rule")
+}
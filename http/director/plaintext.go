@@ -0,0 +1,61 @@
+package director
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/mwitkow/go-httpwares"
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/http/director/proxyreq"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+)
+
+var (
+	flagRedirectHttpsPort = sharedflags.Set.Int("http_redirect_https_port", 443,
+		"Port put into the HTTPS URL when redirecting plain-text requests to HTTPS. It should be the publicly visible "+
+			"port of server_http_tls_port.")
+)
+
+// PlaintextListenerMiddleware marks requests as received on the plain-text (non-TLS) proxy listener. Only routes with
+// allow_plaintext (or redirect_to_https) are served for such requests and adhoc rules are not used at all.
+func PlaintextListenerMiddleware() httpwares.Middleware {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			nextHandler.ServeHTTP(resp, proxyreq.MarkPlaintextListener(req))
+		})
+	}
+}
+
+const acmeChallengePathPrefix = "/.well-known/acme-challenge/"
+
+// RedirectHandler redirects all requests to HTTPS, apart from ACME HTTP-01 challenges (/.well-known/acme-challenge/)
+// that are served by acmeHandler. If acmeHandler is nil, challenges are redirected as well.
+func RedirectHandler(acmeHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if acmeHandler != nil && strings.HasPrefix(req.URL.Path, acmeChallengePathPrefix) {
+			http_ctxtags.ExtractInbound(req).Set(http_ctxtags.TagForHandlerName, "_acme_challenge")
+			acmeHandler.ServeHTTP(resp, req)
+			return
+		}
+		redirectToHTTPS(resp, proxyreq.NormalizeInboundRequest(req))
+	})
+}
+
+// redirectToHTTPS responds with a permanent redirect to the same URL using https scheme.
+func redirectToHTTPS(resp http.ResponseWriter, req *http.Request) {
+	http_ctxtags.ExtractInbound(req).Set(http_ctxtags.TagForHandlerName, "_redirect_https")
+	target := *req.URL
+	target.Scheme = "https"
+	target.Host = req.URL.Hostname()
+	if *flagRedirectHttpsPort != 443 {
+		target.Host = net.JoinHostPort(target.Host, fmt.Sprintf("%d", *flagRedirectHttpsPort))
+	}
+	status := http.StatusMovedPermanently
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		// Other methods would be changed to GET by clients on 301.
+		status = http.StatusPermanentRedirect
+	}
+	http.Redirect(resp, req, target.String(), status)
+}
@@ -0,0 +1,95 @@
+package director
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaintextListener_RoutesOptIn(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusTeapot)
+	}))
+	defer backend.Close()
+	routes := []*pb.Route{
+		{
+			BackendName:    "plain",
+			HostMatcher:    "plain.ext.example.com",
+			AllowPlaintext: true,
+		},
+		{
+			BackendName:     "redirected",
+			HostMatcher:     "redirected.ext.example.com",
+			RedirectToHttps: true,
+		},
+		{
+			BackendName: "tlsonly",
+			HostMatcher: "tlsonly.ext.example.com",
+		},
+	}
+	p := New(&testDialPool{backendAddr: backend.Listener.Addr().String()}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+	proxy := httptest.NewServer(PlaintextListenerMiddleware()(p))
+	defer proxy.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, tcase := range []struct {
+		host             string
+		method           string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{host: "plain.ext.example.com", method: "GET", expectedStatus: http.StatusTeapot},
+		{host: "redirected.ext.example.com", method: "GET", expectedStatus: http.StatusMovedPermanently, expectedLocation: "https://redirected.ext.example.com/some/path?q=1"},
+		{host: "redirected.ext.example.com", method: "POST", expectedStatus: http.StatusPermanentRedirect, expectedLocation: "https://redirected.ext.example.com/some/path?q=1"},
+		{host: "tlsonly.ext.example.com", method: "GET", expectedStatus: http.StatusBadGateway},
+	} {
+		req, err := http.NewRequest(tcase.method, proxy.URL+"/some/path?q=1", nil)
+		require.NoError(t, err)
+		req.Host = tcase.host
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tcase.expectedStatus, resp.StatusCode, "case %v", tcase)
+		assert.Equal(t, tcase.expectedLocation, resp.Header.Get("Location"), "case %v", tcase)
+	}
+}
+
+func TestRedirectHandler_ServesAcmeChallenges(t *testing.T) {
+	acmeHandler := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(RedirectHandler(acmeHandler))
+	defer srv.Close()
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("GET", srv.URL+"/.well-known/acme-challenge/sometoken", nil)
+	require.NoError(t, err)
+	req.Host = "public.example.com"
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err = http.NewRequest("GET", srv.URL+"/index.html", nil)
+	require.NoError(t, err)
+	req.Host = "public.example.com"
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, "https://public.example.com/index.html", resp.Header.Get("Location"))
+}
@@ -1,7 +1,9 @@
 package director
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -11,13 +13,21 @@ import (
 	"github.com/mwitkow/go-conntrack"
 	"github.com/mwitkow/go-httpwares"
 	"github.com/mwitkow/go-httpwares/tags"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
 	"github.com/mwitkow/kedge/http/backendpool"
 	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/cache"
+	"github.com/mwitkow/kedge/http/director/compression"
+	"github.com/mwitkow/kedge/http/director/errorpage"
 	"github.com/mwitkow/kedge/http/director/proxyreq"
 	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/mwitkow/kedge/lib/auditlog"
 	"github.com/mwitkow/kedge/lib/http/ctxtags"
 	"github.com/mwitkow/kedge/lib/http/tripperware"
+	"github.com/mwitkow/kedge/lib/loglevel"
 	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/mwitkow/kedge/lib/tracing"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/oxtoacart/bpool"
 	"github.com/sirupsen/logrus"
 )
@@ -39,6 +49,14 @@ var (
 	flagBufferSizeBytes  = sharedflags.Set.Int("http_reverseproxy_buffer_size_bytes", 32*1024, "Size (bytes) of reusable buffer used for copying HTTP reverse proxy responses.")
 	flagBufferCount      = sharedflags.Set.Int("http_reverseproxy_buffer_count", 2*1024, "Maximum number of of reusable buffer used for copying HTTP reverse proxy responses.")
 	flagFlushingInterval = sharedflags.Set.Duration("http_reverseproxy_flushing_interval", 10*time.Millisecond, "Interval for flushing the responses in HTTP reverse proxy code.")
+
+	// ErrorRenderer writes responses for errors of the proxy itself (e.g. routing or auth errors).
+	ErrorRenderer = errorpage.Default()
+
+	flagCacheMaxSizeBytes       = sharedflags.Set.Int64("http_cache_max_size_bytes", 64*1024*1024, "Maximum total size (bytes) of backend responses kept in memory by response cache of routes with cache enabled.")
+	flagCacheMaxObjectSizeBytes = sharedflags.Set.Int64("http_cache_max_object_size_bytes", 1024*1024, "Maximum size (bytes) of a single backend response to be stored in response cache.")
+
+	authLogger = loglevel.Subsystem(loglevel.Auth)
 )
 
 // New creates a forward/reverse proxy that is either Route+Backend and Adhoc Rules forwarding.
@@ -53,16 +71,17 @@ func New(pool backendpool.Pool, router router.Router, addresser adhoc.Addresser)
 	p := &Proxy{
 		backendReverseProxy: &httputil.ReverseProxy{
 			Director:      func(r *http.Request) {},
-			Transport:     &backendPoolTripper{pool: pool},
+			Transport:     &errorRenderingTripper{parent: cache.NewTripper(compression.NewTripper(&upstreamTimingTripper{parent: &backendPoolTripper{pool: pool}}), cache.NewStore(*flagCacheMaxSizeBytes, *flagCacheMaxObjectSizeBytes))},
 			FlushInterval: *flagFlushingInterval,
 			BufferPool:    bufferpool,
 		},
 		adhocReverseProxy: &httputil.ReverseProxy{
 			Director:      func(r *http.Request) {},
-			Transport:     AdhocTransport,
+			Transport:     &errorRenderingTripper{parent: &upstreamTimingTripper{parent: tracing.WrapTransport(AdhocTransport, "_adhoc")}},
 			FlushInterval: *flagFlushingInterval,
 			BufferPool:    bufferpool,
 		},
+		pool:      pool,
 		router:    router,
 		addresser: addresser,
 	}
@@ -71,6 +90,7 @@ func New(pool backendpool.Pool, router router.Router, addresser adhoc.Addresser)
 
 // Proxy is a forward/reverse proxy that implements Route+Backend and Adhoc Rules forwarding.
 type Proxy struct {
+	pool      backendpool.Pool
 	router    router.Router
 	addresser adhoc.Addresser
 
@@ -84,31 +104,126 @@ func (p *Proxy) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	}
 	// note resp needs to implement Flusher, otherwise flush intervals won't work.
 	normReq := proxyreq.NormalizeInboundRequest(req)
-	backend, err := p.router.Route(req)
+	routeSpan := tracing.StartSpan(req, "route")
+	route, err := p.router.Route(normReq)
+	backend := ""
+	if err == nil {
+		backend = route.BackendName
+		routeSpan.SetTag("backend", backend)
+	} else if err != router.ErrRouteNotFound {
+		ext.Error.Set(routeSpan, true)
+	}
+	routeSpan.Finish()
+	routeName := ""
+	if err == nil {
+		routeName = router.Name(route)
+		logPendingAudit(req, routeName, backend)
+	} else if err == router.ErrRouteNotFound {
+		logPendingAudit(req, "", "_adhoc")
+	} else {
+		logPendingAudit(req, "", "")
+	}
 	tags := http_ctxtags.ExtractInbound(req)
 	tags.Set(http_ctxtags.TagForCallService, "proxy")
 	if err == nil {
 		resp.Header().Set("x-kedge-backend-name", backend)
 		tags.Set(ctxtags.TagForProxyBackend, backend)
+		tags.Set(ctxtags.TagForProxyRoute, routeName)
 		tags.Set(http_ctxtags.TagForHandlerName, backend)
+		markRouted(req)
+		limits := route.RequestLimits
+		// Header limits apply to tunnels (CONNECT, Upgrade) too, not only to proxied requests.
+		if limits != nil {
+			if err := checkRequestLimits(normReq, limits); err != nil {
+				respondWithError(err, req, resp)
+				return
+			}
+		}
+		if proxyreq.GetProxyMode(normReq) == proxyreq.MODE_CONNECT {
+			serveConnect(resp, normReq, backend, p.backendTunnelDialFunc(backend))
+			return
+		}
+		// HSTS makes sense only when kedge is the origin for the browser, and must not be sent over plain-text.
+		var hsts *pb.Hsts
+		if normReq.TLS != nil && proxyreq.GetProxyMode(normReq) == proxyreq.MODE_REVERSE_PROXY {
+			hsts = route.Hsts
+		}
+		cachePolicy := route.Cache
+		compressionPolicy := route.Compression
 		normReq.URL.Host = backend
+		if isUpgradeRequest(normReq) {
+			serveUpgrade(resp, normReq, backend, p.backendDialFunc(backend))
+			return
+		}
+		if limits != nil {
+			var body *limitedBody
+			body, normReq = newLimitedBody(normReq, limits)
+			defer body.Stop()
+			resp = &limitsResponseWriter{ResponseWriter: resp, body: body}
+		}
+		if hsts != nil {
+			resp = &hstsResponseWriter{ResponseWriter: resp, value: hstsHeaderValue(hsts)}
+		}
+		if cachePolicy != nil {
+			normReq = cache.WithPolicy(normReq, cachePolicy)
+		}
+		if compressionPolicy != nil {
+			normReq = compression.WithPolicy(normReq, compressionPolicy)
+			compressionResp := compression.NewResponseWriter(resp, normReq, compressionPolicy)
+			defer compressionResp.Close()
+			resp = compressionResp
+		}
 		p.backendReverseProxy.ServeHTTP(resp, normReq)
 		return
+	} else if err == router.ErrRedirectToHTTPS {
+		redirectToHTTPS(resp, normReq)
+		return
 	} else if err != router.ErrRouteNotFound {
 		respondWithError(err, req, resp)
 		return
 	}
-	addr, err := p.addresser.Address(req)
+	if proxyreq.IsFromPlaintextListener(req) {
+		// Adhoc rules are not allowed over plain-text.
+		respondWithError(router.ErrRouteNotFound, req, resp)
+		return
+	}
+	addr, rule, err := p.addresser.Address(req)
 	if err == nil {
 		normReq.URL.Host = addr
 		tags.Set(ctxtags.TagForProxyAdhoc, addr)
+		tags.Set(ctxtags.TagForProxyAdhocRule, rule.DnsNameMatcher)
 		tags.Set(http_ctxtags.TagForHandlerName, "_adhoc")
+		markRouted(req)
+		if proxyreq.GetProxyMode(normReq) == proxyreq.MODE_CONNECT {
+			serveConnect(resp, normReq, "_adhoc", adhocDialFunc(addr))
+			return
+		}
+		if isUpgradeRequest(normReq) {
+			serveUpgrade(resp, normReq, "_adhoc", adhocDialFunc(addr))
+			return
+		}
 		p.adhocReverseProxy.ServeHTTP(resp, normReq)
 		return
 	}
 	respondWithError(err, req, resp)
 }
 
+func (p *Proxy) backendDialFunc(backend string) upgradeDialFunc {
+	return func(req *http.Request) (net.Conn, error) {
+		dialer, err := p.pool.Dialer(backend)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.Dial(req)
+	}
+}
+
+func adhocDialFunc(addr string) upgradeDialFunc {
+	return func(req *http.Request) (net.Conn, error) {
+		return AdhocTransport.DialContext(req.Context(), "tcp", addr)
+	}
+}
+
 // backendPoolTripper assumes the response has been rewritten by the proxy to have the backend as req.URL.Host
 type backendPoolTripper struct {
 	pool backendpool.Pool
@@ -122,37 +237,118 @@ func (t *backendPoolTripper) RoundTrip(req *http.Request) (*http.Response, error
 	return nil, err
 }
 
+// upstreamTimingTripper tags the time until response headers are received from the backend or adhoc destination.
+type upstreamTimingTripper struct {
+	parent http.RoundTripper
+}
+
+func (t *upstreamTimingTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.parent.RoundTrip(req)
+	http_ctxtags.ExtractInbound(req).Set(ctxtags.TagForUpstreamTime, time.Since(start))
+	return resp, err
+}
+
+// errorRenderingTripper turns errors of sending requests to backends (including violated request limits) into
+// responses rendered by ErrorRenderer, since httputil.ReverseProxy would respond with a bare 502 instead.
+type errorRenderingTripper struct {
+	parent http.RoundTripper
+}
+
+func (t *errorRenderingTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.parent.RoundTrip(req)
+	if err == nil {
+		return resp, nil
+	}
+	if violation := limitViolation(req); violation != nil {
+		err = violation
+	}
+	rendered := &bufferedResponseWriter{header: http.Header{}, code: http.StatusOK}
+	respondWithError(err, req, rendered)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rendered.code, http.StatusText(rendered.code)),
+		StatusCode:    rendered.code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rendered.header,
+		Body:          ioutil.NopCloser(&rendered.body),
+		ContentLength: int64(rendered.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// bufferedResponseWriter keeps the response in memory.
+type bufferedResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
 func respondWithError(err error, req *http.Request, resp http.ResponseWriter) {
 	status := http.StatusBadGateway
 	if rErr, ok := (err).(*router.Error); ok {
 		status = rErr.StatusCode()
 	}
 	http_ctxtags.ExtractInbound(req).Set(logrus.ErrorKey, err)
-	resp.Header().Set("x-kedge-error", err.Error())
-	resp.Header().Set("content-type", "text/plain")
-	resp.WriteHeader(status)
-	fmt.Fprintf(resp, "%v", err.Error())
+	ErrorRenderer.Respond(resp, req, status, err, isPublicError(err))
+}
+
+// isPublicError returns true for errors meant to be shown to clients, as opposed to internal errors (e.g. failed
+// backend resolution) that may be hidden by ErrorRenderer.
+func isPublicError(err error) bool {
+	if _, ok := (err).(*router.Error); ok {
+		return true
+	}
+	return err == router.ErrRouteNotFound
 }
 
 func AuthMiddleware(authorizer authorize.Authorizer) httpwares.Middleware {
 	return func(nextHandler http.Handler) http.Handler {
 		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			span := tracing.StartSpan(req, "auth")
 			err := authorize.IsRequestAuthorized(req, authorizer, tripperware.ProxyAuthHeader)
 			if err != nil {
+				ext.Error.Set(span, true)
+			}
+			span.Finish()
+			record := newAuditRecord(req, tokenClaims(req.Header.Get(tripperware.ProxyAuthHeader)))
+			if err != nil {
+				authFailures.Inc()
+				record.Decision = auditlog.DecisionDeny
+				record.Reason = err.Error()
+				AuditLogger.Log(record)
+				authLogger.WithError(err).WithField("subject", record.Subject).Debugf("Unauthorized %s %s request to %s.", req.Method, req.URL.Path, req.Host)
 				respondWithUnauthorized(err, req, resp)
 				return
 			}
 			// Request authorized - continue.
+			if record.Subject != "" {
+				http_ctxtags.ExtractInbound(req).Set(ctxtags.TagForAuthSubject, record.Subject)
+			}
+			record.Decision = auditlog.DecisionAllow
+			record.Reason = "authorized"
+			authLogger.WithField("subject", record.Subject).Debugf("Authorized %s %s request to %s.", req.Method, req.URL.Path, req.Host)
+			req, logAudit := withPendingAudit(req, record)
+			defer logAudit()
 			nextHandler.ServeHTTP(resp, req)
 		})
 	}
 }
 
 func respondWithUnauthorized(err error, req *http.Request, resp http.ResponseWriter) {
-	status := http.StatusUnauthorized
 	http_ctxtags.ExtractInbound(req).Set(logrus.ErrorKey, err)
-	resp.Header().Set("x-kedge-error", err.Error())
-	resp.Header().Set("content-type", "text/plain")
-	resp.WriteHeader(status)
-	fmt.Fprintf(resp, "%v", err.Error())
+	ErrorRenderer.Respond(resp, req, http.StatusUnauthorized, err, true)
 }
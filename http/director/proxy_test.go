@@ -0,0 +1,52 @@
+package director
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/errorpage"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/mwitkow/kedge/lib/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_BackendTransportError_IsRenderedByErrorRenderer(t *testing.T) {
+	// Nothing listens on the backend address anymore.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	backendAddr := listener.Addr().String()
+	listener.Close()
+
+	routes := []*pb.Route{
+		{
+			BackendName: "down",
+			HostMatcher: "down.ext.example.com",
+		},
+	}
+	p := New(&testDialPool{backendAddr: backendAddr}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	req, err := http.NewRequest("GET", proxy.URL, nil)
+	require.NoError(t, err)
+	req.Host = "down.ext.example.com"
+	req.Header.Set("Accept", "application/problem+json")
+	req.Header.Set(requestid.HeaderName, "some-request-id")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("content-type"))
+	assert.NotEmpty(t, resp.Header.Get("x-kedge-error"))
+	var problem errorpage.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, http.StatusBadGateway, problem.Status)
+	assert.Equal(t, "some-request-id", problem.RequestID)
+}
@@ -11,10 +11,13 @@ type ProxyMode int
 const (
 	MODE_FORWARD_PROXY ProxyMode = iota
 	MODE_REVERSE_PROXY
+	// MODE_CONNECT is a CONNECT request asking for a raw TCP tunnel to host:port stored in URL.Host.
+	MODE_CONNECT
 )
 
 var (
-	typeMarker = "proxy_mode_marker"
+	typeMarker      = "proxy_mode_marker"
+	plaintextMarker = "plaintext_listener_marker"
 )
 
 // NormalizeInboundRequest makes sure that the request received by the proxy has the destination inside URL.Host.
@@ -24,11 +27,15 @@ func NormalizeInboundRequest(r *http.Request) *http.Request {
 	if t == MODE_REVERSE_PROXY {
 		reqCopy.URL.Host = reqCopy.Host
 	}
+	// CONNECT requests (both HTTP/1.1 and HTTP/2) already have the destination authority in URL.Host.
 	return reqCopy
 }
 
 func unnormalizedRequestMode(r *http.Request) ProxyMode {
-	if r.Method != "CONNECT" && strings.HasPrefix(r.RequestURI, "http") {
+	if r.Method == http.MethodConnect {
+		return MODE_CONNECT
+	}
+	if strings.HasPrefix(r.RequestURI, "http") {
 		// Forward Proxy requests embed the host information of the destination inside the RequestURI.
 		return MODE_FORWARD_PROXY
 	} else {
@@ -43,3 +50,14 @@ func GetProxyMode(r *http.Request) ProxyMode {
 	}
 	return unnormalizedRequestMode(r)
 }
+
+// MarkPlaintextListener marks the request as received on the plain-text (non-TLS) proxy listener.
+func MarkPlaintextListener(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), plaintextMarker, true))
+}
+
+// IsFromPlaintextListener returns true if the request was received on the plain-text (non-TLS) proxy listener.
+func IsFromPlaintextListener(r *http.Request) bool {
+	v, ok := r.Context().Value(plaintextMarker).(bool)
+	return ok && v
+}
@@ -16,12 +16,16 @@ import (
 var (
 	emptyMd          = metadata.Pairs()
 	ErrRouteNotFound = errors.New("unknown route to service")
+	// ErrRedirectToHTTPS is returned when a request on the plain-text listener matched a route that requires a
+	// redirect to HTTPS.
+	ErrRedirectToHTTPS = errors.New("route requires HTTPS")
 )
 
 type Router interface {
-	// Route returns a backend name for a given call, or an error.
+	// Route returns the first route matching the call, or an error. The backend and all per-route policies are read
+	// from the returned route, so they come from the same routing table even if it is updated concurrently.
 	// Note: the request *must* be normalized.
-	Route(req *http.Request) (backendName string, err error)
+	Route(req *http.Request) (*pb.Route, error)
 }
 
 type dynamic struct {
@@ -34,7 +38,7 @@ func NewDynamic() *dynamic {
 	return &dynamic{staticRouter: NewStatic([]*pb.Route{})}
 }
 
-func (d *dynamic) Route(req *http.Request) (backendName string, err error) {
+func (d *dynamic) Route(req *http.Request) (*pb.Route, error) {
 	d.mu.RLock()
 	staticRouter := d.staticRouter
 	d.mu.RUnlock()
@@ -57,7 +61,24 @@ func NewStatic(routes []*pb.Route) *static {
 	return &static{routes: routes}
 }
 
-func (r *static) Route(req *http.Request) (backendName string, err error) {
+// Name returns a human readable identifier of the route built from its matchers, e.g. "api.example.com:443/v1/*".
+// Routes have no explicit names, so it is meant for logs only.
+func Name(route *pb.Route) string {
+	host := route.HostMatcher
+	if host == "" {
+		host = "*"
+	}
+	if route.PortMatcher != 0 {
+		host = fmt.Sprintf("%s:%d", host, route.PortMatcher)
+	}
+	paths := "/*"
+	if len(route.PathRules) > 0 {
+		paths = strings.Join(route.PathRules, ",")
+	}
+	return host + paths
+}
+
+func (r *static) Route(req *http.Request) (*pb.Route, error) {
 	for _, route := range r.routes {
 		if !r.urlMatches(req.URL, route.PathRules) {
 			continue
@@ -74,9 +95,17 @@ func (r *static) Route(req *http.Request) (backendName string, err error) {
 		if !r.requestTypeMatch(proxyreq.GetProxyMode(req), route.ProxyMode) {
 			continue
 		}
-		return route.BackendName, nil
+		if proxyreq.IsFromPlaintextListener(req) {
+			if route.RedirectToHttps && proxyreq.GetProxyMode(req) != proxyreq.MODE_CONNECT {
+				return nil, ErrRedirectToHTTPS
+			}
+			if !route.AllowPlaintext {
+				continue
+			}
+		}
+		return route, nil
 	}
-	return "", ErrRouteNotFound
+	return nil, ErrRouteNotFound
 }
 
 func (r *static) urlMatches(u *url.URL, matchers []string) bool {
@@ -144,5 +173,9 @@ func (r *static) requestTypeMatch(requestMode proxyreq.ProxyMode, routeMode pb.P
 	if requestMode == proxyreq.MODE_REVERSE_PROXY && routeMode == pb.ProxyMode_REVERSE_PROXY {
 		return true
 	}
+	// CONNECT tunnels are a form of forward proxying, where the client addresses the destination explicitly.
+	if requestMode == proxyreq.MODE_CONNECT && routeMode == pb.ProxyMode_FORWARD_PROXY {
+		return true
+	}
 	return false
 }
@@ -0,0 +1,225 @@
+package director
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/mwitkow/kedge/lib/http/tunnel"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	flagUpgradeIdleTimeout = sharedflags.Set.Duration("http_upgrade_idle_timeout", 5*time.Minute,
+		"Maximum time an upgraded (WebSocket, SPDY) connection can stay without any traffic in either direction. "+
+			"If 0, upgraded connections never time out.")
+	flagUpgradeBackendResponseTimeout = sharedflags.Set.Duration("http_upgrade_backend_response_timeout", 10*time.Second,
+		"Maximum time to wait for the backend response to an Upgrade request.")
+
+	upgradeConnsStarted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_upgrade",
+			Name:      "conns_started_total",
+			Help:      "Total number of connections switched to the upgraded protocol.",
+		},
+		[]string{"backend", "protocol"},
+	)
+	upgradeConnsActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "http_upgrade",
+			Name:      "conns_active",
+			Help:      "Number of currently spliced upgraded connections.",
+		},
+		[]string{"backend", "protocol"},
+	)
+	upgradeBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_upgrade",
+			Name:      "bytes_total",
+			Help:      "Total number of bytes spliced through upgraded connections.",
+		},
+		[]string{"backend", "protocol", "direction"},
+	)
+	upgradeConnsFailed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_upgrade",
+			Name:      "failed_total",
+			Help:      "Total number of Upgrade requests that failed before switching protocols.",
+		},
+		[]string{"backend", "protocol"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(upgradeConnsStarted)
+	prometheus.MustRegister(upgradeConnsActive)
+	prometheus.MustRegister(upgradeBytes)
+	prometheus.MustRegister(upgradeConnsFailed)
+}
+
+// upgradeDialFunc dials a raw connection to the destination of the given (normalized) request.
+type upgradeDialFunc func(req *http.Request) (net.Conn, error)
+
+// upgradeHopHeaders are hop-by-hop headers that are removed from Upgrade requests and responses.
+// Connection and Upgrade are intentionally kept, as the backend needs them to switch protocols.
+var upgradeHopHeaders = []string{
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+}
+
+// isUpgradeRequest returns true if the request asks to switch the connection protocol (e.g. WebSocket, SPDY).
+func isUpgradeRequest(req *http.Request) bool {
+	if req.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range req.Header["Connection"] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveUpgrade sends the Upgrade request to the destination and, if the destination switches protocols, hijacks the
+// inbound connection and splices it bidirectionally with the outbound one.
+//
+// Hijacked connections are not governed by http.Server timeouts anymore, instead they are closed after
+// http_upgrade_idle_timeout of inactivity.
+func serveUpgrade(resp http.ResponseWriter, req *http.Request, backendLabel string, dial upgradeDialFunc) {
+	protocol := strings.ToLower(req.Header.Get("Upgrade"))
+	tags := http_ctxtags.ExtractInbound(req)
+	tags.Set(ctxtags.TagForUpgrade, protocol)
+
+	if _, ok := resp.(http.Hijacker); !ok {
+		upgradeConnsFailed.WithLabelValues(backendLabel, protocol).Inc()
+		respondWithError(router.NewError(http.StatusBadRequest, "connection upgrade not supported over this protocol"), req, resp)
+		return
+	}
+
+	backendConn, err := dial(req)
+	if err != nil {
+		upgradeConnsFailed.WithLabelValues(backendLabel, protocol).Inc()
+		respondWithError(err, req, resp)
+		return
+	}
+
+	outReq := req.WithContext(req.Context()) // shallow copy.
+	outReq.Header = cloneHeader(req.Header)
+	for _, h := range upgradeHopHeaders {
+		outReq.Header.Del(h)
+	}
+	// Request.Write writes RequestURI from URL, so make sure we send origin-form even for forward proxy requests.
+	outURL := *req.URL
+	outURL.Scheme = ""
+	outURL.Host = ""
+	outReq.URL = &outURL
+
+	if *flagUpgradeBackendResponseTimeout > 0 {
+		backendConn.SetDeadline(time.Now().Add(*flagUpgradeBackendResponseTimeout))
+	}
+	if err := outReq.Write(backendConn); err != nil {
+		backendConn.Close()
+		upgradeConnsFailed.WithLabelValues(backendLabel, protocol).Inc()
+		respondWithError(errors.Wrap(err, "failed to send upgrade request to backend"), req, resp)
+		return
+	}
+	backendReader := bufio.NewReader(backendConn)
+	backendResp, err := http.ReadResponse(backendReader, outReq)
+	if err != nil {
+		backendConn.Close()
+		upgradeConnsFailed.WithLabelValues(backendLabel, protocol).Inc()
+		respondWithError(errors.Wrap(err, "failed to read upgrade response from backend"), req, resp)
+		return
+	}
+	backendConn.SetDeadline(time.Time{})
+
+	if backendResp.StatusCode != http.StatusSwitchingProtocols {
+		// Backend refused to upgrade, pass its response as is.
+		defer backendConn.Close()
+		defer backendResp.Body.Close()
+		upgradeConnsFailed.WithLabelValues(backendLabel, protocol).Inc()
+		copyHeader(resp.Header(), backendResp.Header)
+		resp.WriteHeader(backendResp.StatusCode)
+		io.Copy(resp, backendResp.Body)
+		return
+	}
+
+	clientConn, clientBuf, err := tunnel.Hijack(resp, *flagUpgradeIdleTimeout)
+	if err != nil {
+		backendConn.Close()
+		upgradeConnsFailed.WithLabelValues(backendLabel, protocol).Inc()
+		respondWithError(err, req, resp)
+		return
+	}
+
+	for _, h := range upgradeHopHeaders {
+		backendResp.Header.Del(h)
+	}
+	// Headers set by us on resp (e.g. x-kedge-backend-name) were never sent, pass them along.
+	copyHeader(backendResp.Header, resp.Header())
+	if err := writeSwitchingProtocols(clientBuf, backendResp); err != nil {
+		clientConn.Close()
+		backendConn.Close()
+		upgradeConnsFailed.WithLabelValues(backendLabel, protocol).Inc()
+		return
+	}
+
+	upgradeConnsStarted.WithLabelValues(backendLabel, protocol).Inc()
+	upgradeConnsActive.WithLabelValues(backendLabel, protocol).Inc()
+	defer upgradeConnsActive.WithLabelValues(backendLabel, protocol).Dec()
+
+	inbound, outbound := tunnel.Splice(clientConn, tunnel.WithIdleTimeout(backendConn, backendReader, *flagUpgradeIdleTimeout))
+	upgradeBytes.WithLabelValues(backendLabel, protocol, "inbound").Add(float64(inbound))
+	upgradeBytes.WithLabelValues(backendLabel, protocol, "outbound").Add(float64(outbound))
+}
+
+func writeSwitchingProtocols(w *bufio.Writer, backendResp *http.Response) error {
+	if _, err := fmt.Fprintf(w, "HTTP/1.1 %s\r\n", backendResp.Status); err != nil {
+		return err
+	}
+	if err := backendResp.Header.Write(w); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func cloneHeader(h http.Header) http.Header {
+	h2 := make(http.Header, len(h))
+	for k, vv := range h {
+		vv2 := make([]string, len(vv))
+		copy(vv2, vv)
+		h2[k] = vv2
+	}
+	return h2
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+}
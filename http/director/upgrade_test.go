@@ -0,0 +1,172 @@
+package director
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mwitkow/go-httpwares"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/http/backendpool"
+	"github.com/mwitkow/kedge/http/director/adhoc"
+	"github.com/mwitkow/kedge/http/director/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDialPool struct {
+	backendAddr string
+}
+
+func (p *testDialPool) Tripper(_ string) (http.RoundTripper, error) {
+	return httpwares.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = "http"
+		req.URL.Host = p.backendAddr
+		return http.DefaultTransport.RoundTrip(req)
+	}), nil
+}
+
+func (p *testDialPool) Dialer(_ string) (backendpool.Dialer, error) {
+	return p, nil
+}
+
+func (p *testDialPool) Dial(_ *http.Request) (net.Conn, error) {
+	return net.Dial("tcp", p.backendAddr)
+}
+
+func (p *testDialPool) DialTunnel(req *http.Request) (net.Conn, error) {
+	return p.Dial(req)
+}
+
+// echoUpgradeHandler switches to the "echo" protocol and writes back everything it reads.
+func echoUpgradeHandler(resp http.ResponseWriter, req *http.Request) {
+	if !isUpgradeRequest(req) || req.Header.Get("Upgrade") != "echo" {
+		resp.WriteHeader(http.StatusUpgradeRequired)
+		return
+	}
+	conn, buf, err := resp.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	buf.Flush()
+	io.Copy(conn, buf)
+}
+
+func startUpgradeTestProxy(t *testing.T) (proxyAddr string, closeFn func()) {
+	backend := httptest.NewServer(http.HandlerFunc(echoUpgradeHandler))
+	routes := []*pb.Route{
+		{
+			BackendName: "echo",
+			HostMatcher: "echo.ext.example.com",
+		},
+		{
+			BackendName:   "echo",
+			HostMatcher:   "limited.ext.example.com",
+			RequestLimits: &pb.RequestLimits{MaxHeaderBytes: 128},
+		},
+	}
+	p := New(&testDialPool{backendAddr: backend.Listener.Addr().String()}, router.NewStatic(routes), adhoc.NewStaticAddresser(nil))
+	proxy := httptest.NewServer(p)
+	return proxy.Listener.Addr().String(), func() {
+		proxy.Close()
+		backend.Close()
+	}
+}
+
+func TestUpgrade_SplicesConnection(t *testing.T) {
+	proxyAddr, closeFn := startUpgradeTestProxy(t)
+	defer closeFn()
+
+	conn, err := net.Dial("tcp", proxyAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "GET /stream HTTP/1.1\r\nHost: echo.ext.example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "echo", resp.Header.Get("Upgrade"))
+	assert.Equal(t, "echo", resp.Header.Get("x-kedge-backend-name"))
+
+	for _, msg := range []string{"ping", "pong", "some longer message"} {
+		_, err = io.WriteString(conn, msg)
+		require.NoError(t, err)
+		got := make([]byte, len(msg))
+		_, err = io.ReadFull(reader, got)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(got))
+	}
+}
+
+func TestUpgrade_BackendRefusal_IsPassedThrough(t *testing.T) {
+	proxyAddr, closeFn := startUpgradeTestProxy(t)
+	defer closeFn()
+
+	req, err := http.NewRequest("GET", "http://"+proxyAddr+"/stream", nil)
+	require.NoError(t, err)
+	req.Host = "echo.ext.example.com"
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode, "backend refused websocket, its response should be passed")
+}
+
+func TestUpgrade_NonUpgradeRequest_GoesThroughReverseProxy(t *testing.T) {
+	proxyAddr, closeFn := startUpgradeTestProxy(t)
+	defer closeFn()
+
+	req, err := http.NewRequest("GET", "http://"+proxyAddr+"/stream", nil)
+	require.NoError(t, err)
+	req.Host = "echo.ext.example.com"
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+}
+
+func TestIsUpgradeRequest(t *testing.T) {
+	for _, tcase := range []struct {
+		connection string
+		upgrade    string
+		expected   bool
+	}{
+		{connection: "Upgrade", upgrade: "websocket", expected: true},
+		{connection: "keep-alive, Upgrade", upgrade: "SPDY/3.1", expected: true},
+		{connection: "keep-alive", upgrade: "websocket", expected: false},
+		{connection: "Upgrade", upgrade: "", expected: false},
+	} {
+		req := httptest.NewRequest("GET", "http://example.com", nil)
+		req.Header.Set("Connection", tcase.connection)
+		if tcase.upgrade != "" {
+			req.Header.Set("Upgrade", tcase.upgrade)
+		}
+		assert.Equal(t, tcase.expected, isUpgradeRequest(req), "case %v", tcase)
+	}
+}
+
+func TestUpgrade_HeaderLimits_AreEnforced(t *testing.T) {
+	proxyAddr, closeFn := startUpgradeTestProxy(t)
+	defer closeFn()
+
+	req, err := http.NewRequest("GET", "http://"+proxyAddr+"/stream", nil)
+	require.NoError(t, err)
+	req.Host = "limited.ext.example.com"
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	req.Header.Set("X-Big", strings.Repeat("a", 256))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode, "upgrade should not bypass header limits")
+}
@@ -0,0 +1,94 @@
+package lbtransport
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/naming"
+)
+
+var (
+	resolutionUpdates = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "http_lbtransport",
+			Name:      "resolution_updates_total",
+			Help:      "Total number of targets added or deleted by the resolver.",
+		},
+		[]string{"resolve_addr", "op"},
+	)
+
+	resolvedTargetsDesc = prometheus.NewDesc(
+		"kedge_http_lbtransport_resolved_targets",
+		"Number of targets currently resolved for the backend.",
+		[]string{"resolve_addr"}, nil,
+	)
+	blacklistedTargetsDesc = prometheus.NewDesc(
+		"kedge_http_lbtransport_blacklisted_targets",
+		"Number of targets currently blacklisted after failed dials.",
+		[]string{"resolve_addr"}, nil,
+	)
+
+	targetsMetrics = &targetsCollector{trippers: make(map[*tripper]struct{})}
+)
+
+func init() {
+	prometheus.MustRegister(resolutionUpdates)
+	prometheus.MustRegister(targetsMetrics)
+}
+
+func opLabel(op naming.Operation) string {
+	if op == naming.Add {
+		return "add"
+	}
+	return "delete"
+}
+
+// blacklistCounter is implemented by policies that blacklist targets.
+type blacklistCounter interface {
+	blacklistedCount() int
+}
+
+// targetsCollector reports current number of resolved and blacklisted targets of all open trippers.
+type targetsCollector struct {
+	mu       sync.Mutex
+	trippers map[*tripper]struct{}
+}
+
+func (c *targetsCollector) add(t *tripper) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trippers[t] = struct{}{}
+}
+
+func (c *targetsCollector) remove(t *tripper) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.trippers, t)
+}
+
+func (c *targetsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resolvedTargetsDesc
+	ch <- blacklistedTargetsDesc
+}
+
+func (c *targetsCollector) Collect(ch chan<- prometheus.Metric) {
+	resolved := map[string]int{}
+	blacklisted := map[string]int{}
+	c.mu.Lock()
+	for t := range c.trippers {
+		t.mu.RLock()
+		resolved[t.targetName] += len(t.currentTargets)
+		t.mu.RUnlock()
+		if counter, ok := t.policy.(blacklistCounter); ok {
+			blacklisted[t.targetName] += counter.blacklistedCount()
+		}
+	}
+	c.mu.Unlock()
+
+	// Many backends can resolve the same address, so metrics are summed up per resolve_addr.
+	for resolveAddr, count := range resolved {
+		ch <- prometheus.MustNewConstMetric(resolvedTargetsDesc, prometheus.GaugeValue, float64(count), resolveAddr)
+		ch <- prometheus.MustNewConstMetric(blacklistedTargetsDesc, prometheus.GaugeValue, float64(blacklisted[resolveAddr]), resolveAddr)
+	}
+}
@@ -110,6 +110,20 @@ func (rr *roundRobinPolicy) blacklistTarget(target *Target) {
 	rr.blacklistedTargets[*target] = rr.timeNow()
 }
 
+// blacklistedCount returns the number of currently blacklisted targets.
+func (rr *roundRobinPolicy) blacklistedCount() int {
+	rr.blacklistMu.Lock()
+	defer rr.blacklistMu.Unlock()
+
+	count := 0
+	for _, failTime := range rr.blacklistedTargets {
+		if !failTime.Add(rr.blacklistBackoffDuration).Before(rr.timeNow()) {
+			count++
+		}
+	}
+	return count
+}
+
 func (rr *roundRobinPolicy) isBlacklistDisabled() bool {
 	return rr.blacklistBackoffDuration == (0 * time.Microsecond)
 }
@@ -1,12 +1,14 @@
 package lbtransport
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"sync"
 
 	"github.com/mwitkow/go-httpwares/tags"
 	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/mwitkow/kedge/lib/loglevel"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc/naming"
@@ -41,6 +43,8 @@ func init() {
 	prometheus.MustRegister(failedDialsCounter)
 }
 
+var logger = loglevel.Subsystem(loglevel.LBTransport)
+
 // New creates a new load-balanced Round Tripper for a single backend.
 //
 // This RoundTripper is meant to only dial a single backend, and will throw errors if the req.URL.Host
@@ -59,6 +63,7 @@ func New(targetAddr string, parent http.RoundTripper, resolver naming.Resolver,
 		return nil, err
 	}
 	s.watcher = watcher
+	targetsMetrics.add(s)
 	go s.run()
 	return s, nil
 }
@@ -67,6 +72,7 @@ func (s *tripper) run() {
 	for {
 		updates, err := s.watcher.Next() // blocking call until new updates are there
 		if err != nil {
+			logger.WithError(err).WithField("target", s.targetName).Debug("lb: resolver watcher stopped, no more resolution updates.")
 			s.mu.Lock()
 			s.currentTargets = []*Target{}
 			s.lastResolveError = err
@@ -77,6 +83,8 @@ func (s *tripper) run() {
 		targets := s.currentTargets
 		s.mu.RUnlock()
 		for _, u := range updates {
+			resolutionUpdates.WithLabelValues(s.targetName, opLabel(u.Op)).Inc()
+			logger.WithField("target", s.targetName).Debugf("lb: resolution update: %s %s", opLabel(u.Op), u.Addr)
 			if u.Op == naming.Add {
 				targets = append(targets, &Target{DialAddr: u.Addr})
 			} else if u.Op == naming.Delete {
@@ -96,20 +104,21 @@ func (s *tripper) run() {
 }
 
 func (s *tripper) Close() error {
+	targetsMetrics.remove(s)
 	s.watcher.Close()
 	return nil
 }
 
 func (s *tripper) RoundTrip(r *http.Request) (*http.Response, error) {
 	tags := http_ctxtags.ExtractInbound(r)
-	tags.Set(ctxtags.TagForBackendAuthTime, s.targetName)
+	tags.Set(ctxtags.TagForBackendTarget, s.targetName)
 
 	s.mu.RLock()
 	targetsRef := s.currentTargets
 	lastResolvErr := s.lastResolveError
 	s.mu.RUnlock()
 	if len(targetsRef) == 0 {
-		return nil, errors.Wrapf(lastResolvErr, "lb: no resolution available for %s", s.targetName)
+		return nil, noResolutionError(lastResolvErr, s.targetName)
 	}
 
 	picker := s.policy.Picker()
@@ -124,6 +133,7 @@ func (s *tripper) RoundTrip(r *http.Request) (*http.Response, error) {
 		// We override it to make sure it enters the appropriate dial method and the appropriate connection pool.
 		// See http.connectMethodKey.
 		r.URL.Host = target.DialAddr
+		tags.Set(ctxtags.TagForBackendTargetAddr, target.DialAddr)
 		resp, err := s.parent.RoundTrip(r)
 		if err == nil {
 			return resp, nil
@@ -134,6 +144,7 @@ func (s *tripper) RoundTrip(r *http.Request) (*http.Response, error) {
 		}
 
 		failedDialsCounter.WithLabelValues(s.targetName, target.DialAddr).Inc()
+		logger.WithError(err).WithField("target", s.targetName).Debugf("lb: dial to %s failed, trying next one.", target.DialAddr)
 
 		// Retry without this target.
 		// NOTE: We need to trust picker that it blacklist the targets well.
@@ -141,6 +152,59 @@ func (s *tripper) RoundTrip(r *http.Request) (*http.Response, error) {
 	}
 }
 
+// DialContextFunc dials a single, already resolved address.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dial picks a target using the same policy as RoundTrip and dials a raw connection to it.
+//
+// It is meant for connections that cannot go through http.RoundTripper, like HTTP Upgrade (WebSocket, SPDY)
+// or CONNECT tunnels. Failed dials blacklist the target and the next one is tried, exactly like in RoundTrip.
+func (s *tripper) Dial(r *http.Request, dial DialContextFunc) (net.Conn, error) {
+	tags := http_ctxtags.ExtractInbound(r)
+	tags.Set(ctxtags.TagForBackendTarget, s.targetName)
+
+	s.mu.RLock()
+	targetsRef := s.currentTargets
+	lastResolvErr := s.lastResolveError
+	s.mu.RUnlock()
+	if len(targetsRef) == 0 {
+		return nil, noResolutionError(lastResolvErr, s.targetName)
+	}
+
+	picker := s.policy.Picker()
+	for {
+		target, err := picker.Pick(r, targetsRef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "lb: failed choosing valid target for %s", s.targetName)
+		}
+
+		tags.Set(ctxtags.TagForBackendTargetAddr, target.DialAddr)
+		conn, err := dial(r.Context(), "tcp", target.DialAddr)
+		if err == nil {
+			return conn, nil
+		}
+
+		if !isDialError(err) {
+			return nil, err
+		}
+
+		failedDialsCounter.WithLabelValues(s.targetName, target.DialAddr).Inc()
+		logger.WithError(err).WithField("target", s.targetName).Debugf("lb: dial to %s failed, trying next one.", target.DialAddr)
+
+		// Retry without this target.
+		picker.ExcludeTarget(target)
+	}
+}
+
+// noResolutionError returns an error for a backend without targets. The last resolve error is nil if resolution has
+// not finished yet or all targets were deleted, so it cannot be just wrapped.
+func noResolutionError(lastResolveErr error, targetName string) error {
+	if lastResolveErr == nil {
+		return errors.Errorf("lb: no resolution available for %s", targetName)
+	}
+	return errors.Wrapf(lastResolveErr, "lb: no resolution available for %s", targetName)
+}
+
 func isDialError(err error) bool {
 	if opErr, ok := err.(*net.OpError); ok {
 		if opErr.Op == "dial" {
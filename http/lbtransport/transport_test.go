@@ -2,7 +2,9 @@ package lbtransport
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -262,3 +264,41 @@ func (t *mockSRVWatcher) Next() ([]*naming.Update, error) {
 func (t *mockSRVWatcher) Close() {
 	close(t.backendAddrUpdatesCh)
 }
+
+// emptyResolver resolves to watchers that never return any targets, like before the first resolution.
+type emptyResolver struct{}
+
+func (emptyResolver) Resolve(_ string) (naming.Watcher, error) {
+	return &emptyWatcher{closed: make(chan struct{})}, nil
+}
+
+type emptyWatcher struct {
+	closed chan struct{}
+}
+
+func (w *emptyWatcher) Next() ([]*naming.Update, error) {
+	<-w.closed
+	return nil, errors.New("watcher closed")
+}
+
+func (w *emptyWatcher) Close() {
+	close(w.closed)
+}
+
+func TestTripper_Dial_NoTargets(t *testing.T) {
+	lb, err := New("empty-srv", http.DefaultTransport, emptyResolver{}, RoundRobinPolicy(testFailBlacklistDuration, testDialTimeout))
+	require.NoError(t, err)
+	defer lb.Close()
+
+	req := httptest.NewRequest("GET", "http://empty-srv/", nil)
+	conn, err := lb.Dial(req, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t.Fatalf("no target should be dialed, got %s", addr)
+		return nil, nil
+	})
+	assert.Nil(t, conn)
+	require.Error(t, err, "no targets should be an error, not a nil connection")
+	assert.Contains(t, err.Error(), "lb: no resolution available for empty-srv")
+
+	_, err = lb.RoundTrip(req)
+	require.Error(t, err)
+}
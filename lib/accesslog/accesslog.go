@@ -0,0 +1,280 @@
+// Package accesslog implements a structured access log of requests handled by kedge proxy.
+//
+// It is independent of the operational log: it has its own format, output and sampling, and is not affected by the
+// log level.
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/mwitkow/go-httpwares"
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatLogfmt   = "logfmt"
+	FormatCommon   = "clf"
+	FormatCombined = "combined"
+
+	SinkNone     = "none"
+	SinkStdout   = "stdout"
+	SinkFile     = "file"
+	SinkLogstash = "logstash"
+)
+
+// Fields that can be put in JSON and logfmt access log entries. Empty fields are omitted.
+const (
+	FieldRemoteAddr       = "remote_addr"
+	FieldMethod           = "method"
+	FieldHost             = "host"
+	FieldURI              = "uri"
+	FieldProto            = "proto"
+	FieldStatus           = "status"
+	FieldRequestBytes     = "request_bytes"
+	FieldResponseBytes    = "response_bytes"
+	FieldDuration         = "duration_sec"
+	FieldUpstreamDuration = "upstream_duration_sec"
+	FieldRoute            = "route"
+	FieldBackend          = "backend"
+	FieldAdhoc            = "adhoc"
+	FieldTarget           = "target"
+	FieldAuthSubject      = "auth_subject"
+	FieldRequestID        = "request_id"
+	FieldTraceID          = "trace_id"
+	FieldUserAgent        = "user_agent"
+	FieldReferer          = "referer"
+)
+
+var (
+	// AllFields is the default field set.
+	AllFields = []string{
+		FieldRemoteAddr, FieldMethod, FieldHost, FieldURI, FieldProto, FieldStatus, FieldRequestBytes,
+		FieldResponseBytes, FieldDuration, FieldUpstreamDuration, FieldRoute, FieldBackend, FieldAdhoc, FieldTarget,
+		FieldAuthSubject, FieldRequestID, FieldTraceID, FieldUserAgent, FieldReferer,
+	}
+
+	commonFields   = []string{FieldRemoteAddr, FieldAuthSubject, FieldMethod, FieldURI, FieldProto, FieldStatus, FieldResponseBytes}
+	combinedFields = append(append([]string{}, commonFields...), FieldReferer, FieldUserAgent)
+)
+
+// Config configures the access Logger.
+type Config struct {
+	// Sink is one of SinkNone, SinkStdout, SinkFile or SinkLogstash.
+	Sink string
+	// Format is one of FormatJSON, FormatLogfmt, FormatCommon or FormatCombined. It is ignored for SinkLogstash, as
+	// entries are encoded by the remote log sink.
+	Format string
+	// Fields is the set of fields put in JSON and logfmt entries. If empty, AllFields are used.
+	Fields []string
+	// SampleRate is the fraction (0 to 1) of requests to be logged. Responses with 5xx status are always logged.
+	SampleRate float64
+
+	// FilePath is the path of the log file for SinkFile. It is rotated when it reaches FileMaxSizeMB.
+	FilePath       string
+	FileMaxSizeMB  int
+	FileMaxBackups int
+	FileMaxAgeDays int
+
+	// LogstashHook is the hook for SinkLogstash, usually the remote log sink hook used by the operational log.
+	LogstashHook logrus.Hook
+}
+
+// Logger writes access log entries.
+type Logger struct {
+	logger     *logrus.Logger
+	fields     []string
+	sampleRate float64
+	closer     io.Closer
+}
+
+// New creates an access Logger. With SinkNone, its Middleware does nothing.
+func New(conf Config) (*Logger, error) {
+	if conf.SampleRate < 0 || conf.SampleRate > 1 {
+		return nil, errors.Errorf("accesslog: sample rate %v is not between 0 and 1", conf.SampleRate)
+	}
+	l := &Logger{sampleRate: conf.SampleRate, fields: conf.Fields}
+	if len(l.fields) == 0 {
+		l.fields = AllFields
+	}
+	for _, field := range l.fields {
+		if !isKnownField(field) {
+			return nil, errors.Errorf("accesslog: unknown field %q", field)
+		}
+	}
+
+	logger := logrus.New()
+	logger.Level = logrus.InfoLevel
+	switch conf.Format {
+	case FormatJSON:
+		logger.Formatter = &logrus.JSONFormatter{}
+	case FormatLogfmt:
+		logger.Formatter = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	case FormatCommon:
+		logger.Formatter = &clfFormatter{}
+		l.fields = commonFields
+	case FormatCombined:
+		logger.Formatter = &clfFormatter{combined: true}
+		l.fields = combinedFields
+	default:
+		return nil, errors.Errorf("accesslog: unknown format %q", conf.Format)
+	}
+
+	switch conf.Sink {
+	case SinkNone:
+		return &Logger{}, nil
+	case SinkStdout:
+		logger.Out = os.Stdout
+	case SinkFile:
+		if conf.FilePath == "" {
+			return nil, errors.New("accesslog: file path is required for file sink")
+		}
+		file := &lumberjack.Logger{
+			Filename:   conf.FilePath,
+			MaxSize:    conf.FileMaxSizeMB,
+			MaxBackups: conf.FileMaxBackups,
+			MaxAge:     conf.FileMaxAgeDays,
+		}
+		logger.Out = file
+		l.closer = file
+	case SinkLogstash:
+		if conf.LogstashHook == nil {
+			return nil, errors.New("accesslog: logstash sink requires remote log sink to be configured")
+		}
+		logger.Out = ioutil.Discard
+		logger.Hooks.Add(conf.LogstashHook)
+	default:
+		return nil, errors.Errorf("accesslog: unknown sink %q", conf.Sink)
+	}
+	l.logger = logger
+	return l, nil
+}
+
+func isKnownField(field string) bool {
+	for _, f := range AllFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the log file, if any.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// Middleware logs each request once it is handled.
+//
+// It needs to be placed after http_ctxtags.Middleware, and as early as possible in the chain so the duration covers
+// all other middlewares.
+func (l *Logger) Middleware() httpwares.Middleware {
+	return func(nextHandler http.Handler) http.Handler {
+		if l.logger == nil {
+			return nextHandler
+		}
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			var body *countingBody
+			if req.Body != nil {
+				body = &countingBody{ReadCloser: req.Body}
+				req.Body = body
+			}
+			wrapped := httpwares.WrapResponseWriter(resp)
+
+			nextHandler.ServeHTTP(wrapped, req)
+
+			if wrapped.StatusCode() < http.StatusInternalServerError && rand.Float64() >= l.sampleRate {
+				return
+			}
+			var requestBytes int64
+			if body != nil {
+				requestBytes = atomic.LoadInt64(&body.read)
+			}
+			all := allFields(req, wrapped, requestBytes, time.Since(start))
+			fields := logrus.Fields{}
+			for _, field := range l.fields {
+				if v, ok := all[field]; ok {
+					fields[field] = v
+				}
+			}
+			l.logger.WithFields(fields).Info(fmt.Sprintf("%s %s %s", req.Method, requestURI(req), req.Proto))
+		})
+	}
+}
+
+func allFields(req *http.Request, resp httpwares.WrappedResponseWriter, requestBytes int64, duration time.Duration) logrus.Fields {
+	fields := logrus.Fields{
+		FieldRemoteAddr:    req.RemoteAddr,
+		FieldMethod:        req.Method,
+		FieldHost:          req.Host,
+		FieldURI:           requestURI(req),
+		FieldProto:         req.Proto,
+		FieldStatus:        resp.StatusCode(),
+		FieldRequestBytes:  requestBytes,
+		FieldResponseBytes: resp.MessageLength(),
+		FieldDuration:      duration.Seconds(),
+	}
+	values := http_ctxtags.ExtractInbound(req).Values()
+	if upstream, ok := values[ctxtags.TagForUpstreamTime].(time.Duration); ok {
+		fields[FieldUpstreamDuration] = upstream.Seconds()
+	}
+	for field, tag := range map[string]string{
+		FieldRoute:       ctxtags.TagForProxyRoute,
+		FieldBackend:     ctxtags.TagForProxyBackend,
+		FieldAdhoc:       ctxtags.TagForProxyAdhoc,
+		FieldTarget:      ctxtags.TagForBackendTargetAddr,
+		FieldAuthSubject: ctxtags.TagForAuthSubject,
+		FieldRequestID:   ctxtags.TagForRequestID,
+		FieldTraceID:     ctxtags.TagForTraceID,
+	} {
+		if v, ok := values[tag].(string); ok && v != "" {
+			fields[field] = v
+		}
+	}
+	if _, ok := fields[FieldTarget]; !ok {
+		// Adhoc destinations are dialed directly.
+		if adhoc, ok := fields[FieldAdhoc]; ok {
+			fields[FieldTarget] = adhoc
+		}
+	}
+	if ua := req.UserAgent(); ua != "" {
+		fields[FieldUserAgent] = ua
+	}
+	if referer := req.Referer(); referer != "" {
+		fields[FieldReferer] = referer
+	}
+	return fields
+}
+
+func requestURI(req *http.Request) string {
+	if req.RequestURI != "" {
+		return req.RequestURI
+	}
+	return req.URL.RequestURI()
+}
+
+type countingBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.read, int64(n))
+	return n, err
+}
@@ -0,0 +1,96 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger(t *testing.T, conf Config) (*Logger, *bytes.Buffer) {
+	conf.Sink = SinkStdout
+	l, err := New(conf)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	l.logger.Out = buf
+	return l, buf
+}
+
+func serve(l *Logger, status int) {
+	handler := http_ctxtags.Middleware("test")(l.Middleware()(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		tags := http_ctxtags.ExtractInbound(req)
+		tags.Set(ctxtags.TagForProxyBackend, "backend_a")
+		tags.Set(ctxtags.TagForBackendTargetAddr, "10.0.0.1:8080")
+		tags.Set(ctxtags.TagForUpstreamTime, 2*time.Second)
+		tags.Set(ctxtags.TagForAuthSubject, "someone@example.com")
+		io.Copy(ioutil.Discard, req.Body)
+		resp.WriteHeader(status)
+		resp.Write([]byte("hello"))
+	})))
+	req := httptest.NewRequest("POST", "/some/path?a=b", strings.NewReader("some body"))
+	req.Host = "backend.example.com"
+	req.RemoteAddr = "192.168.0.1:1234"
+	req.Header.Set("User-Agent", "test-agent")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestMiddleware_JSONWithSelectedFields(t *testing.T) {
+	l, buf := newTestLogger(t, Config{
+		Format:     FormatJSON,
+		Fields:     []string{FieldStatus, FieldBackend, FieldTarget, FieldUpstreamDuration, FieldRequestBytes, FieldResponseBytes},
+		SampleRate: 1,
+	})
+	serve(l, http.StatusTeapot)
+
+	entry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, float64(http.StatusTeapot), entry[FieldStatus])
+	assert.Equal(t, "backend_a", entry[FieldBackend])
+	assert.Equal(t, "10.0.0.1:8080", entry[FieldTarget])
+	assert.Equal(t, float64(2), entry[FieldUpstreamDuration])
+	assert.Equal(t, float64(len("some body")), entry[FieldRequestBytes])
+	assert.Equal(t, float64(len("hello")), entry[FieldResponseBytes])
+	assert.NotContains(t, entry, FieldUserAgent, "field not selected")
+}
+
+func TestMiddleware_CombinedLogFormat(t *testing.T) {
+	l, buf := newTestLogger(t, Config{Format: FormatCombined, SampleRate: 1})
+	serve(l, http.StatusOK)
+
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "192.168.0.1 - someone@example.com ["), line)
+	assert.True(t, strings.HasSuffix(line, `] "POST /some/path?a=b HTTP/1.1" 200 5 "-" "test-agent"`+"\n"), line)
+}
+
+func TestMiddleware_SamplingAlwaysLogsServerErrors(t *testing.T) {
+	l, buf := newTestLogger(t, Config{Format: FormatLogfmt, SampleRate: 0})
+	serve(l, http.StatusOK)
+	assert.Empty(t, buf.String())
+
+	serve(l, http.StatusBadGateway)
+	assert.Contains(t, buf.String(), "status=502")
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	for _, conf := range []Config{
+		{Sink: SinkStdout, Format: "unknown", SampleRate: 1},
+		{Sink: "unknown", Format: FormatJSON, SampleRate: 1},
+		{Sink: SinkStdout, Format: FormatJSON, SampleRate: 2},
+		{Sink: SinkStdout, Format: FormatJSON, SampleRate: 1, Fields: []string{"unknown"}},
+		{Sink: SinkFile, Format: FormatJSON, SampleRate: 1},
+		{Sink: SinkLogstash, Format: FormatJSON, SampleRate: 1},
+	} {
+		_, err := New(conf)
+		assert.Error(t, err, "config %v", conf)
+	}
+}
@@ -0,0 +1,53 @@
+package accesslog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/sirupsen/logrus"
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// clfFormatter formats entries in NCSA Common Log Format, or Combined Log Format if combined is set.
+// See https://httpd.apache.org/docs/2.4/logs.html#common
+type clfFormatter struct {
+	combined bool
+}
+
+func (f *clfFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	host := clfValue(entry.Data[FieldRemoteAddr])
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	responseBytes := clfValue(entry.Data[FieldResponseBytes])
+	if responseBytes == "0" {
+		responseBytes = "-"
+	}
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "%s - %s [%s] \"%s %s %s\" %s %s",
+		host,
+		clfValue(entry.Data[FieldAuthSubject]),
+		entry.Time.Format(clfTimeFormat),
+		entry.Data[FieldMethod], entry.Data[FieldURI], entry.Data[FieldProto],
+		clfValue(entry.Data[FieldStatus]),
+		responseBytes,
+	)
+	if f.combined {
+		fmt.Fprintf(b, " %q %q", clfValue(entry.Data[FieldReferer]), clfValue(entry.Data[FieldUserAgent]))
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+func clfValue(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	s := fmt.Sprintf("%v", v)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package accesslog
+
+import (
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	flagSink = sharedflags.Set.String("access_log_sink", SinkNone,
+		"Where to write the access log of proxied requests: none, stdout, file (see access_log_file) or logstash (the remote log sink, see remote_log_sink).")
+	flagFormat = sharedflags.Set.String("access_log_format", FormatJSON,
+		"Format of the access log: json, logfmt, clf (Common Log Format) or combined (Combined Log Format). Ignored for logstash sink.")
+	flagFields = sharedflags.Set.StringSlice("access_log_fields", []string{},
+		"Fields to put in json and logfmt access log entries. If empty, all fields are logged. "+
+			"See lib/accesslog for the list of fields.")
+	flagSampleRate = sharedflags.Set.Float64("access_log_sample_rate", 1.0,
+		"Fraction (0 to 1) of requests to put in the access log. Responses with 5xx status are always logged.")
+
+	flagFile = sharedflags.Set.String("access_log_file", "",
+		"Path of the access log file for file sink.")
+	flagFileMaxSizeMB = sharedflags.Set.Int("access_log_file_max_size_mb", 100,
+		"Size of the access log file in megabytes after which it is rotated.")
+	flagFileMaxBackups = sharedflags.Set.Int("access_log_file_max_backups", 10,
+		"Number of rotated access log files to retain. If 0, all are retained (unless access_log_file_max_age_days is set).")
+	flagFileMaxAgeDays = sharedflags.Set.Int("access_log_file_max_age_days", 0,
+		"Number of days to retain rotated access log files. If 0, files are not removed based on age.")
+)
+
+// NewFromFlags creates an access Logger configured by flags. The logstashHook is used for logstash sink and can be nil
+// if remote logging is not configured.
+func NewFromFlags(logstashHook logrus.Hook) (*Logger, error) {
+	return New(Config{
+		Sink:           *flagSink,
+		Format:         *flagFormat,
+		Fields:         *flagFields,
+		SampleRate:     *flagSampleRate,
+		FilePath:       *flagFile,
+		FileMaxSizeMB:  *flagFileMaxSizeMB,
+		FileMaxBackups: *flagFileMaxBackups,
+		FileMaxAgeDays: *flagFileMaxAgeDays,
+		LogstashHook:   logstashHook,
+	})
+}
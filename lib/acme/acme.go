@@ -0,0 +1,115 @@
+// Package acmecert provisions TLS certificates for public hosts from ACME (e.g. Let's Encrypt) certificate authority.
+//
+// Certificates and the ACME account key are stored either on disk or in a Kubernetes Secret, so they survive restarts
+// and can be shared between kedge replicas.
+package acmecert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Config specifies ACME certificate provisioning.
+type Config struct {
+	// DirectoryURL is the ACME directory endpoint, e.g. https://acme-v01.api.letsencrypt.org/directory.
+	DirectoryURL string
+	// DirectoryCAFile is an optional path to PEM CA certificates used to verify the ACME server (e.g. local pebble).
+	DirectoryCAFile string
+	// Email is the contact address of the ACME account.
+	Email string
+	// Hosts are the only host names certificates are requested for.
+	Hosts []string
+	// RenewBefore specifies how early certificates are renewed before they expire.
+	RenewBefore time.Duration
+	// Cache stores certificates and the ACME account key.
+	Cache autocert.Cache
+}
+
+// Manager obtains and renews certificates for configured hosts.
+type Manager struct {
+	hosts   map[string]struct{}
+	manager *autocert.Manager
+}
+
+// New returns Manager for given config. Certificates are obtained lazily, on the first TLS handshake for the host.
+func New(conf Config) (*Manager, error) {
+	if conf.DirectoryURL == "" {
+		return nil, errors.New("acme: directory URL needs to be specified")
+	}
+	if len(conf.Hosts) == 0 {
+		return nil, errors.New("acme: at least one host needs to be specified")
+	}
+	if conf.Cache == nil {
+		return nil, errors.New("acme: cache needs to be specified")
+	}
+	httpClient := http.DefaultClient
+	if conf.DirectoryCAFile != "" {
+		ca, err := ioutil.ReadFile(conf.DirectoryCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "acme: failed to read directory CA file %s", conf.DirectoryCAFile)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("acme: failed to parse directory CA file %s", conf.DirectoryCAFile)
+		}
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: certPool},
+			},
+		}
+	}
+
+	hosts := make(map[string]struct{}, len(conf.Hosts))
+	for _, h := range conf.Hosts {
+		hosts[strings.ToLower(h)] = struct{}{}
+	}
+	return &Manager{
+		hosts: hosts,
+		manager: &autocert.Manager{
+			Prompt:      autocert.AcceptTOS,
+			Cache:       conf.Cache,
+			HostPolicy:  autocert.HostWhitelist(conf.Hosts...),
+			RenewBefore: conf.RenewBefore,
+			Email:       conf.Email,
+			Client: &acme.Client{
+				DirectoryURL: conf.DirectoryURL,
+				HTTPClient:   httpClient,
+			},
+		},
+	}, nil
+}
+
+// Handles returns true if certificates for given host (SNI server name) are provisioned by the Manager.
+func (m *Manager) Handles(serverName string) bool {
+	_, ok := m.hosts[strings.ToLower(strings.TrimSuffix(serverName, "."))]
+	return ok
+}
+
+// GetCertificate returns certificate from ACME for configured hosts and the fallback one for all the other server
+// names. It is meant to be used as tls.Config.GetCertificate. If fallback is nil, (nil, nil) is returned for
+// not configured hosts, so tls.Config.Certificates are used.
+func (m *Manager) GetCertificate(fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error)) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if m.Handles(hello.ServerName) {
+			return m.manager.GetCertificate(hello)
+		}
+		if fallback != nil {
+			return fallback(hello)
+		}
+		return nil, nil
+	}
+}
+
+// HTTPHandler answers ACME HTTP-01 challenges (/.well-known/acme-challenge/) and passes all other requests to fallback.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	return m.manager.HTTPHandler(fallback)
+}
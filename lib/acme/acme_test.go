@@ -0,0 +1,278 @@
+package acmecert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// fakeCA is a minimal ACME (RFC 8555) directory that issues certificates for a single order, after validating its
+// HTTP-01 challenge against challengeHandler.
+type fakeCA struct {
+	t                *testing.T
+	server           *httptest.Server
+	caCert           *x509.Certificate
+	caKey            *ecdsa.PrivateKey
+	challengeHandler http.Handler
+
+	mu         sync.Mutex
+	nonce      int
+	host       string
+	authzValid bool
+	certDER    []byte
+	validated  int
+}
+
+func newFakeCA(t *testing.T) *fakeCA {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	ca := &fakeCA{t: t, caCert: caCert, caKey: caKey}
+	ca.server = httptest.NewServer(http.HandlerFunc(ca.serveHTTP))
+	return ca
+}
+
+func (ca *fakeCA) url(path string) string {
+	return ca.server.URL + path
+}
+
+func (ca *fakeCA) serveHTTP(resp http.ResponseWriter, req *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	ca.nonce++
+	resp.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", ca.nonce))
+	resp.Header().Set("Cache-Control", "no-store")
+	if req.Method == http.MethodHead {
+		return
+	}
+
+	switch req.URL.Path {
+	case "/directory":
+		ca.writeJSON(resp, http.StatusOK, map[string]string{
+			"newNonce":   ca.url("/new-nonce"),
+			"newAccount": ca.url("/new-account"),
+			"newOrder":   ca.url("/new-order"),
+			"revokeCert": ca.url("/revoke-cert"),
+			"keyChange":  ca.url("/key-change"),
+		})
+	case "/new-nonce":
+		resp.WriteHeader(http.StatusNoContent)
+	case "/new-account":
+		resp.Header().Set("Location", ca.url("/account/1"))
+		ca.writeJSON(resp, http.StatusCreated, map[string]interface{}{"status": "valid"})
+	case "/new-order":
+		var order struct {
+			Identifiers []struct {
+				Value string `json:"value"`
+			} `json:"identifiers"`
+		}
+		ca.readPayload(req, &order)
+		require.Len(ca.t, order.Identifiers, 1)
+		ca.host = order.Identifiers[0].Value
+		resp.Header().Set("Location", ca.url("/order/1"))
+		ca.writeJSON(resp, http.StatusCreated, ca.order())
+	case "/order/1":
+		resp.Header().Set("Location", ca.url("/order/1"))
+		ca.writeJSON(resp, http.StatusOK, ca.order())
+	case "/authz/1":
+		ca.writeJSON(resp, http.StatusOK, ca.authz())
+	case "/challenge/1":
+		ca.validateChallenge()
+		ca.writeJSON(resp, http.StatusOK, ca.challenge())
+	case "/finalize/1":
+		var finalize struct {
+			CSR string `json:"csr"`
+		}
+		ca.readPayload(req, &finalize)
+		ca.issue(finalize.CSR)
+		resp.Header().Set("Location", ca.url("/order/1"))
+		ca.writeJSON(resp, http.StatusOK, ca.order())
+	case "/cert/1":
+		resp.Header().Set("Content-Type", "application/pem-certificate-chain")
+		pem.Encode(resp, &pem.Block{Type: "CERTIFICATE", Bytes: ca.certDER})
+		pem.Encode(resp, &pem.Block{Type: "CERTIFICATE", Bytes: ca.caCert.Raw})
+	default:
+		resp.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (ca *fakeCA) order() map[string]interface{} {
+	status := "pending"
+	if ca.authzValid {
+		status = "ready"
+	}
+	order := map[string]interface{}{
+		"status":         status,
+		"identifiers":    []map[string]string{{"type": "dns", "value": ca.host}},
+		"authorizations": []string{ca.url("/authz/1")},
+		"finalize":       ca.url("/finalize/1"),
+	}
+	if ca.certDER != nil {
+		order["status"] = "valid"
+		order["certificate"] = ca.url("/cert/1")
+	}
+	return order
+}
+
+func (ca *fakeCA) authz() map[string]interface{} {
+	status := "pending"
+	if ca.authzValid {
+		status = "valid"
+	}
+	return map[string]interface{}{
+		"status":     status,
+		"identifier": map[string]string{"type": "dns", "value": ca.host},
+		"challenges": []interface{}{ca.challenge()},
+	}
+}
+
+func (ca *fakeCA) challenge() map[string]interface{} {
+	status := "pending"
+	if ca.authzValid {
+		status = "valid"
+	}
+	return map[string]interface{}{
+		"type":   "http-01",
+		"url":    ca.url("/challenge/1"),
+		"token":  "some-token",
+		"status": status,
+	}
+}
+
+// validateChallenge does what a real CA does: fetches the HTTP-01 key authorization from the validated host.
+func (ca *fakeCA) validateChallenge() {
+	ca.validated++
+	req := httptest.NewRequest("GET", "http://"+ca.host+"/.well-known/acme-challenge/some-token", nil)
+	resp := httptest.NewRecorder()
+	ca.challengeHandler.ServeHTTP(resp, req)
+	ca.authzValid = resp.Code == http.StatusOK && strings.HasPrefix(resp.Body.String(), "some-token.")
+}
+
+func (ca *fakeCA) issue(csrB64 string) {
+	csrDER, err := base64.RawURLEncoding.DecodeString(csrB64)
+	require.NoError(ca.t, err)
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	require.NoError(ca.t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: ca.host},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(12 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	ca.certDER, err = x509.CreateCertificate(rand.Reader, template, ca.caCert, csr.PublicKey, ca.caKey)
+	require.NoError(ca.t, err)
+}
+
+// readPayload decodes payload of the JWS request. Signatures are not verified.
+func (ca *fakeCA) readPayload(req *http.Request, v interface{}) {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	require.NoError(ca.t, json.NewDecoder(req.Body).Decode(&jws))
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	require.NoError(ca.t, err)
+	require.NoError(ca.t, json.Unmarshal(payload, v))
+}
+
+func (ca *fakeCA) writeJSON(resp http.ResponseWriter, code int, v interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(code)
+	require.NoError(ca.t, json.NewEncoder(resp).Encode(v))
+}
+
+func TestManager_GetCertificate_FallsBackForOtherHosts(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	m, err := New(Config{
+		DirectoryURL: "https://acme.invalid/directory",
+		Hosts:        []string{"public.example.com"},
+		Cache:        autocert.DirCache(cacheDir), // never written to in this test.
+	})
+	require.NoError(t, err)
+	assert.True(t, m.Handles("Public.Example.com."))
+	assert.False(t, m.Handles("internal.example.com"))
+
+	getCert := m.GetCertificate(nil)
+	cert, err := getCert(&tls.ClientHelloInfo{ServerName: "internal.example.com"})
+	assert.NoError(t, err)
+	assert.Nil(t, cert, "static certificates should be used for other hosts")
+}
+
+func TestManager_GetCertificate_ObtainsCertificateUsingHTTP01(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	ca := newFakeCA(t)
+	defer ca.server.Close()
+	m, err := New(Config{
+		DirectoryURL: ca.url("/directory"),
+		Email:        "admin@example.com",
+		Hosts:        []string{"public.example.com"},
+		RenewBefore:  time.Hour,
+		Cache:        autocert.DirCache(cacheDir),
+	})
+	require.NoError(t, err)
+	fallback := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusTeapot)
+	})
+	ca.challengeHandler = m.HTTPHandler(fallback)
+
+	cert, err := m.GetCertificate(nil)(&tls.ClientHelloInfo{ServerName: "public.example.com"})
+	require.NoError(t, err)
+	require.NotNil(t, cert)
+	require.Len(t, cert.Certificate, 2, "leaf and the issuing CA are expected")
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname("public.example.com"))
+	assert.Equal(t, ca.caCert.Subject.CommonName, leaf.Issuer.CommonName)
+	assert.Equal(t, 1, ca.validated, "HTTP-01 challenge should be validated once")
+
+	files, err := ioutil.ReadDir(cacheDir)
+	require.NoError(t, err)
+	assert.NotEmpty(t, files, "certificate and account key should be cached")
+
+	// Requests that are not ACME challenges are passed to the fallback handler.
+	resp := httptest.NewRecorder()
+	ca.challengeHandler.ServeHTTP(resp, httptest.NewRequest("GET", "http://public.example.com/index.html", nil))
+	assert.Equal(t, http.StatusTeapot, resp.Code)
+}
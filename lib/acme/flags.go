@@ -0,0 +1,72 @@
+package acmecert
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mwitkow/kedge/lib/resolvers/k8s"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var (
+	fDirectoryURL = sharedflags.Set.String("acme_directory_url", "",
+		"ACME directory URL (e.g. https://acme-v01.api.letsencrypt.org/directory) used to obtain certificates for "+
+			"acme_hosts. If empty, ACME certificate provisioning is disabled. HTTP-01 challenges are answered on "+
+			"server_http_redirect_port, so it needs to be set.")
+	fDirectoryCAFile = sharedflags.Set.String("acme_directory_ca_file", "",
+		"Path to PEM CA certificates used to verify ACME directory server. If empty, system roots are used.")
+	fEmail = sharedflags.Set.String("acme_email", "", "Contact email of the ACME account.")
+	fHosts = sharedflags.Set.StringSlice("acme_hosts", []string{},
+		"Public host names (comma separated) to obtain certificates for. Certificates for other server names are "+
+			"taken from server_tls_cert_file.")
+	fRenewBefore = sharedflags.Set.Duration("acme_renew_before", 30*24*time.Hour,
+		"How early certificates are renewed before they expire.")
+	fCacheDir = sharedflags.Set.String("acme_cache_dir", "",
+		"Directory to store ACME certificates and account key in. Either this or acme_k8s_secret needs to be specified.")
+	fK8sSecret = sharedflags.Set.String("acme_k8s_secret", "",
+		"Kubernetes Secret in a form of '<namespace>/<name>' to store ACME certificates and account key in. "+
+			"Kube API access is configured using k8sresolver_* flags.")
+)
+
+// NewFromFlags returns Manager configured from sharedflags.Set. It returns nil Manager if ACME is disabled.
+// The httpChallengePort is the port of the plain HTTP listener that serves Manager.HTTPHandler. The only challenge
+// type supported is HTTP-01, so ACME cannot be enabled without it.
+func NewFromFlags(httpChallengePort int) (*Manager, error) {
+	if *fDirectoryURL == "" {
+		return nil, nil
+	}
+	if httpChallengePort == 0 {
+		return nil, errors.New("acme: HTTP-01 challenges are served on server_http_redirect_port, it needs to be specified")
+	}
+
+	var cache autocert.Cache
+	switch {
+	case *fCacheDir != "" && *fK8sSecret != "":
+		return nil, errors.New("acme: only one of acme_cache_dir and acme_k8s_secret can be specified")
+	case *fCacheDir != "":
+		cache = autocert.DirCache(*fCacheDir)
+	case *fK8sSecret != "":
+		split := strings.Split(*fK8sSecret, "/")
+		if len(split) != 2 || split[0] == "" || split[1] == "" {
+			return nil, errors.Errorf("acme: acme_k8s_secret needs to be in a form of '<namespace>/<name>'. Value %s", *fK8sSecret)
+		}
+		k8sURL, k8sClient, err := k8sresolver.NewClientFromFlags()
+		if err != nil {
+			return nil, errors.Wrap(err, "acme: failed to create kube API client")
+		}
+		cache = NewSecretCache(k8sURL, k8sClient, split[0], split[1])
+	default:
+		return nil, errors.New("acme: either acme_cache_dir or acme_k8s_secret needs to be specified")
+	}
+
+	return New(Config{
+		DirectoryURL:    *fDirectoryURL,
+		DirectoryCAFile: *fDirectoryCAFile,
+		Email:           *fEmail,
+		Hosts:           *fHosts,
+		RenewBefore:     *fRenewBefore,
+		Cache:           cache,
+	})
+}
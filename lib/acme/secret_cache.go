@@ -0,0 +1,183 @@
+package acmecert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const maxSecretUpdateAttempts = 5
+
+// secretCache is autocert.Cache storing all entries as data keys of a single Kubernetes Secret.
+type secretCache struct {
+	k8sURL    string
+	k8sClient *http.Client
+	namespace string
+	name      string
+}
+
+// NewSecretCache returns autocert.Cache storing entries in the given Kubernetes Secret. Secret is created if it
+// does not exist. Given http.Client needs to be configured to be used against kube-apiserver
+// (see k8sresolver.NewClientFromFlags).
+func NewSecretCache(k8sURL string, k8sClient *http.Client, namespace string, name string) autocert.Cache {
+	return &secretCache{
+		k8sURL:    k8sURL,
+		k8sClient: k8sClient,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// secret is the part of the Kubernetes Secret object used by the cache.
+type secret struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Metadata   secretMetadata `json:"metadata"`
+	Type       string         `json:"type,omitempty"`
+	// Data values are base64 encoded by the JSON encoding.
+	Data map[string][]byte `json:"data"`
+}
+
+type secretMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// secretDataKey maps cache key to a valid Secret data key. Autocert keys are host names optionally suffixed with
+// "+<kind>" (e.g. "example.com+rsa") and '+' is not allowed in Secret keys. Host names never contain '_'.
+func secretDataKey(key string) string {
+	return strings.Replace(key, "+", "_", -1)
+}
+
+func (c *secretCache) Get(ctx context.Context, key string) ([]byte, error) {
+	s, err := c.getSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, autocert.ErrCacheMiss
+	}
+	data, ok := s.Data[secretDataKey(key)]
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, nil
+}
+
+func (c *secretCache) Put(ctx context.Context, key string, data []byte) error {
+	return c.update(ctx, func(s *secret) { s.Data[secretDataKey(key)] = data })
+}
+
+func (c *secretCache) Delete(ctx context.Context, key string) error {
+	return c.update(ctx, func(s *secret) { delete(s.Data, secretDataKey(key)) })
+}
+
+// update applies the change to the current Secret. Conflicting concurrent updates (e.g. from other replicas) are retried.
+func (c *secretCache) update(ctx context.Context, change func(s *secret)) error {
+	for i := 0; i < maxSecretUpdateAttempts; i++ {
+		s, err := c.getSecret(ctx)
+		if err != nil {
+			return err
+		}
+		create := s == nil
+		if create {
+			s = &secret{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Metadata:   secretMetadata{Name: c.name, Namespace: c.namespace},
+				Type:       "Opaque",
+			}
+		}
+		if s.Data == nil {
+			s.Data = map[string][]byte{}
+		}
+		change(s)
+
+		err = c.putSecret(ctx, s, create)
+		if err == errSecretConflict {
+			continue
+		}
+		return err
+	}
+	return errors.Errorf("acme: failed to update secret %s/%s after %d attempts due to conflicts", c.namespace, c.name, maxSecretUpdateAttempts)
+}
+
+var errSecretConflict = errors.New("acme: secret was modified concurrently")
+
+func (c *secretCache) secretsURL() string {
+	return fmt.Sprintf("%s/api/v1/namespaces/%s/secrets", c.k8sURL, c.namespace)
+}
+
+// getSecret returns nil if the Secret does not exist.
+func (c *secretCache) getSecret(ctx context.Context) (*secret, error) {
+	url := fmt.Sprintf("%s/%s", c.secretsURL(), c.name)
+	resp, err := c.do(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("acme: invalid response code %d on GET %s request", resp.StatusCode, url)
+	}
+	s := &secret{}
+	if err := json.NewDecoder(resp.Body).Decode(s); err != nil {
+		return nil, errors.Wrapf(err, "acme: failed to decode secret from GET %s", url)
+	}
+	return s, nil
+}
+
+// putSecret creates (POST) or replaces (PUT) the Secret. errSecretConflict is returned if the Secret was modified
+// (or created) in the meantime.
+func (c *secretCache) putSecret(ctx context.Context, s *secret, create bool) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "acme: failed to encode secret")
+	}
+	method, url := "PUT", fmt.Sprintf("%s/%s", c.secretsURL(), c.name)
+	if create {
+		method, url = "POST", c.secretsURL()
+	}
+	resp, err := c.do(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusConflict:
+		return errSecretConflict
+	default:
+		return errors.Errorf("acme: invalid response code %d on %s %s request", resp.StatusCode, method, url)
+	}
+}
+
+func (c *secretCache) do(ctx context.Context, method string, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "acme: failed to create new %s request %s", method, url)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.k8sClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "acme: failed to do %s %s request", method, url)
+	}
+	return resp, nil
+}
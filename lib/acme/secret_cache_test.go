@@ -0,0 +1,103 @@
+package acmecert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const secretPath = "/api/v1/namespaces/kedge/secrets/acme-certs"
+
+// fakeSecretAPI is a minimal kube-apiserver handling a single Secret with optimistic concurrency.
+type fakeSecretAPI struct {
+	mu              sync.Mutex
+	secret          *secret
+	resourceVersion int
+	// conflictsLeft makes the next N updates fail with 409 Conflict.
+	conflictsLeft int
+}
+
+func (f *fakeSecretAPI) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case req.Method == "GET" && req.URL.Path == secretPath:
+		if f.secret == nil {
+			resp.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(resp).Encode(f.secret)
+	case req.Method == "POST" && req.URL.Path == "/api/v1/namespaces/kedge/secrets",
+		req.Method == "PUT" && req.URL.Path == secretPath:
+		s := &secret{}
+		if err := json.NewDecoder(req.Body).Decode(s); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.conflictsLeft > 0 {
+			f.conflictsLeft--
+			resp.WriteHeader(http.StatusConflict)
+			return
+		}
+		if (req.Method == "POST") != (f.secret == nil) ||
+			(f.secret != nil && s.Metadata.ResourceVersion != f.secret.Metadata.ResourceVersion) {
+			resp.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.resourceVersion++
+		s.Metadata.ResourceVersion = strconv.Itoa(f.resourceVersion)
+		f.secret = s
+		resp.WriteHeader(http.StatusOK)
+	default:
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestSecretCache_PutGetDelete(t *testing.T) {
+	api := &fakeSecretAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	cache := NewSecretCache(srv.URL, srv.Client(), "kedge", "acme-certs")
+	ctx := context.Background()
+
+	_, err := cache.Get(ctx, "example.com")
+	assert.Equal(t, autocert.ErrCacheMiss, err, "secret does not exist yet")
+
+	require.NoError(t, cache.Put(ctx, "acme_account+key", []byte("account key")))
+	require.NoError(t, cache.Put(ctx, "example.com", []byte("example cert")))
+	require.NotNil(t, api.secret)
+	assert.Equal(t, "Secret", api.secret.Kind)
+	assert.Equal(t, []byte("account key"), api.secret.Data["acme_account_key"], "'+' is not allowed in secret keys")
+
+	data, err := cache.Get(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("example cert"), data)
+
+	require.NoError(t, cache.Delete(ctx, "example.com"))
+	_, err = cache.Get(ctx, "example.com")
+	assert.Equal(t, autocert.ErrCacheMiss, err)
+	data, err = cache.Get(ctx, "acme_account+key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("account key"), data)
+}
+
+func TestSecretCache_RetriesOnConflict(t *testing.T) {
+	api := &fakeSecretAPI{conflictsLeft: 2}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	cache := NewSecretCache(srv.URL, srv.Client(), "kedge", "acme-certs")
+
+	require.NoError(t, cache.Put(context.Background(), "example.com", []byte("example cert")))
+	assert.Equal(t, []byte("example cert"), api.secret.Data["example.com"])
+
+	api.conflictsLeft = maxSecretUpdateAttempts
+	assert.Error(t, cache.Put(context.Background(), "example.com", []byte("other cert")))
+}
@@ -0,0 +1,145 @@
+// Package auditlog implements an append-only audit log of authorization decisions made by kedge.
+//
+// Records are JSON lines, written separately from both the operational and the access log.
+package auditlog
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SinkNone     = "none"
+	SinkFile     = "file"
+	SinkLogstash = "logstash"
+
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// Record is a single authorization decision.
+type Record struct {
+	Decision string
+	Reason   string
+
+	// Subject is email or, if not present, sub claim of the OIDC ID token. For denied requests it comes from an
+	// unverified token.
+	Subject string
+	// CertCommonName is the common name of the verified TLS client certificate.
+	CertCommonName string
+	// PermsClaim is the name of the ID token claim the permissions were checked against and Perms are its values.
+	PermsClaim string
+	Perms      []string
+
+	Route   string
+	Backend string
+
+	RequestID  string
+	RemoteAddr string
+	Method     string
+	Host       string
+	URI        string
+}
+
+func (r *Record) fields() logrus.Fields {
+	fields := logrus.Fields{
+		"decision": r.Decision,
+		"reason":   r.Reason,
+	}
+	for key, val := range map[string]string{
+		"subject":     r.Subject,
+		"cert_cn":     r.CertCommonName,
+		"perms_claim": r.PermsClaim,
+		"route":       r.Route,
+		"backend":     r.Backend,
+		"request_id":  r.RequestID,
+		"remote_addr": r.RemoteAddr,
+		"method":      r.Method,
+		"host":        r.Host,
+		"uri":         r.URI,
+	} {
+		if val != "" {
+			fields[key] = val
+		}
+	}
+	if len(r.Perms) > 0 {
+		fields["perms"] = r.Perms
+	}
+	return fields
+}
+
+// Config configures the audit Logger.
+type Config struct {
+	// Sink is one of SinkNone, SinkFile or SinkLogstash.
+	Sink string
+	// FilePath is the path of the file for SinkFile. Records are always appended to it.
+	FilePath string
+	// LogstashHook is the hook for SinkLogstash, usually the remote log sink hook used by the operational log.
+	LogstashHook logrus.Hook
+	// PermsClaim is the name of the ID token claim with permissions, recorded for OIDC authorization.
+	PermsClaim string
+}
+
+// Logger writes audit records. Zero value Logger discards them.
+type Logger struct {
+	logger     *logrus.Logger
+	permsClaim string
+	closer     io.Closer
+}
+
+// New creates an audit Logger.
+func New(conf Config) (*Logger, error) {
+	logger := logrus.New()
+	logger.Level = logrus.InfoLevel
+	logger.Formatter = &logrus.JSONFormatter{}
+	l := &Logger{permsClaim: conf.PermsClaim}
+	switch conf.Sink {
+	case SinkNone:
+		return l, nil
+	case SinkFile:
+		if conf.FilePath == "" {
+			return nil, errors.New("auditlog: file path is required for file sink")
+		}
+		file, err := os.OpenFile(conf.FilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, errors.Wrap(err, "auditlog: failed to open file")
+		}
+		logger.Out = file
+		l.closer = file
+	case SinkLogstash:
+		if conf.LogstashHook == nil {
+			return nil, errors.New("auditlog: logstash sink requires remote log sink to be configured")
+		}
+		logger.Out = ioutil.Discard
+		logger.Hooks.Add(conf.LogstashHook)
+	default:
+		return nil, errors.Errorf("auditlog: unknown sink %q", conf.Sink)
+	}
+	l.logger = logger
+	return l, nil
+}
+
+// PermsClaim returns the name of the ID token claim with permissions.
+func (l *Logger) PermsClaim() string {
+	return l.permsClaim
+}
+
+// Log writes the record.
+func (l *Logger) Log(record *Record) {
+	if l.logger == nil {
+		return
+	}
+	l.logger.WithFields(record.fields()).Info("authorization decision")
+}
+
+// Close closes the audit file, if any.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
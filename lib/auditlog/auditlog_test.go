@@ -0,0 +1,65 @@
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_AppendsJSONLinesToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auditlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("{\"existing\":true}\n"), 0600))
+
+	l, err := New(Config{Sink: SinkFile, FilePath: path, PermsClaim: "perms"})
+	require.NoError(t, err)
+	assert.Equal(t, "perms", l.PermsClaim())
+	l.Log(&Record{Decision: DecisionAllow, Reason: "authorized", Subject: "someone@example.com", PermsClaim: "perms", Perms: []string{"admin"}, Route: "*/*", Backend: "backend_a"})
+	l.Log(&Record{Decision: DecisionDeny, Reason: "token expired"})
+	require.NoError(t, l.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 3)
+	assert.Equal(t, true, records[0]["existing"], "existing records should be kept")
+	assert.Equal(t, "allow", records[1]["decision"])
+	assert.Equal(t, "someone@example.com", records[1]["subject"])
+	assert.Equal(t, []interface{}{"admin"}, records[1]["perms"])
+	assert.Equal(t, "backend_a", records[1]["backend"])
+	assert.Equal(t, "deny", records[2]["decision"])
+	assert.Equal(t, "token expired", records[2]["reason"])
+	assert.NotContains(t, records[2], "subject", "empty fields should be omitted")
+}
+
+func TestLogger_ZeroValueDiscards(t *testing.T) {
+	l := &Logger{}
+	l.Log(&Record{Decision: DecisionAllow})
+	assert.NoError(t, l.Close())
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	for _, conf := range []Config{
+		{Sink: "unknown"},
+		{Sink: SinkFile},
+		{Sink: SinkLogstash},
+	} {
+		_, err := New(conf)
+		assert.Error(t, err, "config %v", conf)
+	}
+}
@@ -0,0 +1,24 @@
+package auditlog
+
+import (
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	flagSink = sharedflags.Set.String("audit_log_sink", SinkNone,
+		"Where to write the audit log of authorization decisions: none, file (see audit_log_file) or logstash (the remote log sink, see remote_log_sink).")
+	flagFile = sharedflags.Set.String("audit_log_file", "",
+		"Path of the audit log file for file sink. Records are appended as JSON lines, the file is never rotated by kedge.")
+)
+
+// NewFromFlags creates an audit Logger configured by flags. The logstashHook is used for logstash sink and can be nil
+// if remote logging is not configured.
+func NewFromFlags(logstashHook logrus.Hook, permsClaim string) (*Logger, error) {
+	return New(Config{
+		Sink:         *flagSink,
+		FilePath:     *flagFile,
+		LogstashHook: logstashHook,
+		PermsClaim:   permsClaim,
+	})
+}
@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const tlsSecretType = "kubernetes.io/tls"
+
+type secret struct {
+	Metadata objectMeta        `json:"metadata"`
+	Type     string            `json:"type"`
+	Data     map[string][]byte `json:"data"`
+}
+
+// Certificates are TLS certificates of Ingresses by host name, loaded from their TLS Secrets.
+type Certificates struct {
+	mu     sync.RWMutex
+	byHost map[string]*tls.Certificate
+}
+
+// NewCertificates returns empty Certificates, filled by Controller serving Ingresses.
+func NewCertificates() *Certificates {
+	return &Certificates{byHost: map[string]*tls.Certificate{}}
+}
+
+// GetCertificate returns tls.Config GetCertificate function selecting Ingress certificates by SNI, falling back to
+// fallback (that can be nil) for other server names.
+func (c *Certificates) GetCertificate(fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error)) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if cert := c.get(strings.ToLower(hello.ServerName)); cert != nil {
+			return cert, nil
+		}
+		if fallback != nil {
+			return fallback(hello)
+		}
+		return nil, nil
+	}
+}
+
+func (c *Certificates) get(serverName string) *tls.Certificate {
+	if serverName == "" {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if cert, ok := c.byHost[serverName]; ok {
+		return cert
+	}
+	// Try wildcard certificate of the parent domain.
+	if i := strings.Index(serverName, "."); i > 0 {
+		return c.byHost["*"+serverName[i:]]
+	}
+	return nil
+}
+
+func (c *Certificates) update(byHost map[string]*tls.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byHost = byHost
+}
+
+// ingressCertificates loads certificates for TLS hosts of ingresses from secrets. Certificates that cannot be loaded
+// are returned as errors by ingress namespace/name.
+func ingressCertificates(ingresses []*ingress, secrets map[string]json.RawMessage) (map[string]*tls.Certificate, map[string]error) {
+	byHost := map[string]*tls.Certificate{}
+	errs := map[string]error{}
+	for _, ing := range ingresses {
+		for _, t := range ing.Spec.TLS {
+			if t.SecretName == "" {
+				continue
+			}
+			cert, err := loadCertificate(secrets, ing.Metadata.Namespace, t.SecretName)
+			if err != nil {
+				errs[ing.Metadata.Namespace+"/"+ing.Metadata.Name] = err
+				continue
+			}
+			for _, host := range t.Hosts {
+				host = strings.ToLower(host)
+				// First ingress wins, as for routes.
+				if _, ok := byHost[host]; !ok {
+					byHost[host] = cert
+				}
+			}
+		}
+	}
+	return byHost, errs
+}
+
+func loadCertificate(secrets map[string]json.RawMessage, namespace string, name string) (*tls.Certificate, error) {
+	raw, ok := secrets[namespace+"/"+name]
+	if !ok {
+		return nil, errors.Errorf("TLS secret %v not found", name)
+	}
+	s := &secret{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode TLS secret %v", name)
+	}
+	if s.Type != tlsSecretType {
+		return nil, errors.Errorf("secret %v is of type %q, expected %q", name, s.Type, tlsSecretType)
+	}
+	cert, err := tls.X509KeyPair(s.Data["tls.crt"], s.Data["tls.key"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load key pair from TLS secret %v", name)
+	}
+	return &cert, nil
+}
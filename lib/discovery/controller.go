@@ -0,0 +1,362 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var watchRetryBackoff = &backoff.Backoff{
+	Min:    10 * time.Millisecond,
+	Jitter: true,
+	Factor: 2,
+	Max:    30 * time.Second,
+}
+
+// Kinds of watched objects.
+const (
+	kindServices          = "services"
+	kindAnnotatedServices = "annotated services"
+	kindIngresses         = "ingresses"
+	kindGateways          = "gateways"
+	kindHTTPRoutes        = "httproutes"
+	kindTLSSecrets        = "secrets"
+)
+
+// Controller watches Kubernetes Services, Ingresses and Gateway API objects and synthesizes kedge backends and routes from them.
+type Controller struct {
+	logger       logrus.FieldLogger
+	config       Config
+	certificates *Certificates
+	onUpdate     func(Configs)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// objects are objects by namespace/name for each watched collection path, by kind. TLS Secrets are by
+	// namespace/name of the watched Secret instead of path.
+	objects map[string]map[string]map[string]json.RawMessage
+	errs    map[string]string
+	// secretWatches are watches of TLS Secrets referenced by served Ingresses and Gateways, by Secret namespace/name.
+	secretWatches map[string]*secretWatch
+}
+
+type secretWatch struct {
+	cancel context.CancelFunc
+}
+
+// Config configures Controller.
+type Config struct {
+	K8sURL    string
+	K8sClient *http.Client
+	// Namespaces to watch objects in. All namespaces if empty.
+	Namespaces []string
+
+	// DiscoverServices enables synthesizing backends and routes from annotations of Services.
+	DiscoverServices bool
+	// LabelSelector filters annotated Services, e.g. "app=foo,tier!=db".
+	LabelSelector string
+	// AuthAvailable tells whether kedge authorizes requests. Services requiring auth are not exposed without it.
+	AuthAvailable bool
+
+	// IngressClass enables serving Ingresses of this class. Ingresses are not watched if empty.
+	IngressClass string
+	// GatewayClass enables serving HTTPRoutes attached to Gateways of this class. Gateway API objects are not watched
+	// if empty.
+	GatewayClass string
+	// IngressStatusAddresses are IPs or host names of kedge written to status of served Ingresses and Gateways. Status
+	// is not updated if empty.
+	IngressStatusAddresses []string
+}
+
+// watchesAllServices tells whether routes are served from objects that can point to any Service.
+func (c Config) watchesAllServices() bool {
+	return c.IngressClass != "" || c.GatewayClass != ""
+}
+
+// Start starts watching objects. onUpdate is called with all discovered configs every time any of them changes.
+// TLS certificates of served Ingresses and Gateways are put into certificates.
+func Start(logger logrus.FieldLogger, config Config, certificates *Certificates, onUpdate func(Configs)) *Controller {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Controller{
+		logger:       logger,
+		config:       config,
+		certificates: certificates,
+		onUpdate:     onUpdate,
+		ctx:          ctx,
+		cancel:       cancel,
+		objects: map[string]map[string]map[string]json.RawMessage{
+			kindServices:          {},
+			kindAnnotatedServices: {},
+			kindIngresses:         {},
+			kindGateways:          {},
+			kindHTTPRoutes:        {},
+			kindTLSSecrets:        {},
+		},
+		errs:          map[string]string{},
+		secretWatches: map[string]*secretWatch{},
+	}
+
+	if config.DiscoverServices && (!config.watchesAllServices() || config.LabelSelector != "") {
+		c.watch(ctx, kindAnnotatedServices, "/api/v1", "services", config.LabelSelector, "")
+	}
+	if config.watchesAllServices() {
+		// Ingresses and HTTPRoutes can point to any Service. They are also the annotated ones if there is no label
+		// selector.
+		c.watch(ctx, kindServices, "/api/v1", "services", "", "")
+	}
+	// TLS Secrets are watched only once referenced by served Ingresses or Gateways, see watchSecrets.
+	if config.IngressClass != "" {
+		c.watch(ctx, kindIngresses, "/apis/networking.k8s.io/v1", "ingresses", "", "")
+	}
+	if config.GatewayClass != "" {
+		c.watch(ctx, kindGateways, "/apis/gateway.networking.k8s.io/v1", "gateways", "", "")
+		c.watch(ctx, kindHTTPRoutes, "/apis/gateway.networking.k8s.io/v1", "httproutes", "", "")
+	}
+	return c
+}
+
+func (c *Controller) watch(ctx context.Context, kind string, apiPath string, resource string, labelSelector string, fieldSelector string) {
+	paths := []string{fmt.Sprintf("%s/%s", apiPath, resource)}
+	if len(c.config.Namespaces) > 0 {
+		paths = nil
+		for _, ns := range c.config.Namespaces {
+			paths = append(paths, fmt.Sprintf("%s/namespaces/%s/%s", apiPath, ns, resource))
+		}
+	}
+	for _, path := range paths {
+		path := path
+		w := c.listWatcher(path, labelSelector, fieldSelector, func(objects map[string]json.RawMessage) {
+			c.mu.Lock()
+			c.objects[kind][path] = objects
+			c.sync()
+		})
+		go w.run(ctx)
+	}
+}
+
+// watchSecrets watches exactly the TLS Secrets referenced by ingresses, so kedge does not need to read all Secrets of
+// the cluster. Watches of Secrets that are not referenced anymore are stopped. Must be called with c.mu held.
+func (c *Controller) watchSecrets(ingresses []*ingress) {
+	referenced := map[string]struct{}{}
+	for _, ing := range ingresses {
+		for _, t := range ing.Spec.TLS {
+			if t.SecretName != "" {
+				referenced[ing.Metadata.Namespace+"/"+t.SecretName] = struct{}{}
+			}
+		}
+	}
+	for key, sw := range c.secretWatches {
+		if _, ok := referenced[key]; !ok {
+			sw.cancel()
+			delete(c.secretWatches, key)
+			delete(c.objects[kindTLSSecrets], key)
+		}
+	}
+	for key := range referenced {
+		if _, ok := c.secretWatches[key]; ok {
+			continue
+		}
+		key := key
+		namespace, name := splitKey(key)
+		ctx, cancel := context.WithCancel(c.ctx)
+		sw := &secretWatch{cancel: cancel}
+		c.secretWatches[key] = sw
+		path := fmt.Sprintf("/api/v1/namespaces/%s/secrets", namespace)
+		fieldSelector := fmt.Sprintf("metadata.name=%s,type=%s", name, tlsSecretType)
+		w := c.listWatcher(path, "", fieldSelector, func(objects map[string]json.RawMessage) {
+			c.mu.Lock()
+			if c.secretWatches[key] != sw {
+				// Stopped watch, the Secret is not referenced anymore.
+				c.mu.Unlock()
+				return
+			}
+			c.objects[kindTLSSecrets][key] = objects
+			c.sync()
+		})
+		go w.run(ctx)
+	}
+}
+
+func (c *Controller) listWatcher(path string, labelSelector string, fieldSelector string, onChange func(map[string]json.RawMessage)) *listWatcher {
+	return &listWatcher{
+		logger:        c.logger,
+		k8sURL:        c.config.K8sURL,
+		k8sClient:     c.config.K8sClient,
+		path:          path,
+		labelSelector: labelSelector,
+		fieldSelector: fieldSelector,
+		retryBackoff: &backoff.Backoff{
+			Min:    watchRetryBackoff.Min,
+			Jitter: watchRetryBackoff.Jitter,
+			Factor: watchRetryBackoff.Factor,
+			Max:    watchRetryBackoff.Max,
+		},
+		onChange: onChange,
+	}
+}
+
+func splitKey(key string) (namespace string, name string) {
+	i := strings.Index(key, "/")
+	return key[:i], key[i+1:]
+}
+
+func (c *Controller) all(kind string) map[string]json.RawMessage {
+	all := map[string]json.RawMessage{}
+	for _, objects := range c.objects[kind] {
+		for key, object := range objects {
+			all[key] = object
+		}
+	}
+	return all
+}
+
+// sync synthesizes configs from all watched objects and calls onUpdate with them. Must be called with c.mu held, which
+// it releases before updating status of Ingresses and Gateways.
+func (c *Controller) sync() {
+	configs := emptyConfigs()
+	errs := map[string]error{}
+	services := c.all(kindServices)
+	if c.config.DiscoverServices {
+		annotated := c.all(kindAnnotatedServices)
+		if c.config.watchesAllServices() && c.config.LabelSelector == "" {
+			annotated = services
+		}
+		var serviceErrs map[string]error
+		configs, serviceErrs = servicesToConfigs(annotated, c.config.AuthAvailable)
+		for key, err := range serviceErrs {
+			errs["service "+key] = err
+		}
+	}
+	var ingressesToUpdate []*ingress
+	var gatewaysToUpdate []*gateway
+	if c.config.watchesAllServices() {
+		b := newRouteBuilder(configs, services)
+		// Ingresses and Gateways with TLS Secrets to serve, Gateways translated to Ingresses with their certificates.
+		var ingresses, gatewayIngresses []*ingress
+		if c.config.IngressClass != "" {
+			var ingressErrs map[string]error
+			ingresses, ingressErrs = b.addIngresses(c.all(kindIngresses), c.config.IngressClass)
+			for key, err := range ingressErrs {
+				errs["ingress "+key] = err
+			}
+		}
+		var gateways []*gateway
+		if c.config.GatewayClass != "" {
+			var gatewayErrs, routeErrs map[string]error
+			gateways, gatewayIngresses, gatewayErrs, routeErrs = b.addGateways(c.all(kindGateways), c.all(kindHTTPRoutes), c.config.GatewayClass)
+			for key, err := range gatewayErrs {
+				errs["gateway "+key] = err
+			}
+			for key, err := range routeErrs {
+				errs["httproute "+key] = err
+			}
+		}
+		b.finish()
+
+		c.watchSecrets(append(append([]*ingress{}, ingresses...), gatewayIngresses...))
+		certs, certErrs := ingressCertificates(ingresses, c.all(kindTLSSecrets))
+		for key, err := range certErrs {
+			errs["ingress "+key] = err
+		}
+		gatewayCerts, gatewayCertErrs := ingressCertificates(gatewayIngresses, c.all(kindTLSSecrets))
+		for key, err := range gatewayCertErrs {
+			errs["gateway "+key] = err
+		}
+		for host, cert := range gatewayCerts {
+			// Ingresses win, as they are served first.
+			if _, ok := certs[host]; !ok {
+				certs[host] = cert
+			}
+		}
+		if c.certificates != nil {
+			c.certificates.update(certs)
+		}
+
+		if len(c.config.IngressStatusAddresses) > 0 {
+			status := statusOf(c.config.IngressStatusAddresses)
+			for _, ing := range ingresses {
+				if !reflect.DeepEqual(ing.Status.LoadBalancer.Ingress, status) {
+					ingressesToUpdate = append(ingressesToUpdate, ing)
+				}
+			}
+			addresses := gatewayAddressesOf(c.config.IngressStatusAddresses)
+			for _, gw := range gateways {
+				if !reflect.DeepEqual(gw.Status.Addresses, addresses) {
+					gatewaysToUpdate = append(gatewaysToUpdate, gw)
+				}
+			}
+		}
+	}
+
+	newErrs := map[string]string{}
+	for key, err := range errs {
+		newErrs[key] = err.Error()
+		// Log only new errors, configs are synthesized on every change.
+		if c.errs[key] != err.Error() {
+			c.logger.WithError(err).WithField("object", key).Warn("discovery: Skipping invalid configuration")
+		}
+	}
+	c.errs = newErrs
+	c.onUpdate(configs)
+	c.mu.Unlock()
+
+	for _, ing := range ingressesToUpdate {
+		status := map[string]interface{}{
+			"loadBalancer": map[string]interface{}{
+				"ingress": statusOf(c.config.IngressStatusAddresses),
+			},
+		}
+		if err := c.updateStatus("/apis/networking.k8s.io/v1", "ingresses", ing.Metadata, status); err != nil {
+			c.logger.WithError(err).WithField("object", "ingress "+ing.Metadata.Namespace+"/"+ing.Metadata.Name).Error("discovery: Failed to update ingress status")
+		}
+	}
+	for _, gw := range gatewaysToUpdate {
+		status := map[string]interface{}{
+			"addresses": gatewayAddressesOf(c.config.IngressStatusAddresses),
+		}
+		if err := c.updateStatus("/apis/gateway.networking.k8s.io/v1", "gateways", gw.Metadata, status); err != nil {
+			c.logger.WithError(err).WithField("object", "gateway "+gw.Metadata.Namespace+"/"+gw.Metadata.Name).Error("discovery: Failed to update gateway status")
+		}
+	}
+}
+
+// updateStatus merges status into the status of the object, e.g. to set kedge addresses. Updated object is received
+// through the watch again, with status already up to date.
+func (c *Controller) updateStatus(apiPath string, resource string, meta objectMeta, status map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s%s/namespaces/%s/%s/%s/status", c.config.K8sURL, apiPath, meta.Namespace, resource, meta.Name)
+	req, err := http.NewRequest("PATCH", u, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create new PATCH request %s", u)
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	resp, err := c.config.K8sClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to do PATCH %s request", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("Invalid response code %d on PATCH %s request", resp.StatusCode, u)
+	}
+	return nil
+}
+
+// Close stops watching objects.
+func (c *Controller) Close() {
+	c.cancel()
+}
@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func svcJSON(namespace string, name string, resourceVersion string, host string) string {
+	return fmt.Sprintf(`{"metadata":{"name":%q,"namespace":%q,"resourceVersion":%q,"annotations":{"kedge.io/host":%q}}}`,
+		name, namespace, resourceVersion, host)
+}
+
+func TestController_ListsWatchesAndRelistsOnGone(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		lists    int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests = append(requests, req.URL.String())
+		mu.Unlock()
+		flusher := resp.(http.Flusher)
+		switch req.URL.Query().Get("watch") {
+		case "":
+			mu.Lock()
+			lists++
+			n := lists
+			mu.Unlock()
+			if n == 1 {
+				fmt.Fprintf(resp, `{"metadata":{"resourceVersion":"10"},"items":[%s]}`, svcJSON("ns1", "first", "9", "first.example.com"))
+				return
+			}
+			fmt.Fprintf(resp, `{"metadata":{"resourceVersion":"20"},"items":[%s]}`, svcJSON("ns1", "third", "19", "third.example.com"))
+		default:
+			if req.URL.Query().Get("resourceVersion") == "10" {
+				fmt.Fprintf(resp, `{"type":"ADDED","object":%s}`, svcJSON("ns1", "second", "11", "second.example.com"))
+				flusher.Flush()
+				fmt.Fprint(resp, `{"type":"ERROR","object":{"kind":"Status","code":410}}`)
+				return
+			}
+			// Block until client is gone.
+			<-req.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	updates := make(chan Configs, 10)
+	c := Start(logrus.New(), Config{
+		K8sURL:           server.URL,
+		K8sClient:        http.DefaultClient,
+		Namespaces:       []string{"ns1"},
+		DiscoverServices: true,
+		LabelSelector:    "expose=kedge",
+	}, nil, func(configs Configs) { updates <- configs })
+	defer c.Close()
+
+	var hosts [][]string
+	for i := 0; i < 3; i++ {
+		select {
+		case configs := <-updates:
+			var h []string
+			for _, r := range configs.Director.Http.Routes {
+				h = append(h, r.HostMatcher)
+			}
+			hosts = append(hosts, h)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for discovered configs")
+		}
+	}
+	assert.Equal(t, [][]string{
+		{"first.example.com"},
+		{"first.example.com", "second.example.com"},
+		{"third.example.com"},
+	}, hosts)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.True(t, len(requests) >= 3)
+	assert.Equal(t, []string{
+		"/api/v1/namespaces/ns1/services?labelSelector=expose%3Dkedge",
+		"/api/v1/namespaces/ns1/services?labelSelector=expose%3Dkedge&resourceVersion=10&watch=true",
+		"/api/v1/namespaces/ns1/services?labelSelector=expose%3Dkedge",
+	}, requests[:3])
+}
+
+func TestController_WatchesOnlyReferencedSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+	c := Start(logrus.New(), Config{K8sURL: server.URL, K8sClient: http.DefaultClient}, nil, func(Configs) {})
+	defer c.Close()
+
+	ing := func(namespace string, secretNames ...string) *ingress {
+		i := &ingress{Metadata: objectMeta{Name: "ing", Namespace: namespace}}
+		for _, name := range secretNames {
+			i.Spec.TLS = append(i.Spec.TLS, ingressTLS{SecretName: name})
+		}
+		return i
+	}
+	watched := func() []string {
+		var keys []string
+		for key := range c.secretWatches {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchSecrets([]*ingress{ing("ns1", "a", "b"), ing("ns2", "a", "")})
+	assert.Equal(t, []string{"ns1/a", "ns1/b", "ns2/a"}, watched())
+
+	c.objects[kindTLSSecrets]["ns1/b"] = map[string]json.RawMessage{"ns1/b": json.RawMessage(`{}`)}
+	c.watchSecrets([]*ingress{ing("ns1", "a")})
+	assert.Equal(t, []string{"ns1/a"}, watched())
+	assert.NotContains(t, c.objects[kindTLSSecrets], "ns1/b", "objects of secrets not referenced anymore should be dropped")
+}
@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"github.com/mwitkow/kedge/lib/resolvers/k8s"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	fEnabled = sharedflags.Set.Bool("k8sdiscovery_enabled", false,
+		"If enabled, kedge watches Kubernetes Services annotated with kedge.io/host and adds backends and routes for "+
+			"them to the ones from config flags. Kube API access is configured using k8sresolver_* flags.")
+	fNamespaces = sharedflags.Set.StringSlice("k8sdiscovery_namespaces", []string{},
+		"Namespaces (comma separated) to discover Services, Ingresses and Gateway API objects in. If empty, all namespaces are watched.")
+	fLabelSelector = sharedflags.Set.String("k8sdiscovery_label_selector", "",
+		"Label selector of discovered Services, e.g. 'expose=kedge'. If empty, all annotated Services are discovered.")
+	fIngressClass = sharedflags.Set.String("k8sdiscovery_ingress_class", "",
+		"If specified, kedge acts as Ingress controller for Kubernetes Ingresses of this class, adding their rules as "+
+			"HTTP routes and their TLS Secrets as SNI certificates. Kube API access is configured using k8sresolver_* flags.")
+	fGatewayClass = sharedflags.Set.String("k8sdiscovery_gateway_class", "",
+		"If specified, kedge acts as Gateway API controller for Gateways of this GatewayClass, adding rules of HTTPRoutes "+
+			"attached to them as HTTP routes and their listener TLS Secrets as SNI certificates. Kube API access is "+
+			"configured using k8sresolver_* flags.")
+	fIngressStatusAddresses = sharedflags.Set.StringSlice("k8sdiscovery_ingress_status_addresses", []string{},
+		"IPs or host names (comma separated) of kedge written to load balancer status of served Ingresses and to "+
+			"addresses of served Gateways. If empty, status is not updated.")
+)
+
+// NewFromFlags starts Controller configured from sharedflags.Set. It returns nil Controller if discovery is disabled.
+// TLS certificates of served Ingresses and Gateways are put into certificates.
+func NewFromFlags(logger logrus.FieldLogger, authAvailable bool, certificates *Certificates, onUpdate func(Configs)) (*Controller, error) {
+	if !*fEnabled && *fIngressClass == "" && *fGatewayClass == "" {
+		return nil, nil
+	}
+	k8sURL, k8sClient, err := k8sresolver.NewClientFromFlags()
+	if err != nil {
+		return nil, errors.Wrap(err, "discovery: failed to create kube API client")
+	}
+	return Start(logger, Config{
+		K8sURL:                 k8sURL,
+		K8sClient:              k8sClient,
+		Namespaces:             *fNamespaces,
+		DiscoverServices:       *fEnabled,
+		LabelSelector:          *fLabelSelector,
+		AuthAvailable:          authAvailable,
+		IngressClass:           *fIngressClass,
+		GatewayClass:           *fGatewayClass,
+		IngressStatusAddresses: *fIngressStatusAddresses,
+	}, certificates, onUpdate), nil
+}
@@ -0,0 +1,272 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	gatewayGroup = "gateway.networking.k8s.io"
+
+	gatewayPathTypeExact      = "Exact"
+	gatewayPathTypePathPrefix = "PathPrefix"
+)
+
+type gateway struct {
+	Metadata objectMeta    `json:"metadata"`
+	Spec     gatewaySpec   `json:"spec"`
+	Status   gatewayStatus `json:"status"`
+}
+
+type gatewaySpec struct {
+	GatewayClassName string            `json:"gatewayClassName"`
+	Listeners        []gatewayListener `json:"listeners,omitempty"`
+}
+
+type gatewayListener struct {
+	Name     string      `json:"name"`
+	Hostname string      `json:"hostname,omitempty"`
+	Protocol string      `json:"protocol"`
+	TLS      *gatewayTLS `json:"tls,omitempty"`
+}
+
+type gatewayTLS struct {
+	Mode            string             `json:"mode,omitempty"`
+	CertificateRefs []gatewayObjectRef `json:"certificateRefs,omitempty"`
+}
+
+type gatewayObjectRef struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+type gatewayStatus struct {
+	Addresses []gatewayAddress `json:"addresses,omitempty"`
+}
+
+type gatewayAddress struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type httpRoute struct {
+	Metadata objectMeta    `json:"metadata"`
+	Spec     httpRouteSpec `json:"spec"`
+}
+
+type httpRouteSpec struct {
+	ParentRefs []gatewayObjectRef `json:"parentRefs,omitempty"`
+	Hostnames  []string           `json:"hostnames,omitempty"`
+	Rules      []httpRouteRule    `json:"rules,omitempty"`
+}
+
+type httpRouteRule struct {
+	Matches     []httpRouteMatch  `json:"matches,omitempty"`
+	Filters     []json.RawMessage `json:"filters,omitempty"`
+	BackendRefs []httpBackendRef  `json:"backendRefs,omitempty"`
+}
+
+type httpRouteMatch struct {
+	Path *struct {
+		Type  string `json:"type,omitempty"`
+		Value string `json:"value,omitempty"`
+	} `json:"path,omitempty"`
+	Headers     []json.RawMessage `json:"headers,omitempty"`
+	QueryParams []json.RawMessage `json:"queryParams,omitempty"`
+	Method      string            `json:"method,omitempty"`
+}
+
+type httpBackendRef struct {
+	gatewayObjectRef
+	Port    int32             `json:"port,omitempty"`
+	Weight  *int32            `json:"weight,omitempty"`
+	Filters []json.RawMessage `json:"filters,omitempty"`
+}
+
+// addGateways adds routes of HTTPRoutes attached to gateways of the given class and returns the matched gateways.
+// Gateways are also returned translated to ingresses with their listener certificates as TLS, so certificates are
+// served the same way as for Ingresses. Rules that cannot be served are skipped and returned as errors by gateway or
+// HTTPRoute namespace/name.
+func (b *routeBuilder) addGateways(gatewayObjects map[string]json.RawMessage, routeObjects map[string]json.RawMessage, class string) ([]*gateway, []*ingress, map[string]error, map[string]error) {
+	gatewayErrs := map[string]error{}
+	var matched []*gateway
+	var tlsIngresses []*ingress
+	served := map[string]struct{}{}
+	for _, key := range sortedKeys(gatewayObjects) {
+		gw := &gateway{}
+		if err := json.Unmarshal(gatewayObjects[key], gw); err != nil {
+			gatewayErrs[key] = errors.Wrap(err, "failed to decode gateway")
+			continue
+		}
+		if gw.Spec.GatewayClassName != class {
+			continue
+		}
+		matched = append(matched, gw)
+		served[key] = struct{}{}
+		ing, gwErrs := gatewayToIngress(gw)
+		tlsIngresses = append(tlsIngresses, ing)
+		if len(gwErrs) > 0 {
+			gatewayErrs[key] = errors.New(strings.Join(gwErrs, "; "))
+		}
+	}
+
+	routeErrs := map[string]error{}
+	for _, key := range sortedKeys(routeObjects) {
+		route := &httpRoute{}
+		if err := json.Unmarshal(routeObjects[key], route); err != nil {
+			routeErrs[key] = errors.Wrap(err, "failed to decode HTTPRoute")
+			continue
+		}
+		if !route.attachedTo(served) {
+			continue
+		}
+		ing, ingErrs := httpRouteToIngress(route)
+		ingErrs = append(ingErrs, b.addIngress(ing)...)
+		if len(ingErrs) > 0 {
+			routeErrs[key] = errors.New(strings.Join(ingErrs, "; "))
+		}
+	}
+	return matched, tlsIngresses, gatewayErrs, routeErrs
+}
+
+// attachedTo tells whether the HTTPRoute references any of the gateways by namespace/name as its parent.
+func (r *httpRoute) attachedTo(gateways map[string]struct{}) bool {
+	for _, ref := range r.Spec.ParentRefs {
+		if (ref.Group != "" && ref.Group != gatewayGroup) || (ref.Kind != "" && ref.Kind != "Gateway") {
+			continue
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = r.Metadata.Namespace
+		}
+		if _, ok := gateways[namespace+"/"+ref.Name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayToIngress translates certificates of HTTPS listeners of the gateway to ingress TLS.
+func gatewayToIngress(gw *gateway) (*ingress, []string) {
+	var errs []string
+	ing := &ingress{Metadata: gw.Metadata}
+	for _, l := range gw.Spec.Listeners {
+		if l.Protocol != "HTTPS" || l.TLS == nil {
+			continue
+		}
+		if l.TLS.Mode != "" && l.TLS.Mode != "Terminate" {
+			errs = append(errs, fmt.Sprintf("listener %v: TLS mode %v is not supported", l.Name, l.TLS.Mode))
+			continue
+		}
+		if l.Hostname == "" || strings.Contains(l.Hostname, "*") {
+			errs = append(errs, fmt.Sprintf("listener %v: certificates are served only for listeners with exact hostname", l.Name))
+			continue
+		}
+		for _, ref := range l.TLS.CertificateRefs {
+			if (ref.Group != "" && ref.Group != "core") || (ref.Kind != "" && ref.Kind != "Secret") {
+				errs = append(errs, fmt.Sprintf("listener %v: only Secret certificate refs are supported", l.Name))
+				continue
+			}
+			if ref.Namespace != "" && ref.Namespace != gw.Metadata.Namespace {
+				errs = append(errs, fmt.Sprintf("listener %v: cross-namespace certificate refs are not supported", l.Name))
+				continue
+			}
+			ing.Spec.TLS = append(ing.Spec.TLS, ingressTLS{Hosts: []string{l.Hostname}, SecretName: ref.Name})
+		}
+	}
+	return ing, errs
+}
+
+// httpRouteToIngress translates the HTTPRoute to ingress with a rule for each of its hostnames, or a rule for all hosts
+// if it has none. Only path matches and a single Service backend per rule are supported.
+func httpRouteToIngress(route *httpRoute) (*ingress, []string) {
+	var errs []string
+	var paths []ingressPath
+	for i, rule := range route.Spec.Rules {
+		if len(rule.Filters) > 0 {
+			errs = append(errs, fmt.Sprintf("rule %d: filters are not supported", i))
+			continue
+		}
+		backend, err := httpRouteBackend(route.Metadata.Namespace, rule.BackendRefs)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("rule %d: %v", i, err))
+			continue
+		}
+		matches := rule.Matches
+		if len(matches) == 0 {
+			// No matches means matching all requests.
+			matches = []httpRouteMatch{{}}
+		}
+		for _, m := range matches {
+			if len(m.Headers) > 0 || len(m.QueryParams) > 0 || m.Method != "" {
+				errs = append(errs, fmt.Sprintf("rule %d: only path matches are supported", i))
+				continue
+			}
+			path := ingressPath{Path: "/", PathType: pathTypePrefix, Backend: *backend}
+			if m.Path != nil {
+				if m.Path.Value != "" {
+					path.Path = m.Path.Value
+				}
+				switch m.Path.Type {
+				case "", gatewayPathTypePathPrefix:
+				case gatewayPathTypeExact:
+					path.PathType = pathTypeExact
+				default:
+					errs = append(errs, fmt.Sprintf("rule %d: path type %v is not supported", i, m.Path.Type))
+					continue
+				}
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	ing := &ingress{Metadata: route.Metadata}
+	hosts := route.Spec.Hostnames
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, ingressRule{Host: host, HTTP: &ingressRuleHTTP{Paths: paths}})
+	}
+	return ing, errs
+}
+
+func httpRouteBackend(namespace string, refs []httpBackendRef) (*ingressBackend, error) {
+	if len(refs) != 1 {
+		return nil, errors.New("exactly one backend ref is supported")
+	}
+	ref := refs[0]
+	if (ref.Group != "" && ref.Group != "core") || (ref.Kind != "" && ref.Kind != "Service") {
+		return nil, errors.New("only service backends are supported")
+	}
+	if ref.Namespace != "" && ref.Namespace != namespace {
+		return nil, errors.New("cross-namespace backend refs are not supported")
+	}
+	if len(ref.Filters) > 0 {
+		return nil, errors.New("backend filters are not supported")
+	}
+	if ref.Port == 0 {
+		return nil, errors.Errorf("service %v needs a port", ref.Name)
+	}
+	backend := &ingressBackend{Service: &ingressServiceBackend{Name: ref.Name}}
+	backend.Service.Port.Number = ref.Port
+	return backend, nil
+}
+
+func gatewayAddressesOf(addresses []string) []gatewayAddress {
+	var gas []gatewayAddress
+	for _, addr := range addresses {
+		if net.ParseIP(addr) != nil {
+			gas = append(gas, gatewayAddress{Type: "IPAddress", Value: addr})
+		} else {
+			gas = append(gas, gatewayAddress{Type: "Hostname", Value: addr})
+		}
+	}
+	return gas
+}
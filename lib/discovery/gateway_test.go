@@ -0,0 +1,175 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	pb_httproute "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testGateway = `{"metadata":{"name":"gw","namespace":"ns1"},"spec":{
+	"gatewayClassName":"kedge",
+	"listeners":[
+		{"name":"http","protocol":"HTTP","port":80},
+		{"name":"https","hostname":"gw.example.com","protocol":"HTTPS","port":443,
+			"tls":{"mode":"Terminate","certificateRefs":[{"kind":"Secret","name":"www-tls"}]}}
+	]}}`
+	otherClassGateway = `{"metadata":{"name":"other","namespace":"ns1"},"spec":{
+	"gatewayClassName":"nginx",
+	"listeners":[{"name":"https","hostname":"other.example.com","protocol":"HTTPS","port":443,
+		"tls":{"certificateRefs":[{"name":"other-tls"}]}}]}}`
+	testHTTPRoute = `{"metadata":{"name":"route","namespace":"ns1"},"spec":{
+	"parentRefs":[{"name":"gw"}],
+	"hostnames":["gw.example.com"],
+	"rules":[
+		{"matches":[{"path":{"type":"PathPrefix","value":"/api/"}},{"path":{"type":"Exact","value":"/healthz"}}],
+			"backendRefs":[{"name":"api-v2","port":8080}]},
+		{"backendRefs":[{"name":"web","port":80}]},
+		{"matches":[{"headers":[{"name":"x-canary","value":"true"}]}],"backendRefs":[{"name":"web","port":80}]},
+		{"matches":[{"path":{"value":"/split"}}],"backendRefs":[{"name":"web","port":80},{"name":"api-v2","port":8080}]}
+	]}}`
+	otherGatewayHTTPRoute = `{"metadata":{"name":"other","namespace":"ns1"},"spec":{
+	"parentRefs":[{"name":"other"}],
+	"rules":[{"backendRefs":[{"name":"web","port":80}]}]}}`
+)
+
+func TestGatewaysToConfigs(t *testing.T) {
+	configs := emptyConfigs()
+	b := newRouteBuilder(configs, servicesByKey(t))
+	gateways, tlsIngresses, gatewayErrs, routeErrs := b.addGateways(map[string]json.RawMessage{
+		"ns1/gw":    json.RawMessage(testGateway),
+		"ns1/other": json.RawMessage(otherClassGateway),
+	}, map[string]json.RawMessage{
+		"ns1/route": json.RawMessage(testHTTPRoute),
+		"ns1/other": json.RawMessage(otherGatewayHTTPRoute),
+	}, "kedge")
+	b.finish()
+
+	require.Len(t, gateways, 1, "only gateway of kedge class should be served")
+	assert.Equal(t, "gw", gateways[0].Metadata.Name)
+	require.Len(t, tlsIngresses, 1)
+	assert.Equal(t, []ingressTLS{{Hosts: []string{"gw.example.com"}, SecretName: "www-tls"}}, tlsIngresses[0].Spec.TLS)
+	assert.Empty(t, gatewayErrs)
+	require.Len(t, routeErrs, 1, "only HTTPRoutes of served gateways should be reported")
+	assert.Contains(t, routeErrs["ns1/route"].Error(), "rule 2: only path matches are supported")
+	assert.Contains(t, routeErrs["ns1/route"].Error(), "rule 3: exactly one backend ref is supported")
+
+	assert.Equal(t, []*pb_httproute.Route{
+		{BackendName: "api_v_.ns__ylexnjxibv", HostMatcher: "gw.example.com", PathRules: []string{"/healthz"}},
+		{BackendName: "api_v_.ns__ylexnjxibv", HostMatcher: "gw.example.com", PathRules: []string{"/api", "/api/*"}},
+		{BackendName: "web.ns_.http_fsysuuxgen", HostMatcher: "gw.example.com"},
+	}, configs.Director.Http.Routes, "routes should be ordered from the most specific one")
+}
+
+func TestGatewaysToConfigs_RoutesWithoutHostnamesMatchAllHosts(t *testing.T) {
+	configs := emptyConfigs()
+	b := newRouteBuilder(configs, servicesByKey(t))
+	_, _, _, routeErrs := b.addGateways(map[string]json.RawMessage{
+		"ns1/gw": json.RawMessage(testGateway),
+	}, map[string]json.RawMessage{
+		"ns2/route": json.RawMessage(`{"metadata":{"name":"route","namespace":"ns2"},"spec":{
+			"parentRefs":[{"name":"gw","namespace":"ns1"}],
+			"rules":[{"backendRefs":[{"name":"web","port":80}]}]}}`),
+		"ns1/route": json.RawMessage(`{"metadata":{"name":"route","namespace":"ns1"},"spec":{
+			"parentRefs":[{"name":"gw"}],
+			"rules":[{"matches":[{"path":{"value":"/metrics"}}],"backendRefs":[{"name":"web","port":9090}]}]}}`),
+	}, "kedge")
+	b.finish()
+
+	assert.Contains(t, routeErrs["ns2/route"].Error(), "service web not found", "backends are resolved in the namespace of HTTPRoute")
+	assert.Equal(t, []*pb_httproute.Route{
+		{BackendName: "web.ns_.metrics_mvhcbxgrax", PathRules: []string{"/metrics", "/metrics/*"}},
+	}, configs.Director.Http.Routes)
+}
+
+func TestController_ServesGatewaysAndUpdatesStatus(t *testing.T) {
+	var (
+		mu              sync.Mutex
+		patches         []string
+		secretSelectors []string
+	)
+	secrets := fmt.Sprintf(`{"metadata":{"resourceVersion":"1"},"items":[%s]}`, testSecret(t))
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(req.Body)
+			mu.Lock()
+			patches = append(patches, req.URL.Path+" "+req.Header.Get("Content-Type")+" "+string(body))
+			mu.Unlock()
+			return
+		}
+		if req.URL.Query().Get("watch") != "" {
+			<-req.Context().Done()
+			return
+		}
+		switch req.URL.Path {
+		case "/api/v1/namespaces/ns1/services":
+			fmt.Fprint(resp, testServices)
+		case "/apis/gateway.networking.k8s.io/v1/namespaces/ns1/gateways":
+			fmt.Fprintf(resp, `{"metadata":{"resourceVersion":"1"},"items":[%s,%s]}`, testGateway, otherClassGateway)
+		case "/apis/gateway.networking.k8s.io/v1/namespaces/ns1/httproutes":
+			fmt.Fprintf(resp, `{"metadata":{"resourceVersion":"1"},"items":[%s,%s]}`, testHTTPRoute, otherGatewayHTTPRoute)
+		case "/api/v1/namespaces/ns1/secrets":
+			mu.Lock()
+			secretSelectors = append(secretSelectors, req.URL.Query().Get("fieldSelector"))
+			mu.Unlock()
+			fmt.Fprint(resp, secrets)
+		default:
+			resp.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	certs := NewCertificates()
+	updates := make(chan Configs, 10)
+	c := Start(logrus.New(), Config{
+		K8sURL:                 server.URL,
+		K8sClient:              http.DefaultClient,
+		Namespaces:             []string{"ns1"},
+		GatewayClass:           "kedge",
+		IngressStatusAddresses: []string{"10.0.0.1", "kedge.example.com"},
+	}, certs, func(configs Configs) { updates <- configs })
+	defer c.Close()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		var configs Configs
+		select {
+		case configs = <-updates:
+		case <-deadline:
+			t.Fatal("timed out waiting for HTTPRoute routes and gateway certificates")
+		}
+		if len(configs.Director.Http.Routes) == 3 && certs.get("gw.example.com") != nil {
+			break
+		}
+	}
+
+	// Status is updated after configs are applied.
+	for i := 0; ; i++ {
+		mu.Lock()
+		n := len(patches)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("timed out waiting for gateway status update")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, `/apis/gateway.networking.k8s.io/v1/namespaces/ns1/gateways/gw/status application/merge-patch+json `+
+		`{"status":{"addresses":[{"type":"IPAddress","value":"10.0.0.1"},{"type":"Hostname","value":"kedge.example.com"}]}}`, patches[0])
+	assert.Equal(t, []string{"metadata.name=www-tls,type=kubernetes.io/tls"}, secretSelectors,
+		"only TLS secrets of served gateways should be read")
+}
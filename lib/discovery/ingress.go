@@ -0,0 +1,316 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	pb_res "github.com/mwitkow/kedge/_protogen/kedge/config/common/resolvers"
+	pb_httpbe "github.com/mwitkow/kedge/_protogen/kedge/config/http/backends"
+	pb_httproute "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ingressClassAnnotation is the deprecated way of specifying ingress class, still widely used.
+	ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+	pathTypeExact  = "Exact"
+	pathTypePrefix = "Prefix"
+)
+
+type ingress struct {
+	Metadata objectMeta    `json:"metadata"`
+	Spec     ingressSpec   `json:"spec"`
+	Status   ingressStatus `json:"status"`
+}
+
+type ingressSpec struct {
+	IngressClassName string          `json:"ingressClassName,omitempty"`
+	DefaultBackend   *ingressBackend `json:"defaultBackend,omitempty"`
+	TLS              []ingressTLS    `json:"tls,omitempty"`
+	Rules            []ingressRule   `json:"rules,omitempty"`
+}
+
+type ingressBackend struct {
+	Service *ingressServiceBackend `json:"service,omitempty"`
+}
+
+type ingressServiceBackend struct {
+	Name string `json:"name"`
+	Port struct {
+		Name   string `json:"name,omitempty"`
+		Number int32  `json:"number,omitempty"`
+	} `json:"port"`
+}
+
+type ingressTLS struct {
+	Hosts      []string `json:"hosts,omitempty"`
+	SecretName string   `json:"secretName,omitempty"`
+}
+
+type ingressRule struct {
+	Host string           `json:"host,omitempty"`
+	HTTP *ingressRuleHTTP `json:"http,omitempty"`
+}
+
+type ingressRuleHTTP struct {
+	Paths []ingressPath `json:"paths"`
+}
+
+type ingressPath struct {
+	Path     string         `json:"path,omitempty"`
+	PathType string         `json:"pathType"`
+	Backend  ingressBackend `json:"backend"`
+}
+
+type ingressStatus struct {
+	LoadBalancer struct {
+		Ingress []loadBalancerIngress `json:"ingress,omitempty"`
+	} `json:"loadBalancer"`
+}
+
+type loadBalancerIngress struct {
+	IP       string `json:"ip,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// matchesClass tells whether the ingress should be served by kedge with the given ingress class.
+func (i *ingress) matchesClass(class string) bool {
+	if i.Spec.IngressClassName != "" {
+		return i.Spec.IngressClassName == class
+	}
+	return i.Metadata.Annotations[ingressClassAnnotation] == class
+}
+
+// ingressRoute is a route with the information needed to order it as Ingress specifies.
+type ingressRoute struct {
+	route *pb_httproute.Route
+	exact bool
+	path  string
+}
+
+// routeBuilder adds HTTP backends and routes of Ingresses (and HTTPRoutes translated to them) to configs. Backends are
+// resolved through the k8s resolver, using services to translate service ports to endpoint port names.
+type routeBuilder struct {
+	configs  Configs
+	services map[string]json.RawMessage
+
+	routes   []ingressRoute
+	defaults []*pb_httproute.Route
+}
+
+func newRouteBuilder(configs Configs, services map[string]json.RawMessage) *routeBuilder {
+	return &routeBuilder{configs: configs, services: services}
+}
+
+// ingressesToConfigs adds HTTP backends and routes of ingresses of the given class to configs. Rules that cannot be
+// served are skipped and returned as errors by ingress namespace/name.
+func ingressesToConfigs(configs Configs, objects map[string]json.RawMessage, services map[string]json.RawMessage, class string) ([]*ingress, map[string]error) {
+	b := newRouteBuilder(configs, services)
+	matched, errs := b.addIngresses(objects, class)
+	b.finish()
+	return matched, errs
+}
+
+// addIngresses adds routes of ingresses of the given class and returns the matched ingresses. Rules that cannot be
+// served are skipped and returned as errors by ingress namespace/name.
+func (b *routeBuilder) addIngresses(objects map[string]json.RawMessage, class string) ([]*ingress, map[string]error) {
+	errs := map[string]error{}
+	var matched []*ingress
+	for _, key := range sortedKeys(objects) {
+		ing := &ingress{}
+		if err := json.Unmarshal(objects[key], ing); err != nil {
+			errs[key] = errors.Wrap(err, "failed to decode ingress")
+			continue
+		}
+		if !ing.matchesClass(class) {
+			continue
+		}
+		matched = append(matched, ing)
+		if ingErrs := b.addIngress(ing); len(ingErrs) > 0 {
+			errs[key] = errors.New(strings.Join(ingErrs, "; "))
+		}
+	}
+	return matched, errs
+}
+
+// addIngress adds routes of a single ingress and returns descriptions of rules that cannot be served.
+func (b *routeBuilder) addIngress(ing *ingress) []string {
+	var ingErrs []string
+	if ing.Spec.DefaultBackend != nil {
+		backendName, err := addIngressBackend(b.configs, ing.Metadata.Namespace, ing.Spec.DefaultBackend, b.services)
+		if err != nil {
+			ingErrs = append(ingErrs, fmt.Sprintf("default backend: %v", err))
+		} else {
+			b.defaults = append(b.defaults, &pb_httproute.Route{BackendName: backendName})
+		}
+	}
+	for _, rule := range ing.Spec.Rules {
+		if strings.Contains(rule.Host, "*") {
+			ingErrs = append(ingErrs, fmt.Sprintf("host %v: wildcard hosts are not supported", rule.Host))
+			continue
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			backendName, err := addIngressBackend(b.configs, ing.Metadata.Namespace, &path.Backend, b.services)
+			if err != nil {
+				ingErrs = append(ingErrs, fmt.Sprintf("host %v path %v: %v", rule.Host, path.Path, err))
+				continue
+			}
+			pathRules, err := ingressPathRules(path)
+			if err != nil {
+				ingErrs = append(ingErrs, fmt.Sprintf("host %v path %v: %v", rule.Host, path.Path, err))
+				continue
+			}
+			b.routes = append(b.routes, ingressRoute{
+				route: &pb_httproute.Route{
+					BackendName: backendName,
+					HostMatcher: rule.Host,
+					PathRules:   pathRules,
+				},
+				exact: path.PathType == pathTypeExact,
+				path:  path.Path,
+			})
+		}
+	}
+	return ingErrs
+}
+
+// finish adds all routes to configs, ordered from the most specific one.
+func (b *routeBuilder) finish() {
+	// Kedge uses the first matching route, while Ingress wants the most specific one: routes with host first, exact
+	// paths before prefixes and longer prefixes first.
+	sort.SliceStable(b.routes, func(i, j int) bool {
+		x, y := b.routes[i], b.routes[j]
+		if (x.route.HostMatcher == "") != (y.route.HostMatcher == "") {
+			return x.route.HostMatcher != ""
+		}
+		if x.exact != y.exact {
+			return x.exact
+		}
+		return len(x.path) > len(y.path)
+	})
+	for _, r := range b.routes {
+		b.configs.Director.Http.Routes = append(b.configs.Director.Http.Routes, r.route)
+	}
+	// Default backend catches everything else, only the first one can ever match.
+	b.configs.Director.Http.Routes = append(b.configs.Director.Http.Routes, b.defaults...)
+}
+
+func sortedKeys(objects map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ingressPathRules translates Ingress path into kedge path rules.
+func ingressPathRules(path ingressPath) ([]string, error) {
+	p := path.Path
+	if p == "" {
+		p = "/"
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, errors.New("path needs to be absolute")
+	}
+	switch path.PathType {
+	case pathTypeExact:
+		return []string{p}, nil
+	case pathTypePrefix, "ImplementationSpecific":
+		// Prefix is matched element-wise, so /foo matches /foo and /foo/bar, but not /foobar.
+		p = strings.TrimSuffix(p, "/")
+		if p == "" {
+			return nil, nil
+		}
+		return []string{p, p + "/*"}, nil
+	default:
+		return nil, errors.Errorf("unknown path type %q", path.PathType)
+	}
+}
+
+// addIngressBackend adds HTTP backend for the service referenced by the ingress, unless it already exists, and returns
+// its name.
+func addIngressBackend(configs Configs, namespace string, b *ingressBackend, services map[string]json.RawMessage) (string, error) {
+	if b.Service == nil {
+		return "", errors.New("only service backends are supported")
+	}
+
+	raw, ok := services[namespace+"/"+b.Service.Name]
+	if !ok {
+		return "", errors.Errorf("service %v not found", b.Service.Name)
+	}
+	svc := &service{}
+	if err := json.Unmarshal(raw, svc); err != nil {
+		return "", errors.Wrapf(err, "failed to decode service %v", b.Service.Name)
+	}
+	var port *servicePort
+	for i, p := range svc.Spec.Ports {
+		if (b.Service.Port.Name != "" && p.Name == b.Service.Port.Name) || (b.Service.Port.Number != 0 && p.Port == b.Service.Port.Number) {
+			port = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if port == nil {
+		return "", errors.Errorf("service %v has no port %v%v", b.Service.Name, b.Service.Port.Name, portNumber(b.Service.Port.Number))
+	}
+
+	// Endpoint ports are named as service ports. Port name can be empty only for a service with a single port.
+	target := fmt.Sprintf("%s.%s", svc.Metadata.Name, svc.Metadata.Namespace)
+	name := target
+	if port.Name != "" {
+		target += ":" + port.Name
+		name += "." + port.Name
+	}
+	backend := &pb_httpbe.Backend{
+		Name:     discoveredBackendName(name),
+		Resolver: &pb_httpbe.Backend_K8S{K8S: &pb_res.K8SResolver{DnsPortName: target}},
+	}
+
+	for _, existing := range configs.Backendpool.Http.Backends {
+		if existing.Name != backend.Name {
+			continue
+		}
+		if !proto.Equal(existing, backend) {
+			return "", errors.Errorf("backend name %v is already used for different backend", backend.Name)
+		}
+		return backend.Name, nil
+	}
+	for _, existing := range configs.Backendpool.Grpc.Backends {
+		if existing.Name == backend.Name {
+			return "", errors.Errorf("backend name %v is already used by gRPC backend", backend.Name)
+		}
+	}
+	if err := validate(backend); err != nil {
+		return "", err
+	}
+	configs.Backendpool.Http.Backends = append(configs.Backendpool.Http.Backends, backend)
+	return backend.Name, nil
+}
+
+func portNumber(n int32) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// statusOf returns Ingress load balancer status for given addresses, which are either IPs or host names.
+func statusOf(addresses []string) []loadBalancerIngress {
+	var lbs []loadBalancerIngress
+	for _, addr := range addresses {
+		if net.ParseIP(addr) != nil {
+			lbs = append(lbs, loadBalancerIngress{IP: addr})
+		} else {
+			lbs = append(lbs, loadBalancerIngress{Hostname: addr})
+		}
+	}
+	return lbs
+}
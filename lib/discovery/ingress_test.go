@@ -0,0 +1,216 @@
+package discovery
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	pb_res "github.com/mwitkow/kedge/_protogen/kedge/config/common/resolvers"
+	pb_httpbe "github.com/mwitkow/kedge/_protogen/kedge/config/http/backends"
+	pb_httproute "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testServices = `{"metadata":{"resourceVersion":"1"},"items":[
+	{"metadata":{"name":"web","namespace":"ns1"},"spec":{"ports":[{"name":"http","port":80},{"name":"metrics","port":9090}]}},
+	{"metadata":{"name":"api-v2","namespace":"ns1"},"spec":{"ports":[{"port":8080}]}}
+]}`
+	testIngress = `{"metadata":{"name":"ing","namespace":"ns1"},"spec":{
+	"ingressClassName":"kedge",
+	"defaultBackend":{"service":{"name":"web","port":{"name":"http"}}},
+	"tls":[{"hosts":["www.example.com"],"secretName":"www-tls"}],
+	"rules":[
+		{"host":"www.example.com","http":{"paths":[
+			{"path":"/","pathType":"Prefix","backend":{"service":{"name":"web","port":{"number":80}}}},
+			{"path":"/api/","pathType":"Prefix","backend":{"service":{"name":"api-v2","port":{"number":8080}}}},
+			{"path":"/api/healthz","pathType":"Exact","backend":{"service":{"name":"web","port":{"name":"http"}}}},
+			{"path":"/missing","pathType":"Prefix","backend":{"service":{"name":"missing","port":{"number":80}}}}
+		]}},
+		{"host":"*.example.com","http":{"paths":[
+			{"path":"/","pathType":"Prefix","backend":{"service":{"name":"web","port":{"number":80}}}}
+		]}},
+		{"http":{"paths":[
+			{"path":"/metrics","pathType":"Prefix","backend":{"service":{"name":"web","port":{"number":9090}}}}
+		]}}
+	]}}`
+	otherClassIngress = `{"metadata":{"name":"other","namespace":"ns1","annotations":{"kubernetes.io/ingress.class":"nginx"}},"spec":{
+	"tls":[{"hosts":["other.example.com"],"secretName":"other-tls"}],
+	"rules":[{"host":"other.example.com","http":{"paths":[
+		{"path":"/","pathType":"Prefix","backend":{"service":{"name":"web","port":{"number":80}}}}
+	]}}]}}`
+)
+
+func getTestingCertsPath() string {
+	_, callerPath, _, _ := runtime.Caller(0)
+	return path.Join(path.Dir(callerPath), "..", "..", "misc")
+}
+
+func testSecret(t *testing.T) string {
+	crt, err := ioutil.ReadFile(path.Join(getTestingCertsPath(), "localhost.crt"))
+	require.NoError(t, err)
+	key, err := ioutil.ReadFile(path.Join(getTestingCertsPath(), "localhost.key"))
+	require.NoError(t, err)
+	s, err := json.Marshal(secret{
+		Metadata: objectMeta{Name: "www-tls", Namespace: "ns1", ResourceVersion: "3"},
+		Type:     tlsSecretType,
+		Data:     map[string][]byte{"tls.crt": crt, "tls.key": key},
+	})
+	require.NoError(t, err)
+	return string(s)
+}
+
+func servicesByKey(t *testing.T) map[string]json.RawMessage {
+	list := &objectList{}
+	require.NoError(t, json.Unmarshal([]byte(testServices), list))
+	services := map[string]json.RawMessage{}
+	for _, item := range list.Items {
+		meta, err := metaOf(item)
+		require.NoError(t, err)
+		services[meta.Namespace+"/"+meta.Name] = item
+	}
+	return services
+}
+
+func TestIngressesToConfigs(t *testing.T) {
+	configs := emptyConfigs()
+	ingresses, errs := ingressesToConfigs(configs, map[string]json.RawMessage{
+		"ns1/ing":   json.RawMessage(testIngress),
+		"ns1/other": json.RawMessage(otherClassIngress),
+	}, servicesByKey(t), "kedge")
+
+	require.Len(t, ingresses, 1, "only ingress of kedge class should be served")
+	assert.Equal(t, "ing", ingresses[0].Metadata.Name)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs["ns1/ing"].Error(), "service missing not found")
+	assert.Contains(t, errs["ns1/ing"].Error(), "wildcard hosts are not supported")
+
+	assert.Equal(t, []*pb_httpbe.Backend{
+		{Name: "web.ns_.http_fsysuuxgen", Resolver: &pb_httpbe.Backend_K8S{K8S: &pb_res.K8SResolver{DnsPortName: "web.ns1:http"}}},
+		{Name: "api_v_.ns__ylexnjxibv", Resolver: &pb_httpbe.Backend_K8S{K8S: &pb_res.K8SResolver{DnsPortName: "api-v2.ns1"}}},
+		{Name: "web.ns_.metrics_mvhcbxgrax", Resolver: &pb_httpbe.Backend_K8S{K8S: &pb_res.K8SResolver{DnsPortName: "web.ns1:metrics"}}},
+	}, configs.Backendpool.Http.Backends)
+	assert.Equal(t, []*pb_httproute.Route{
+		{BackendName: "web.ns_.http_fsysuuxgen", HostMatcher: "www.example.com", PathRules: []string{"/api/healthz"}},
+		{BackendName: "api_v_.ns__ylexnjxibv", HostMatcher: "www.example.com", PathRules: []string{"/api", "/api/*"}},
+		{BackendName: "web.ns_.http_fsysuuxgen", HostMatcher: "www.example.com"},
+		{BackendName: "web.ns_.metrics_mvhcbxgrax", PathRules: []string{"/metrics", "/metrics/*"}},
+		{BackendName: "web.ns_.http_fsysuuxgen"},
+	}, configs.Director.Http.Routes, "routes should be ordered from the most specific one")
+}
+
+func TestCertificates(t *testing.T) {
+	ing := &ingress{}
+	require.NoError(t, json.Unmarshal([]byte(testIngress), ing))
+	byHost, errs := ingressCertificates([]*ingress{ing}, map[string]json.RawMessage{"ns1/www-tls": json.RawMessage(testSecret(t))})
+	require.Empty(t, errs)
+
+	fallback := &tls.Certificate{}
+	certs := NewCertificates()
+	certs.update(byHost)
+	getCertificate := certs.GetCertificate(func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return fallback, nil })
+
+	cert, err := getCertificate(&tls.ClientHelloInfo{ServerName: "WWW.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, byHost["www.example.com"], cert)
+	cert, err = getCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, fallback, cert)
+
+	_, errs = ingressCertificates([]*ingress{ing}, map[string]json.RawMessage{})
+	assert.Contains(t, errs["ns1/ing"].Error(), "TLS secret www-tls not found")
+}
+
+func TestController_ServesIngressesAndUpdatesStatus(t *testing.T) {
+	var (
+		mu              sync.Mutex
+		patches         []string
+		secretSelectors []string
+	)
+	secrets := fmt.Sprintf(`{"metadata":{"resourceVersion":"1"},"items":[%s]}`, testSecret(t))
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(req.Body)
+			mu.Lock()
+			patches = append(patches, req.URL.Path+" "+req.Header.Get("Content-Type")+" "+string(body))
+			mu.Unlock()
+			return
+		}
+		if req.URL.Query().Get("watch") != "" {
+			<-req.Context().Done()
+			return
+		}
+		switch req.URL.Path {
+		case "/api/v1/namespaces/ns1/services":
+			fmt.Fprint(resp, testServices)
+		case "/apis/networking.k8s.io/v1/namespaces/ns1/ingresses":
+			fmt.Fprintf(resp, `{"metadata":{"resourceVersion":"1"},"items":[%s,%s]}`, testIngress, otherClassIngress)
+		case "/api/v1/namespaces/ns1/secrets":
+			mu.Lock()
+			secretSelectors = append(secretSelectors, req.URL.Query().Get("fieldSelector"))
+			mu.Unlock()
+			if req.URL.Query().Get("fieldSelector") != "metadata.name=www-tls,type=kubernetes.io/tls" {
+				fmt.Fprint(resp, `{"metadata":{"resourceVersion":"1"},"items":[]}`)
+				return
+			}
+			fmt.Fprint(resp, secrets)
+		default:
+			resp.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	certs := NewCertificates()
+	updates := make(chan Configs, 10)
+	c := Start(logrus.New(), Config{
+		K8sURL:                 server.URL,
+		K8sClient:              http.DefaultClient,
+		Namespaces:             []string{"ns1"},
+		IngressClass:           "kedge",
+		IngressStatusAddresses: []string{"10.0.0.1", "kedge.example.com"},
+	}, certs, func(configs Configs) { updates <- configs })
+	defer c.Close()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		var configs Configs
+		select {
+		case configs = <-updates:
+		case <-deadline:
+			t.Fatal("timed out waiting for ingress routes and certificates")
+		}
+		if len(configs.Director.Http.Routes) == 5 && certs.get("www.example.com") != nil {
+			break
+		}
+	}
+
+	// Status is updated after configs are applied.
+	for i := 0; ; i++ {
+		mu.Lock()
+		n := len(patches)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("timed out waiting for ingress status update")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, `/apis/networking.k8s.io/v1/namespaces/ns1/ingresses/ing/status application/merge-patch+json `+
+		`{"status":{"loadBalancer":{"ingress":[{"ip":"10.0.0.1"},{"hostname":"kedge.example.com"}]}}}`, patches[0])
+	assert.Equal(t, []string{"metadata.name=www-tls,type=kubernetes.io/tls"}, secretSelectors,
+		"only TLS secrets of served ingresses should be read")
+}
@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	pb_config "github.com/mwitkow/kedge/_protogen/kedge/config"
+	"github.com/sirupsen/logrus"
+)
+
+// MergeBackendpool returns static backendpool config with discovered backends appended. Static backends win on name
+// conflict. Neither of the configs is modified.
+func MergeBackendpool(logger logrus.FieldLogger, static *pb_config.BackendPoolConfig, discovered Configs) *pb_config.BackendPoolConfig {
+	merged := &pb_config.BackendPoolConfig{
+		TlsServerConfigs: static.GetTlsServerConfigs(),
+		Grpc:             &pb_config.BackendPoolConfig_Grpc{},
+		Http:             &pb_config.BackendPoolConfig_Http{},
+		Tcp:              static.GetTcp(),
+	}
+
+	grpcNames := map[string]struct{}{}
+	merged.Grpc.Backends = append(merged.Grpc.Backends, static.GetGrpc().GetBackends()...)
+	for _, b := range static.GetGrpc().GetBackends() {
+		grpcNames[b.Name] = struct{}{}
+	}
+	for _, b := range discovered.Backendpool.GetGrpc().GetBackends() {
+		if _, ok := grpcNames[b.Name]; ok {
+			logger.Warnf("discovery: gRPC backend %v conflicts with static one. Ignoring", b.Name)
+			continue
+		}
+		merged.Grpc.Backends = append(merged.Grpc.Backends, b)
+	}
+
+	httpNames := map[string]struct{}{}
+	merged.Http.Backends = append(merged.Http.Backends, static.GetHttp().GetBackends()...)
+	for _, b := range static.GetHttp().GetBackends() {
+		httpNames[b.Name] = struct{}{}
+	}
+	for _, b := range discovered.Backendpool.GetHttp().GetBackends() {
+		if _, ok := httpNames[b.Name]; ok {
+			logger.Warnf("discovery: http backend %v conflicts with static one. Ignoring", b.Name)
+			continue
+		}
+		merged.Http.Backends = append(merged.Http.Backends, b)
+	}
+	return merged
+}
+
+// MergeDirector returns static director config with discovered routes appended, so static routes are matched first.
+// Discovered routes to backends that conflict with static ones are dropped. Neither of the configs is modified.
+func MergeDirector(static *pb_config.DirectorConfig, staticBackends *pb_config.BackendPoolConfig, discovered Configs) *pb_config.DirectorConfig {
+	merged := &pb_config.DirectorConfig{
+		Grpc: &pb_config.DirectorConfig_Grpc{},
+		Http: &pb_config.DirectorConfig_Http{AdhocRules: static.GetHttp().GetAdhocRules()},
+		Tcp:  static.GetTcp(),
+	}
+
+	merged.Grpc.Routes = append(merged.Grpc.Routes, static.GetGrpc().GetRoutes()...)
+	grpcConflicts := map[string]struct{}{}
+	for _, b := range staticBackends.GetGrpc().GetBackends() {
+		grpcConflicts[b.Name] = struct{}{}
+	}
+	for _, r := range discovered.Director.GetGrpc().GetRoutes() {
+		if _, ok := grpcConflicts[r.BackendName]; !ok {
+			merged.Grpc.Routes = append(merged.Grpc.Routes, r)
+		}
+	}
+
+	merged.Http.Routes = append(merged.Http.Routes, static.GetHttp().GetRoutes()...)
+	httpConflicts := map[string]struct{}{}
+	for _, b := range staticBackends.GetHttp().GetBackends() {
+		httpConflicts[b.Name] = struct{}{}
+	}
+	for _, r := range discovered.Director.GetHttp().GetRoutes() {
+		if _, ok := httpConflicts[r.BackendName]; !ok {
+			merged.Http.Routes = append(merged.Http.Routes, r)
+		}
+	}
+	return merged
+}
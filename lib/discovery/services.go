@@ -0,0 +1,265 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mwitkow/go-proto-validators"
+	pb_config "github.com/mwitkow/kedge/_protogen/kedge/config"
+	pb_res "github.com/mwitkow/kedge/_protogen/kedge/config/common/resolvers"
+	pb_grpcbe "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/backends"
+	pb_grpcroute "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/routes"
+	pb_httpbe "github.com/mwitkow/kedge/_protogen/kedge/config/http/backends"
+	pb_httproute "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/pkg/errors"
+)
+
+// Service annotations used to expose the service through kedge. Only services with AnnotationHost are discovered.
+const (
+	// AnnotationHost is the host (HTTP) or authority (gRPC) matcher of the routes.
+	AnnotationHost = "kedge.io/host"
+	// AnnotationProtocol is either "http" (default) or "grpc".
+	AnnotationProtocol = "kedge.io/protocol"
+	// AnnotationPaths are comma separated HTTP path rules, e.g. "/api/*,/static/*". All paths if empty.
+	AnnotationPaths = "kedge.io/paths"
+	// AnnotationGrpcServices are comma separated gRPC service name matchers, e.g. "myorg.api.*". All services if empty.
+	AnnotationGrpcServices = "kedge.io/grpc-services"
+	// AnnotationPort is the endpoint port name or number to proxy to. First port of the endpoints if empty.
+	AnnotationPort = "kedge.io/port"
+	// AnnotationBalancer is the balancer of the backend, e.g. "round_robin".
+	AnnotationBalancer = "kedge.io/balancer"
+	// AnnotationAuth set to "required" makes the HTTP service exposed only if kedge authorizes requests.
+	AnnotationAuth = "kedge.io/auth"
+	// AnnotationBackendName overrides the backend name, which is "<name>.<namespace>" otherwise. If that is not a valid
+	// backend name (e.g. contains '-' or digits, or is too long), it is sanitized and suffixed with its hash.
+	AnnotationBackendName = "kedge.io/backend-name"
+)
+
+const (
+	protocolHTTP = "http"
+	protocolGRPC = "grpc"
+
+	authRequired = "required"
+)
+
+const (
+	maxBackendNameLen  = 64
+	backendNameHashLen = 10
+)
+
+var invalidBackendNameChars = regexp.MustCompile("[^a-z_.]")
+
+// discoveredBackendName returns a valid backend name for the name of Kubernetes objects. Names with characters not
+// allowed in backend names have them replaced by '_', are truncated and suffixed with '_' and a hash (in letters) of the
+// original name, so different objects (e.g. api-v1 and api-v2) don't share a backend. Kubernetes names cannot contain
+// '_', so the hashed names cannot collide with the valid ones either.
+func discoveredBackendName(name string) string {
+	name = strings.ToLower(name)
+	sanitized := invalidBackendNameChars.ReplaceAllString(name, "_")
+	if sanitized == name && len(name) <= maxBackendNameLen {
+		return name
+	}
+	if max := maxBackendNameLen - backendNameHashLen - 1; len(sanitized) > max {
+		sanitized = sanitized[:max]
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	sum := h.Sum64()
+	hash := make([]byte, backendNameHashLen)
+	for i := range hash {
+		hash[i] = byte('a' + sum%26)
+		sum /= 26
+	}
+	return sanitized + "_" + string(hash)
+}
+
+type service struct {
+	Metadata objectMeta  `json:"metadata"`
+	Spec     serviceSpec `json:"spec"`
+}
+
+type serviceSpec struct {
+	Ports []servicePort `json:"ports"`
+}
+
+type servicePort struct {
+	Name string `json:"name"`
+	Port int32  `json:"port"`
+}
+
+// Configs are kedge configs discovered from Kubernetes objects. They are merged with the static configs.
+type Configs struct {
+	Director    *pb_config.DirectorConfig
+	Backendpool *pb_config.BackendPoolConfig
+}
+
+func emptyConfigs() Configs {
+	return Configs{
+		Director: &pb_config.DirectorConfig{
+			Grpc: &pb_config.DirectorConfig_Grpc{},
+			Http: &pb_config.DirectorConfig_Http{},
+		},
+		Backendpool: &pb_config.BackendPoolConfig{
+			Grpc: &pb_config.BackendPoolConfig_Grpc{},
+			Http: &pb_config.BackendPoolConfig_Http{},
+		},
+	}
+}
+
+// servicesToConfigs synthesizes backends and routes from annotated services. Services with invalid annotations are
+// skipped and returned as errors by namespace/name.
+func servicesToConfigs(objects map[string]json.RawMessage, authAvailable bool) (Configs, map[string]error) {
+	configs := emptyConfigs()
+	errs := map[string]error{}
+
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	// Routes are matched in order, so keep it stable.
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var svc service
+		if err := json.Unmarshal(objects[key], &svc); err != nil {
+			errs[key] = errors.Wrap(err, "failed to decode service")
+			continue
+		}
+		if svc.Metadata.Annotations[AnnotationHost] == "" {
+			continue
+		}
+		if err := addService(configs, svc.Metadata, authAvailable); err != nil {
+			errs[key] = err
+		}
+	}
+	return configs, errs
+}
+
+func addService(configs Configs, meta objectMeta, authAvailable bool) error {
+	annotations := meta.Annotations
+	switch auth := annotations[AnnotationAuth]; auth {
+	case "":
+	case authRequired:
+		if annotations[AnnotationProtocol] == protocolGRPC {
+			return errors.Errorf("%s is %q, but kedge authorizes only HTTP requests", AnnotationAuth, auth)
+		}
+		if !authAvailable {
+			return errors.Errorf("%s is %q, but kedge has no authorization configured", AnnotationAuth, auth)
+		}
+	default:
+		return errors.Errorf("unknown %s value %q", AnnotationAuth, auth)
+	}
+
+	backendName := annotations[AnnotationBackendName]
+	if backendName == "" {
+		backendName = discoveredBackendName(fmt.Sprintf("%s.%s", meta.Name, meta.Namespace))
+	}
+	if backendExists(configs, backendName) {
+		return errors.Errorf("backend name %v is already used by another service", backendName)
+	}
+	k8sResolver := &pb_res.K8SResolver{DnsPortName: fmt.Sprintf("%s.%s", meta.Name, meta.Namespace)}
+	if port := annotations[AnnotationPort]; port != "" {
+		k8sResolver.DnsPortName += ":" + port
+	}
+	host := annotations[AnnotationHost]
+
+	switch protocol := annotations[AnnotationProtocol]; protocol {
+	case "", protocolHTTP:
+		backend := &pb_httpbe.Backend{
+			Name:     backendName,
+			Resolver: &pb_httpbe.Backend_K8S{K8S: k8sResolver},
+		}
+		if b := annotations[AnnotationBalancer]; b != "" {
+			balancer, ok := pb_httpbe.Balancer_value[strings.ToUpper(b)]
+			if !ok {
+				return errors.Errorf("unknown %s value %q", AnnotationBalancer, b)
+			}
+			backend.Balancer = pb_httpbe.Balancer(balancer)
+		}
+		route := &pb_httproute.Route{
+			BackendName: backendName,
+			HostMatcher: host,
+			PathRules:   splitList(annotations[AnnotationPaths]),
+		}
+		if err := validate(backend, route); err != nil {
+			return err
+		}
+		configs.Backendpool.Http.Backends = append(configs.Backendpool.Http.Backends, backend)
+		configs.Director.Http.Routes = append(configs.Director.Http.Routes, route)
+	case protocolGRPC:
+		backend := &pb_grpcbe.Backend{
+			Name:     backendName,
+			Resolver: &pb_grpcbe.Backend_K8S{K8S: k8sResolver},
+		}
+		if b := annotations[AnnotationBalancer]; b != "" {
+			balancer, ok := pb_grpcbe.Balancer_value[strings.ToUpper(b)]
+			if !ok {
+				return errors.Errorf("unknown %s value %q", AnnotationBalancer, b)
+			}
+			backend.Balancer = pb_grpcbe.Balancer(balancer)
+		}
+		serviceNames := splitList(annotations[AnnotationGrpcServices])
+		if len(serviceNames) == 0 {
+			serviceNames = []string{""}
+		}
+		var routes []*pb_grpcroute.Route
+		for _, serviceName := range serviceNames {
+			route := &pb_grpcroute.Route{
+				BackendName:        backendName,
+				AuthorityMatcher:   host,
+				ServiceNameMatcher: serviceName,
+			}
+			if err := validate(route); err != nil {
+				return err
+			}
+			routes = append(routes, route)
+		}
+		if err := validate(backend); err != nil {
+			return err
+		}
+		configs.Backendpool.Grpc.Backends = append(configs.Backendpool.Grpc.Backends, backend)
+		configs.Director.Grpc.Routes = append(configs.Director.Grpc.Routes, routes...)
+	default:
+		return errors.Errorf("unknown %s value %q", AnnotationProtocol, protocol)
+	}
+	return nil
+}
+
+func backendExists(configs Configs, name string) bool {
+	for _, b := range configs.Backendpool.Http.Backends {
+		if b.Name == name {
+			return true
+		}
+	}
+	for _, b := range configs.Backendpool.Grpc.Backends {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validate(msgs ...interface{}) error {
+	for _, msg := range msgs {
+		if v, ok := msg.(validator.Validator); ok {
+			if err := v.Validate(); err != nil {
+				return errors.Wrap(err, "invalid config synthesized from annotations")
+			}
+		}
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
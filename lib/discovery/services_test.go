@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pb_config "github.com/mwitkow/kedge/_protogen/kedge/config"
+	pb_res "github.com/mwitkow/kedge/_protogen/kedge/config/common/resolvers"
+	pb_grpcbe "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/backends"
+	pb_grpcroute "github.com/mwitkow/kedge/_protogen/kedge/config/grpc/routes"
+	pb_httpbe "github.com/mwitkow/kedge/_protogen/kedge/config/http/backends"
+	pb_httproute "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serviceObject(t *testing.T, namespace string, name string, annotations map[string]string) json.RawMessage {
+	o, err := json.Marshal(service{Metadata: objectMeta{Name: name, Namespace: namespace, Annotations: annotations}})
+	require.NoError(t, err)
+	return o
+}
+
+func TestServicesToConfigs(t *testing.T) {
+	objects := map[string]json.RawMessage{
+		"web/front-end": serviceObject(t, "web", "front-end", map[string]string{
+			AnnotationHost:  "www.example.com",
+			AnnotationPaths: "/api/*, /static/*",
+			AnnotationPort:  "http",
+		}),
+		"rpc/users": serviceObject(t, "rpc", "users", map[string]string{
+			AnnotationHost:         "users.example.com",
+			AnnotationProtocol:     "grpc",
+			AnnotationGrpcServices: "users.v1.*,admin.Users",
+			AnnotationBalancer:     "round_robin",
+			AnnotationBackendName:  "users",
+		}),
+		"web2/api-v2":       serviceObject(t, "web2", "api-v2", map[string]string{AnnotationHost: "api.example.com"}),
+		"web/not-exposed":   serviceObject(t, "web", "not-exposed", nil),
+		"web/auth":          serviceObject(t, "web", "auth", map[string]string{AnnotationHost: "a.example.com", AnnotationAuth: "required"}),
+		"web/bad-balancer":  serviceObject(t, "web", "bad-balancer", map[string]string{AnnotationHost: "b.example.com", AnnotationBalancer: "random"}),
+		"web/bad-name":      serviceObject(t, "web", "bad-name", map[string]string{AnnotationHost: "c.example.com", AnnotationBackendName: "Bad-Name"}),
+		"web/users":         serviceObject(t, "web", "users", map[string]string{AnnotationHost: "d.example.com", AnnotationBackendName: "users"}),
+		"web/bad-protocol":  serviceObject(t, "web", "bad-protocol", map[string]string{AnnotationHost: "e.example.com", AnnotationProtocol: "tcp"}),
+		"rpc/auth-required": serviceObject(t, "rpc", "auth-required", map[string]string{AnnotationHost: "f.example.com", AnnotationProtocol: "grpc", AnnotationAuth: "required"}),
+	}
+
+	configs, errs := servicesToConfigs(objects, false)
+	assert.Equal(t, []*pb_httpbe.Backend{
+		{Name: "front_end.web_uwamvnsdzq", Resolver: &pb_httpbe.Backend_K8S{K8S: &pb_res.K8SResolver{DnsPortName: "front-end.web:http"}}},
+		{Name: "api_v_.web__gjjhnwfxwq", Resolver: &pb_httpbe.Backend_K8S{K8S: &pb_res.K8SResolver{DnsPortName: "api-v2.web2"}}},
+	}, configs.Backendpool.Http.Backends)
+	assert.Equal(t, []*pb_httproute.Route{
+		{BackendName: "front_end.web_uwamvnsdzq", HostMatcher: "www.example.com", PathRules: []string{"/api/*", "/static/*"}},
+		{BackendName: "api_v_.web__gjjhnwfxwq", HostMatcher: "api.example.com"},
+	}, configs.Director.Http.Routes)
+	assert.Equal(t, []*pb_grpcbe.Backend{
+		{Name: "users", Balancer: pb_grpcbe.Balancer_ROUND_ROBIN, Resolver: &pb_grpcbe.Backend_K8S{K8S: &pb_res.K8SResolver{DnsPortName: "users.rpc"}}},
+	}, configs.Backendpool.Grpc.Backends)
+	assert.Equal(t, []*pb_grpcroute.Route{
+		{BackendName: "users", AuthorityMatcher: "users.example.com", ServiceNameMatcher: "users.v1.*"},
+		{BackendName: "users", AuthorityMatcher: "users.example.com", ServiceNameMatcher: "admin.Users"},
+	}, configs.Director.Grpc.Routes)
+
+	assert.Len(t, errs, 6)
+	for _, key := range []string{"web/auth", "web/bad-balancer", "web/bad-name", "web/users", "web/bad-protocol", "rpc/auth-required"} {
+		assert.Error(t, errs[key], key)
+	}
+
+	configs, errs = servicesToConfigs(map[string]json.RawMessage{"web/auth": objects["web/auth"]}, true)
+	assert.Empty(t, errs)
+	assert.Len(t, configs.Backendpool.Http.Backends, 1, "service requiring auth should be exposed if kedge authorizes requests")
+}
+
+func TestMerge_StaticWinsOnConflict(t *testing.T) {
+	static := &pb_config.BackendPoolConfig{
+		Http: &pb_config.BackendPoolConfig_Http{Backends: []*pb_httpbe.Backend{{Name: "static"}, {Name: "conflict"}}},
+	}
+	staticDirector := &pb_config.DirectorConfig{
+		Http: &pb_config.DirectorConfig_Http{Routes: []*pb_httproute.Route{{BackendName: "static"}}},
+	}
+	discovered := emptyConfigs()
+	discovered.Backendpool.Http.Backends = []*pb_httpbe.Backend{{Name: "conflict", Balancer: 0}, {Name: "discovered"}}
+	discovered.Backendpool.Grpc.Backends = []*pb_grpcbe.Backend{{Name: "grpc_discovered"}}
+	discovered.Director.Http.Routes = []*pb_httproute.Route{{BackendName: "conflict", HostMatcher: "c"}, {BackendName: "discovered"}}
+	discovered.Director.Grpc.Routes = []*pb_grpcroute.Route{{BackendName: "grpc_discovered"}}
+
+	backendpool := MergeBackendpool(logrus.New(), static, discovered)
+	assert.Equal(t, []*pb_httpbe.Backend{{Name: "static"}, {Name: "conflict"}, {Name: "discovered"}}, backendpool.Http.Backends)
+	assert.Equal(t, []*pb_grpcbe.Backend{{Name: "grpc_discovered"}}, backendpool.Grpc.Backends)
+	assert.Len(t, static.Http.Backends, 2, "static config should not be modified")
+
+	director := MergeDirector(staticDirector, static, discovered)
+	assert.Equal(t, []*pb_httproute.Route{{BackendName: "static"}, {BackendName: "discovered"}}, director.Http.Routes)
+	assert.Equal(t, []*pb_grpcroute.Route{{BackendName: "grpc_discovered"}}, director.Grpc.Routes)
+	assert.Len(t, staticDirector.Http.Routes, 1, "static config should not be modified")
+}
+
+func TestDiscoveredBackendName(t *testing.T) {
+	assert.Equal(t, "web.ns", discoveredBackendName("web.ns"))
+	assert.NotEqual(t, discoveredBackendName("api-v1.web"), discoveredBackendName("api-v2.web"),
+		"names differing only by digits should not collide")
+	assert.NotEqual(t, discoveredBackendName("web.ns1.http1"), discoveredBackendName("web.ns1.http2"),
+		"ports differing only by digits should not collide")
+
+	long := strings.Repeat("very-long-service-name", 3) + ".namespace"
+	name := discoveredBackendName(long)
+	assert.Len(t, name, maxBackendNameLen)
+	assert.Regexp(t, "^[a-z_.]{2,64}$", name)
+	assert.NotEqual(t, name, discoveredBackendName(long+"2"), "truncated names should not collide")
+}
@@ -0,0 +1,186 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// objectMeta is the part of Kubernetes object metadata used by discovery.
+type objectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type objectList struct {
+	Metadata objectMeta        `json:"metadata"`
+	Items    []json.RawMessage `json:"items"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type watchStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var errGone = errors.New("resourceVersion is too old (410 Gone)")
+
+// listWatcher keeps objects of a single Kubernetes collection (e.g. Services in a namespace) up to date. It lists them
+// first and then watches for changes from the resourceVersion of the list, listing again if it is too old.
+type listWatcher struct {
+	logger        logrus.FieldLogger
+	k8sURL        string
+	k8sClient     *http.Client
+	path          string
+	labelSelector string
+	fieldSelector string
+	retryBackoff  *backoff.Backoff
+
+	// onChange is called with all objects, by namespace/name, after every change.
+	onChange func(objects map[string]json.RawMessage)
+}
+
+func (w *listWatcher) run(ctx context.Context) {
+	objects := map[string]json.RawMessage{}
+	resourceVersion := ""
+	for ctx.Err() == nil {
+		if resourceVersion == "" {
+			list := &objectList{}
+			if err := w.get(ctx, false, "", func(body io.Reader) error { return json.NewDecoder(body).Decode(list) }); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				w.logger.WithError(err).Errorf("discovery: Failed to list %s", w.path)
+				time.Sleep(w.retryBackoff.Duration())
+				continue
+			}
+			objects = map[string]json.RawMessage{}
+			for _, item := range list.Items {
+				meta, err := metaOf(item)
+				if err != nil {
+					w.logger.WithError(err).Warnf("discovery: Failed to decode object from %s", w.path)
+					continue
+				}
+				objects[meta.Namespace+"/"+meta.Name] = item
+			}
+			w.onChange(copyObjects(objects))
+			resourceVersion = list.Metadata.ResourceVersion
+		}
+
+		err := w.get(ctx, true, resourceVersion, func(body io.Reader) error {
+			decoder := json.NewDecoder(body)
+			for {
+				var e watchEvent
+				if err := decoder.Decode(&e); err != nil {
+					return err
+				}
+				w.retryBackoff.Reset()
+				switch e.Type {
+				case "ADDED", "MODIFIED", "DELETED":
+					meta, err := metaOf(e.Object)
+					if err != nil {
+						return err
+					}
+					if e.Type == "DELETED" {
+						delete(objects, meta.Namespace+"/"+meta.Name)
+					} else {
+						objects[meta.Namespace+"/"+meta.Name] = e.Object
+					}
+					if meta.ResourceVersion != "" {
+						resourceVersion = meta.ResourceVersion
+					}
+					w.onChange(copyObjects(objects))
+				case "ERROR":
+					var s watchStatus
+					if err := json.Unmarshal(e.Object, &s); err != nil {
+						return errors.Wrap(err, "Unable to decode status of ERROR event")
+					}
+					if s.Code == http.StatusGone {
+						return errGone
+					}
+					return errors.Errorf("Got ERROR event: %d %s", s.Code, s.Message)
+				default:
+					return errors.Errorf("Got invalid watch event type: %v", e.Type)
+				}
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err == errGone:
+			resourceVersion = ""
+		case err == io.EOF:
+			// Server closes watch after a timeout, it is expected.
+		default:
+			w.logger.WithError(err).Errorf("discovery: Error on watch stream of %s. Retrying", w.path)
+			time.Sleep(w.retryBackoff.Duration())
+		}
+	}
+}
+
+func (w *listWatcher) get(ctx context.Context, watch bool, resourceVersion string, handle func(io.Reader) error) error {
+	query := url.Values{}
+	if w.labelSelector != "" {
+		query.Set("labelSelector", w.labelSelector)
+	}
+	if w.fieldSelector != "" {
+		query.Set("fieldSelector", w.fieldSelector)
+	}
+	if watch {
+		query.Set("watch", "true")
+	}
+	if resourceVersion != "" {
+		query.Set("resourceVersion", resourceVersion)
+	}
+	u := fmt.Sprintf("%s%s?%s", w.k8sURL, w.path, query.Encode())
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create new GET request %s", u)
+	}
+	resp, err := w.k8sClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "Failed to do GET %s request", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return errGone
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("Invalid response code %d on GET %s request", resp.StatusCode, u)
+	}
+	return handle(resp.Body)
+}
+
+func metaOf(object json.RawMessage) (objectMeta, error) {
+	var o struct {
+		Metadata objectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(object, &o); err != nil {
+		return objectMeta{}, errors.Wrap(err, "Unable to decode object metadata")
+	}
+	return o.Metadata, nil
+}
+
+func copyObjects(objects map[string]json.RawMessage) map[string]json.RawMessage {
+	c := make(map[string]json.RawMessage, len(objects))
+	for k, v := range objects {
+		c[k] = v
+	}
+	return c
+}
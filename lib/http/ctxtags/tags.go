@@ -11,6 +11,8 @@ const (
 
 	// TagForProxyAdhoc is used in kedge proxy to specify adhoc rule used in request.
 	TagForProxyAdhoc = "http.proxy.adhoc"
+	// TagForProxyAdhocRule specifies the DNS name matcher of the adhoc rule that matched the request.
+	TagForProxyAdhocRule = "http.proxy.adhoc_rule"
 	// TagForProxyBackend is used in kedge proxy to specify backend used in request.
 	TagForProxyBackend = "http.proxy.backend"
 	// TagForScheme specifies which scheme request is using. It is specified by each server.
@@ -25,4 +27,24 @@ const (
 
 	// TagForBackendTarget specifies the target name used to resolve in lbtransport.
 	TagForBackendTarget = "http.backend.target"
+	// TagForBackendTargetAddr specifies the resolved address chosen by lbtransport (of the last attempt).
+	TagForBackendTargetAddr = "http.backend.target_addr"
+	// TagForUpstreamTime specifies time that took to receive response headers from backend or adhoc destination.
+	TagForUpstreamTime = "http.upstream.time"
+
+	// TagForProxyRoute specifies the route (its host, port and path matchers) that matched the request in kedge proxy.
+	TagForProxyRoute = "http.proxy.route"
+	// TagForAuthSubject specifies the subject (email or sub claim) of the ID token authorized by kedge proxy auth.
+	TagForAuthSubject = "http.auth.subject"
+
+	// TagForUpgrade specifies the protocol requested in Upgrade header (e.g. websocket) for upgraded connections.
+	TagForUpgrade = "http.upgrade"
+
+	// TagForProxyCache specifies the response cache result (hit, miss, revalidated, bypass) for routes with cache enabled.
+	TagForProxyCache = "http.proxy.cache"
+
+	// TagForRequestID specifies the request ID (X-Request-Id) used to correlate the call across winch, kedge and backends.
+	TagForRequestID = "http.request_id"
+	// TagForTraceID specifies the distributed tracing trace ID of the call, if it is traced.
+	TagForTraceID = "http.trace_id"
 )
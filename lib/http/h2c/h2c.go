@@ -0,0 +1,123 @@
+// Package h2c implements plain-text HTTP/2 (h2c) with prior knowledge on top of a listener used by HTTP/1.x server.
+package h2c
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// NewListener wraps the listener, so connections starting with the HTTP/2 client preface (h2c with prior knowledge,
+// e.g. insecure gRPC clients) are served by HTTP/2 server using the handler. All other connections are returned from
+// Accept as usual, e.g. to be served by http.Server.
+//
+// The preface needs to be sent within peekTimeout, otherwise the connection is treated as HTTP/1.x.
+func NewListener(l net.Listener, handler http.Handler, peekTimeout time.Duration) net.Listener {
+	h := &listener{
+		Listener:    l,
+		handler:     handler,
+		h2Server:    &http2.Server{},
+		peekTimeout: peekTimeout,
+		conns:       make(chan net.Conn),
+		errs:        make(chan error),
+		done:        make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+type listener struct {
+	net.Listener
+	handler     http.Handler
+	h2Server    *http2.Server
+	peekTimeout time.Duration
+
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *listener) run() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go l.sniff(conn)
+	}
+}
+
+func (l *listener) sniff(conn net.Conn) {
+	if l.peekTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(l.peekTimeout))
+	}
+	reader := bufio.NewReader(conn)
+	isH2 := hasClientPreface(reader)
+	conn.SetReadDeadline(time.Time{})
+
+	peekedConn := &bufferedConn{Conn: conn, reader: reader}
+	if isH2 {
+		l.h2Server.ServeConn(peekedConn, &http2.ServeConnOpts{Handler: l.handler})
+		return
+	}
+	select {
+	case l.conns <- peekedConn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// hasClientPreface peeks byte by byte, so it does not block on short HTTP/1.x requests that differ early.
+func hasClientPreface(reader *bufio.Reader) bool {
+	for i := 1; i <= len(http2.ClientPreface); i++ {
+		b, err := reader.Peek(i)
+		if err != nil {
+			return false
+		}
+		if b[i-1] != http2.ClientPreface[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, errClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+var errClosed = errors.New("h2c: listener closed")
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
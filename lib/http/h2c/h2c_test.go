@@ -0,0 +1,53 @@
+package h2c
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestListener_ServesBothHTTP1AndH2C(t *testing.T) {
+	handler := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(resp, req.Proto)
+	})
+	rawListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener := NewListener(rawListener, handler, 1*time.Second)
+	defer listener.Close()
+	go http.Serve(listener, handler)
+
+	url := fmt.Sprintf("http://%s/", rawListener.Addr().String())
+	h2cClient := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+	for _, tcase := range []struct {
+		name          string
+		client        *http.Client
+		expectedProto string
+	}{
+		{name: "HTTP1", client: &http.Client{}, expectedProto: "HTTP/1.1"},
+		{name: "H2C", client: h2cClient, expectedProto: "HTTP/2.0"},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			resp, err := tcase.client.Get(url)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tcase.expectedProto, string(body))
+		})
+	}
+}
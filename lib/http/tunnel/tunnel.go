@@ -0,0 +1,135 @@
+// Package tunnel implements bidirectional byte splicing used for HTTP Upgrade and CONNECT proxying.
+package tunnel
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Splice copies bytes in both directions until one of the sides finishes or errors. Both sides are closed on return.
+// It returns number of bytes copied from client to backend (inbound) and from backend to client (outbound).
+func Splice(client io.ReadWriteCloser, backend io.ReadWriteCloser) (inbound int64, outbound int64) {
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		inbound, _ = io.Copy(backend, client)
+		// Unblock the other direction.
+		backend.Close()
+		client.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		outbound, _ = io.Copy(client, backend)
+		client.Close()
+		backend.Close()
+	}()
+	wg.Wait()
+	return inbound, outbound
+}
+
+// WithIdleTimeout wraps the connection, so its deadline is extended by idleTimeout on every read and write.
+// The connection is closed by the OS if there was no traffic in any direction for idleTimeout.
+// The reader (if not nil) is used for reads instead of the connection itself. It is useful for draining bytes that
+// were buffered before the connection was hijacked.
+//
+// If idleTimeout is 0, the connection never times out.
+func WithIdleTimeout(conn net.Conn, reader io.Reader, idleTimeout time.Duration) net.Conn {
+	if reader == nil {
+		reader = conn
+	}
+	// Clear any deadlines that were set by previous users (e.g. http.Server).
+	conn.SetDeadline(time.Time{})
+	return &idleTimeoutConn{Conn: conn, reader: reader, idleTimeout: idleTimeout}
+}
+
+type idleTimeoutConn struct {
+	net.Conn
+	reader      io.Reader
+	idleTimeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	c.extendDeadline()
+	return c.reader.Read(b)
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	c.extendDeadline()
+	return c.Conn.Write(b)
+}
+
+func (c *idleTimeoutConn) extendDeadline() {
+	if c.idleTimeout > 0 {
+		c.Conn.SetDeadline(time.Now().Add(c.idleTimeout))
+	}
+}
+
+// Hijack takes over the inbound connection of the HTTP/1.x request.
+// It returns connection with the idle timeout applied, that drains any bytes already buffered by http.Server.
+// NOTE: Nothing is written to the connection, it is up to caller to respond.
+func Hijack(resp http.ResponseWriter, idleTimeout time.Duration) (net.Conn, *bufio.Writer, error) {
+	hijacker, ok := resp.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("tunnel: response writer does not support hijacking")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "tunnel: failed to hijack connection")
+	}
+	return WithIdleTimeout(conn, buf.Reader, idleTimeout), buf.Writer, nil
+}
+
+// HTTP2Stream returns the inbound HTTP/2 stream of the request as a ReadWriteCloser. Writes are flushed immediately.
+// It is meant for HTTP/2 CONNECT requests, where the connection cannot be hijacked. Response headers need to be
+// written before using the stream.
+func HTTP2Stream(resp http.ResponseWriter, req *http.Request) (io.ReadWriteCloser, error) {
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		return nil, errors.New("tunnel: response writer is not a flusher")
+	}
+	return &http2Stream{body: req.Body, w: resp, flusher: flusher}, nil
+}
+
+type http2Stream struct {
+	body    io.ReadCloser
+	w       io.Writer
+	flusher http.Flusher
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *http2Stream) Read(b []byte) (int, error) {
+	return s.body.Read(b)
+}
+
+func (s *http2Stream) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := s.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	s.flusher.Flush()
+	return n, nil
+}
+
+func (s *http2Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.body.Close()
+}
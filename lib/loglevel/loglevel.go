@@ -0,0 +1,150 @@
+// Package loglevel controls the level of the operational log at runtime, globally and per subsystem.
+//
+// Levels are dynamic flags, so they can be changed without restart, e.g. through /debug/flagz.
+package loglevel
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mwitkow/go-flagz"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Subsystems which level can be overridden.
+const (
+	K8sResolver = "k8sresolver"
+	LBTransport = "lbtransport"
+	Director    = "director"
+	Auth        = "auth"
+)
+
+var (
+	// Subsystems is the list of all subsystems.
+	Subsystems = []string{K8sResolver, LBTransport, Director, Auth}
+	// Flags are names of the flags controlling log levels, meant to be changed at runtime through /debug/flagz.
+	Flags = []string{"log_level", "log_level_overrides"}
+
+	flagLevel = flagz.DynString(sharedflags.Set, "log_level", "info",
+		"Log level of the operational log: debug, info, warning, error, fatal or panic. Dynamic, can be changed at runtime.").
+		WithValidator(validateLevel)
+	flagOverrides = flagz.DynStringSlice(sharedflags.Set, "log_level_overrides", []string{},
+		"Log levels of subsystems that differ from log_level, as <subsystem>=<level> (e.g. k8sresolver=debug). "+
+			"Subsystems are: "+strings.Join(Subsystems, ", ")+". Dynamic, can be changed at runtime.").
+		WithValidator(validateOverrides)
+
+	mu      sync.Mutex
+	loggers = map[string]*logrus.Logger{}
+)
+
+func init() {
+	// Notifiers are set here, as they refer back to the flags.
+	flagLevel.WithNotifier(func(_, _ string) { update() })
+	flagOverrides.WithNotifier(func(_, _ []string) { update() })
+}
+
+func validateLevel(value string) error {
+	_, err := logrus.ParseLevel(value)
+	return err
+}
+
+func validateOverrides(values []string) error {
+	_, err := parseOverrides(values)
+	return err
+}
+
+func parseOverrides(values []string) (map[string]logrus.Level, error) {
+	overrides := map[string]logrus.Level{}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("loglevel: override %q is not in <subsystem>=<level> format", value)
+		}
+		subsystem := strings.TrimSpace(kv[0])
+		if !isSubsystem(subsystem) {
+			return nil, errors.Errorf("loglevel: unknown subsystem %q, expected one of: %s", subsystem, strings.Join(Subsystems, ", "))
+		}
+		level, err := logrus.ParseLevel(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "loglevel: override for %s", subsystem)
+		}
+		overrides[subsystem] = level
+	}
+	return overrides, nil
+}
+
+func isSubsystem(name string) bool {
+	for _, s := range Subsystems {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// levels returns the global level and the level for each subsystem. Flag values are already validated.
+func levels() (logrus.Level, map[string]logrus.Level) {
+	level, err := logrus.ParseLevel(flagLevel.Get())
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	overrides, err := parseOverrides(flagOverrides.Get())
+	if err != nil {
+		overrides = map[string]logrus.Level{}
+	}
+	return level, overrides
+}
+
+// update applies current flag values to the standard logger and all subsystem loggers.
+func update() {
+	level, overrides := levels()
+	mu.Lock()
+	defer mu.Unlock()
+	logrus.SetLevel(level)
+	for subsystem, logger := range loggers {
+		logger.Level = levelFor(subsystem, level, overrides)
+	}
+}
+
+func levelFor(subsystem string, level logrus.Level, overrides map[string]logrus.Level) logrus.Level {
+	if l, ok := overrides[subsystem]; ok {
+		return l
+	}
+	return level
+}
+
+// Subsystem returns a log entry for the given subsystem, tagged with a "subsystem" field.
+//
+// Its level is log_level unless overridden in log_level_overrides. Everything else (output, formatter and hooks) is
+// shared with the standard logger, so it needs to be configured before anything is logged, but can be done after
+// Subsystem is called.
+func Subsystem(name string) *logrus.Entry {
+	mu.Lock()
+	defer mu.Unlock()
+	logger, ok := loggers[name]
+	if !ok {
+		level, overrides := levels()
+		std := logrus.StandardLogger()
+		logger = logrus.New()
+		logger.Out = stdOutput{}
+		logger.Formatter = stdFormatter{}
+		logger.Hooks = std.Hooks
+		logger.Level = levelFor(name, level, overrides)
+		loggers[name] = logger
+	}
+	return logger.WithField("subsystem", name)
+}
+
+type stdOutput struct{}
+
+func (stdOutput) Write(p []byte) (int, error) {
+	return logrus.StandardLogger().Out.Write(p)
+}
+
+type stdFormatter struct{}
+
+func (stdFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return logrus.StandardLogger().Formatter.Format(entry)
+}
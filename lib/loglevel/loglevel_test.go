@@ -0,0 +1,56 @@
+package loglevel
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := parseOverrides([]string{"k8sresolver=debug", " auth = error "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]logrus.Level{K8sResolver: logrus.DebugLevel, Auth: logrus.ErrorLevel}, overrides)
+
+	for _, invalid := range [][]string{{"k8sresolver"}, {"unknown=debug"}, {"director=loud"}} {
+		_, err := parseOverrides(invalid)
+		assert.Error(t, err, "%v", invalid)
+	}
+}
+
+func TestSubsystem_FollowsLevelAndOverrides(t *testing.T) {
+	out := &bytes.Buffer{}
+	logrus.SetOutput(out)
+	logrus.SetFormatter(&logrus.TextFormatter{DisableColors: true, DisableTimestamp: true})
+	defer func() {
+		require.NoError(t, flagLevel.Set("info"))
+		require.NoError(t, flagOverrides.Set(""))
+		update()
+	}()
+
+	director := Subsystem(Director)
+	director.Debug("hidden")
+	assert.Empty(t, out.String())
+
+	require.NoError(t, flagOverrides.Set("director=debug"))
+	update()
+	director.Debug("shown")
+	assert.Contains(t, out.String(), `msg=shown subsystem=director`)
+	assert.Equal(t, logrus.InfoLevel, logrus.GetLevel(), "overrides should not change the global level")
+
+	out.Reset()
+	require.NoError(t, flagLevel.Set("error"))
+	update()
+	Subsystem(Auth).Warn("hidden")
+	director.Debug("shown")
+	assert.NotContains(t, out.String(), "hidden")
+	assert.Contains(t, out.String(), "shown")
+	assert.Equal(t, logrus.ErrorLevel, logrus.GetLevel())
+}
+
+func TestValidators(t *testing.T) {
+	assert.Error(t, flagLevel.Set("loud"))
+	assert.Error(t, flagOverrides.Set("unknown=debug"))
+}
@@ -0,0 +1,99 @@
+package logsink
+
+import (
+	"time"
+
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+)
+
+const (
+	SinkLogstash = "logstash"
+	SinkSyslog   = "syslog"
+	SinkFluentd  = "fluentd"
+	SinkHTTP     = "http"
+)
+
+var (
+	flagSink = sharedflags.Set.String("remote_log_sink", SinkLogstash,
+		"Type of the remote log sink: logstash, syslog, fluentd or http (bulk JSON).")
+	flagAddress = sharedflags.Set.String("remote_log_address", "",
+		"Host:port of the remote log sink, or URL for http sink. If empty (and logstash_hostport is empty), remote logging is disabled.")
+	flagLogstashAddress = sharedflags.Set.String("logstash_hostport", "",
+		"Host:port of logstash for remote logging. Deprecated: use remote_log_address with remote_log_sink=logstash.")
+
+	flagSyslogFacility = sharedflags.Set.String("remote_log_syslog_facility", "local0",
+		"Facility of messages sent to syslog remote log sink, e.g. daemon or local0.")
+	flagFluentdTag = sharedflags.Set.String("remote_log_fluentd_tag", "",
+		"Tag of events sent to fluentd remote log sink. If empty, the service name (kedge or winch) is used.")
+
+	// deprecatedFlags maps names used when remote logging supported only logstash to the current ones.
+	deprecatedFlags = map[string]string{
+		"logstash_transport":            "remote_log_transport",
+		"logstash_tls_ca_path":          "remote_log_tls_ca_path",
+		"logstash_tls_client_cert_path": "remote_log_tls_client_cert_path",
+		"logstash_tls_client_key_path":  "remote_log_tls_client_key_path",
+		"logstash_tls_server_name":      "remote_log_tls_server_name",
+		"logstash_batch_size":           "remote_log_batch_size",
+		"logstash_flush_interval":       "remote_log_flush_interval",
+	}
+)
+
+func init() {
+	sharedflags.Set.String("logstash_transport", TransportTCP, "Deprecated: use remote_log_transport.")
+	sharedflags.Set.String("logstash_tls_ca_path", "", "Deprecated: use remote_log_tls_ca_path.")
+	sharedflags.Set.String("logstash_tls_client_cert_path", "", "Deprecated: use remote_log_tls_client_cert_path.")
+	sharedflags.Set.String("logstash_tls_client_key_path", "", "Deprecated: use remote_log_tls_client_key_path.")
+	sharedflags.Set.String("logstash_tls_server_name", "", "Deprecated: use remote_log_tls_server_name.")
+	sharedflags.Set.Int("logstash_batch_size", 1, "Deprecated: use remote_log_batch_size.")
+	sharedflags.Set.Duration("logstash_flush_interval", 1*time.Second, "Deprecated: use remote_log_flush_interval.")
+	for deprecated, replacement := range deprecatedFlags {
+		sharedflags.Set.MarkDeprecated(deprecated, "use "+replacement+" instead")
+	}
+}
+
+// applyDeprecatedFlags copies values of deprecated flags to their replacements, unless those are set as well.
+func applyDeprecatedFlags() error {
+	for deprecated, replacement := range deprecatedFlags {
+		if !sharedflags.Set.Changed(deprecated) || sharedflags.Set.Changed(replacement) {
+			continue
+		}
+		if err := sharedflags.Set.Set(replacement, sharedflags.Set.Lookup(deprecated).Value.String()); err != nil {
+			return errors.Wrapf(err, "logsink: failed to apply deprecated flag %v", deprecated)
+		}
+	}
+	return nil
+}
+
+// NewHookFromFlags creates a hook for the remote log sink specified in flags, or returns nil if remote logging is
+// disabled. The serviceName is used as syslog app name and default fluentd tag.
+func NewHookFromFlags(serviceName string) (*Hook, error) {
+	if err := applyDeprecatedFlags(); err != nil {
+		return nil, err
+	}
+	address := *flagAddress
+	if address == "" {
+		if *flagLogstashAddress == "" {
+			return nil, nil
+		}
+		if *flagSink != SinkLogstash {
+			return nil, errors.New("logsink: logstash_hostport can be used only with logstash remote log sink")
+		}
+		address = *flagLogstashAddress
+	}
+	switch *flagSink {
+	case SinkLogstash:
+		return NewLogstashHook(address)
+	case SinkSyslog:
+		return NewSyslogHook(address, serviceName, *flagSyslogFacility)
+	case SinkFluentd:
+		tag := *flagFluentdTag
+		if tag == "" {
+			tag = serviceName
+		}
+		return NewFluentdHook(address, tag)
+	case SinkHTTP:
+		return NewHTTPHook(address)
+	}
+	return nil, errors.Errorf("logsink: unknown remote log sink %q", *flagSink)
+}
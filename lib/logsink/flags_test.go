@@ -0,0 +1,30 @@
+package logsink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDeprecatedFlags(t *testing.T) {
+	defaultTransport, defaultBatchSize, defaultFlushInterval := *flagTransport, *flagBatchSize, *flagFlushInterval
+	defer func() {
+		*flagTransport, *flagBatchSize, *flagFlushInterval = defaultTransport, defaultBatchSize, defaultFlushInterval
+		for _, name := range []string{"logstash_transport", "logstash_batch_size", "logstash_flush_interval", "remote_log_flush_interval"} {
+			sharedflags.Set.Lookup(name).Changed = false
+		}
+	}()
+
+	require.NoError(t, sharedflags.Set.Set("logstash_transport", TransportUDP))
+	require.NoError(t, sharedflags.Set.Set("logstash_batch_size", "50"))
+	require.NoError(t, sharedflags.Set.Set("logstash_flush_interval", "5s"))
+	require.NoError(t, sharedflags.Set.Set("remote_log_flush_interval", "3s"))
+	require.NoError(t, applyDeprecatedFlags())
+
+	assert.Equal(t, TransportUDP, *flagTransport)
+	assert.Equal(t, 50, *flagBatchSize)
+	assert.Equal(t, 3*time.Second, *flagFlushInterval, "explicitly set replacement should win over deprecated flag")
+}
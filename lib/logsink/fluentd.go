@@ -0,0 +1,87 @@
+package logsink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+)
+
+// NewFluentdHook creates a hook sending logs with tag to hostPort in Fluentd forward protocol (Forward Mode), with
+// transport specified in flags.
+// See https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1
+func NewFluentdHook(hostPort string, tag string) (*Hook, error) {
+	if *flagTransport == TransportUDP {
+		return nil, errors.New("logsink: udp transport is not supported by fluentd forward protocol")
+	}
+	dial, err := dialFromFlags(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	return NewHook(newFluentdSink(NewReconnectingWriter(dial, newErrLogger()), tag), 0)
+}
+
+// fluentdSink encodes entries as msgpack [time, record] and sends each batch as a single [tag, [entries...]] message.
+type fluentdSink struct {
+	writer io.Writer
+	tag    string
+	handle *codec.MsgpackHandle
+}
+
+func newFluentdSink(writer io.Writer, tag string) *fluentdSink {
+	handle := &codec.MsgpackHandle{}
+	handle.WriteExt = true // Use str8 and bin types of msgpack spec.
+	return &fluentdSink{writer: writer, tag: tag, handle: handle}
+}
+
+func (s *fluentdSink) Encode(entry *logrus.Entry) ([]byte, error) {
+	record := map[string]interface{}{
+		"message": entry.Message,
+		"level":   entry.Level.String(),
+	}
+	for k, v := range entry.Data {
+		switch val := v.(type) {
+		case string, bool, int, int32, int64, uint, uint32, uint64, float32, float64, nil:
+			record[k] = val
+		case error:
+			record[k] = val.Error()
+		default:
+			record[k] = fmt.Sprintf("%v", val)
+		}
+	}
+	var b []byte
+	if err := codec.NewEncoderBytes(&b, s.handle).Encode([]interface{}{entry.Time.Unix(), record}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *fluentdSink) Write(batch [][]byte) error {
+	var tag []byte
+	if err := codec.NewEncoderBytes(&tag, s.handle).Encode(s.tag); err != nil {
+		return err
+	}
+	b := &bytes.Buffer{}
+	b.Write(msgpackArrayHeader(2))
+	b.Write(tag)
+	b.Write(msgpackArrayHeader(len(batch)))
+	for _, entry := range batch {
+		b.Write(entry)
+	}
+	_, err := s.writer.Write(b.Bytes())
+	return err
+}
+
+func msgpackArrayHeader(n int) []byte {
+	switch {
+	case n < 16:
+		return []byte{0x90 | byte(n)}
+	case n <= math.MaxUint16:
+		return []byte{0xdc, byte(n >> 8), byte(n)}
+	}
+	return []byte{0xdd, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
@@ -0,0 +1,162 @@
+// Package logsink implements logrus hooks sending logs asynchronously to remote log collectors: logstash, syslog,
+// Fluentd or HTTP endpoints accepting bulk JSON.
+package logsink
+
+import (
+	"time"
+
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	flagLogstashWriteTimeout = sharedflags.Set.Duration(
+		"logstash_write_timeout", 2*time.Second,
+		"Time to wait for a successfull write to the remote log sink before dropping a log")
+	flagLogstashWriteBufferSize = sharedflags.Set.Int(
+		"logstash_write_buffer_size", 5000,
+		"Size of the buffer for log entries for the async writer",
+	)
+	flagBatchSize = sharedflags.Set.Int(
+		"remote_log_batch_size", 1,
+		"Max number of log entries sent to the remote log sink in a single write. If 1, entries are not batched.",
+	)
+	flagFlushInterval = sharedflags.Set.Duration(
+		"remote_log_flush_interval", 1*time.Second,
+		"Max time log entries wait in a batch before being sent to the remote log sink.",
+	)
+)
+
+// Sink encodes log entries and writes them to a remote log collector.
+type Sink interface {
+	// Encode returns a single encoded entry.
+	Encode(entry *logrus.Entry) ([]byte, error)
+	// Write sends the batch of encoded entries. It is never called concurrently.
+	Write(batch [][]byte) error
+}
+
+// Hook sends logs to a Sink.
+type Hook struct {
+	sink      Sink
+	buffer    chan *logrus.Entry
+	errLogger *logrus.Entry
+
+	batchSize     int
+	maxBatchBytes int
+	flushInterval time.Duration
+}
+
+// NewHook creates a hook to be added to an instance of logger. Entries are buffered and sent to the sink in batches
+// of at most maxBatchBytes (if not 0).
+func NewHook(sink Sink, maxBatchBytes int) (*Hook, error) {
+	hook := &Hook{
+		sink:          sink,
+		buffer:        make(chan *logrus.Entry, *flagLogstashWriteBufferSize),
+		errLogger:     newErrLogger(),
+		batchSize:     *flagBatchSize,
+		maxBatchBytes: maxBatchBytes,
+		flushInterval: *flagFlushInterval,
+	}
+	if hook.batchSize < 1 {
+		hook.batchSize = 1
+	}
+	if hook.batchSize > 1 && hook.flushInterval <= 0 {
+		return nil, errors.Errorf("logsink: remote_log_flush_interval needs to be positive when batching, got %v", hook.flushInterval)
+	}
+
+	go hook.start()
+
+	return hook, nil
+}
+
+func newErrLogger() *logrus.Entry {
+	return logrus.New().WithField("system", "logsink")
+}
+
+// Fire implements the Fire method from the Hook interface. It writes to its local buffer for the Entry to be sent
+// off asynchronously. If the buffer is full, it will drop the entry.
+func (hook *Hook) Fire(entry *logrus.Entry) error {
+	reportEntry(entry.Level)
+	select {
+	case hook.buffer <- entry:
+	default:
+		hook.errLogger.Error("Failed to write log message due to full buffer.")
+		reportDropped(entry.Level, BufferFull)
+	}
+	return nil
+}
+
+// Levels returns all logrus levels.
+func (hook Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook *Hook) start() {
+	// Without batching every entry is written right away, so there is nothing to flush periodically.
+	var flushes <-chan time.Time
+	if hook.batchSize > 1 {
+		ticker := time.NewTicker(hook.flushInterval)
+		defer ticker.Stop()
+		flushes = ticker.C
+	}
+	b := &batch{}
+	for {
+		select {
+		case entry := <-hook.buffer:
+			payload, err := hook.sink.Encode(entry)
+			if err != nil {
+				hook.errLogger.WithError(err).Errorf("Failed to write log message due to bad format: %v", err)
+				reportDropped(entry.Level, BadFormat)
+				continue
+			}
+			if hook.maxBatchBytes > 0 && b.bytes+len(payload) > hook.maxBatchBytes {
+				hook.flush(b)
+			}
+			b.add(entry.Level, payload)
+			if len(b.levels) >= hook.batchSize {
+				hook.flush(b)
+			}
+		case <-flushes:
+			hook.flush(b)
+		}
+	}
+}
+
+// flush writes all entries of the batch at once and resets it.
+func (hook *Hook) flush(b *batch) {
+	if len(b.levels) == 0 {
+		return
+	}
+	defer b.reset()
+	err := hook.sink.Write(b.payloads)
+	if err != nil {
+		for _, level := range b.levels {
+			reportDropped(level, FailedToWrite)
+		}
+		hook.errLogger.WithError(err).Errorf("Failed to write log message to remote sink due to connection issues: %v", err)
+		return
+	}
+	for _, level := range b.levels {
+		reportRemoteSuccess(level)
+	}
+}
+
+// batch is encoded entries waiting to be written together.
+type batch struct {
+	payloads [][]byte
+	levels   []logrus.Level
+	bytes    int
+}
+
+func (b *batch) add(level logrus.Level, payload []byte) {
+	b.payloads = append(b.payloads, payload)
+	b.levels = append(b.levels, level)
+	b.bytes += len(payload)
+}
+
+func (b *batch) reset() {
+	b.payloads = nil
+	b.levels = b.levels[:0]
+	b.bytes = 0
+}
@@ -0,0 +1,118 @@
+package logsink
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingWriter struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(b))
+	return len(b), nil
+}
+
+func (w *recordingWriter) Writes() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string{}, w.writes...)
+}
+
+type messageFormatter struct{}
+
+func (messageFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return []byte(entry.Message + "\n"), nil
+}
+
+func newTestHook(batchSize int, maxBatchBytes int, flushInterval time.Duration) (*Hook, *recordingWriter) {
+	w := &recordingWriter{}
+	hook := &Hook{
+		sink:          &streamSink{formatter: messageFormatter{}, writer: w},
+		buffer:        make(chan *logrus.Entry, 10),
+		errLogger:     logrus.NewEntry(logrus.New()),
+		batchSize:     batchSize,
+		maxBatchBytes: maxBatchBytes,
+		flushInterval: flushInterval,
+	}
+	go hook.start()
+	return hook, w
+}
+
+func fire(hook *Hook, messages ...string) {
+	for _, m := range messages {
+		hook.Fire(&logrus.Entry{Message: m, Level: logrus.InfoLevel})
+	}
+}
+
+func TestHook_WritesFullBatches(t *testing.T) {
+	hook, w := newTestHook(2, 0, time.Hour)
+	fire(hook, "a", "b", "c", "d")
+	require.True(t, waitFor(func() bool { return len(w.Writes()) == 2 }))
+	assert.Equal(t, []string{"a\nb\n", "c\nd\n"}, w.Writes())
+}
+
+func TestHook_FlushesIncompleteBatchAfterInterval(t *testing.T) {
+	hook, w := newTestHook(100, 0, 50*time.Millisecond)
+	fire(hook, "a", "b")
+	require.True(t, waitFor(func() bool { return len(w.Writes()) == 1 }))
+	assert.Equal(t, []string{"a\nb\n"}, w.Writes())
+}
+
+func TestHook_LimitsBatchBytes(t *testing.T) {
+	hook, w := newTestHook(100, 7, 50*time.Millisecond)
+	fire(hook, "aa", "bb", "cc")
+	require.True(t, waitFor(func() bool { return len(w.Writes()) == 2 }))
+	assert.Equal(t, []string{"aa\nbb\n", "cc\n"}, w.Writes())
+}
+
+func TestHook_WithoutBatching_WritesEachEntry(t *testing.T) {
+	hook, w := newTestHook(1, 0, 0)
+	fire(hook, "a", "b")
+	require.True(t, waitFor(func() bool { return len(w.Writes()) == 2 }))
+	assert.Equal(t, []string{"a\n", "b\n"}, w.Writes())
+}
+
+func TestNewHook_RejectsNonPositiveFlushIntervalWhenBatching(t *testing.T) {
+	defaultBatchSize, defaultFlushInterval := *flagBatchSize, *flagFlushInterval
+	defer func() { *flagBatchSize, *flagFlushInterval = defaultBatchSize, defaultFlushInterval }()
+
+	*flagBatchSize, *flagFlushInterval = 10, 0
+	_, err := NewHook(&streamSink{formatter: messageFormatter{}, writer: &recordingWriter{}}, 0)
+	assert.Error(t, err)
+
+	*flagBatchSize = 1
+	_, err = NewHook(&streamSink{formatter: messageFormatter{}, writer: &recordingWriter{}}, 0)
+	assert.NoError(t, err, "flush interval is not used without batching")
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tlsConfig, err := newTLSConfig("../../misc/ca.crt", "../../misc/client.crt", "../../misc/client.key")
+	require.NoError(t, err)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Len(t, tlsConfig.Certificates, 1)
+
+	_, err = newTLSConfig("", "../../misc/client.crt", "")
+	assert.Error(t, err, "client cert without key")
+	_, err = newTLSConfig("../../misc/client.key", "", "")
+	assert.Error(t, err, "no CA certificates in file")
+}
+
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
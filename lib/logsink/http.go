@@ -0,0 +1,97 @@
+package logsink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// NewHTTPHook creates a hook posting logs as JSON array of entries (in logrus JSON format) to the given HTTP(S) URL.
+// For https, TLS flags of the remote log transport are used.
+func NewHTTPHook(rawURL string) (*Hook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "logsink: failed to parse HTTP sink URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("logsink: HTTP sink URL %q needs http or https scheme", rawURL)
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if u.Scheme == "https" {
+		tlsConfig, err := newTLSConfig(*flagTLSCAPath, *flagTLSCertPath, *flagTLSKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = *flagTLSServerName
+		transport.TLSClientConfig = tlsConfig
+	}
+	return NewHook(&httpSink{
+		url:       u.String(),
+		client:    &http.Client{Transport: transport, Timeout: *flagLogstashWriteTimeout},
+		formatter: &logrus.JSONFormatter{},
+		errLogger: newErrLogger(),
+		backoff: &backoff.Backoff{
+			Factor: 2,
+			Min:    50 * time.Millisecond,
+			Max:    2 * time.Second,
+		},
+	}, 0)
+}
+
+// httpSink posts each batch in a single request. Like ReconnectingWriter, it retries until the batch is accepted,
+// unless the endpoint rejects it as a bad request.
+type httpSink struct {
+	url       string
+	client    *http.Client
+	formatter logrus.Formatter
+	errLogger logrus.FieldLogger
+	backoff   *backoff.Backoff
+}
+
+func (s *httpSink) Encode(entry *logrus.Entry) ([]byte, error) {
+	b, err := s.formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b, "\n"), nil
+}
+
+func (s *httpSink) Write(batch [][]byte) error {
+	body := append(append([]byte("["), bytes.Join(batch, []byte(","))...), ']')
+	defer s.backoff.Reset()
+	for {
+		retry, err := s.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			return err
+		}
+		s.errLogger.WithError(err).Warnf("failed to write")
+		time.Sleep(s.backoff.Duration())
+	}
+}
+
+func (s *httpSink) post(body []byte) (retry bool, err error) {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, errors.Wrap(err, "could not send logs to HTTP sink")
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("HTTP sink responded with %v", resp.Status)
+	// Client errors (except throttling) won't be fixed by retrying the same batch.
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
@@ -1,8 +1,10 @@
-package logstash
+package logsink
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -19,6 +21,10 @@ const (
 
 	// maxMessageBodySize is the max length of the log message before it is trimmed.
 	maxMessageBodySize int = 1 << 14
+
+	// maxDatagramBytes limits the size of batches sent over UDP, so they fit in a single datagram (max UDP payload
+	// over IPv4).
+	maxDatagramBytes = 65507
 )
 
 var (
@@ -27,7 +33,39 @@ var (
 		"@tags field to inject to while formatting log message for logstash.")
 )
 
-func NewFormatter() (*logstashFormatter, error) {
+// NewLogstashHook creates a hook sending logs in logstash JSON format to hostPort, with transport specified in flags.
+func NewLogstashHook(hostPort string) (*Hook, error) {
+	formatter, err := NewLogstashFormatter()
+	if err != nil {
+		return nil, err
+	}
+	dial, err := dialFromFlags(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	maxBatchBytes := 0
+	if *flagTransport == TransportUDP {
+		maxBatchBytes = maxDatagramBytes
+	}
+	return NewHook(&streamSink{formatter: formatter, writer: NewReconnectingWriter(dial, newErrLogger())}, maxBatchBytes)
+}
+
+// streamSink writes all formatted entries of a batch in a single write, e.g. to ReconnectingWriter.
+type streamSink struct {
+	formatter logrus.Formatter
+	writer    io.Writer
+}
+
+func (s *streamSink) Encode(entry *logrus.Entry) ([]byte, error) {
+	return s.formatter.Format(entry)
+}
+
+func (s *streamSink) Write(batch [][]byte) error {
+	_, err := s.writer.Write(bytes.Join(batch, nil))
+	return err
+}
+
+func NewLogstashFormatter() (*logstashFormatter, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
 		return nil, err
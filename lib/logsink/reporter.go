@@ -1,4 +1,4 @@
-package logstash
+package logsink
 
 import (
 	"github.com/sirupsen/logrus"
@@ -0,0 +1,106 @@
+package logsink
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+var testEntry = &logrus.Entry{
+	Time:    time.Date(2017, 10, 11, 22, 14, 15, 3000, time.UTC),
+	Level:   logrus.WarnLevel,
+	Message: "something happened",
+	Data:    logrus.Fields{"backend": "backend_a", "error": errors.New(`bad "quote"]`)},
+}
+
+func TestSyslogSink_EncodesRFC5424(t *testing.T) {
+	sink := &syslogSink{hostname: "host1", appName: "kedge", procID: "42", facility: 16}
+	msg, err := sink.Encode(testEntry)
+	require.NoError(t, err)
+	assert.Equal(t, `<132>1 2017-10-11T22:14:15.000003Z host1 kedge 42 - [fields@32473 backend="backend_a" error="bad \"quote\"\]"] something happened`, string(msg))
+}
+
+func TestSyslogSink_Framing(t *testing.T) {
+	w := &recordingWriter{}
+	require.NoError(t, (&syslogSink{writer: w, octetCounting: true}).Write([][]byte{[]byte("abc"), []byte("de")}))
+	assert.Equal(t, []string{"3 abc2 de"}, w.Writes())
+
+	w = &recordingWriter{}
+	require.NoError(t, (&syslogSink{writer: w}).Write([][]byte{[]byte("abc"), []byte("de")}))
+	assert.Equal(t, []string{"abc", "de"}, w.Writes(), "each message in its own datagram")
+}
+
+func TestFluentdSink_WritesForwardMode(t *testing.T) {
+	w := &recordingWriter{}
+	sink := newFluentdSink(w, "kedge.logs")
+	entry, err := sink.Encode(testEntry)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write([][]byte{entry, entry}))
+	require.Len(t, w.Writes(), 1)
+
+	handle := &codec.MsgpackHandle{}
+	handle.RawToString = true
+	var msg []interface{}
+	require.NoError(t, codec.NewDecoderBytes([]byte(w.Writes()[0]), handle).Decode(&msg))
+	require.Len(t, msg, 2)
+	assert.Equal(t, "kedge.logs", msg[0])
+	entries := msg[1].([]interface{})
+	require.Len(t, entries, 2)
+	event := entries[0].([]interface{})
+	assert.EqualValues(t, testEntry.Time.Unix(), event[0])
+	record := event[1].(map[interface{}]interface{})
+	assert.Equal(t, "something happened", record["message"])
+	assert.Equal(t, "warning", record["level"])
+	assert.Equal(t, `bad "quote"]`, record["error"])
+}
+
+func TestHTTPSink_PostsJSONArrayAndRetries(t *testing.T) {
+	var bodies []string
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if failures > 0 {
+			failures--
+			resp.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	sink := &httpSink{
+		url:       server.URL,
+		client:    http.DefaultClient,
+		formatter: &logrus.JSONFormatter{},
+		errLogger: logrus.New(),
+		backoff:   &backoff.Backoff{Min: time.Millisecond, Max: time.Millisecond},
+	}
+	entry, err := sink.Encode(testEntry)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write([][]byte{entry, entry}))
+
+	require.Len(t, bodies, 1)
+	var entries []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "something happened", entries[0]["msg"])
+	assert.Equal(t, "backend_a", entries[1]["backend"])
+}
+
+func TestHTTPSink_DoesNotRetryBadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+	sink := &httpSink{url: server.URL, client: http.DefaultClient, errLogger: logrus.New(), backoff: &backoff.Backoff{}}
+	assert.Error(t, sink.Write([][]byte{[]byte("{}")}))
+}
@@ -0,0 +1,165 @@
+package logsink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	syslogVersion = 1
+	// syslogTimestampFormat is RFC 3339 with microseconds, the max precision allowed by RFC 5424.
+	syslogTimestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+	// syslogFieldsSDID is the structured data ID for entry fields. 32473 is the private enterprise number reserved for
+	// documentation (RFC 5612).
+	syslogFieldsSDID    = "fields@32473"
+	maxSyslogParamName  = 32
+	defaultSyslogHeader = "-"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7, "uucp": 8, "cron": 9,
+	"authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// NewSyslogHook creates a hook sending logs as RFC 5424 syslog messages to hostPort, with transport specified in flags.
+// Over tcp and tls, messages are framed with octet counting (RFC 5425, RFC 6587), over udp each message is sent in
+// its own datagram (RFC 5426).
+func NewSyslogHook(hostPort string, appName string, facility string) (*Hook, error) {
+	facilityCode, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, errors.Errorf("logsink: unknown syslog facility %q", facility)
+	}
+	dial, err := dialFromFlags(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return NewHook(&syslogSink{
+		writer:        NewReconnectingWriter(dial, newErrLogger()),
+		hostname:      hostname,
+		appName:       appName,
+		procID:        strconv.Itoa(os.Getpid()),
+		facility:      facilityCode,
+		octetCounting: *flagTransport != TransportUDP,
+	}, 0)
+}
+
+// syslogSink formats entries as RFC 5424 messages, with entry fields put in structured data.
+type syslogSink struct {
+	writer        io.Writer
+	hostname      string
+	appName       string
+	procID        string
+	facility      int
+	octetCounting bool
+}
+
+func (s *syslogSink) Encode(entry *logrus.Entry) ([]byte, error) {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "<%d>%d %s %s %s %s %s ",
+		s.facility*8+syslogSeverity(entry.Level),
+		syslogVersion,
+		entry.Time.Format(syslogTimestampFormat),
+		syslogHeaderValue(s.hostname, 255),
+		syslogHeaderValue(s.appName, 48),
+		syslogHeaderValue(s.procID, 128),
+		defaultSyslogHeader, // MSGID
+	)
+	if len(entry.Data) == 0 {
+		b.WriteString("-")
+	} else {
+		keys := make([]string, 0, len(entry.Data))
+		for k := range entry.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("[" + syslogFieldsSDID)
+		for _, k := range keys {
+			fmt.Fprintf(b, " %s=\"%s\"", syslogParamName(k), syslogParamValue(fmt.Sprintf("%v", entry.Data[k])))
+		}
+		b.WriteString("]")
+	}
+	if entry.Message != "" {
+		b.WriteString(" " + entry.Message)
+	}
+	return b.Bytes(), nil
+}
+
+func (s *syslogSink) Write(batch [][]byte) error {
+	if !s.octetCounting {
+		for _, msg := range batch {
+			if _, err := s.writer.Write(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	b := &bytes.Buffer{}
+	for _, msg := range batch {
+		fmt.Fprintf(b, "%d ", len(msg))
+		b.Write(msg)
+	}
+	_, err := s.writer.Write(b.Bytes())
+	return err
+}
+
+// syslogSeverity maps levels the same way as logrus syslog hook.
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2 // crit
+	case logrus.ErrorLevel:
+		return 3 // err
+	case logrus.WarnLevel:
+		return 4 // warning
+	case logrus.InfoLevel:
+		return 6 // info
+	}
+	return 7 // debug
+}
+
+// syslogHeaderValue returns value limited to printable US-ASCII without spaces, as required for header fields.
+func syslogHeaderValue(value string, maxLen int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return defaultSyslogHeader
+	}
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	return value
+}
+
+func syslogParamName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > maxSyslogParamName {
+		name = name[:maxSyslogParamName]
+	}
+	return name
+}
+
+func syslogParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
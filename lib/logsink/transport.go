@@ -0,0 +1,94 @@
+package logsink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+)
+
+const (
+	TransportTCP = "tcp"
+	TransportTLS = "tls"
+	TransportUDP = "udp"
+
+	dialTimeout = 2 * time.Second
+)
+
+var (
+	flagTransport = sharedflags.Set.String(
+		"remote_log_transport", TransportTCP,
+		"Transport used to send logs to logstash, syslog or fluentd remote log sink: tcp, tls or udp (not supported by "+
+			"fluentd). For logstash over udp, each batch is sent in datagrams of at most 64KB, so logstash udp input "+
+			"buffer_size should be set accordingly.")
+	flagTLSCAPath = sharedflags.Set.String(
+		"remote_log_tls_ca_path", "",
+		"Path to the PEM CA certificates used to verify the remote log sink certificate for tls transport. If empty, system CAs are used.")
+	flagTLSCertPath = sharedflags.Set.String(
+		"remote_log_tls_client_cert_path", "",
+		"Path to the PEM client certificate presented to the remote log sink for tls transport. Requires remote_log_tls_client_key_path.")
+	flagTLSKeyPath = sharedflags.Set.String(
+		"remote_log_tls_client_key_path", "",
+		"Path to the PEM key of the client certificate presented to the remote log sink for tls transport.")
+	flagTLSServerName = sharedflags.Set.String(
+		"remote_log_tls_server_name", "",
+		"Server name used to verify the remote log sink certificate for tls transport. If empty, host from remote_log_address is used.")
+)
+
+// dialFromFlags returns a function dialing hostPort with the transport specified in flags.
+func dialFromFlags(hostPort string) (dialFunc, error) {
+	switch *flagTransport {
+	case TransportTCP, TransportUDP:
+		network := *flagTransport
+		return func() (net.Conn, error) {
+			conn, err := net.DialTimeout(network, hostPort, dialTimeout)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to establish remote log connection.")
+			}
+			return conn, nil
+		}, nil
+	case TransportTLS:
+		tlsConfig, err := newTLSConfig(*flagTLSCAPath, *flagTLSCertPath, *flagTLSKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = *flagTLSServerName
+		return func() (net.Conn, error) {
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", hostPort, tlsConfig)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to establish remote log TLS connection.")
+			}
+			return conn, nil
+		}, nil
+	}
+	return nil, errors.Errorf("logsink: unknown transport %q", *flagTransport)
+}
+
+func newTLSConfig(caPath string, certPath string, keyPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caPath != "" {
+		data, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "logsink: failed reading CA file %v", caPath)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if ok := tlsConfig.RootCAs.AppendCertsFromPEM(data); !ok {
+			return nil, errors.Errorf("logsink: failed processing CA file %v", caPath)
+		}
+	}
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return nil, errors.New("logsink: both client cert and key paths need to be specified")
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "logsink: failed loading client cert")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,16 @@
+package requestid
+
+import (
+	"github.com/mwitkow/kedge/lib/sharedflags"
+)
+
+var (
+	fTrustedCIDRs = sharedflags.Set.StringSlice("request_id_trusted_cidrs", []string{},
+		"Networks (comma separated CIDRs) of callers (e.g. winch or in-cluster services) whose X-Request-Id is accepted. "+
+			"Calls from other callers get a newly generated request ID. Use 0.0.0.0/0,::/0 to trust all callers.")
+)
+
+// TrustFromFlags returns TrustFunc configured from sharedflags.Set.
+func TrustFromFlags() (TrustFunc, error) {
+	return TrustNetworks(*fTrustedCIDRs)
+}
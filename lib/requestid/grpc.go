@@ -0,0 +1,47 @@
+package requestid
+
+import (
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const grpcTagForRequestID = "grpc.request_id"
+
+// UnaryServerInterceptor is the gRPC counterpart of Middleware. The request ID is put into the incoming metadata
+// (so it is forwarded to backends by the proxy), echoed in the response header and added to the grpc_ctxtags.
+//
+// It needs to be placed after grpc_ctxtags interceptor.
+func UnaryServerInterceptor(trusted TrustFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		newCtx, id := withRequestID(ctx, trusted)
+		grpc.SetHeader(newCtx, metadata.Pairs(MetadataKey, id))
+		return handler(newCtx, req)
+	}
+}
+
+// StreamServerInterceptor is the gRPC counterpart of Middleware for streams. See UnaryServerInterceptor.
+func StreamServerInterceptor(trusted TrustFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		newCtx, id := withRequestID(stream.Context(), trusted)
+		stream.SetHeader(metadata.Pairs(MetadataKey, id))
+		wrapped := grpc_middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = newCtx
+		return handler(srv, wrapped)
+	}
+}
+
+func withRequestID(ctx context.Context, trusted TrustFunc) (context.Context, string) {
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	md := metautils.ExtractIncoming(ctx)
+	id := resolve(md.Get(MetadataKey), remoteAddr, trusted)
+	grpc_ctxtags.Extract(ctx).Set(grpcTagForRequestID, id)
+	return md.Clone().Set(MetadataKey, id).ToIncoming(ctx), id
+}
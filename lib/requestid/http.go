@@ -0,0 +1,31 @@
+package requestid
+
+import (
+	"net/http"
+
+	"github.com/mwitkow/go-httpwares"
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+)
+
+// Middleware makes sure every request has a request ID: it accepts the one sent by trusted callers or generates a new
+// one. The request ID is set as the request header (so it is forwarded to backends), echoed in the response header
+// and added to the request ctxtags (so it is logged).
+//
+// It needs to be placed after http_ctxtags.Middleware.
+func Middleware(trusted TrustFunc) httpwares.Middleware {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			id := resolve(req.Header.Get(HeaderName), req.RemoteAddr, trusted)
+			req.Header.Set(HeaderName, id)
+			resp.Header().Set(HeaderName, id)
+			http_ctxtags.ExtractInbound(req).Set(ctxtags.TagForRequestID, id)
+			nextHandler.ServeHTTP(resp, req)
+		})
+	}
+}
+
+// FromRequest returns the request ID of the request, or empty string if it has none.
+func FromRequest(req *http.Request) string {
+	return req.Header.Get(HeaderName)
+}
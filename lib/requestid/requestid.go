@@ -0,0 +1,96 @@
+// Package requestid generates and propagates request IDs that correlate a single call across winch, kedge and
+// backends logs.
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// HeaderName is the HTTP header carrying the request ID.
+	HeaderName = "X-Request-Id"
+	// MetadataKey is the gRPC metadata key carrying the request ID.
+	MetadataKey = "x-request-id"
+
+	maxLength = 128
+)
+
+// TrustFunc returns true if the request ID sent by the caller with the given remote address ("host:port") can be
+// accepted. Otherwise, a new request ID is generated.
+type TrustFunc func(remoteAddr string) bool
+
+// TrustAll accepts request IDs from all callers.
+func TrustAll(string) bool {
+	return true
+}
+
+// TrustNone generates new request IDs for all calls.
+func TrustNone(string) bool {
+	return false
+}
+
+// TrustNetworks accepts request IDs only from callers within the given CIDRs.
+func TrustNetworks(cidrs []string) (TrustFunc, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "requestid: failed to parse trusted network %v", cidr)
+		}
+		networks = append(networks, network)
+	}
+	if len(networks) == 0 {
+		return TrustNone, nil
+	}
+	return func(remoteAddr string) bool {
+		host, _, err := net.SplitHostPort(remoteAddr)
+		if err != nil {
+			host = remoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// New generates a new random request ID.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Not really possible on supported platforms, but request ID is not worth failing the request for.
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// resolve returns the request ID sent by the caller if it can be accepted, or a new one.
+func resolve(sent string, remoteAddr string, trusted TrustFunc) string {
+	if sent != "" && isValid(sent) && trusted(remoteAddr) {
+		return sent
+	}
+	return New()
+}
+
+// isValid makes sure that request IDs put into logs and headers are sane.
+func isValid(id string) bool {
+	if len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
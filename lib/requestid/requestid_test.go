@@ -0,0 +1,82 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTrustNetworks(t *testing.T) {
+	trusted, err := TrustNetworks([]string{"10.0.0.0/8", "::1/128"})
+	require.NoError(t, err)
+	assert.True(t, trusted("10.1.2.3:4567"))
+	assert.True(t, trusted("[::1]:4567"))
+	assert.False(t, trusted("8.8.8.8:4567"))
+	assert.False(t, trusted("not-an-ip"))
+
+	trusted, err = TrustNetworks(nil)
+	require.NoError(t, err)
+	assert.False(t, trusted("10.1.2.3:4567"), "no networks should trust nobody")
+
+	_, err = TrustNetworks([]string{"10.0.0.0"})
+	require.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	assert.Equal(t, "abc-123", resolve("abc-123", "10.0.0.1:80", TrustAll))
+	assert.NotEqual(t, "abc-123", resolve("abc-123", "10.0.0.1:80", TrustNone), "untrusted caller should get new ID")
+	assert.NotEqual(t, "abc 123", resolve("abc 123", "10.0.0.1:80", TrustAll), "invalid ID should be replaced")
+	assert.NotEqual(t, strings.Repeat("a", 129), resolve(strings.Repeat("a", 129), "10.0.0.1:80", TrustAll))
+	assert.Len(t, resolve("", "10.0.0.1:80", TrustAll), 32)
+}
+
+func TestMiddleware(t *testing.T) {
+	var backendID, taggedID string
+	handler := http_ctxtags.Middleware("test")(Middleware(TrustAll)(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		backendID = req.Header.Get(HeaderName)
+		taggedID, _ = http_ctxtags.ExtractInbound(req).Values()[ctxtags.TagForRequestID].(string)
+	})))
+
+	req := httptest.NewRequest("GET", "http://backend.example.com/", nil)
+	req.Header.Set(HeaderName, "from-winch")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, "from-winch", backendID)
+	assert.Equal(t, "from-winch", taggedID)
+	assert.Equal(t, "from-winch", rec.Header().Get(HeaderName))
+
+	req = httptest.NewRequest("GET", "http://backend.example.com/", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.NotEmpty(t, backendID, "request ID should be generated if missing")
+	assert.Equal(t, backendID, rec.Header().Get(HeaderName))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "from-client", "other", "value"))
+	interceptor := grpc_middleware.ChainUnaryServer(grpc_ctxtags.UnaryServerInterceptor(), UnaryServerInterceptor(TrustNone))
+	var forwardedMd metautils.NiceMD
+	var taggedID interface{}
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		forwardedMd = metautils.ExtractIncoming(ctx)
+		taggedID = grpc_ctxtags.Extract(ctx).Values()[grpcTagForRequestID]
+		return nil, nil
+	})
+	require.NoError(t, err)
+	id := forwardedMd.Get(MetadataKey)
+	assert.NotEqual(t, "from-client", id, "untrusted callers should get new request ID")
+	assert.Equal(t, "value", forwardedMd.Get("other"), "other metadata should be preserved")
+	assert.Equal(t, id, taggedID)
+}
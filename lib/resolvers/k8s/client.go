@@ -2,38 +2,70 @@ package k8sresolver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/pkg/errors"
 )
 
+// errGone is returned when the resourceVersion to watch from is too old. Objects need to be listed again.
+var errGone = errors.New("resourceVersion is too old (410 Gone)")
+
 type endpointClient interface {
-	StartChangeStream(ctx context.Context, t targetEntry, resourceVersion int) (io.ReadCloser, error)
+	List(ctx context.Context, t targetEntry, useEndpointSlices bool) (*endpointsList, error)
+	StartChangeStream(ctx context.Context, t targetEntry, useEndpointSlices bool, resourceVersion string) (io.ReadCloser, error)
 }
 
 type client struct {
 	k8sURL    string
 	k8sClient *http.Client
+	// cluster is a name of the cluster kube-apiserver belongs to, empty for the default one.
+	cluster string
+}
+
+// List returns Endpoints (at most one) or EndpointSlices of the target service, with resourceVersion of the list.
+// See https://kubernetes.io/docs/reference/using-api/api-concepts/#efficient-detection-of-changes
+func (c *client) List(ctx context.Context, t targetEntry, useEndpointSlices bool) (*endpointsList, error) {
+	body, err := c.startGET(ctx, c.url(t, useEndpointSlices, false, ""))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	list := &endpointsList{}
+	if err := json.NewDecoder(body).Decode(list); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode list response")
+	}
+	return list, nil
 }
 
 // StartChangeStream starts stream of changes from watch endpoint.
 // See https://kubernetes.io/docs/api-reference/v1.7/#watch-132
-// NOTE: In the beginning of stream, k8s will give us sufficient info about current state. (No need to GET first)
-// `resourceVersion` is just a revision.
-func (c *client) StartChangeStream(ctx context.Context, t targetEntry, resourceVersion int) (io.ReadCloser, error) {
-	epWatchURL := fmt.Sprintf("%s/api/v1/watch/namespaces/%s/endpoints/%s",
-		c.k8sURL,
-		t.namespace,
-		t.service,
-	)
+// NOTE: Stream starts after `resourceVersion`, usually the one of the List. If it is too old, errGone is returned.
+func (c *client) StartChangeStream(ctx context.Context, t targetEntry, useEndpointSlices bool, resourceVersion string) (io.ReadCloser, error) {
+	return c.startGET(ctx, c.url(t, useEndpointSlices, true, resourceVersion))
+}
 
-	if resourceVersion != 0 {
-		epWatchURL = fmt.Sprintf("%s?resourceVersion=%d", epWatchURL, resourceVersion)
+func (c *client) url(t targetEntry, useEndpointSlices bool, watch bool, resourceVersion string) string {
+	epURL := fmt.Sprintf("%s/api/v1/namespaces/%s/endpoints", c.k8sURL, t.namespace)
+	query := url.Values{}
+	query.Set("fieldSelector", "metadata.name="+t.service)
+	if useEndpointSlices {
+		// Service can have many slices, all labeled with its name.
+		epURL = fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices", c.k8sURL, t.namespace)
+		query = url.Values{}
+		query.Set("labelSelector", "kubernetes.io/service-name="+t.service)
 	}
-
-	return c.startGET(ctx, epWatchURL)
+	if watch {
+		query.Set("watch", "true")
+	}
+	if resourceVersion != "" {
+		query.Set("resourceVersion", resourceVersion)
+	}
+	return fmt.Sprintf("%s?%s", epURL, query.Encode())
 }
 
 // NOTE: It is caller responsibility to read body through and close it.
@@ -48,6 +80,10 @@ func (c *client) startGET(ctx context.Context, url string) (io.ReadCloser, error
 		return nil, errors.Wrapf(err, "Failed to do GET %s request", url)
 	}
 
+	if resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, errGone
+	}
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
 		return nil, errors.Errorf("Invalid response code %d on GET %s request", resp.StatusCode, url)
@@ -6,16 +6,21 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"sync"
 
+	k8scache "github.com/Bplotka/oidc/login/k8scache"
 	"github.com/mwitkow/kedge/lib/sharedflags"
 	"github.com/mwitkow/kedge/lib/tokenauth"
-	"github.com/mwitkow/kedge/lib/tokenauth/sources/direct"
+	"github.com/mwitkow/kedge/lib/tokenauth/sources/file"
 	"github.com/mwitkow/kedge/lib/tokenauth/sources/k8s"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/naming"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 var (
@@ -38,21 +43,170 @@ var (
 		"If user is specified resolver will try to fetch api auth method directly from kubeconfig. "+
 			"This auth method has priority 1.")
 	fKubeConfigAuthPath = sharedflags.Set.String("k8sresolver_kubeconfig_path", "", "Kube config path. "+
-		"Only used when k8sresolver_kubeconfig_user is specified. If empty it will try default path.")
+		"Only used when k8sresolver_kubeconfig_user or k8sresolver_clusters is specified. If empty it will try default path.")
+
+	// Other clusters are configured using kube config contexts, each with its own kube-apiserver URL, CA and user.
+	fClusters = sharedflags.Set.StringSlice("k8sresolver_clusters", []string{},
+		"Kube config contexts (comma separated) of other clusters the resolver can resolve services in, when specified "+
+			"as cluster of k8s resolver. Each context specifies kube-apiserver URL and CA of its cluster and user to "+
+			"authenticate as (client certificate, token, token file or auth provider). Kube config is read from k8sresolver_kubeconfig_path.")
+
+	flagsClientsMu sync.Mutex
+	// flagsClients are clients by cluster name, empty for the default cluster.
+	flagsClients = map[string]*client{}
 )
 
 // NewFromFlags creates resolver from flag from k8sresolver.sharedflags.Set.
-func NewFromFlags(logger logrus.FieldLogger) (naming.Resolver, error) {
-	k8sURL := *fKubeAPIURL
+// All resolvers created from flags use the same client, so they share watch streams of the same services.
+func NewFromFlags(logger logrus.FieldLogger, opts Options) (naming.Resolver, error) {
+	return NewForClusterFromFlags(logger, "", opts)
+}
+
+// NewForClusterFromFlags creates resolver of services in the given cluster, one of k8sresolver_clusters. Empty cluster
+// means the default one, see NewFromFlags. All resolvers of the same cluster use the same client.
+func NewForClusterFromFlags(logger logrus.FieldLogger, cluster string, opts Options) (naming.Resolver, error) {
+	flagsClientsMu.Lock()
+	defer flagsClientsMu.Unlock()
+	cl, ok := flagsClients[cluster]
+	if !ok {
+		k8sURL, k8sClient, err := NewClusterClientFromFlags(cluster)
+		if err != nil {
+			return nil, err
+		}
+		cl = &client{k8sURL: k8sURL, k8sClient: k8sClient, cluster: cluster}
+		flagsClients[cluster] = cl
+	}
+	return newResolver(logger, cl, opts), nil
+}
+
+// NewClusterClientFromFlags returns kube-apiserver URL and HTTP client of the given cluster, one of
+// k8sresolver_clusters. Empty cluster means the default one, see NewClientFromFlags.
+func NewClusterClientFromFlags(cluster string) (k8sURL string, k8sClient *http.Client, err error) {
+	if cluster == "" {
+		return NewClientFromFlags()
+	}
+	found := false
+	for _, c := range *fClusters {
+		if c == cluster {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", nil, errors.Errorf("k8sresolver: cluster %s is not one of k8sresolver_clusters %v", cluster, *fClusters)
+	}
+
+	configPath := *fKubeConfigAuthPath
+	if configPath == "" {
+		configPath = k8scache.DefaultKubeConfigPath
+	}
+	kubeConfig, err := clientcmd.LoadFromFile(configPath)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "k8sresolver: failed to load kube config from file %v", configPath)
+	}
+	k8sURL, tlsConfig, user, err := clusterFromKubeConfig(kubeConfig, cluster)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(tlsConfig.Certificates) > 0 && !hasTokenAuth(kubeConfig.AuthInfos[user]) {
+		// User authenticates with client certificate only.
+		return k8sURL, &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, nil
+	}
+	source, err := k8sauth.New("kube_api_"+cluster, configPath, user)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "k8sresolver: failed to create k8sauth Source for cluster %s", cluster)
+	}
+	return k8sURL, newAuthClient(source, tlsConfig), nil
+}
+
+// clusterFromKubeConfig returns kube-apiserver URL, TLS config and user name of the kube config context. Client
+// certificate of the user, if any, is part of the TLS config.
+func clusterFromKubeConfig(kubeConfig *clientcmdapi.Config, contextName string) (k8sURL string, tlsConfig *tls.Config, user string, err error) {
+	kubeContext, ok := kubeConfig.Contexts[contextName]
+	if !ok {
+		return "", nil, "", errors.Errorf("k8sresolver: failed to find context %s inside kube config", contextName)
+	}
+	cluster, ok := kubeConfig.Clusters[kubeContext.Cluster]
+	if !ok {
+		return "", nil, "", errors.Errorf("k8sresolver: failed to find cluster %s of context %s inside kube config", kubeContext.Cluster, contextName)
+	}
+	if _, err := url.Parse(cluster.Server); err != nil || cluster.Server == "" {
+		return "", nil, "", errors.Errorf("k8sresolver: cluster %s of context %s needs to have valid server URL. Value %s", kubeContext.Cluster, contextName, cluster.Server)
+	}
+
+	tlsConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS10,
+		InsecureSkipVerify: cluster.InsecureSkipTLSVerify,
+	}
+	ca := cluster.CertificateAuthorityData
+	if len(ca) == 0 && cluster.CertificateAuthority != "" {
+		ca, err = ioutil.ReadFile(cluster.CertificateAuthority)
+		if err != nil {
+			return "", nil, "", errors.Wrapf(err, "k8sresolver: failed to read CA file of cluster %s", kubeContext.Cluster)
+		}
+	}
+	if len(ca) > 0 && !cluster.InsecureSkipTLSVerify {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return "", nil, "", errors.Errorf("k8sresolver: failed to parse CA of cluster %s", kubeContext.Cluster)
+		}
+	}
+	if authInfo, ok := kubeConfig.AuthInfos[kubeContext.AuthInfo]; ok {
+		cert, err := clientCertificate(authInfo)
+		if err != nil {
+			return "", nil, "", errors.Wrapf(err, "k8sresolver: failed to load client certificate of user %s", kubeContext.AuthInfo)
+		}
+		if cert != nil {
+			tlsConfig.Certificates = []tls.Certificate{*cert}
+		}
+	}
+	return cluster.Server, tlsConfig, kubeContext.AuthInfo, nil
+}
+
+// clientCertificate returns client certificate of the kube config user or nil if it has none.
+func clientCertificate(authInfo *clientcmdapi.AuthInfo) (*tls.Certificate, error) {
+	certPEM, keyPEM := authInfo.ClientCertificateData, authInfo.ClientKeyData
+	var err error
+	if len(certPEM) == 0 && authInfo.ClientCertificate != "" {
+		certPEM, err = ioutil.ReadFile(authInfo.ClientCertificate)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read client certificate file")
+		}
+	}
+	if len(keyPEM) == 0 && authInfo.ClientKey != "" {
+		keyPEM, err = ioutil.ReadFile(authInfo.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read client key file")
+		}
+	}
+	if len(certPEM) == 0 && len(keyPEM) == 0 {
+		return nil, nil
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse client certificate and key")
+	}
+	return &cert, nil
+}
+
+// hasTokenAuth tells whether the kube config user authenticates with a token, token file or auth provider.
+func hasTokenAuth(authInfo *clientcmdapi.AuthInfo) bool {
+	return authInfo != nil && (authInfo.Token != "" || authInfo.TokenFile != "" || authInfo.AuthProvider != nil)
+}
+
+// NewClientFromFlags returns kube-apiserver URL and HTTP client with TLS and auth configured from
+// k8sresolver.sharedflags.Set. It can be used by other components talking to kube-apiserver.
+func NewClientFromFlags() (k8sURL string, k8sClient *http.Client, err error) {
+	k8sURL = *fKubeAPIURL
 	if k8sURL == "" || k8sURL == "https://:" {
-		return nil, errors.Errorf(
+		return "", nil, errors.Errorf(
 			"k8sresolver: k8sresolver_kubeapi_url flag needs to be specified or " +
 				"KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
 	}
 
-	_, err := url.Parse(k8sURL)
+	_, err = url.Parse(k8sURL)
 	if err != nil {
-		return nil, errors.Wrapf(err, "k8sresolver: k8sresolver_kubeapi_url flag needs to be valid URL. Value %s ", k8sURL)
+		return "", nil, errors.Wrapf(err, "k8sresolver: k8sresolver_kubeapi_url flag needs to be valid URL. Value %s ", k8sURL)
 	}
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: *fInsecureSkipVerify,
@@ -60,7 +214,7 @@ func NewFromFlags(logger logrus.FieldLogger) (naming.Resolver, error) {
 	if !*fInsecureSkipVerify {
 		ca, err := ioutil.ReadFile(*fKubeAPIRootCAPath)
 		if err != nil {
-			return nil, errors.Wrapf(err, "k8sresolver: failed to parse RootCA from file %s", *fKubeAPIRootCAPath)
+			return "", nil, errors.Wrapf(err, "k8sresolver: failed to parse RootCA from file %s", *fKubeAPIRootCAPath)
 		}
 		certPool := x509.NewCertPool()
 		certPool.AppendCertsFromPEM(ca)
@@ -76,18 +230,17 @@ func NewFromFlags(logger logrus.FieldLogger) (naming.Resolver, error) {
 	if user := *fKubeConfigAuthUser; user != "" {
 		source, err = k8sauth.New("kube_api", *fKubeConfigAuthPath, user)
 		if err != nil {
-			return nil, errors.Wrap(err, "k8sresolver: failed to create k8sauth Source")
+			return "", nil, errors.Wrap(err, "k8sresolver: failed to create k8sauth Source")
 		}
 	}
 
 	if source == nil {
-		// Try token auth as fallback.
-		token, err := ioutil.ReadFile(*fTokenAuthPath)
-		if err != nil {
-			return nil, errors.Wrapf(err, "k8sresolver: failed to parse token from %s. No auth method found", *fTokenAuthPath)
+		// Try token auth as fallback. Token is read on every request, as service account tokens rotate.
+		if _, err := ioutil.ReadFile(*fTokenAuthPath); err != nil {
+			return "", nil, errors.Wrapf(err, "k8sresolver: failed to parse token from %s. No auth method found", *fTokenAuthPath)
 		}
-		source = directauth.New("kube_api", string(token))
+		source = fileauth.New("kube_api", *fTokenAuthPath)
 	}
 
-	return New(logger, k8sURL, source, tlsConfig), nil
+	return k8sURL, newAuthClient(source, tlsConfig), nil
 }
@@ -0,0 +1,114 @@
+package k8sresolver
+
+import (
+	"io/ioutil"
+	"path"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func getTestingCertsPath() string {
+	_, callerPath, _, _ := runtime.Caller(0)
+	return path.Join(path.Dir(callerPath), "..", "..", "..", "misc")
+}
+
+func TestClusterFromKubeConfig(t *testing.T) {
+	ca, err := ioutil.ReadFile(path.Join(getTestingCertsPath(), "ca.crt"))
+	require.NoError(t, err)
+	kubeConfig := &clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"eu1":      {Server: "https://eu1.example.com", CertificateAuthorityData: ca},
+			"us1":      {Server: "https://us1.example.com", CertificateAuthority: path.Join(getTestingCertsPath(), "ca.crt")},
+			"insecure": {Server: "https://insecure.example.com", InsecureSkipTLSVerify: true},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"eu1-prod":      {Cluster: "eu1", AuthInfo: "eu1-sa"},
+			"us1-prod":      {Cluster: "us1", AuthInfo: "us1-user"},
+			"insecure-test": {Cluster: "insecure", AuthInfo: "test"},
+			"missing":       {Cluster: "missing", AuthInfo: "test"},
+		},
+	}
+
+	k8sURL, tlsConfig, user, err := clusterFromKubeConfig(kubeConfig, "eu1-prod")
+	require.NoError(t, err)
+	assert.Equal(t, "https://eu1.example.com", k8sURL)
+	assert.Equal(t, "eu1-sa", user)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+
+	_, tlsConfig, user, err = clusterFromKubeConfig(kubeConfig, "us1-prod")
+	require.NoError(t, err)
+	assert.Equal(t, "us1-user", user)
+	assert.NotNil(t, tlsConfig.RootCAs, "CA should be read from file")
+
+	_, tlsConfig, _, err = clusterFromKubeConfig(kubeConfig, "insecure-test")
+	require.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	_, _, _, err = clusterFromKubeConfig(kubeConfig, "missing")
+	assert.Error(t, err)
+	_, _, _, err = clusterFromKubeConfig(kubeConfig, "other")
+	assert.Error(t, err)
+}
+
+func TestNewForClusterFromFlags_UnknownCluster(t *testing.T) {
+	_, err := NewForClusterFromFlags(nil, "eu1-prod", Options{})
+	assert.Error(t, err, "cluster needs to be one of k8sresolver_clusters")
+}
+
+func TestResolver_ClusterSeparatesInformers(t *testing.T) {
+	epClient := newEndpointClientMock()
+	local := startNewWatcher(logrus.New(), targetEntry{service: "service1", namespace: "ns1"}, epClient, Options{})
+	defer local.Close()
+	other := startNewWatcher(logrus.New(), targetEntry{cluster: "eu1-prod", service: "service1", namespace: "ns1"}, epClient, Options{})
+	defer other.Close()
+	assert.NotEqual(t, local.informer, other.informer, "the same service in different clusters should not share informer")
+}
+
+func TestClusterFromKubeConfig_ClientCertificate(t *testing.T) {
+	crt, err := ioutil.ReadFile(path.Join(getTestingCertsPath(), "client.crt"))
+	require.NoError(t, err)
+	kubeConfig := &clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"eu1": {Server: "https://eu1.example.com", InsecureSkipTLSVerify: true},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"eu1-data":    {Cluster: "eu1", AuthInfo: "data"},
+			"eu1-files":   {Cluster: "eu1", AuthInfo: "files"},
+			"eu1-token":   {Cluster: "eu1", AuthInfo: "token"},
+			"eu1-invalid": {Cluster: "eu1", AuthInfo: "invalid"},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			"data": {
+				ClientCertificateData: crt,
+				ClientKey:             path.Join(getTestingCertsPath(), "client.key"),
+			},
+			"files": {
+				ClientCertificate: path.Join(getTestingCertsPath(), "client.crt"),
+				ClientKey:         path.Join(getTestingCertsPath(), "client.key"),
+			},
+			"token":   {Token: "secret"},
+			"invalid": {ClientCertificateData: crt},
+		},
+	}
+
+	for _, context := range []string{"eu1-data", "eu1-files"} {
+		_, tlsConfig, user, err := clusterFromKubeConfig(kubeConfig, context)
+		require.NoError(t, err, context)
+		assert.Len(t, tlsConfig.Certificates, 1, context)
+		assert.False(t, hasTokenAuth(kubeConfig.AuthInfos[user]), "client certificate should be enough to authenticate")
+	}
+
+	_, tlsConfig, user, err := clusterFromKubeConfig(kubeConfig, "eu1-token")
+	require.NoError(t, err)
+	assert.Empty(t, tlsConfig.Certificates)
+	assert.True(t, hasTokenAuth(kubeConfig.AuthInfos[user]))
+
+	_, _, _, err = clusterFromKubeConfig(kubeConfig, "eu1-invalid")
+	assert.Error(t, err, "client certificate without key should be rejected")
+}
@@ -0,0 +1,297 @@
+package k8sresolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	reconnectReasonError  = "error"
+	reconnectReasonGone   = "gone"
+	reconnectReasonClosed = "closed"
+)
+
+type eventType string
+
+const (
+	added    eventType = "ADDED"
+	modified eventType = "MODIFIED"
+	deleted  eventType = "DELETED"
+	failed   eventType = "ERROR"
+)
+
+// event represents a single event to a watched resource.
+type event struct {
+	Type eventType `json:"type"`
+	// Object is endpoints, or status for ERROR event.
+	Object json.RawMessage `json:"object"`
+}
+
+type endpointsList struct {
+	Metadata metadata    `json:"metadata"`
+	Items    []endpoints `json:"items"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+type informerKey struct {
+	epClient          endpointClient
+	cluster           string
+	namespace         string
+	service           string
+	useEndpointSlices bool
+}
+
+// informerRegistry keeps informers of all resolvers, so watchers of the same service share a single watch stream, even
+// if they use different ports.
+type informerRegistry struct {
+	mu        sync.Mutex
+	informers map[informerKey]*informer
+}
+
+var sharedInformers = &informerRegistry{informers: map[informerKey]*informer{}}
+
+// subscribe returns the informer for the target and registers w to be notified on changes. It starts the informer if
+// it is not running yet.
+func (r *informerRegistry) subscribe(logger logrus.FieldLogger, epClient endpointClient, t targetEntry, useEndpointSlices bool, w *watcher) *informer {
+	key := informerKey{epClient: epClient, cluster: t.cluster, namespace: t.namespace, service: t.service, useEndpointSlices: useEndpointSlices}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.informers[key]
+	if !ok {
+		i = startInformer(logger, key, &backoff.Backoff{
+			Min:    watchRetryBackoff.Min,
+			Jitter: watchRetryBackoff.Jitter,
+			Factor: watchRetryBackoff.Factor,
+			Max:    watchRetryBackoff.Max,
+		})
+		r.informers[key] = i
+	}
+	i.subscribe(w)
+	return i
+}
+
+// unsubscribe stops notifying w. Informer without watchers is stopped.
+func (r *informerRegistry) unsubscribe(i *informer, w *watcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i.unsubscribe(w) == 0 {
+		i.cancel()
+		if r.informers[i.key] == i {
+			delete(r.informers, i.key)
+		}
+	}
+}
+
+// informer keeps the Endpoints or EndpointSlices of a single service up to date. It lists them first and then watches
+// for changes from the resourceVersion of the list. When resourceVersion is too old (410 Gone), it lists them again.
+type informer struct {
+	logger       logrus.FieldLogger
+	key          informerKey
+	target       targetEntry
+	retryBackoff *backoff.Backoff
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	synced   bool
+	objects  map[string]endpoints
+	watchers map[*watcher]struct{}
+}
+
+func startInformer(logger logrus.FieldLogger, key informerKey, retryBackoff *backoff.Backoff) *informer {
+	// NOTE(bplotka): Would love to have proper context from above but naming.Resolver does not allow that.
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &informer{
+		logger:       logger.WithField("service", fmt.Sprintf("%s.%s", key.service, key.namespace)),
+		key:          key,
+		target:       targetEntry{cluster: key.cluster, service: key.service, namespace: key.namespace},
+		retryBackoff: retryBackoff,
+		ctx:          ctx,
+		cancel:       cancel,
+		objects:      map[string]endpoints{},
+		watchers:     map[*watcher]struct{}{},
+	}
+	go i.run()
+	return i
+}
+
+func (i *informer) subscribe(w *watcher) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.watchers[w] = struct{}{}
+	watchersGauge.WithLabelValues(i.key.cluster, i.metricTarget()).Inc()
+	if i.synced {
+		notify(w)
+	}
+}
+
+func (i *informer) unsubscribe(w *watcher) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.watchers[w]; ok {
+		delete(i.watchers, w)
+		watchersGauge.WithLabelValues(i.key.cluster, i.metricTarget()).Dec()
+	}
+	return len(i.watchers)
+}
+
+func (i *informer) metricTarget() string {
+	return fmt.Sprintf("%s.%s", i.key.service, i.key.namespace)
+}
+
+// snapshot returns current objects by name. It is empty until the first list is done.
+func (i *informer) snapshot() map[string]endpoints {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	objects := make(map[string]endpoints, len(i.objects))
+	for name, object := range i.objects {
+		objects[name] = object
+	}
+	return objects
+}
+
+// update applies change to objects under lock and notifies all watchers.
+func (i *informer) update(change func(objects map[string]endpoints)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	change(i.objects)
+	i.synced = true
+	for w := range i.watchers {
+		notify(w)
+	}
+}
+
+func notify(w *watcher) {
+	// Notifications are coalesced, watcher reads the whole state anyway.
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// run lists and watches in loop until informer is stopped.
+func (i *informer) run() {
+	resourceVersion := ""
+	for i.ctx.Err() == nil {
+		if resourceVersion == "" {
+			list, err := i.key.epClient.List(i.ctx, i.target, i.key.useEndpointSlices)
+			if err != nil {
+				if i.ctx.Err() != nil {
+					return
+				}
+				i.logger.WithError(err).Error("k8sresolver informer: Failed to list")
+				i.sleep(i.retryBackoff.Duration())
+				continue
+			}
+			i.update(func(objects map[string]endpoints) {
+				for name := range objects {
+					delete(objects, name)
+				}
+				for _, object := range list.Items {
+					objects[object.Metadata.Name] = object
+				}
+			})
+			resourceVersion = list.Metadata.ResourceVersion
+		}
+
+		stream, err := i.key.epClient.StartChangeStream(i.ctx, i.target, i.key.useEndpointSlices, resourceVersion)
+		if err == nil {
+			resourceVersion, err = i.proxyEvents(json.NewDecoder(stream), resourceVersion)
+			stream.Close()
+		}
+		if i.ctx.Err() != nil {
+			return
+		}
+
+		if errors.Cause(err) == errGone {
+			i.logger.WithField("resourceVersion", resourceVersion).Debug("k8sresolver informer: resourceVersion too old. Listing again")
+			streamReconnects.WithLabelValues(i.key.cluster, i.metricTarget(), reconnectReasonGone).Inc()
+			resourceVersion = ""
+			continue
+		}
+		if err == io.EOF {
+			// Server closes watch after a timeout, it is expected. Still, don't reconnect in a tight loop if it closes
+			// the stream right away.
+			streamReconnects.WithLabelValues(i.key.cluster, i.metricTarget(), reconnectReasonClosed).Inc()
+			i.retryBackoff.Reset()
+			i.sleep(closedStreamReconnectDelay)
+			continue
+		}
+		streamReconnects.WithLabelValues(i.key.cluster, i.metricTarget(), reconnectReasonError).Inc()
+		i.logger.WithError(err).Error("k8sresolver informer: Error on watch stream. Retrying")
+		i.sleep(i.retryBackoff.Duration())
+	}
+}
+
+// sleep waits for the given duration, or until informer is stopped.
+func (i *informer) sleep(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-i.ctx.Done():
+	}
+}
+
+// proxyEvents applies events from the stream to objects until the stream ends. It returns resourceVersion of the last
+// event, to continue from.
+func (i *informer) proxyEvents(decoder *json.Decoder, resourceVersion string) (string, error) {
+	for {
+		var got event
+
+		// Blocking read, it is interrupted when context is done.
+		if err := decoder.Decode(&got); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return resourceVersion, errors.Wrap(err, "Unexpected EOF during watch stream event decoding")
+			}
+			if err == io.EOF {
+				return resourceVersion, err
+			}
+			return resourceVersion, errors.Wrap(err, "Unable to decode an event from the watch stream")
+		}
+		i.retryBackoff.Reset()
+
+		switch got.Type {
+		case added, modified, deleted:
+			var object endpoints
+			if err := json.Unmarshal(got.Object, &object); err != nil {
+				return resourceVersion, errors.Wrapf(err, "Unable to decode object of %s event", got.Type)
+			}
+			i.update(func(objects map[string]endpoints) {
+				if got.Type == deleted {
+					delete(objects, object.Metadata.Name)
+					return
+				}
+				objects[object.Metadata.Name] = object
+			})
+			if object.Metadata.ResourceVersion != "" {
+				resourceVersion = object.Metadata.ResourceVersion
+			}
+		case failed:
+			var s status
+			if err := json.Unmarshal(got.Object, &s); err != nil {
+				return resourceVersion, errors.Wrap(err, "Unable to decode status of ERROR event")
+			}
+			if s.Code == http.StatusGone {
+				return resourceVersion, errGone
+			}
+			return resourceVersion, errors.Errorf("Got ERROR event: %d %s: %s", s.Code, s.Reason, s.Message)
+		default:
+			return resourceVersion, errors.Errorf("Got invalid watch event type: %v", got.Type)
+		}
+	}
+}
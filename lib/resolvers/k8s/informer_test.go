@@ -0,0 +1,219 @@
+package k8sresolver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/naming"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+type endpointClientMock struct {
+	lists   chan *endpointsList
+	streams chan io.ReadCloser
+
+	mu               sync.Mutex
+	listCalls        int
+	resourceVersions []string
+}
+
+func newEndpointClientMock() *endpointClientMock {
+	return &endpointClientMock{lists: make(chan *endpointsList, 10), streams: make(chan io.ReadCloser, 10)}
+}
+
+func (m *endpointClientMock) List(ctx context.Context, t targetEntry, useEndpointSlices bool) (*endpointsList, error) {
+	select {
+	case l := <-m.lists:
+		m.mu.Lock()
+		m.listCalls++
+		m.mu.Unlock()
+		return l, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *endpointClientMock) StartChangeStream(ctx context.Context, t targetEntry, useEndpointSlices bool, resourceVersion string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	m.resourceVersions = append(m.resourceVersions, resourceVersion)
+	m.mu.Unlock()
+	select {
+	case s := <-m.streams:
+		return s, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *endpointClientMock) calls() (int, []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listCalls, append([]string{}, m.resourceVersions...)
+}
+
+func endpointsObject(resourceVersion string, ips ...string) string {
+	addresses := ""
+	for i, ip := range ips {
+		if i > 0 {
+			addresses += ","
+		}
+		addresses += fmt.Sprintf(`{"ip":%q}`, ip)
+	}
+	return fmt.Sprintf(`{"metadata":{"name":"service1","resourceVersion":%q},"subsets":[{"addresses":[%s],"ports":[{"name":"http","port":8080},{"name":"grpc","port":9090}]}]}`,
+		resourceVersion, addresses)
+}
+
+func nextWithTimeout(t *testing.T, w *watcher) []*naming.Update {
+	type result struct {
+		updates []*naming.Update
+		err     error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		updates, err := w.Next()
+		resultCh <- result{updates, err}
+	}()
+	select {
+	case r := <-resultCh:
+		require.NoError(t, r.err)
+		sort.Slice(r.updates, func(i, j int) bool { return r.updates[i].Addr < r.updates[j].Addr })
+		return r.updates
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for updates")
+		return nil
+	}
+}
+
+func TestInformer_SharedByWatchersAndRelistedOnGone(t *testing.T) {
+	epClient := newEndpointClientMock()
+	epClient.lists <- &endpointsList{Metadata: metadata{ResourceVersion: "10"}}
+	stream1, stream1Writer := io.Pipe()
+	epClient.streams <- stream1
+
+	httpWatcher := startNewWatcher(logrus.New(), targetEntry{service: "service1", namespace: "ns1", port: targetPort{value: "http", isNamed: true}}, epClient, Options{})
+	grpcWatcher := startNewWatcher(logrus.New(), targetEntry{service: "service1", namespace: "ns1", port: targetPort{value: "grpc", isNamed: true}}, epClient, Options{})
+	require.Equal(t, httpWatcher.informer, grpcWatcher.informer, "watchers of the same service should share informer")
+
+	assert.Empty(t, nextWithTimeout(t, httpWatcher), "service has no endpoints yet")
+	assert.Empty(t, nextWithTimeout(t, grpcWatcher), "service has no endpoints yet")
+
+	fmt.Fprintf(stream1Writer, `{"type":"ADDED","object":%s}`, endpointsObject("11", "1.2.3.4"))
+	assert.Equal(t, []*naming.Update{{Op: naming.Add, Addr: "1.2.3.4:8080", Metadata: Metadata{Ready: true}}}, nextWithTimeout(t, httpWatcher))
+	assert.Equal(t, []*naming.Update{{Op: naming.Add, Addr: "1.2.3.4:9090", Metadata: Metadata{Ready: true}}}, nextWithTimeout(t, grpcWatcher))
+
+	// Stream broken, watch should continue from the last seen resourceVersion.
+	stream2, stream2Writer := io.Pipe()
+	epClient.streams <- stream2
+	stream1Writer.Close()
+	// resourceVersion is too old, list again.
+	fmt.Fprint(stream2Writer, `{"type":"ERROR","object":{"kind":"Status","code":410,"reason":"Expired"}}`)
+	stream3, stream3Writer := io.Pipe()
+	defer stream3Writer.Close()
+	epClient.streams <- stream3
+	epClient.lists <- &endpointsList{Metadata: metadata{ResourceVersion: "20"}, Items: []endpoints{
+		{Metadata: metadata{Name: "service1"}, Subsets: []subset{{Addresses: []address{{IP: "1.2.3.5"}}, Ports: []port{{Name: "http", Port: 8080}}}}},
+	}}
+
+	assert.Equal(t, []*naming.Update{
+		{Op: naming.Delete, Addr: "1.2.3.4:8080"},
+		{Op: naming.Add, Addr: "1.2.3.5:8080", Metadata: Metadata{Ready: true}},
+	}, nextWithTimeout(t, httpWatcher))
+
+	listCalls, resourceVersions := epClient.calls()
+	assert.Equal(t, 2, listCalls)
+	assert.Equal(t, []string{"10", "11", "20"}, resourceVersions)
+
+	httpWatcher.Close()
+	grpcWatcher.Close()
+	sharedInformers.mu.Lock()
+	assert.Empty(t, sharedInformers.informers, "informer without watchers should be stopped")
+	sharedInformers.mu.Unlock()
+}
+
+func reconnectsValue(t *testing.T, service string, reason string) float64 {
+	m := &dto.Metric{}
+	require.NoError(t, streamReconnects.WithLabelValues("", service, reason).Write(m))
+	return m.GetCounter().GetValue()
+}
+
+func TestInformer_ReconnectsOnStreamErrors(t *testing.T) {
+	defaultDelay := closedStreamReconnectDelay
+	closedStreamReconnectDelay = 10 * time.Millisecond
+	defer func() { closedStreamReconnectDelay = defaultDelay }()
+
+	epClient := newEndpointClientMock()
+	epClient.lists <- &endpointsList{Metadata: metadata{ResourceVersion: "10"}}
+	// Undecodable event.
+	epClient.streams <- ioutil.NopCloser(strings.NewReader(`{{{{ "temp-err": true}`))
+	// Not supported event type.
+	epClient.streams <- ioutil.NopCloser(strings.NewReader(`{"type":"not-supported"}`))
+	// Stream closed by the server (EOF).
+	epClient.streams <- ioutil.NopCloser(strings.NewReader(``))
+	stream, streamWriter := io.Pipe()
+	defer streamWriter.Close()
+	epClient.streams <- stream
+	go fmt.Fprintf(streamWriter, `{"type":"ADDED","object":%s}`, endpointsObject("11", "1.2.3.4"))
+
+	errorsBefore := reconnectsValue(t, "reconnects.ns1", reconnectReasonError)
+	closedBefore := reconnectsValue(t, "reconnects.ns1", reconnectReasonClosed)
+	key := informerKey{epClient: epClient, namespace: "ns1", service: "reconnects"}
+	i := startInformer(logrus.New(), key, &backoff.Backoff{Min: time.Millisecond, Max: time.Millisecond})
+	defer i.cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(i.snapshot()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Contains(t, i.snapshot(), "service1", "event after reconnects should be applied")
+
+	listCalls, resourceVersions := epClient.calls()
+	assert.Equal(t, 1, listCalls, "errors other than 410 Gone should not cause list")
+	assert.Equal(t, []string{"10", "10", "10", "10"}, resourceVersions, "watch should be continued from the listed resourceVersion")
+	assert.Equal(t, float64(2), reconnectsValue(t, "reconnects.ns1", reconnectReasonError)-errorsBefore)
+	assert.Equal(t, float64(1), reconnectsValue(t, "reconnects.ns1", reconnectReasonClosed)-closedBefore)
+}
+
+func TestClient_URLs(t *testing.T) {
+	var requests []string
+	gone := false
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.URL.String())
+		if gone {
+			resp.WriteHeader(http.StatusGone)
+			return
+		}
+		fmt.Fprint(resp, `{"metadata":{"resourceVersion":"5"},"items":[]}`)
+	}))
+	defer server.Close()
+	cl := &client{k8sURL: server.URL, k8sClient: http.DefaultClient}
+	target := targetEntry{service: "service1", namespace: "ns1"}
+
+	list, err := cl.List(context.Background(), target, false)
+	require.NoError(t, err)
+	assert.Equal(t, "5", list.Metadata.ResourceVersion)
+	_, err = cl.List(context.Background(), target, true)
+	require.NoError(t, err)
+	gone = true
+	_, err = cl.StartChangeStream(context.Background(), target, true, "5")
+	assert.Equal(t, errGone, err)
+
+	assert.Equal(t, []string{
+		"/api/v1/namespaces/ns1/endpoints?fieldSelector=metadata.name%3Dservice1",
+		"/apis/discovery.k8s.io/v1/namespaces/ns1/endpointslices?labelSelector=kubernetes.io%2Fservice-name%3Dservice1",
+		"/apis/discovery.k8s.io/v1/namespaces/ns1/endpointslices?labelSelector=kubernetes.io%2Fservice-name%3Dservice1&resourceVersion=5&watch=true",
+	}, requests)
+}
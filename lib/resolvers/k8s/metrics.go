@@ -0,0 +1,32 @@
+package k8sresolver
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	streamReconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "k8sresolver",
+			Name:      "stream_reconnects_total",
+			Help:      "Total number of watch stream reconnects. Reason is 'gone' if resourceVersion was too old and objects were listed again, 'closed' if the server ended the stream (e.g. watch timeout), 'error' otherwise. Cluster is empty for the default one.",
+		},
+		[]string{"cluster", "service", "reason"},
+	)
+
+	watchersGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "k8sresolver",
+			Name:      "watchers",
+			Help:      "Number of resolver watchers sharing the watch stream of the service. Cluster is empty for the default one.",
+		},
+		[]string{"cluster", "service"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(streamReconnects)
+	prometheus.MustRegister(watchersGauge)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	pb "github.com/mwitkow/kedge/_protogen/kedge/config/common/resolvers"
+	"github.com/mwitkow/kedge/lib/loglevel"
 	"github.com/mwitkow/kedge/lib/tokenauth"
 	"github.com/mwitkow/kedge/lib/tokenauth/http"
 	"github.com/pkg/errors"
@@ -26,15 +27,31 @@ const (
 	ExpectedTargetFmt = "<service>(|.<namespace>)(|.<whatever suffix>)(|:<port_name>|:<value number>)"
 )
 
+// Options changes what API is watched and which addresses are resolved.
+type Options struct {
+	// UseEndpointSlices makes the resolver watch EndpointSlice API (discovery.k8s.io/v1) instead of Endpoints API.
+	// Only EndpointSlice API provides zones and topology hints of endpoints.
+	UseEndpointSlices bool
+	// IncludeNotReady makes the resolver resolve addresses that are not ready as well. Their Metadata.Ready is false.
+	IncludeNotReady bool
+}
+
 // resolver resolves service names using Kubernetes endpoints instead of usual SRV DNS lookup.
 type resolver struct {
 	logger logrus.FieldLogger
 	cl     *client
+	opts   Options
 }
 
 func NewFromConfig(conf *pb.K8SResolver) (target string, name naming.Resolver, err error) {
-	logger := logrus.New().WithField("target", conf.GetDnsPortName())
-	resolver, err := NewFromFlags(logger)
+	logger := loglevel.Subsystem(loglevel.K8sResolver).WithField("target", conf.GetDnsPortName())
+	if conf.GetCluster() != "" {
+		logger = logger.WithField("cluster", conf.GetCluster())
+	}
+	resolver, err := NewForClusterFromFlags(logger, conf.GetCluster(), Options{
+		UseEndpointSlices: conf.GetUseEndpointSlices(),
+		IncludeNotReady:   conf.GetIncludeNotReady(),
+	})
 	if err != nil {
 		return "", nil, err
 	}
@@ -42,8 +59,12 @@ func NewFromConfig(conf *pb.K8SResolver) (target string, name naming.Resolver, e
 }
 
 // New returns a new Kubernetes resolver with HTTP client (based on given tokenauth Source and tlsConfig) to be used against kube-apiserver.
-func New(logger logrus.FieldLogger, k8sURL string, source tokenauth.Source, tlsConfig *tls.Config) naming.Resolver {
-	k8sClient := &http.Client{
+func New(logger logrus.FieldLogger, k8sURL string, source tokenauth.Source, tlsConfig *tls.Config, opts Options) naming.Resolver {
+	return NewWithClient(logger, k8sURL, newAuthClient(source, tlsConfig), opts)
+}
+
+func newAuthClient(source tokenauth.Source, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
 		// TLS transport with auth injection.
 		Transport: httpauth.NewTripper(
 			&http.Transport{
@@ -53,20 +74,21 @@ func New(logger logrus.FieldLogger, k8sURL string, source tokenauth.Source, tlsC
 			"Authorization",
 		),
 	}
-	return NewWithClient(logger, k8sURL, k8sClient)
 }
 
 // NewWithClient returns a new Kubernetes resolver using given http.Client configured to be used against kube-apiserver.
-func NewWithClient(logger logrus.FieldLogger, k8sURL string, k8sClient *http.Client) naming.Resolver {
+func NewWithClient(logger logrus.FieldLogger, k8sURL string, k8sClient *http.Client, opts Options) naming.Resolver {
+	return newResolver(logger, &client{k8sURL: k8sURL, k8sClient: k8sClient}, opts)
+}
+
+func newResolver(logger logrus.FieldLogger, cl *client, opts Options) *resolver {
 	if logger == nil {
 		logger = logrus.New()
 	}
 	return &resolver{
 		logger: logger,
-		cl: &client{
-			k8sURL:    k8sURL,
-			k8sClient: k8sClient,
-		},
+		cl:     cl,
+		opts:   opts,
 	}
 }
 
@@ -78,6 +100,8 @@ type targetPort struct {
 var noTargetPort = targetPort{}
 
 type targetEntry struct {
+	// cluster is not part of the target name, it is the cluster of the resolver.
+	cluster   string
 	service   string
 	namespace string
 	port      targetPort
@@ -136,7 +160,8 @@ func (r *resolver) Resolve(target string) (naming.Watcher, error) {
 	if err != nil {
 		return nil, err
 	}
+	t.cluster = r.cl.cluster
 
 	// Now the tricky part begins (:
-	return startNewWatcher(r.logger, t, r.cl), nil
+	return startNewWatcher(r.logger, t, r.cl, r.opts), nil
 }
@@ -19,46 +19,71 @@ var (
 		Factor: 2,
 		Max:    3 * time.Second,
 	}
+	// closedStreamReconnectDelay is the delay before watching again after the server closed the watch stream.
+	closedStreamReconnectDelay = 100 * time.Millisecond
 )
 
-type watchResult struct {
-	ep  *event
-	err error
+// Metadata is set on naming.Update of added addresses.
+type Metadata struct {
+	// Ready is false for addresses that are not ready. These are resolved only with Options.IncludeNotReady.
+	Ready bool
+	// NodeName is the name of the node hosting the endpoint, if known.
+	NodeName string
+	// Zone is the zone of the endpoint. Only EndpointSlice API provides it.
+	Zone string
+	// ZoneHints are the zones the endpoint should be consumed from (topology aware hints), if any. Only EndpointSlice
+	// API provides them.
+	ZoneHints []string
+}
+
+func (m Metadata) equal(other Metadata) bool {
+	if m.Ready != other.Ready || m.NodeName != other.NodeName || m.Zone != other.Zone || len(m.ZoneHints) != len(other.ZoneHints) {
+		return false
+	}
+	for i := range m.ZoneHints {
+		if m.ZoneHints[i] != other.ZoneHints[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // A Watcher provides name resolution updates by watching endpoints API.
-// It works by watching endpoint Watch API (retries if connection broke). Returned events with
-// changes inside endpoints are translated to resolution naming.Updates.
+// It works on top of informer of the service, shared with other watchers of the same service (retries if connection
+// broke). On every change of endpoints, they are translated to resolution naming.Updates.
 type watcher struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	target      targetEntry
-	watchChange chan watchResult
-	lastUpdates map[string]struct{}
+	target          targetEntry
+	includeNotReady bool
+	informer        *informer
+	changed         chan struct{}
+	lastUpdates     map[string]Metadata
 }
 
-func startNewWatcher(logger logrus.FieldLogger, target targetEntry, epClient endpointClient) *watcher {
-	// NOTE(bplotka): Would love to have proper context from above but naming.Resolver does not allow that.
+func startNewWatcher(logger logrus.FieldLogger, target targetEntry, epClient endpointClient, opts Options) *watcher {
 	ctx, cancel := context.WithCancel(context.Background())
 	w := &watcher{
-		ctx:         ctx,
-		cancel:      cancel,
-		target:      target,
-		watchChange: make(chan watchResult),
-		lastUpdates: make(map[string]struct{}),
+		ctx:             ctx,
+		cancel:          cancel,
+		target:          target,
+		includeNotReady: opts.IncludeNotReady,
+		changed:         make(chan struct{}, 1),
+		lastUpdates:     make(map[string]Metadata),
 	}
-
-	startWatchingEndpointsChanges(ctx, logger, target, epClient, w.watchChange, watchRetryBackoff, 0)
+	w.informer = sharedInformers.subscribe(logger, epClient, target, opts.UseEndpointSlices, w)
 	return w
 }
 
-// Close closes the watcher, cleaning up any open connections.
+// Close closes the watcher. Informer is stopped, cleaning up any open connections, once it has no watchers.
 func (w *watcher) Close() {
 	w.cancel()
+	sharedInformers.unsubscribe(w.informer, w)
 }
 
 // Next updates the endpoints for the targetEntry being watched.
+// First call blocks until endpoints are listed, next ones until they change.
 func (w *watcher) Next() ([]*naming.Update, error) {
 	if w.ctx.Err() != nil {
 		// We already stopped.
@@ -66,56 +91,66 @@ func (w *watcher) Next() ([]*naming.Update, error) {
 	}
 
 	updates := make([]*naming.Update, 0)
-	updatedEndpoints := make(map[string]struct{})
-	var event event
 	select {
 	case <-w.ctx.Done():
 		// We already stopped.
 		return []*naming.Update(nil), w.ctx.Err()
-	case r := <-w.watchChange:
-		if r.err != nil {
-			return []*naming.Update(nil), errors.Wrap(r.err, "error on reading event stream")
-		}
-		event = *r.ep
+	case <-w.changed:
 	}
 
 	// Translate kube api endpoint watch event to resolver address and put into map for easier lookup.
-	for _, subset := range event.Object.Subsets {
-		updatedAddresses, err := subsetToAddresses(w.target, subset)
+	updatedEndpoints := make(map[string]Metadata)
+	for _, object := range w.informer.snapshot() {
+		updatedAddresses, err := objectToAddresses(w.target, object)
 		if err != nil {
-			return []*naming.Update(nil), errors.Wrap(err, "failed to convert k8s endpoint subset to update Addr")
+			return []*naming.Update(nil), errors.Wrap(err, "failed to convert k8s endpoints to update Addr")
 		}
 
-		for _, address := range updatedAddresses {
-			updatedEndpoints[address] = struct{}{}
+		for address, md := range updatedAddresses {
+			if !md.Ready && !w.includeNotReady {
+				continue
+			}
+			if existing, ok := updatedEndpoints[address]; ok && existing.Ready {
+				// Address can be in more than one slice for a while. Prefer ready one.
+				continue
+			}
+			updatedEndpoints[address] = md
 		}
 	}
 
-	// Create updates to add new endpoints.
-	for addr, md := range updatedEndpoints {
-		if _, ok := w.lastUpdates[addr]; ok {
+	// Create updates to delete old and changed endpoints.
+	for addr, md := range w.lastUpdates {
+		if updated, ok := updatedEndpoints[addr]; ok && updated.equal(md) {
 			continue
 		}
-
-		updates = append(updates, &naming.Update{Op: naming.Add, Addr: addr, Metadata: md})
+		updates = append(updates, &naming.Update{Op: naming.Delete, Addr: addr, Metadata: nil})
 	}
-	// Create updates to delete old endpoints.
-	for addr := range w.lastUpdates {
-		if _, ok := updatedEndpoints[addr]; ok {
+	// Create updates to add new and changed endpoints.
+	for addr, md := range updatedEndpoints {
+		if last, ok := w.lastUpdates[addr]; ok && last.equal(md) {
 			continue
 		}
-		updates = append(updates, &naming.Update{Op: naming.Delete, Addr: addr, Metadata: nil})
+
+		updates = append(updates, &naming.Update{Op: naming.Add, Addr: addr, Metadata: md})
 	}
 
 	w.lastUpdates = updatedEndpoints
 	return updates, nil
 }
 
+// endpoints is either Endpoints or EndpointSlice object, depending on the watched API.
 type endpoints struct {
 	Kind       string   `json:"kind"`
 	APIVersion string   `json:"apiVersion"`
 	Metadata   metadata `json:"metadata"`
-	Subsets    []subset `json:"subsets"`
+
+	// Endpoints API.
+	Subsets []subset `json:"subsets"`
+
+	// EndpointSlice API.
+	AddressType string     `json:"addressType,omitempty"`
+	Endpoints   []endpoint `json:"endpoints,omitempty"`
+	Ports       []port     `json:"ports,omitempty"`
 }
 
 type metadata struct {
@@ -124,12 +159,14 @@ type metadata struct {
 }
 
 type subset struct {
-	Addresses []address `json:"addresses"`
-	Ports     []port    `json:"ports"`
+	Addresses         []address `json:"addresses"`
+	NotReadyAddresses []address `json:"notReadyAddresses,omitempty"`
+	Ports             []port    `json:"ports"`
 }
 
 type address struct {
-	IP string `json:"ip"`
+	IP       string `json:"ip"`
+	NodeName string `json:"nodeName,omitempty"`
 }
 
 type port struct {
@@ -137,30 +174,106 @@ type port struct {
 	Port int    `json:"port"`
 }
 
-func subsetToAddresses(t targetEntry, sub subset) ([]string, error) {
-	if len(sub.Ports) == 0 {
-		return []string(nil), errors.Errorf("Retrieved subset update contains no port")
+type endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions endpointConditions `json:"conditions"`
+	NodeName   string             `json:"nodeName,omitempty"`
+	Zone       string             `json:"zone,omitempty"`
+	Hints      *endpointHints     `json:"hints,omitempty"`
+}
+
+type endpointConditions struct {
+	// Ready is nil if unknown, which should be interpreted as ready.
+	Ready *bool `json:"ready,omitempty"`
+}
+
+type endpointHints struct {
+	ForZones []zoneHint `json:"forZones"`
+}
+
+type zoneHint struct {
+	Name string `json:"name"`
+}
+
+func objectToAddresses(t targetEntry, object endpoints) (map[string]Metadata, error) {
+	addresses := make(map[string]Metadata)
+	for _, subset := range object.Subsets {
+		subsetAddresses, err := subsetToAddresses(t, subset)
+		if err != nil {
+			return nil, err
+		}
+		for addr, md := range subsetAddresses {
+			addresses[addr] = md
+		}
+	}
+	if len(object.Endpoints) == 0 {
+		return addresses, nil
 	}
 
-	var port string
-	if t.port == noTargetPort {
-		// Get first one spotted.
-		port = strconv.Itoa(sub.Ports[0].Port)
-	} else if t.port.isNamed {
-		for _, p := range sub.Ports {
-			if p.Name == t.port.value {
-				port = strconv.Itoa(p.Port)
-				break
+	port, err := portFor(t, object.Ports)
+	if err != nil {
+		return nil, errors.Wrapf(err, "EndpointSlice %s", object.Metadata.Name)
+	}
+	if port == "" {
+		// Slice without the named port, it belongs to other port of the service.
+		return addresses, nil
+	}
+	for _, ep := range object.Endpoints {
+		md := Metadata{
+			Ready:    ep.Conditions.Ready == nil || *ep.Conditions.Ready,
+			NodeName: ep.NodeName,
+			Zone:     ep.Zone,
+		}
+		if ep.Hints != nil {
+			for _, hint := range ep.Hints.ForZones {
+				md.ZoneHints = append(md.ZoneHints, hint.Name)
 			}
 		}
-	} else {
-		port = t.port.value
+		for _, ip := range ep.Addresses {
+			addresses[net.JoinHostPort(ip, port)] = md
+		}
+	}
+	return addresses, nil
+}
+
+func subsetToAddresses(t targetEntry, sub subset) (map[string]Metadata, error) {
+	port, err := portFor(t, sub.Ports)
+	if err != nil {
+		return nil, errors.Wrap(err, "Retrieved subset update")
 	}
 
-	var updatedAddresses []string
+	updatedAddresses := make(map[string]Metadata)
+	if port == "" {
+		// Subset without the named port.
+		return updatedAddresses, nil
+	}
 	for _, address := range sub.Addresses {
-		updatedAddresses = append(updatedAddresses, net.JoinHostPort(address.IP, port))
+		updatedAddresses[net.JoinHostPort(address.IP, port)] = Metadata{Ready: true, NodeName: address.NodeName}
+	}
+	for _, address := range sub.NotReadyAddresses {
+		updatedAddresses[net.JoinHostPort(address.IP, port)] = Metadata{Ready: false, NodeName: address.NodeName}
 	}
 
 	return updatedAddresses, nil
 }
+
+// portFor returns port matching the target or empty string if there is no port with target's name.
+func portFor(t targetEntry, ports []port) (string, error) {
+	if len(ports) == 0 {
+		return "", errors.Errorf("contains no port")
+	}
+
+	if t.port == noTargetPort {
+		// Get first one spotted.
+		return strconv.Itoa(ports[0].Port), nil
+	}
+	if t.port.isNamed {
+		for _, p := range ports {
+			if p.Name == t.port.value {
+				return strconv.Itoa(p.Port), nil
+			}
+		}
+		return "", nil
+	}
+	return t.port.value, nil
+}
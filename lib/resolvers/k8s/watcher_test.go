@@ -0,0 +1,129 @@
+package k8sresolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/jpillora/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/naming"
+)
+
+func newTestWatcher(target targetEntry, includeNotReady bool) *watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{
+		ctx:             ctx,
+		cancel:          cancel,
+		target:          target,
+		includeNotReady: includeNotReady,
+		informer: &informer{
+			retryBackoff: &backoff.Backoff{},
+			objects:      make(map[string]endpoints),
+			watchers:     make(map[*watcher]struct{}),
+		},
+		changed:     make(chan struct{}, 1),
+		lastUpdates: make(map[string]Metadata),
+	}
+	w.informer.watchers[w] = struct{}{}
+	return w
+}
+
+func nextUpdates(t *testing.T, w *watcher, eventType eventType, object endpoints) []*naming.Update {
+	o, err := json.Marshal(object)
+	require.NoError(t, err)
+	e, err := json.Marshal(event{Type: eventType, Object: o})
+	require.NoError(t, err)
+	_, err = w.informer.proxyEvents(json.NewDecoder(bytes.NewReader(e)), "")
+	require.Equal(t, io.EOF, err)
+
+	updates, err := w.Next()
+	require.NoError(t, err)
+	sort.Slice(updates, func(i, j int) bool {
+		if updates[i].Op != updates[j].Op {
+			return updates[i].Op == naming.Delete
+		}
+		return updates[i].Addr < updates[j].Addr
+	})
+	return updates
+}
+
+func slice(name string, ports []port, eps ...endpoint) endpoints {
+	return endpoints{
+		Kind:        "EndpointSlice",
+		Metadata:    metadata{Name: name},
+		AddressType: "IPv4",
+		Endpoints:   eps,
+		Ports:       ports,
+	}
+}
+
+func TestWatcher_EndpointSlices(t *testing.T) {
+	notReady := false
+	ports := []port{{Name: "http", Port: 8080}}
+	w := newTestWatcher(targetEntry{service: "service1", namespace: "ns1", port: targetPort{value: "http", isNamed: true}}, false)
+
+	updates := nextUpdates(t, w, added, slice("service1-abc", ports,
+		endpoint{Addresses: []string{"10.0.0.1"}, NodeName: "node1", Zone: "zone-a", Hints: &endpointHints{ForZones: []zoneHint{{Name: "zone-a"}}}},
+		endpoint{Addresses: []string{"10.0.0.2"}, Conditions: endpointConditions{Ready: &notReady}, Zone: "zone-b"},
+	))
+	assert.Equal(t, []*naming.Update{
+		{Op: naming.Add, Addr: "10.0.0.1:8080", Metadata: Metadata{Ready: true, NodeName: "node1", Zone: "zone-a", ZoneHints: []string{"zone-a"}}},
+	}, updates, "not ready endpoint should be excluded")
+
+	updates = nextUpdates(t, w, added, slice("service1-def", ports,
+		endpoint{Addresses: []string{"10.0.0.3"}, Zone: "zone-b"},
+	))
+	assert.Equal(t, []*naming.Update{
+		{Op: naming.Add, Addr: "10.0.0.3:8080", Metadata: Metadata{Ready: true, Zone: "zone-b"}},
+	}, updates, "second slice should be added to the first one")
+
+	updates = nextUpdates(t, w, added, slice("service1-other-port", []port{{Name: "grpc", Port: 9090}},
+		endpoint{Addresses: []string{"10.0.0.4"}},
+	))
+	assert.Empty(t, updates, "slice without target port should be ignored")
+
+	updates = nextUpdates(t, w, modified, slice("service1-abc", ports,
+		endpoint{Addresses: []string{"10.0.0.1"}, NodeName: "node1", Zone: "zone-a"},
+	))
+	assert.Equal(t, []*naming.Update{
+		{Op: naming.Delete, Addr: "10.0.0.1:8080"},
+		{Op: naming.Add, Addr: "10.0.0.1:8080", Metadata: Metadata{Ready: true, NodeName: "node1", Zone: "zone-a"}},
+	}, updates, "changed metadata should re-add the address")
+
+	updates = nextUpdates(t, w, deleted, slice("service1-def", ports))
+	assert.Equal(t, []*naming.Update{{Op: naming.Delete, Addr: "10.0.0.3:8080"}}, updates)
+}
+
+func TestWatcher_EndpointsNotReady(t *testing.T) {
+	object := endpoints{
+		Metadata: metadata{Name: "service1"},
+		Subsets: []subset{
+			{
+				Addresses:         []address{{IP: "10.0.0.1", NodeName: "node1"}},
+				NotReadyAddresses: []address{{IP: "10.0.0.2"}},
+				Ports:             []port{{Name: "http", Port: 8080}},
+			},
+		},
+	}
+	target := targetEntry{service: "service1", namespace: "ns1", port: noTargetPort}
+
+	updates := nextUpdates(t, newTestWatcher(target, false), added, object)
+	assert.Equal(t, []*naming.Update{
+		{Op: naming.Add, Addr: "10.0.0.1:8080", Metadata: Metadata{Ready: true, NodeName: "node1"}},
+	}, updates)
+
+	w := newTestWatcher(target, true)
+	updates = nextUpdates(t, w, added, object)
+	assert.Equal(t, []*naming.Update{
+		{Op: naming.Add, Addr: "10.0.0.1:8080", Metadata: Metadata{Ready: true, NodeName: "node1"}},
+		{Op: naming.Add, Addr: "10.0.0.2:8080", Metadata: Metadata{Ready: false}},
+	}, updates)
+
+	updates = nextUpdates(t, w, deleted, object)
+	assert.Len(t, updates, 2, "deleted Endpoints should remove all addresses")
+}
@@ -0,0 +1,98 @@
+package sharedflags
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/mwitkow/go-flagz"
+	"github.com/mwitkow/go-httpwares"
+	"github.com/pkg/errors"
+)
+
+var (
+	flagFlagzWriteTokenFile = Set.String("flagz_write_token_file", "",
+		"Path to a file with bearer token that authorizes changing flags through /debug/flagz (POST with Authorization "+
+			"header). If empty, flags can be changed only by requests authorized by kedge OIDC auth, if configured.")
+)
+
+// FlagzEndpoint lists flags of Set on GET and changes a dynamic flag on POST, with `name` and `value` form values.
+//
+// Only the writable flags can be changed, and only by requests passed through writeAuth. If writeAuth is nil, no flag
+// can be changed. Static flags cannot be changed, they are read only on startup.
+func FlagzEndpoint(writeAuth httpwares.Middleware, writable ...string) http.HandlerFunc {
+	status := flagz.NewStatusEndpoint(Set)
+	allowed := make(map[string]struct{}, len(writable))
+	for _, name := range writable {
+		allowed[name] = struct{}{}
+	}
+	var write http.Handler = http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		http.Error(resp, "changing flags is not authorized", http.StatusForbidden)
+	})
+	if writeAuth != nil {
+		write = writeAuth(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			setFlag(resp, req, allowed)
+		}))
+	}
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			status.ListFlags(resp, req)
+			return
+		}
+		write.ServeHTTP(resp, req)
+	}
+}
+
+func setFlag(resp http.ResponseWriter, req *http.Request, allowed map[string]struct{}) {
+	name := req.PostFormValue("name")
+	f := Set.Lookup(name)
+	if f == nil {
+		http.Error(resp, fmt.Sprintf("flag %q not found", name), http.StatusNotFound)
+		return
+	}
+	if _, ok := allowed[name]; !ok {
+		http.Error(resp, fmt.Sprintf("flag %q cannot be changed through this endpoint", name), http.StatusForbidden)
+		return
+	}
+	if !flagz.IsFlagDynamic(f) {
+		http.Error(resp, fmt.Sprintf("flag %q is static and cannot be changed at runtime", name), http.StatusBadRequest)
+		return
+	}
+	if err := Set.Set(name, req.PostFormValue("value")); err != nil {
+		http.Error(resp, fmt.Sprintf("failed to set flag %q: %v", name, err), http.StatusBadRequest)
+		return
+	}
+	fmt.Fprintf(resp, "%s=%s\n", name, f.Value.String())
+}
+
+// WriteTokenAuthFromFlags returns middleware passing only requests with the bearer token from flagz_write_token_file,
+// or nil if the token file is not specified.
+func WriteTokenAuthFromFlags() (httpwares.Middleware, error) {
+	if *flagFlagzWriteTokenFile == "" {
+		return nil, nil
+	}
+	token, err := ioutil.ReadFile(*flagFlagzWriteTokenFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sharedflags: failed to read flagz write token file %v", *flagFlagzWriteTokenFile)
+	}
+	return TokenAuth(strings.TrimSpace(string(token)))
+}
+
+// TokenAuth returns middleware passing only requests with the given token in Authorization header (as Bearer).
+func TokenAuth(token string) (httpwares.Middleware, error) {
+	if token == "" {
+		return nil, errors.New("sharedflags: token cannot be empty")
+	}
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), expected) != 1 {
+				http.Error(resp, "invalid or missing bearer token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(resp, req)
+		})
+	}, nil
+}
@@ -0,0 +1,69 @@
+package sharedflags
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mwitkow/go-flagz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testWritableFlag = flagz.DynString(Set, "test_writable_flag", "a", "Dynamic flag that is allowed to be changed.")
+	testDynamicFlag  = flagz.DynString(Set, "test_dynamic_flag", "a", "Dynamic flag that is not allowed to be changed.")
+	testStaticFlag   = Set.String("test_static_flag", "a", "Static flag.")
+)
+
+func postFlag(handler http.Handler, name string, value string, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/debug/flagz", strings.NewReader(url.Values{"name": {name}, "value": {value}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestFlagzEndpoint_WithoutWriteAuth_DoesNotChangeFlags(t *testing.T) {
+	handler := FlagzEndpoint(nil, "test_writable_flag")
+	resp := postFlag(handler, "test_writable_flag", "b", "")
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, "a", testWritableFlag.Get())
+}
+
+func TestFlagzEndpoint_ChangesOnlyWritableFlagsWithToken(t *testing.T) {
+	tokenAuth, err := TokenAuth("some-token")
+	require.NoError(t, err)
+	handler := FlagzEndpoint(tokenAuth, "test_writable_flag")
+	defer Set.Set("test_writable_flag", "a")
+
+	for _, tcase := range []struct {
+		name           string
+		flag           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "MissingToken", flag: "test_writable_flag", expectedStatus: http.StatusUnauthorized},
+		{name: "InvalidToken", flag: "test_writable_flag", authorization: "Bearer other-token", expectedStatus: http.StatusUnauthorized},
+		{name: "NotWritableDynamicFlag", flag: "test_dynamic_flag", authorization: "Bearer some-token", expectedStatus: http.StatusForbidden},
+		{name: "StaticFlag", flag: "test_static_flag", authorization: "Bearer some-token", expectedStatus: http.StatusForbidden},
+		{name: "UnknownFlag", flag: "test_unknown_flag", authorization: "Bearer some-token", expectedStatus: http.StatusNotFound},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			resp := postFlag(handler, tcase.flag, "b", tcase.authorization)
+			assert.Equal(t, tcase.expectedStatus, resp.Code)
+		})
+	}
+	assert.Equal(t, "a", testWritableFlag.Get())
+	assert.Equal(t, "a", testDynamicFlag.Get())
+	assert.Equal(t, "a", *testStaticFlag)
+
+	resp := postFlag(handler, "test_writable_flag", "b", "Bearer some-token")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "b", testWritableFlag.Get())
+}
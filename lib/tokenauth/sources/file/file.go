@@ -0,0 +1,38 @@
+package fileauth
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mwitkow/kedge/lib/tokenauth"
+	"github.com/pkg/errors"
+)
+
+type source struct {
+	name string
+	path string
+}
+
+// New returns new auth source reading token from the given file on every Token call. This way rotated tokens (e.g.
+// projected service account tokens, refreshed by kubelet) are used as soon as they are written.
+func New(name string, path string) tokenauth.Source {
+	return &source{
+		name: name,
+		path: path,
+	}
+}
+
+// Name of the auth source.
+func (s *source) Name() string {
+	return s.name
+}
+
+// Token returns the current content of the token file.
+func (s *source) Token(_ context.Context) (string, error) {
+	token, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read token file %v", s.path)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
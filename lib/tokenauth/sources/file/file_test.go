@@ -0,0 +1,33 @@
+package fileauth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource_Token_ReadsRotatedToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileauth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+
+	s := New("test", path)
+	_, err = s.Token(context.Background())
+	assert.Error(t, err, "missing token file should be an error")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+	token, err := s.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "first", token)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("second\n"), 0600))
+	token, err = s.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "second", token, "rotated token should be used")
+}
@@ -1,6 +1,8 @@
 package k8sauth
 
 import (
+	"io/ioutil"
+
 	"github.com/mwitkow/kedge/lib/tokenauth"
 	"github.com/Bplotka/oidc/login/k8scache"
 	"github.com/pkg/errors"
@@ -8,6 +10,7 @@ import (
 	"github.com/mwitkow/kedge/lib/tokenauth/sources/oidc"
 	"github.com/mwitkow/kedge/lib/tokenauth/sources/oauth2"
 	"github.com/mwitkow/kedge/lib/tokenauth/sources/direct"
+	"github.com/mwitkow/kedge/lib/tokenauth/sources/file"
 )
 
 // New constructs appropriate tokenAuth Source to the given AuthInfo from kube config referenced by user.
@@ -29,6 +32,7 @@ func New(name string, configPath string, userName string) (tokenauth.Source, err
 
 	// Currently supported:
 	// - token
+	// - token file
 	// - OIDC
 	// - Google compute platform via Oauth2
 	if info.AuthProvider != nil {
@@ -55,6 +59,13 @@ func New(name string, configPath string, userName string) (tokenauth.Source, err
 	if info.Token != "" {
 		return directauth.New(name, info.Token), nil
 	}
+	if info.TokenFile != "" {
+		// E.g. service account token of other cluster. It is read on every request, as projected tokens rotate.
+		if _, err := ioutil.ReadFile(info.TokenFile); err != nil {
+			return nil, errors.Wrapf(err, "Failed to read token file %v of user %s", info.TokenFile, userName)
+		}
+		return fileauth.New(name, info.TokenFile), nil
+	}
 
 	return nil, errors.Errorf("Not found supported auth source from k8s config %+v", info)
 }
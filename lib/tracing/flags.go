@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"io"
+
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/opentracing/opentracing-go"
+)
+
+var (
+	fAgentHostPort = sharedflags.Set.String("tracing_jaeger_agent_hostport", "",
+		"Host:port of Jaeger agent (UDP) to export tracing spans to. If empty and tracing_jaeger_collector_url is "+
+			"empty, tracing is disabled.")
+	fCollectorURL = sharedflags.Set.String("tracing_jaeger_collector_url", "",
+		"URL of Jaeger collector HTTP endpoint (e.g. http://jaeger-collector:14268/api/traces) to export tracing "+
+			"spans to directly, instead of the agent.")
+	fSamplerType = sharedflags.Set.String("tracing_sampler_type", SamplerProbabilistic,
+		"Type of tracing sampler: const, probabilistic or ratelimiting. Calls that are sampled by the caller are "+
+			"always traced.")
+	fSamplerParam = sharedflags.Set.Float64("tracing_sampler_param", 0.001,
+		"Param of tracing sampler: 0 or 1 for const, probability for probabilistic and maximum traces per second "+
+			"for ratelimiting sampler.")
+	fPropagation = sharedflags.Set.String("tracing_propagation", PropagationW3C,
+		"Format of the trace context sent to backends: w3c (traceparent), b3 (Zipkin) or jaeger (uber-trace-id). "+
+			"All of them are accepted from callers.")
+)
+
+// NewFromFlags creates the tracer configured from sharedflags.Set.
+func NewFromFlags(serviceName string) (opentracing.Tracer, io.Closer, error) {
+	return New(Config{
+		ServiceName:   serviceName,
+		AgentHostPort: *fAgentHostPort,
+		CollectorURL:  *fCollectorURL,
+		SamplerType:   *fSamplerType,
+		SamplerParam:  *fSamplerParam,
+		Propagation:   *fPropagation,
+	})
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/mwitkow/go-httpwares"
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Middleware starts a server span for each request, continuing the trace of the caller if its context was sent.
+// The span is put into the request context, so child spans can be started using opentracing.StartSpanFromContext.
+// The trace ID is added to the request ctxtags (so it is logged).
+//
+// It needs to be placed after http_ctxtags.Middleware.
+func Middleware(component string) httpwares.Middleware {
+	return func(nextHandler http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			tracer := opentracing.GlobalTracer()
+			parentCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+			span := tracer.StartSpan(operationName(req), ext.RPCServerOption(parentCtx))
+			defer span.Finish()
+			ext.Component.Set(span, component)
+			ext.HTTPMethod.Set(span, req.Method)
+			ext.HTTPUrl.Set(span, req.URL.String())
+			span.SetTag("http.host", req.Host)
+			if sc, ok := span.Context().(jaeger.SpanContext); ok {
+				http_ctxtags.ExtractInbound(req).Set(ctxtags.TagForTraceID, sc.TraceID().String())
+			}
+
+			wrapped := httpwares.WrapResponseWriter(resp)
+			nextHandler.ServeHTTP(wrapped, req.WithContext(opentracing.ContextWithSpan(req.Context(), span)))
+			setStatus(span, wrapped.StatusCode())
+		})
+	}
+}
+
+// WrapTransport returns a RoundTripper that starts a client span for each round trip, tagged with the actual address
+// the request is sent to (so each load balanced attempt is a separate span), and sends the trace context with it.
+func WrapTransport(parent http.RoundTripper, backendName string) http.RoundTripper {
+	return &tracingTripper{parent: parent, backendName: backendName}
+}
+
+type tracingTripper struct {
+	parent      http.RoundTripper
+	backendName string
+}
+
+func (t *tracingTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := opentracing.SpanFromContext(req.Context())
+	if parent == nil {
+		return t.parent.RoundTrip(req)
+	}
+	tracer := parent.Tracer()
+	span := tracer.StartSpan(operationName(req), ext.SpanKindRPCClient, opentracing.ChildOf(parent.Context()))
+	defer span.Finish()
+	ext.HTTPMethod.Set(span, req.Method)
+	ext.HTTPUrl.Set(span, req.URL.String())
+	ext.PeerAddress.Set(span, req.URL.Host)
+	if t.backendName != "" {
+		ext.PeerService.Set(span, t.backendName)
+	}
+
+	// Request headers may be shared with other attempts and the inbound request, so they are not modified in place.
+	outReq := *req
+	outReq.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		outReq.Header[k] = v
+	}
+	tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(outReq.Header))
+
+	resp, err := t.parent.RoundTrip(&outReq)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("event", "error", "message", err.Error())
+		return resp, err
+	}
+	setStatus(span, resp.StatusCode)
+	return resp, nil
+}
+
+// StartSpan starts a child span of the one in the request context, e.g. for routing or auth. It returns a no-op span if
+// the request is not traced.
+func StartSpan(req *http.Request, operationName string) opentracing.Span {
+	parent := opentracing.SpanFromContext(req.Context())
+	if parent == nil {
+		return opentracing.NoopTracer{}.StartSpan(operationName)
+	}
+	return parent.Tracer().StartSpan(operationName, opentracing.ChildOf(parent.Context()))
+}
+
+func operationName(req *http.Request) string {
+	return "HTTP " + req.Method
+}
+
+func setStatus(span opentracing.Span, code int) {
+	ext.HTTPStatusCode.Set(span, uint16(code))
+	if code >= http.StatusInternalServerError {
+		ext.Error.Set(span, true)
+	}
+}
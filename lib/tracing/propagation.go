@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/zipkin"
+)
+
+const (
+	PropagationW3C    = "w3c"
+	PropagationB3     = "b3"
+	PropagationJaeger = "jaeger"
+
+	traceParentHeader = "traceparent"
+	w3cVersion        = "00"
+	w3cSampledFlag    = 0x01
+)
+
+type propagator interface {
+	jaeger.Injector
+	jaeger.Extractor
+}
+
+// multiPropagator injects the trace context in one format, but accepts all known formats from callers, so kedge can
+// be put between clients and backends using different tracers.
+type multiPropagator struct {
+	injector   jaeger.Injector
+	extractors []jaeger.Extractor
+}
+
+func newMultiPropagator(injectFormat string) (*multiPropagator, error) {
+	propagators := map[string]propagator{
+		PropagationW3C:    &w3cPropagator{},
+		PropagationB3:     zipkin.NewZipkinB3HTTPHeaderPropagator(),
+		PropagationJaeger: jaeger.NewHTTPHeaderPropagator((&jaeger.HeadersConfig{}).ApplyDefaults(), *jaeger.NewNullMetrics()),
+	}
+	injector, ok := propagators[injectFormat]
+	if !ok {
+		return nil, errors.Errorf("tracing: unknown propagation format %q", injectFormat)
+	}
+	p := &multiPropagator{injector: injector, extractors: []jaeger.Extractor{injector}}
+	for _, format := range []string{PropagationW3C, PropagationB3, PropagationJaeger} {
+		if format != injectFormat {
+			p.extractors = append(p.extractors, propagators[format])
+		}
+	}
+	return p, nil
+}
+
+func (p *multiPropagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	return p.injector.Inject(sc, carrier)
+}
+
+func (p *multiPropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	for _, extractor := range p.extractors {
+		sc, err := extractor.Extract(carrier)
+		if err == nil && sc.IsValid() {
+			return sc, nil
+		}
+	}
+	return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+}
+
+// w3cPropagator implements W3C Trace Context traceparent header: <version>-<trace-id>-<parent-id>-<flags>.
+type w3cPropagator struct{}
+
+func (*w3cPropagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	flags := 0
+	if sc.IsSampled() {
+		flags |= w3cSampledFlag
+	}
+	traceID := sc.TraceID()
+	writer.Set(traceParentHeader, fmt.Sprintf("%s-%016x%016x-%016x-%02x", w3cVersion, traceID.High, traceID.Low, uint64(sc.SpanID()), flags))
+	return nil
+}
+
+func (*w3cPropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	var traceParent string
+	err := reader.ForeachKey(func(key, val string) error {
+		if strings.ToLower(key) == traceParentHeader {
+			traceParent = val
+		}
+		return nil
+	})
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	if traceParent == "" {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	return parseTraceParent(traceParent)
+}
+
+func parseTraceParent(value string) (jaeger.SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2] + parts[3]); err != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	high, _ := strconv.ParseUint(parts[1][:16], 16, 64)
+	low, _ := strconv.ParseUint(parts[1][16:], 16, 64)
+	spanID, _ := strconv.ParseUint(parts[2], 16, 64)
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	traceID := jaeger.TraceID{High: high, Low: low}
+	if !traceID.IsValid() || spanID == 0 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	return jaeger.NewSpanContext(traceID, jaeger.SpanID(spanID), 0, flags&w3cSampledFlag != 0, nil), nil
+}
@@ -0,0 +1,93 @@
+// Package tracing configures OpenTracing distributed tracing (with Jaeger tracer and exporter) for kedge and winch,
+// and provides span helpers for proxied HTTP calls.
+package tracing
+
+import (
+	"io"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/transport"
+)
+
+const (
+	SamplerConst         = "const"
+	SamplerProbabilistic = "probabilistic"
+	SamplerRateLimiting  = "ratelimiting"
+)
+
+// Config configures the tracer.
+type Config struct {
+	// ServiceName is the name of the process in traces, e.g. "kedge" or "winch".
+	ServiceName string
+	// AgentHostPort is the Jaeger agent UDP address to export spans to.
+	AgentHostPort string
+	// CollectorURL is the Jaeger collector HTTP endpoint to export spans to, used instead of the agent.
+	CollectorURL string
+	// SamplerType is one of SamplerConst, SamplerProbabilistic or SamplerRateLimiting.
+	SamplerType string
+	// SamplerParam is 0 or 1 for const, probability for probabilistic and traces per second for ratelimiting sampler.
+	SamplerParam float64
+	// Propagation is the format the trace context is sent to backends in (PropagationW3C, PropagationB3 or
+	// PropagationJaeger). All of them are accepted from callers.
+	Propagation string
+}
+
+// New creates a tracer exporting spans to Jaeger. It returns a no-op tracer if neither the agent nor the collector is
+// configured. The returned closer flushes spans that were not exported yet.
+func New(conf Config) (opentracing.Tracer, io.Closer, error) {
+	if conf.AgentHostPort == "" && conf.CollectorURL == "" {
+		return opentracing.NoopTracer{}, nopCloser{}, nil
+	}
+	sampler, err := newSampler(conf.SamplerType, conf.SamplerParam)
+	if err != nil {
+		return nil, nil, err
+	}
+	propagator, err := newMultiPropagator(conf.Propagation)
+	if err != nil {
+		return nil, nil, err
+	}
+	var sender jaeger.Transport
+	if conf.CollectorURL != "" {
+		sender = transport.NewHTTPTransport(conf.CollectorURL)
+	} else {
+		sender, err = jaeger.NewUDPTransport(conf.AgentHostPort, 0)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "tracing: failed to create Jaeger agent transport for %v", conf.AgentHostPort)
+		}
+	}
+	tracer, closer := jaeger.NewTracer(
+		conf.ServiceName,
+		sampler,
+		jaeger.NewRemoteReporter(sender),
+		jaeger.TracerOptions.Injector(opentracing.HTTPHeaders, propagator),
+		jaeger.TracerOptions.Extractor(opentracing.HTTPHeaders, propagator),
+		// gRPC metadata is carried as TextMap.
+		jaeger.TracerOptions.Injector(opentracing.TextMap, propagator),
+		jaeger.TracerOptions.Extractor(opentracing.TextMap, propagator),
+	)
+	return tracer, closer, nil
+}
+
+func newSampler(samplerType string, param float64) (jaeger.Sampler, error) {
+	switch samplerType {
+	case SamplerConst:
+		return jaeger.NewConstSampler(param != 0), nil
+	case SamplerProbabilistic:
+		sampler, err := jaeger.NewProbabilisticSampler(param)
+		if err != nil {
+			return nil, errors.Wrap(err, "tracing: invalid probabilistic sampler param")
+		}
+		return sampler, nil
+	case SamplerRateLimiting:
+		return jaeger.NewRateLimitingSampler(param), nil
+	}
+	return nil, errors.Errorf("tracing: unknown sampler type %q", samplerType)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mwitkow/go-httpwares"
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+)
+
+var testSpanContext = jaeger.NewSpanContext(jaeger.TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736}, 0x00f067aa0ba902b7, 0, true, nil)
+
+func TestW3CPropagator_RoundTrip(t *testing.T) {
+	header := http.Header{}
+	require.NoError(t, (&w3cPropagator{}).Inject(testSpanContext, opentracing.HTTPHeadersCarrier(header)))
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", header.Get("traceparent"))
+
+	sc, err := (&w3cPropagator{}).Extract(opentracing.HTTPHeadersCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, testSpanContext.TraceID(), sc.TraceID())
+	assert.Equal(t, testSpanContext.SpanID(), sc.SpanID())
+	assert.True(t, sc.IsSampled())
+}
+
+func TestW3CPropagator_RejectsInvalidTraceParent(t *testing.T) {
+	for _, value := range []string{
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"garbage",
+	} {
+		_, err := parseTraceParent(value)
+		assert.Error(t, err, "value %v", value)
+	}
+}
+
+func TestMultiPropagator_ExtractsAllFormats(t *testing.T) {
+	p, err := newMultiPropagator(PropagationW3C)
+	require.NoError(t, err)
+	for _, header := range []http.Header{
+		{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+		{"X-B3-Traceid": {"4bf92f3577b34da6a3ce929d0e0e4736"}, "X-B3-Spanid": {"00f067aa0ba902b7"}, "X-B3-Sampled": {"1"}},
+		{"Uber-Trace-Id": {"4bf92f3577b34da6a3ce929d0e0e4736:00f067aa0ba902b7:0:1"}},
+	} {
+		sc, err := p.Extract(opentracing.HTTPHeadersCarrier(header))
+		require.NoError(t, err, "header %v", header)
+		assert.Equal(t, testSpanContext.TraceID(), sc.TraceID(), "header %v", header)
+		assert.Equal(t, testSpanContext.SpanID(), sc.SpanID(), "header %v", header)
+	}
+	_, err = p.Extract(opentracing.HTTPHeadersCarrier(http.Header{}))
+	assert.Equal(t, opentracing.ErrSpanContextNotFound, err)
+
+	_, err = newMultiPropagator("unknown")
+	assert.Error(t, err)
+}
+
+func TestNew_NoopWithoutExporter(t *testing.T) {
+	tracer, closer, err := New(Config{ServiceName: "kedge"})
+	require.NoError(t, err)
+	assert.Equal(t, opentracing.NoopTracer{}, tracer)
+	assert.NoError(t, closer.Close())
+}
+
+func TestMiddlewareAndTransport(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	var backendHeader http.Header
+	attempts := 0
+	backend := httpwares.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		backendHeader = req.Header
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	})
+	transport := WrapTransport(backend, "backend_a")
+	handler := http_ctxtags.Middleware("test")(Middleware("kedge")(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		StartSpan(req, "route").Finish()
+		for _, target := range []string{"10.0.0.1:80", "10.0.0.2:80"} {
+			outReq := req.WithContext(req.Context())
+			outReq.URL.Host = target
+			_, err := transport.RoundTrip(outReq)
+			require.NoError(t, err)
+		}
+		resp.WriteHeader(http.StatusBadGateway)
+	})))
+
+	req := httptest.NewRequest("GET", "http://backend.example.com/some/path", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 4)
+	route, attempt1, attempt2, server := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, "route", route.OperationName)
+	assert.Equal(t, "10.0.0.1:80", attempt1.Tag(string(ext.PeerAddress)))
+	assert.Equal(t, "10.0.0.2:80", attempt2.Tag(string(ext.PeerAddress)))
+	assert.Equal(t, "backend_a", attempt2.Tag(string(ext.PeerService)))
+	for _, child := range []*mocktracer.MockSpan{route, attempt1, attempt2} {
+		assert.Equal(t, server.SpanContext.SpanID, child.ParentID)
+	}
+	assert.Equal(t, uint16(http.StatusBadGateway), server.Tag(string(ext.HTTPStatusCode)))
+	assert.Equal(t, true, server.Tag(string(ext.Error)))
+	assert.NotEmpty(t, backendHeader.Get("Mockpfx-Ids-Spanid"), "trace context should be sent to the backend")
+	assert.Empty(t, req.Header.Get("Mockpfx-Ids-Spanid"), "inbound request headers should not be modified")
+}
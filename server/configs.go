@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sync"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/mwitkow/go-flagz/protobuf"
 	"github.com/mwitkow/go-proto-validators"
@@ -12,7 +14,11 @@ import (
 	http_director "github.com/mwitkow/kedge/http/director"
 	http_adhoc "github.com/mwitkow/kedge/http/director/adhoc"
 	http_router "github.com/mwitkow/kedge/http/director/router"
+	"github.com/mwitkow/kedge/lib/discovery"
 	"github.com/mwitkow/kedge/lib/sharedflags"
+	tcp_bp "github.com/mwitkow/kedge/tcp/backendpool"
+	tcp_director "github.com/mwitkow/kedge/tcp/director"
+	tcp_router "github.com/mwitkow/kedge/tcp/director/router"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,6 +28,7 @@ var (
 		&pb_config.DirectorConfig{
 			Grpc: &pb_config.DirectorConfig_Grpc{},
 			Http: &pb_config.DirectorConfig_Http{},
+			Tcp:  &pb_config.DirectorConfig_Tcp{},
 		},
 		"Contents of the Kedge Director configuration. Dynamically settable or read from file").WithFileFlag("../misc/director.json").WithValidator(generalValidator).WithNotifier(directorConfigReload)
 
@@ -30,6 +37,7 @@ var (
 		&pb_config.BackendPoolConfig{
 			Grpc: &pb_config.BackendPoolConfig_Grpc{},
 			Http: &pb_config.BackendPoolConfig_Http{},
+			Tcp:  &pb_config.BackendPoolConfig_Tcp{},
 		},
 		"Contents of the Kedge Backendpool configuration. Dynamically settable or read from file").WithFileFlag("../misc/backendpool.json").WithValidator(generalValidator).WithNotifier(backendConfigReloaded)
 
@@ -38,9 +46,16 @@ var (
 	grpcRouter      = grpc_router.NewDynamic()
 	httpRouter      = http_router.NewDynamic()
 	httpAddresser   = http_adhoc.NewDynamic()
+	tcpBackendPool  = tcp_bp.NewDynamic()
+	tcpRouter       = tcp_router.NewDynamic()
 
 	httpDirector = http_director.New(httpBackendPool, httpRouter, httpAddresser)
 	grpcDirector = grpc_director.New(grpcBackendPool, grpcRouter)
+	tcpDirector  = tcp_director.New(tcpBackendPool, tcpRouter, logrus.NewEntry(logrus.StandardLogger()))
+
+	// configMu guards applying configs, which are merged from config flags and discovered configs.
+	configMu          sync.Mutex
+	discoveredConfigs discovery.Configs
 )
 
 func generalValidator(msg proto.Message) error {
@@ -53,33 +68,95 @@ func generalValidator(msg proto.Message) error {
 }
 
 func directorConfigReload(_ proto.Message, newValue proto.Message) {
-	newConfig := newValue.(*pb_config.DirectorConfig)
+	configMu.Lock()
+	defer configMu.Unlock()
+	applyDirectorConfigLocked(newValue.(*pb_config.DirectorConfig))
+}
+
+func applyDirectorConfigLocked(staticConfig *pb_config.DirectorConfig) {
+	// Discovered routes to backends conflicting with static ones are dropped.
+	staticBackends := flagConfigBackendpool.Get().(*pb_config.BackendPoolConfig)
+	newConfig := discovery.MergeDirector(staticConfig, staticBackends, discoveredConfigs)
 
 	// The gRPC and HTTP fields are guaranteed to be there because of validation.
 	grpcRouter.Update(newConfig.GetGrpc().Routes)
 	httpRouter.Update(newConfig.GetHttp().Routes)
 	httpAddresser.Update(newConfig.GetHttp().AdhocRules)
+	// TCP field is optional, nil config means no TCP routes.
+	tcpRouter.Update(newConfig.GetTcp().GetRoutes())
 }
 
 func backendConfigReloaded(_ proto.Message, newValue proto.Message) {
-	newConfig := newValue.(*pb_config.BackendPoolConfig)
+	configMu.Lock()
+	defer configMu.Unlock()
+	newConfig := discovery.MergeBackendpool(logrus.StandardLogger(), newValue.(*pb_config.BackendPoolConfig), discoveredConfigs)
+	addOrUpdateBackendsLocked(newConfig)
+	removeStaleBackendsLocked(newConfig)
+}
 
+// addOrUpdateBackendsLocked adds new backends of the config to the pools and updates the changed ones. Backends with
+// unchanged config are left as they are.
+func addOrUpdateBackendsLocked(newConfig *pb_config.BackendPoolConfig) {
 	// The gRPC and HTTP fields are guaranteed to be there because of validation.
-	grpcBackendInNewConfig := make(map[string]struct{})
 	grpcBackendInOldConfig := grpcBackendPool.Configs()
-	grpcConfig := newConfig.GetGrpc()
-	if grpcConfig != nil {
-		for _, backend := range grpcConfig.Backends {
-			if err := grpcBackendPool.AddOrUpdate(backend); err != nil {
-				logrus.Errorf("failed creating gRPC backend %v: %v", backend.Name, err)
-			}
+	for _, backend := range newConfig.GetGrpc().GetBackends() {
+		old, exists := grpcBackendInOldConfig[backend.Name]
+		if exists && proto.Equal(old, backend) {
+			continue
+		}
+		if err := grpcBackendPool.AddOrUpdate(backend); err != nil {
+			logrus.Errorf("failed creating gRPC backend %v: %v", backend.Name, err)
+			continue
+		}
+		if exists {
+			logrus.Infof("updating gRPC backend: %v", backend.Name)
+		} else {
 			logrus.Infof("adding new gRPC backend: %v", backend.Name)
+		}
+	}
 
-			grpcBackendInNewConfig[backend.Name] = struct{}{}
+	httpBackendInOldConfig := httpBackendPool.Configs()
+	for _, backend := range newConfig.GetHttp().GetBackends() {
+		old, exists := httpBackendInOldConfig[backend.Name]
+		if exists && proto.Equal(old, backend) {
+			continue
+		}
+		if err := httpBackendPool.AddOrUpdate(backend); err != nil {
+			logrus.Errorf("failed creating http backend %v: %v", backend.Name, err)
+			continue
+		}
+		if exists {
+			logrus.Infof("updating http backend: %v", backend.Name)
+		} else {
+			logrus.Infof("adding new http backend: %v", backend.Name)
+		}
+	}
+
+	tcpBackendInOldConfig := tcpBackendPool.Configs()
+	for _, backend := range newConfig.GetTcp().GetBackends() {
+		old, exists := tcpBackendInOldConfig[backend.Name]
+		if exists && proto.Equal(old, backend) {
+			continue
+		}
+		if err := tcpBackendPool.AddOrUpdate(backend); err != nil {
+			logrus.Errorf("failed creating TCP backend %v: %v", backend.Name, err)
+			continue
+		}
+		if exists {
+			logrus.Infof("updating TCP backend: %v", backend.Name)
+		} else {
+			logrus.Infof("adding new TCP backend: %v", backend.Name)
 		}
 	}
+}
 
-	for backendName := range grpcBackendInOldConfig {
+// removeStaleBackendsLocked removes backends that are not in the config anymore from the pools.
+func removeStaleBackendsLocked(newConfig *pb_config.BackendPoolConfig) {
+	grpcBackendInNewConfig := make(map[string]struct{})
+	for _, backend := range newConfig.GetGrpc().GetBackends() {
+		grpcBackendInNewConfig[backend.Name] = struct{}{}
+	}
+	for backendName := range grpcBackendPool.Configs() {
 		if _, exists := grpcBackendInNewConfig[backendName]; !exists {
 			logrus.Infof("removing gRPC backend: %v", backendName)
 			grpcBackendPool.Remove(backendName)
@@ -87,25 +164,42 @@ func backendConfigReloaded(_ proto.Message, newValue proto.Message) {
 	}
 
 	httpBackendInNewConfig := make(map[string]struct{})
-	httpBackendInOldConfig := httpBackendPool.Configs()
-
-	httpConfig := newConfig.GetHttp()
-	if httpConfig != nil {
-		for _, backend := range newConfig.GetHttp().Backends {
-			if err := httpBackendPool.AddOrUpdate(backend); err != nil {
-				logrus.Errorf("failed creating http backend %v: %v", backend.Name, err)
-			}
-			logrus.Infof("adding new http backend: %v", backend.Name)
-
-			httpBackendInNewConfig[backend.Name] = struct{}{}
-		}
+	for _, backend := range newConfig.GetHttp().GetBackends() {
+		httpBackendInNewConfig[backend.Name] = struct{}{}
 	}
-
-	for backendName := range httpBackendInOldConfig {
-		if _, exists := grpcBackendInNewConfig[backendName]; !exists {
+	for backendName := range httpBackendPool.Configs() {
+		if _, exists := httpBackendInNewConfig[backendName]; !exists {
 			logrus.Infof("removing http backend: %v", backendName)
+			httpBackendPool.Remove(backendName)
+		}
+	}
 
-			grpcBackendPool.Remove(backendName)
+	tcpBackendInNewConfig := make(map[string]struct{})
+	for _, backend := range newConfig.GetTcp().GetBackends() {
+		tcpBackendInNewConfig[backend.Name] = struct{}{}
+	}
+	for backendName := range tcpBackendPool.Configs() {
+		if _, exists := tcpBackendInNewConfig[backendName]; !exists {
+			logrus.Infof("removing TCP backend: %v", backendName)
+			tcpBackendPool.Remove(backendName)
 		}
 	}
 }
+
+// discoveredConfigsUpdated applies configs discovered from Kubernetes together with the ones from config flags.
+// Discovery reports configs on every change of watched objects, so unchanged configs are not applied again.
+func discoveredConfigsUpdated(configs discovery.Configs) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if proto.Equal(configs.Backendpool, discoveredConfigs.Backendpool) && proto.Equal(configs.Director, discoveredConfigs.Director) {
+		return
+	}
+	discoveredConfigs = configs
+
+	backends := discovery.MergeBackendpool(logrus.StandardLogger(), flagConfigBackendpool.Get().(*pb_config.BackendPoolConfig), discoveredConfigs)
+	// New backends first, so new routes don't point to missing backends. Stale backends last, once no route points
+	// to them.
+	addOrUpdateBackendsLocked(backends)
+	applyDirectorConfigLocked(flagConfigDirector.Get().(*pb_config.DirectorConfig))
+	removeStaleBackendsLocked(backends)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	pb_config "github.com/mwitkow/kedge/_protogen/kedge/config"
+	pb_res "github.com/mwitkow/kedge/_protogen/kedge/config/common/resolvers"
+	pb_httpbe "github.com/mwitkow/kedge/_protogen/kedge/config/http/backends"
+	pb_httproute "github.com/mwitkow/kedge/_protogen/kedge/config/http/routes"
+	"github.com/mwitkow/kedge/lib/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func httpBackendConfig(names ...string) *pb_config.BackendPoolConfig {
+	config := &pb_config.BackendPoolConfig{
+		Grpc: &pb_config.BackendPoolConfig_Grpc{},
+		Http: &pb_config.BackendPoolConfig_Http{},
+	}
+	for _, name := range names {
+		config.Http.Backends = append(config.Http.Backends, &pb_httpbe.Backend{
+			Name:                name,
+			DisableConntracking: true,
+			Resolver: &pb_httpbe.Backend_Srv{
+				Srv: &pb_res.SrvResolver{DnsName: "_http._tcp." + name + ".example.com"},
+			},
+		})
+	}
+	return config
+}
+
+func TestBackendConfigReloaded_RemovesHttpBackends(t *testing.T) {
+	defer backendConfigReloaded(nil, httpBackendConfig())
+
+	backendConfigReloaded(nil, httpBackendConfig("foo", "bar"))
+	require.Len(t, httpBackendPool.Configs(), 2)
+
+	backendConfigReloaded(nil, httpBackendConfig("foo"))
+	assert.Len(t, httpBackendPool.Configs(), 1, "backend removed from config should be removed from the pool")
+	assert.Contains(t, httpBackendPool.Configs(), "foo")
+}
+
+func httpDiscoveredConfigs(names ...string) discovery.Configs {
+	configs := discovery.Configs{
+		Backendpool: httpBackendConfig(names...),
+		Director: &pb_config.DirectorConfig{
+			Grpc: &pb_config.DirectorConfig_Grpc{},
+			Http: &pb_config.DirectorConfig_Http{},
+		},
+	}
+	for _, name := range names {
+		configs.Director.Http.Routes = append(configs.Director.Http.Routes, &pb_httproute.Route{
+			BackendName: name,
+			HostMatcher: name + ".example.com",
+		})
+	}
+	return configs
+}
+
+func TestDiscoveredConfigsUpdated_AppliesRoutesAndBackends(t *testing.T) {
+	defer discoveredConfigsUpdated(discovery.Configs{})
+
+	discoveredConfigsUpdated(httpDiscoveredConfigs("disc_a", "disc_b"))
+	require.Len(t, httpBackendPool.Configs(), 2)
+	route, err := httpRouter.Route(httptest.NewRequest("GET", "http://disc_b.example.com/", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "disc_b", route.BackendName)
+
+	// The same configs are reported on every change of watched objects, they should not be applied again.
+	discoveredConfigsUpdated(httpDiscoveredConfigs("disc_a", "disc_b"))
+	assert.Len(t, httpBackendPool.Configs(), 2)
+
+	discoveredConfigsUpdated(httpDiscoveredConfigs("disc_a"))
+	assert.Len(t, httpBackendPool.Configs(), 1, "backend not discovered anymore should be removed")
+	_, err = httpRouter.Route(httptest.NewRequest("GET", "http://disc_b.example.com/", nil))
+	assert.Error(t, err, "route to the removed backend should be dropped")
+}
@@ -13,18 +13,31 @@ import (
 	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
 	"github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/mwitkow/go-conntrack"
 	"github.com/mwitkow/go-conntrack/connhelpers"
 	"github.com/mwitkow/go-flagz"
+	"github.com/mwitkow/go-httpwares"
 	"github.com/mwitkow/go-httpwares/logging/logrus"
 	"github.com/mwitkow/go-httpwares/tags"
 	"github.com/mwitkow/go-httpwares/tracing/debug"
 	"github.com/mwitkow/grpc-proxy/proxy"
+	"github.com/mwitkow/kedge/grpc/grpcweb"
 	http_director "github.com/mwitkow/kedge/http/director"
+	"github.com/mwitkow/kedge/http/director/errorpage"
+	"github.com/mwitkow/kedge/lib/accesslog"
+	"github.com/mwitkow/kedge/lib/acme"
+	"github.com/mwitkow/kedge/lib/auditlog"
+	"github.com/mwitkow/kedge/lib/discovery"
 	"github.com/mwitkow/kedge/lib/http/ctxtags"
-	"github.com/mwitkow/kedge/lib/logstash"
+	"github.com/mwitkow/kedge/lib/http/h2c"
+	"github.com/mwitkow/kedge/lib/loglevel"
+	"github.com/mwitkow/kedge/lib/logsink"
+	"github.com/mwitkow/kedge/lib/requestid"
 	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/mwitkow/kedge/lib/tracing"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pressly/chi"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -37,14 +50,22 @@ var (
 	flagBindAddr    = sharedflags.Set.String("server_bind_address", "0.0.0.0", "address to bind the server to")
 	flagGrpcTlsPort = sharedflags.Set.Int("server_grpc_tls_port", 8444, "TCP TLS port to listen on for secure gRPC calls. If 0, no gRPC-TLS will be open.")
 	flagHttpTlsPort = sharedflags.Set.Int("server_http_tls_port", 8443, "TCP port to listen on for HTTPS. If gRPC call will hit it will bounce to gRPC handler. If 0, no TLS will be open.")
+	flagTcpPorts    = sharedflags.Set.IntSlice("server_tcp_ports", []int{}, "TCP ports to listen on for TCP (L4) proxying. Connections are routed to TCP backends by listener port and TLS SNI, without TLS termination. If empty, no TCP proxying will be open.")
 	flagHttpPort    = sharedflags.Set.Int("server_http_port", 8080, "TCP port to listen on for HTTP1.1/REST calls for debug endpoints like metrics, flagz page or optional pprof (insecure, but private only IP are allowed). If 0, no insecure HTTP will be open.")
 
+	flagHttpPlainProxyPort = sharedflags.Set.Int("server_http_plain_proxy_port", 0, "TCP port to listen on for plain-text (non-TLS) HTTP proxying, e.g. for pods that terminate TLS in a sidecar. "+
+		"Only routes with allow_plaintext or redirect_to_https are served there, adhoc rules are not. gRPC over h2c (prior knowledge) is accepted as well. If 0, no plain-text proxy will be open.")
+	flagHttpRedirectPort = sharedflags.Set.Int("server_http_redirect_port", 0, "TCP port (usually 80) to listen on for plain-text HTTP that is redirected to HTTPS, apart from ACME HTTP-01 challenges. "+
+		"If 0, no redirect listener will be open.")
+	flagHttpRedirectAcmeWebroot = sharedflags.Set.String("server_http_redirect_acme_webroot", "", "Directory with ACME HTTP-01 challenge files (<webroot>/.well-known/acme-challenge/<token>) "+
+		"served on server_http_redirect_port, e.g. maintained by certbot in webroot mode. If empty, challenges are not served from disk.")
+	flagGrpcPlainPort = sharedflags.Set.Int("server_grpc_plain_port", 0, "TCP port to listen on for plain-text (insecure, h2c) gRPC calls, meant for trusted in-cluster traffic. "+
+		"Only routes with allow_plaintext are served there. If 0, no plain-text gRPC will be open.")
+
 	flagHttpMaxWriteTimeout = sharedflags.Set.Duration("server_http_max_write_timeout", 10*time.Second, "HTTP server config, max write duration.")
 	flagHttpMaxReadTimeout  = sharedflags.Set.Duration("server_http_max_read_timeout", 10*time.Second, "HTTP server config, max read duration.")
 	flagGrpcWithTracing     = sharedflags.Set.Bool("server_tracing_grpc_enabled", true, "Whether enable gRPC tracing (could be expensive).")
 
-	flagLogstashAddress = sharedflags.Set.String("logstash_hostport", "", "Host:port of logstash for remote logging. If empty remote logging is disabled.")
-
 	flagLogTestBackendpoolResolution = sharedflags.Set.Bool("log_backend_resolution_on_startup", false, "With this option "+
 		"kedge will parse configuration, fill static backendpool, perform test resolution and print the resolved addresses."+
 		"Useful for debugging backend routings.")
@@ -55,59 +76,100 @@ func main() {
 	if err := sharedflags.Set.Parse(os.Args); err != nil {
 		log.WithError(err).Fatalf("failed parsing flags")
 	}
+	// Tracer needs to be set before reading file flags, since backends created on config load capture it.
+	grpc.EnableTracing = *flagGrpcWithTracing
+	tracer, tracerCloser, err := tracing.NewFromFlags("kedge")
+	if err != nil {
+		log.WithError(err).Fatal("failed to create tracer.")
+	}
+	defer tracerCloser.Close()
+	opentracing.SetGlobalTracer(tracer)
 	if err := flagz.ReadFileFlags(sharedflags.Set); err != nil {
 		log.WithError(err).Fatalf("failed reading flagz from files")
 	}
 
 	if *flagLogTestBackendpoolResolution {
+		lvl := log.GetLevel()
 		log.SetLevel(log.DebugLevel)
 		testLogBackendpool(log.StandardLogger())
-		log.SetLevel(log.InfoLevel)
+		log.SetLevel(lvl)
 	}
 
-	if *flagLogstashAddress != "" {
-		formatter, err := logstash.NewFormatter()
-		if err != nil {
-			log.WithError(err).Fatal("Failed to get hostname for logstash formatter")
-		}
-
-		hook, err := logstash.NewHook(*flagLogstashAddress, formatter)
-		if err != nil {
-			log.WithError(err).Fatal("Failed to create new logstash hook")
-		}
+	var remoteLogHook log.Hook
+	hook, err := logsink.NewHookFromFlags("kedge")
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create remote log hook")
+	}
+	if hook != nil {
 		log.AddHook(hook)
+		remoteLogHook = hook
+	}
+	accessLogger, err := accesslog.NewFromFlags(remoteLogHook)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create access logger.")
+	}
+	defer accessLogger.Close()
+	http_director.AuditLogger, err = auditlog.NewFromFlags(remoteLogHook, *flagOIDCPermsClaim)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create audit logger.")
 	}
+	defer http_director.AuditLogger.Close()
 
-	grpc.EnableTracing = *flagGrpcWithTracing
 	logEntry := log.NewEntry(log.StandardLogger())
+	directorLogEntry := loglevel.Subsystem(loglevel.Director)
 	grpc_logrus.ReplaceGrpcLogger(logEntry)
-	tlsConfig, err := buildTLSConfigFromFlags()
+	acmeManager, err := acmecert.NewFromFlags(*flagHttpRedirectPort)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create ACME certificate manager.")
+	}
+	http_director.ErrorRenderer, err = errorpage.NewFromFlags()
+	if err != nil {
+		log.WithError(err).Fatal("failed to create HTTP error renderer.")
+	}
+	requestIDTrust, err := requestid.TrustFromFlags()
+	if err != nil {
+		log.WithError(err).Fatal("failed to parse request ID trusted networks.")
+	}
+	// Filled by discovery controller serving Kubernetes Ingresses, if enabled.
+	ingressCerts := discovery.NewCertificates()
+	tlsConfig, err := buildTLSConfigFromFlags(acmeManager, ingressCerts)
 	if err != nil {
 		log.Fatalf("failed building TLS config from flags: %v", err)
 	}
 
 	// GRPC kedge.
-	grpcDirectorServer := grpc.NewServer(
+	grpcDirectorOpts := []grpc.ServerOption{
 		grpc.CustomCodec(proxy.Codec()), // needed for director to function.
 		grpc.UnknownServiceHandler(proxy.TransparentHandler(grpcDirector)),
 		grpc_middleware.WithUnaryServerChain(
 			grpc_ctxtags.UnaryServerInterceptor(),
-			grpc_logrus.UnaryServerInterceptor(logEntry),
+			requestid.UnaryServerInterceptor(requestIDTrust),
+			grpc_opentracing.UnaryServerInterceptor(),
+			grpc_logrus.UnaryServerInterceptor(directorLogEntry),
 			grpc_prometheus.UnaryServerInterceptor,
 		),
 		grpc_middleware.WithStreamServerChain(
 			grpc_ctxtags.StreamServerInterceptor(),
-			grpc_logrus.StreamServerInterceptor(logEntry),
+			requestid.StreamServerInterceptor(requestIDTrust),
+			grpc_opentracing.StreamServerInterceptor(),
+			grpc_logrus.StreamServerInterceptor(directorLogEntry),
 			grpc_prometheus.StreamServerInterceptor,
 		),
-		grpc.Creds(credentials.NewTLS(tlsConfig)),
-	)
+	}
+	grpcDirectorServer := grpc.NewServer(append(grpcDirectorOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))...)
+	// Plain-text server has no transport credentials, so calls are served over h2c. Router serves only routes that
+	// allow plaintext there.
+	grpcPlainDirectorServer := grpc.NewServer(grpcDirectorOpts...)
 
 	// HTTPS proxy chain.
 	httpDirectorChain := chi.Chain(
 		http_ctxtags.Middleware("proxy"),
+		http_director.MetricsMiddleware(),
+		accessLogger.Middleware(),
+		requestid.Middleware(requestIDTrust),
+		tracing.Middleware("kedge"),
 		http_debug.Middleware(),
-		http_logrus.Middleware(logEntry, http_logrus.WithLevels(kedgeCodeToLevel)),
+		http_logrus.Middleware(directorLogEntry, http_logrus.WithLevels(kedgeCodeToLevel)),
 	)
 
 	// HTTP debug chain.
@@ -118,7 +180,7 @@ func main() {
 	// httpNonAuthDebugChain chain is shares the same base but will not include auth. It is for metrics and _healthz.
 	httpNonAuthDebugChain := httpDebugChain
 
-	authorizer, err := authorizerFromFlags(logEntry)
+	authorizer, err := authorizerFromFlags(loglevel.Subsystem(loglevel.Auth))
 	if err != nil {
 		log.WithError(err).Fatal("failed to create authorizer.")
 	}
@@ -128,30 +190,92 @@ func main() {
 		logEntry.Info("configured OIDC authorization for HTTPS proxy.")
 	}
 
-	// Bouncer.
-	httpsBouncerServer := httpsBouncerServer(grpcDirectorServer, httpDirectorChain.Handler(httpDirector), logEntry)
+	discoveryController, err := discovery.NewFromFlags(logEntry, authorizer != nil, ingressCerts, discoveredConfigsUpdated)
+	if err != nil {
+		log.WithError(err).Fatal("failed to start Kubernetes discovery.")
+	}
+	if discoveryController != nil {
+		defer discoveryController.Close()
+		logEntry.Info("configured backends and routes discovery from Kubernetes Services and Ingresses.")
+	}
+
+	// Plain-text proxy chain is the same as HTTPS one, but only routes that allow plaintext are served.
+	httpPlainDirectorChain := append(chi.Chain(http_director.PlaintextListenerMiddleware()), httpDirectorChain...)
+
+	// Bouncers.
+	httpsBouncerServer := bouncerServer(grpcDirectorServer, grpcweb.New(grpcDirectorServer, grpcRouter), httpDirectorChain.Handler(httpDirector), directorLogEntry, "tls")
+	httpPlainBouncerServer := bouncerServer(grpcPlainDirectorServer, grpcweb.New(grpcPlainDirectorServer, grpcRouter), httpPlainDirectorChain.Handler(httpDirector), directorLogEntry, "plain")
 
 	if authorizer != nil && *flagEnableOIDCAuthForDebugEnpoints {
 		httpDebugChain = append(httpDebugChain, http_director.AuthMiddleware(authorizer))
 		logEntry.Info("configured OIDC authorization for HTTP debug server.")
 	}
+	// Changing flags at runtime always requires authorization: write token if configured, OIDC otherwise.
+	flagzWriteAuth, err := sharedflags.WriteTokenAuthFromFlags()
+	if err != nil {
+		log.WithError(err).Fatal("failed to create flagz write authorization.")
+	}
+	if flagzWriteAuth == nil && authorizer != nil {
+		if *flagEnableOIDCAuthForDebugEnpoints {
+			// Already authorized by httpDebugChain.
+			flagzWriteAuth = func(next http.Handler) http.Handler { return next }
+		} else {
+			flagzWriteAuth = http_director.AuthMiddleware(authorizer)
+		}
+	}
+
+	// HTTP to HTTPS redirect.
+	var acmeChallengeHandler http.Handler
+	if *flagHttpRedirectAcmeWebroot != "" {
+		acmeChallengeHandler = http.FileServer(http.Dir(*flagHttpRedirectAcmeWebroot))
+	}
+	if acmeManager != nil {
+		// Challenges of certificates provisioned by kedge itself, the rest falls back to the webroot.
+		acmeChallengeHandler = acmeManager.HTTPHandler(acmeChallengeHandler)
+	}
+	httpRedirectChain := chi.Chain(
+		http_ctxtags.Middleware("redirect"),
+		http_logrus.Middleware(logEntry, http_logrus.WithLevels(kedgeCodeToLevel)),
+	)
+	httpRedirectServer := &http.Server{
+		WriteTimeout: *flagHttpMaxWriteTimeout,
+		ReadTimeout:  *flagHttpMaxReadTimeout,
+		ErrorLog:     http_logrus.AsHttpLogger(logEntry.WithField(ctxtags.TagForScheme, "plain")),
+		Handler:      httpRedirectChain.Handler(http_director.RedirectHandler(acmeChallengeHandler)),
+	}
 
 	// Debug.
-	httpDebugServer, err := debugServer(logEntry, httpDebugChain, httpNonAuthDebugChain)
+	httpDebugServer, err := debugServer(logEntry, httpDebugChain, httpNonAuthDebugChain, flagzWriteAuth)
 	if err != nil {
 		log.WithError(err).Fatal("failed to create debug Server.")
 	}
 
 	errChan := make(chan error)
 	var grpcTlsListener net.Listener
+	var grpcPlainListener net.Listener
 	var httpPlainListener net.Listener
+	var httpPlainProxyListener net.Listener
+	var httpRedirectListener net.Listener
 	var httpTlsListener net.Listener
 	if *flagGrpcTlsPort != 0 {
 		grpcTlsListener = buildListenerOrFail("grpc_tls", *flagGrpcTlsPort)
 	}
+	if *flagGrpcPlainPort != 0 {
+		grpcPlainListener = buildListenerOrFail("grpc_plain", *flagGrpcPlainPort)
+	}
 	if *flagHttpPort != 0 {
 		httpPlainListener = buildListenerOrFail("http_plain", *flagHttpPort)
 	}
+	if *flagHttpPlainProxyPort != 0 {
+		httpPlainProxyListener = h2c.NewListener(
+			buildListenerOrFail("http_plain_proxy", *flagHttpPlainProxyPort),
+			httpPlainBouncerServer.Handler,
+			*flagHttpMaxReadTimeout,
+		)
+	}
+	if *flagHttpRedirectPort != 0 {
+		httpRedirectListener = buildListenerOrFail("http_redirect", *flagHttpRedirectPort)
+	}
 	if *flagHttpTlsPort != 0 {
 		httpTlsListener = buildListenerOrFail("http_tls", *flagHttpTlsPort)
 		http2TlsConfig, err := connhelpers.TlsConfigWithHttp2Enabled(tlsConfig)
@@ -169,6 +293,14 @@ func main() {
 			}
 		}()
 	}
+	if grpcPlainListener != nil {
+		log.Infof("listening for gRPC Plain on: %v", grpcPlainListener.Addr().String())
+		go func() {
+			if err := grpcPlainDirectorServer.Serve(grpcPlainListener); err != nil {
+				errChan <- fmt.Errorf("grpc_plain server error: %v", err)
+			}
+		}()
+	}
 	if httpTlsListener != nil {
 		log.Infof("listening for HTTP TLS on: %v", httpTlsListener.Addr().String())
 		go func() {
@@ -177,6 +309,22 @@ func main() {
 			}
 		}()
 	}
+	if httpPlainProxyListener != nil {
+		log.Infof("listening for HTTP Plain proxy on: %v", httpPlainProxyListener.Addr().String())
+		go func() {
+			if err := httpPlainBouncerServer.Serve(httpPlainProxyListener); err != nil {
+				errChan <- fmt.Errorf("http_plain_proxy server error: %v", err)
+			}
+		}()
+	}
+	if httpRedirectListener != nil {
+		log.Infof("listening for HTTP redirect on: %v", httpRedirectListener.Addr().String())
+		go func() {
+			if err := httpRedirectServer.Serve(httpRedirectListener); err != nil {
+				errChan <- fmt.Errorf("http_redirect server error: %v", err)
+			}
+		}()
+	}
 	if httpPlainListener != nil {
 		log.Infof("listening for HTTP Plain on: %v", httpPlainListener.Addr().String())
 		go func() {
@@ -186,17 +334,32 @@ func main() {
 		}()
 	}
 
+	for _, port := range *flagTcpPorts {
+		tcpListener := buildListenerOrFail(fmt.Sprintf("tcp_%d", port), port)
+		log.Infof("listening for TCP on: %v", tcpListener.Addr().String())
+		go func() {
+			if err := tcpDirector.Serve(tcpListener); err != nil {
+				errChan <- fmt.Errorf("tcp server error: %v", err)
+			}
+		}()
+	}
+
 	err = <-errChan // this waits for some server breaking
 	log.WithError(err).Fatalf("Fail")
 }
 
-// httpsBouncerHandler decides what kind of requests it is and redirects to GRPC if needed.
-func httpsBouncerServer(grpcHandler *grpc.Server, httpHandler http.Handler, logEntry *log.Entry) *http.Server {
+// bouncerServer decides what kind of requests it is and redirects to GRPC (or gRPC-Web) if needed.
+func bouncerServer(grpcHandler *grpc.Server, grpcWebHandler http.Handler, httpHandler http.Handler, logEntry *log.Entry, scheme string) *http.Server {
 	httpBouncerHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		if req.URL.Path == "/_healthz" {
 			healthEndpoint(w, req)
 			return
 		}
+		// gRPC-Web content types share the "application/grpc" prefix, so they need to be checked first.
+		if grpcweb.IsGrpcWebRequest(req) || grpcweb.IsGrpcWebPreflight(req) {
+			grpcWebHandler.ServeHTTP(w, req)
+			return
+		}
 		if strings.HasPrefix(req.Header.Get("content-type"), "application/grpc") {
 			grpcHandler.ServeHTTP(w, req)
 			return
@@ -207,12 +370,12 @@ func httpsBouncerServer(grpcHandler *grpc.Server, httpHandler http.Handler, logE
 	return &http.Server{
 		WriteTimeout: *flagHttpMaxWriteTimeout,
 		ReadTimeout:  *flagHttpMaxReadTimeout,
-		ErrorLog:     http_logrus.AsHttpLogger(logEntry.WithField(ctxtags.TagForScheme, "tls")),
+		ErrorLog:     http_logrus.AsHttpLogger(logEntry.WithField(ctxtags.TagForScheme, scheme)),
 		Handler:      http.HandlerFunc(httpBouncerHandler),
 	}
 }
 
-func debugServer(logEntry *log.Entry, middlewares chi.Middlewares, noAuthMiddlewares chi.Middlewares) (*http.Server, error) {
+func debugServer(logEntry *log.Entry, middlewares chi.Middlewares, noAuthMiddlewares chi.Middlewares, flagzWriteAuth httpwares.Middleware) (*http.Server, error) {
 	m := chi.NewMux()
 	m.Handle("/_healthz", noAuthMiddlewares.HandlerFunc(healthEndpoint))
 	m.Handle("/debug/metrics", noAuthMiddlewares.Handler(prometheus.UninstrumentedHandler()))
@@ -221,7 +384,7 @@ func debugServer(logEntry *log.Entry, middlewares chi.Middlewares, noAuthMiddlew
 		// The only one worth to log.
 		chi.Chain(http_logrus.Middleware(logEntry.WithField(ctxtags.TagForScheme, "plain"), http_logrus.WithLevels(kedgeCodeToLevel))).
 			Handler(middlewares.HandlerFunc(versionEndpoint)))
-	m.Handle("/debug/flagz", middlewares.HandlerFunc(flagz.NewStatusEndpoint(sharedflags.Set).ListFlags))
+	m.Handle("/debug/flagz", middlewares.HandlerFunc(sharedflags.FlagzEndpoint(flagzWriteAuth, loglevel.Flags...)))
 
 	m.Handle("/debug/pprof/", middlewares.HandlerFunc(pprof.Index))
 	m.Handle("/debug/pprof/cmdline", middlewares.HandlerFunc(pprof.Cmdline))
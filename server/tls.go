@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 
 	"github.com/mwitkow/go-conntrack/connhelpers"
+	"github.com/mwitkow/kedge/lib/acme"
+	"github.com/mwitkow/kedge/lib/discovery"
 	"github.com/mwitkow/kedge/lib/sharedflags"
 )
 
@@ -29,13 +31,19 @@ var (
 			"If true, connections that are not certified by client CA will be rejected.")
 )
 
-func buildTLSConfigFromFlags() (*tls.Config, error) {
+func buildTLSConfigFromFlags(acmeManager *acmecert.Manager, ingressCerts *discovery.Certificates) (*tls.Config, error) {
 	tlsConfig, err := connhelpers.TlsConfigForServerCerts(*flagTLSServerCert, *flagTLSServerKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed reading TLS server keys. Err: %v", err)
 	}
 	tlsConfig.MinVersion = tls.VersionTLS12
 	tlsConfig.ClientAuth = tls.NoClientCert
+	// SNI selection: ACME certificates for ACME hosts, Ingress certificates for Ingress TLS hosts, server_tls_cert_file
+	// for everything else.
+	tlsConfig.GetCertificate = ingressCerts.GetCertificate(tlsConfig.GetCertificate)
+	if acmeManager != nil {
+		tlsConfig.GetCertificate = acmeManager.GetCertificate(tlsConfig.GetCertificate)
+	}
 
 	err = addClientCertIfNeeded(tlsConfig)
 	if err != nil {
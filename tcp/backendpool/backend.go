@@ -0,0 +1,185 @@
+package backendpool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mwitkow/go-conntrack"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/backends"
+	"github.com/mwitkow/kedge/http/lbtransport"
+	"github.com/mwitkow/kedge/lib/resolvers/k8s"
+	"github.com/mwitkow/kedge/lib/resolvers/srv"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/naming"
+)
+
+var (
+	// ParentDialFunc is the top DialContext func with decreased Dial Timeout in comparison to DefaultDialer.
+	ParentDialFunc = (&net.Dialer{
+		Timeout:   1 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+
+	failedDialsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "tcp_backendpool",
+			Name:      "failed_dials",
+			Help:      "Total number of failed dials that are in resolver and should blacklist the target.",
+		},
+		[]string{"backend", "target"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(failedDialsCounter)
+}
+
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+type backend struct {
+	mu sync.RWMutex
+
+	target   string
+	watcher  naming.Watcher
+	policy   lbtransport.LBPolicy
+	dialFunc dialContextFunc
+	config   *pb.Backend
+	closed   bool
+
+	currentTargets   []*lbtransport.Target
+	lastResolveError error
+}
+
+// newBackend creates backend from given configuration.
+// The targets are resolved using the same resolvers as HTTP backends and balanced using the same LB policies.
+func newBackend(cnf *pb.Backend) (*backend, error) {
+	target, resolver, err := chooseNamingResolver(cnf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to construct resolver for backend %s", cnf.Name)
+	}
+	dialFunc := ParentDialFunc
+	if !cnf.DisableConntracking {
+		dialFunc = conntrack.NewDialContextFunc(
+			conntrack.DialWithName("tcp_backend_"+cnf.Name),
+			conntrack.DialWithDialContextFunc(dialFunc),
+			conntrack.DialWithTracing(),
+		)
+	}
+	watcher, err := resolver.Resolve(target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve target %s for backend %s", target, cnf.Name)
+	}
+	b := &backend{
+		target:         target,
+		watcher:        watcher,
+		policy:         chooseBalancerPolicy(cnf),
+		dialFunc:       dialFunc,
+		config:         cnf,
+		currentTargets: []*lbtransport.Target{},
+	}
+	go b.run()
+	return b, nil
+}
+
+func (b *backend) run() {
+	for {
+		updates, err := b.watcher.Next() // blocking call until new updates are there
+		if err != nil {
+			b.mu.Lock()
+			b.currentTargets = []*lbtransport.Target{}
+			b.lastResolveError = err
+			b.mu.Unlock()
+			return // watcher.Next errors are irrecoverable.
+		}
+		b.mu.RLock()
+		targets := b.currentTargets
+		b.mu.RUnlock()
+		for _, u := range updates {
+			if u.Op == naming.Add {
+				targets = append(targets, &lbtransport.Target{DialAddr: u.Addr})
+			} else if u.Op == naming.Delete {
+				kept := []*lbtransport.Target{}
+				for _, t := range targets {
+					if u.Addr != t.DialAddr {
+						kept = append(kept, t)
+					}
+				}
+				targets = kept
+			}
+		}
+		b.mu.Lock()
+		b.currentTargets = targets
+		b.mu.Unlock()
+	}
+}
+
+// Dial picks a target using the backend's balancing policy and connects to it. Targets that fail to dial are
+// excluded (blacklisted) and the next one is tried.
+func (b *backend) Dial(ctx context.Context) (net.Conn, error) {
+	b.mu.RLock()
+	closed := b.closed
+	targetsRef := b.currentTargets
+	lastResolvErr := b.lastResolveError
+	b.mu.RUnlock()
+	if closed {
+		return nil, errors.New("backend closed")
+	}
+	if len(targetsRef) == 0 {
+		return nil, errors.Wrapf(lastResolvErr, "lb: no resolution available for %s", b.target)
+	}
+
+	picker := b.policy.Picker()
+	for {
+		// TCP connections carry no request, policies must not depend on it.
+		target, err := picker.Pick(nil, targetsRef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "lb: failed choosing valid target for %s", b.target)
+		}
+		conn, err := b.dialFunc(ctx, "tcp", target.DialAddr)
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, errors.Wrapf(err, "lb: failed dialing %s", target.DialAddr)
+		}
+		failedDialsCounter.WithLabelValues(b.config.Name, target.DialAddr).Inc()
+		picker.ExcludeTarget(target)
+	}
+}
+
+// Close is used when backend is removed from configuration dynamically.
+func (b *backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.watcher != nil {
+		b.watcher.Close()
+	}
+	return nil
+}
+
+func chooseNamingResolver(cnf *pb.Backend) (string, naming.Resolver, error) {
+	if s := cnf.GetSrv(); s != nil {
+		return srvresolver.NewFromConfig(s)
+	} else if k := cnf.GetK8S(); k != nil {
+		return k8sresolver.NewFromConfig(k)
+	}
+	return "", nil, fmt.Errorf("unspecified naming resolver for %v", cnf.Name)
+}
+
+func chooseBalancerPolicy(cnf *pb.Backend) lbtransport.LBPolicy {
+	switch cnf.GetBalancer() {
+	case pb.Balancer_ROUND_ROBIN:
+		return lbtransport.RoundRobinPolicyFromFlags()
+	default:
+		return lbtransport.RoundRobinPolicyFromFlags()
+	}
+}
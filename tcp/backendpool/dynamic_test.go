@@ -0,0 +1,62 @@
+package backendpool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/backends"
+	"github.com/mwitkow/kedge/http/lbtransport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamic_Operations(t *testing.T) {
+	d := NewDynamic()
+	d.backendFactory = func(config *pb.Backend) (*backend, error) {
+		return &backend{config: config}, nil
+	}
+	assert.Len(t, d.Configs(), 0, "at first there needs to be nothing")
+	assert.NoError(t, d.AddOrUpdate(&pb.Backend{Name: "foobar", DisableConntracking: true}))
+	assert.NoError(t, d.AddOrUpdate(&pb.Backend{Name: "carbar", DisableConntracking: true}))
+	assert.Len(t, d.Configs(), 2, "we should have two")
+	oldCarBar := d.backends["carbar"]
+	assert.NoError(t, d.AddOrUpdate(&pb.Backend{Name: "carbar"}), "updating carbar shouldn't fail")
+	assert.True(t, oldCarBar.closed, "oldCarBar should enter closed state")
+	assert.Len(t, d.Configs(), 2, "we should still two")
+	assert.Error(t, d.Remove("nonexisting"), "removing a non existing backend should return error")
+	assert.NoError(t, d.Remove("foobar"), "removing a non existing backend should return error")
+	assert.Len(t, d.Configs(), 1, "we now should have two")
+	_, err := d.Dial(context.Background(), "foobar")
+	assert.Equal(t, ErrUnknownBackend, err)
+}
+
+func TestBackend_Dial_SkipsFailingTargets(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// Grab a free port and release it, so nothing listens there.
+	deadListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := deadListener.Addr().String()
+	deadListener.Close()
+
+	b := &backend{
+		target:   "test",
+		policy:   lbtransport.RoundRobinPolicy(time.Minute, 0),
+		dialFunc: ParentDialFunc,
+		config:   &pb.Backend{Name: "test"},
+		currentTargets: []*lbtransport.Target{
+			{DialAddr: deadAddr},
+			{DialAddr: listener.Addr().String()},
+		},
+	}
+	for i := 0; i < 4; i++ {
+		conn, err := b.Dial(context.Background())
+		require.NoError(t, err, "dial %d should succeed on the healthy target", i)
+		assert.Equal(t, listener.Addr().String(), conn.RemoteAddr().String())
+		conn.Close()
+	}
+}
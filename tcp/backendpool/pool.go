@@ -0,0 +1,16 @@
+package backendpool
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var (
+	ErrUnknownBackend = errors.New("unknown backend")
+)
+
+type Pool interface {
+	// Dial connects to one of the load balanced targets of the given backend.
+	Dial(ctx context.Context, backendName string) (net.Conn, error)
+}
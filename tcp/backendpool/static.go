@@ -0,0 +1,35 @@
+package backendpool
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/backends"
+)
+
+// static is a Pool with a static configuration.
+type static struct {
+	backends map[string]*backend
+}
+
+// NewStatic creates a backend pool that has static configuration.
+func NewStatic(backends []*pb.Backend) (*static, error) {
+	s := &static{backends: make(map[string]*backend)}
+	for _, beCnf := range backends {
+		be, err := newBackend(beCnf)
+		if err != nil {
+			return nil, fmt.Errorf("failed creating backend '%v': %v", beCnf.Name, err)
+		}
+		s.backends[beCnf.Name] = be
+	}
+	return s, nil
+}
+
+func (s *static) Dial(ctx context.Context, backendName string) (net.Conn, error) {
+	be, ok := s.backends[backendName]
+	if !ok {
+		return nil, ErrUnknownBackend
+	}
+	return be.Dial(ctx)
+}
@@ -0,0 +1,173 @@
+package director
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/mwitkow/kedge/lib/http/tunnel"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/mwitkow/kedge/tcp/backendpool"
+	"github.com/mwitkow/kedge/tcp/director/router"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	flagPeekTimeout = sharedflags.Set.Duration("tcp_sni_peek_timeout", 5*time.Second,
+		"Maximum time to wait for the TLS ClientHello on listeners with SNI routes. Connections that stay silent are "+
+			"routed without SNI.")
+	flagBackendDialTimeout = sharedflags.Set.Duration("tcp_backend_dial_timeout", 5*time.Second,
+		"Maximum time to connect to a TCP backend, including retries of failing targets.")
+	flagIdleTimeout = sharedflags.Set.Duration("tcp_idle_timeout", 1*time.Hour,
+		"Maximum time a proxied TCP connection can stay without any traffic in either direction. "+
+			"If 0, connections never time out.")
+
+	connsStarted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "tcp_proxy",
+			Name:      "conns_started_total",
+			Help:      "Total number of TCP connections proxied to backends.",
+		},
+		[]string{"backend"},
+	)
+	connsActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "kedge",
+			Subsystem: "tcp_proxy",
+			Name:      "conns_active",
+			Help:      "Number of currently proxied TCP connections.",
+		},
+		[]string{"backend"},
+	)
+	connBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "tcp_proxy",
+			Name:      "bytes_total",
+			Help:      "Total number of bytes passed through proxied TCP connections.",
+		},
+		[]string{"backend", "direction"},
+	)
+	connsFailed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "kedge",
+			Subsystem: "tcp_proxy",
+			Name:      "failed_total",
+			Help:      "Total number of TCP connections that could not be proxied to a backend.",
+		},
+		[]string{"backend", "reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(connsStarted)
+	prometheus.MustRegister(connsActive)
+	prometheus.MustRegister(connBytes)
+	prometheus.MustRegister(connsFailed)
+}
+
+// New creates a TCP (L4) proxy that forwards connections from TCP listeners to backends, as decided by the router.
+func New(pool backendpool.Pool, router router.Router, logEntry *logrus.Entry) *Proxy {
+	return &Proxy{pool: pool, router: router, logEntry: logEntry}
+}
+
+// Proxy is a TCP proxy that never terminates the connections. TLS connections can be routed by the SNI of the peeked
+// ClientHello, all connections can be routed by the port of the listener that accepted them.
+type Proxy struct {
+	pool     backendpool.Pool
+	router   router.Router
+	logEntry *logrus.Entry
+}
+
+// Serve accepts connections on the listener and proxies each of them in a separate goroutine.
+// It returns only when the listener fails (e.g. is closed).
+func (p *Proxy) Serve(listener net.Listener) error {
+	port := 0
+	if tcpAddr, ok := listener.Addr().(*net.TCPAddr); ok {
+		port = tcpAddr.Port
+	}
+	var tempDelay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				// Same backoff as in http.Server.
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if tempDelay > time.Second {
+					tempDelay = time.Second
+				}
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+		go p.ServeConn(conn, port)
+	}
+}
+
+// ServeConn routes the connection accepted on the given listener port and splices it with a backend connection.
+// The connection is closed on return.
+func (p *Proxy) ServeConn(conn net.Conn, port int) {
+	logger := p.logEntry.WithFields(logrus.Fields{
+		"tcp.port":     port,
+		"peer.address": conn.RemoteAddr().String(),
+	})
+
+	sni := ""
+	var peeked []byte
+	if p.router.MatchesOnSNI(port) {
+		var err error
+		sni, peeked, err = peekSNI(conn, *flagPeekTimeout)
+		if err != nil {
+			conn.Close()
+			connsFailed.WithLabelValues("", "peek").Inc()
+			logger.WithError(err).Debug("failed peeking TLS ClientHello")
+			return
+		}
+		logger = logger.WithField("tls.sni", sni)
+	}
+
+	backendName, err := p.router.Route(port, sni)
+	if err != nil {
+		conn.Close()
+		connsFailed.WithLabelValues("", "route").Inc()
+		logger.WithError(err).Warn("failed routing TCP connection")
+		return
+	}
+	logger = logger.WithField("tcp.backend", backendName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *flagBackendDialTimeout)
+	backendConn, err := p.pool.Dial(ctx, backendName)
+	cancel()
+	if err != nil {
+		conn.Close()
+		connsFailed.WithLabelValues(backendName, "dial").Inc()
+		logger.WithError(err).Error("failed dialing TCP backend")
+		return
+	}
+
+	connsStarted.WithLabelValues(backendName).Inc()
+	connsActive.WithLabelValues(backendName).Inc()
+	defer connsActive.WithLabelValues(backendName).Dec()
+
+	startTime := time.Now()
+	// Bytes peeked for SNI were never seen by the backend.
+	clientConn := tunnel.WithIdleTimeout(conn, io.MultiReader(bytes.NewReader(peeked), conn), *flagIdleTimeout)
+	inbound, outbound := tunnel.Splice(clientConn, tunnel.WithIdleTimeout(backendConn, nil, *flagIdleTimeout))
+	connBytes.WithLabelValues(backendName, "inbound").Add(float64(inbound))
+	connBytes.WithLabelValues(backendName, "outbound").Add(float64(outbound))
+	logger.WithFields(logrus.Fields{
+		"tcp.bytes_inbound":  inbound,
+		"tcp.bytes_outbound": outbound,
+		"tcp.time_ms":        time.Since(startTime).Seconds() * 1000,
+	}).Debug("finished proxying TCP connection")
+}
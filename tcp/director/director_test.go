@@ -0,0 +1,122 @@
+package director
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/routes"
+	"github.com/mwitkow/kedge/tcp/backendpool"
+	"github.com/mwitkow/kedge/tcp/director/router"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPool map[string]string
+
+func (p testPool) Dial(ctx context.Context, backendName string) (net.Conn, error) {
+	addr, ok := p[backendName]
+	if !ok {
+		return nil, backendpool.ErrUnknownBackend
+	}
+	return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+}
+
+func startEchoTCPServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+func startTestProxy(t *testing.T, pool backendpool.Pool, routes []*pb.Route) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := New(pool, router.NewStatic(routes), logrus.NewEntry(logrus.New()))
+	go p.Serve(listener)
+	return listener
+}
+
+func TestProxy_RoutesTLSBySNI_WithPassthrough(t *testing.T) {
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("x-sni", req.TLS.ServerName)
+		resp.WriteHeader(http.StatusTeapot)
+	}))
+	defer tlsBackend.Close()
+	echo := startEchoTCPServer(t)
+	defer echo.Close()
+
+	pool := testPool{
+		"tls_backend": tlsBackend.Listener.Addr().String(),
+		"echo":        echo.Addr().String(),
+	}
+	proxy := startTestProxy(t, pool, []*pb.Route{
+		{BackendName: "tls_backend", SniMatcher: "db-a.example.com"},
+		{BackendName: "echo"},
+	})
+	defer proxy.Close()
+
+	conn, err := tls.Dial("tcp", proxy.Addr().String(), &tls.Config{ServerName: "db-a.example.com", InsecureSkipVerify: true})
+	require.NoError(t, err, "TLS handshake should be passed through to the backend")
+	defer conn.Close()
+	req, err := http.NewRequest("GET", "https://db-a.example.com/", nil)
+	require.NoError(t, err)
+	require.NoError(t, req.Write(conn))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	assert.Equal(t, "db-a.example.com", resp.Header.Get("x-sni"))
+}
+
+func TestProxy_RoutesNonTLSByPort(t *testing.T) {
+	echo := startEchoTCPServer(t)
+	defer echo.Close()
+
+	proxy := startTestProxy(t, testPool{"echo": echo.Addr().String()}, []*pb.Route{
+		{BackendName: "tls_backend", SniMatcher: "db-a.example.com"},
+		{BackendName: "echo"},
+	})
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	for _, msg := range []string{"PING\r\n", "some more bytes"} {
+		_, err = io.WriteString(conn, msg)
+		require.NoError(t, err)
+		got := make([]byte, len(msg))
+		_, err = io.ReadFull(conn, got)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(got), "peeked bytes should be replayed to the backend")
+	}
+}
+
+func TestProxy_ClosesUnroutedConnections(t *testing.T) {
+	proxy := startTestProxy(t, testPool{}, []*pb.Route{
+		{BackendName: "echo", PortMatcher: 1},
+	})
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/routes"
+)
+
+var (
+	ErrRouteNotFound = errors.New("unknown route to service")
+)
+
+// Router decides what backend a given TCP connection should be directed to.
+type Router interface {
+	// Route returns a backend name for a connection accepted on the given listener port, or an error.
+	// The sni is the server name from the TLS ClientHello, empty if the connection is not TLS or was not peeked.
+	Route(port int, sni string) (backendName string, err error)
+
+	// MatchesOnSNI returns true if any of the routes for the given listener port needs the SNI. Only then the
+	// ClientHello is peeked, as it requires waiting for the client to speak first.
+	MatchesOnSNI(port int) bool
+}
+
+type dynamic struct {
+	mu           sync.RWMutex
+	staticRouter *static
+}
+
+// NewDynamic creates a new dynamic router that can be have its routes updated.
+func NewDynamic() *dynamic {
+	return &dynamic{staticRouter: NewStatic([]*pb.Route{})}
+}
+
+func (d *dynamic) Route(port int, sni string) (backendName string, err error) {
+	d.mu.RLock()
+	staticRouter := d.staticRouter
+	d.mu.RUnlock()
+	return staticRouter.Route(port, sni)
+}
+
+func (d *dynamic) MatchesOnSNI(port int) bool {
+	d.mu.RLock()
+	staticRouter := d.staticRouter
+	d.mu.RUnlock()
+	return staticRouter.MatchesOnSNI(port)
+}
+
+// Update sets the routing table to the provided set of routes.
+func (d *dynamic) Update(routes []*pb.Route) {
+	staticRouter := NewStatic(routes)
+	d.mu.Lock()
+	d.staticRouter = staticRouter
+	d.mu.Unlock()
+}
+
+type static struct {
+	routes []*pb.Route
+}
+
+func NewStatic(routes []*pb.Route) *static {
+	return &static{routes: routes}
+}
+
+func (r *static) Route(port int, sni string) (backendName string, err error) {
+	for _, route := range r.routes {
+		if !r.portMatches(port, route.PortMatcher) {
+			continue
+		}
+		if !r.sniMatches(sni, route.SniMatcher) {
+			continue
+		}
+		return route.BackendName, nil
+	}
+	return "", ErrRouteNotFound
+}
+
+func (r *static) MatchesOnSNI(port int) bool {
+	for _, route := range r.routes {
+		if r.portMatches(port, route.PortMatcher) && route.SniMatcher != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *static) portMatches(port int, matcher uint32) bool {
+	if matcher == 0 {
+		return true // no matcher set, match all like a boss!
+	}
+	return port == int(matcher)
+}
+
+func (r *static) sniMatches(sni string, matcher string) bool {
+	if matcher == "" {
+		return true
+	}
+	if sni == "" {
+		return false // we expect certain server name.
+	}
+	sni = strings.ToLower(sni)
+	matcher = strings.ToLower(matcher)
+	if strings.HasPrefix(matcher, "*.") {
+		return strings.HasSuffix(sni, matcher[1:])
+	}
+	return sni == matcher
+}
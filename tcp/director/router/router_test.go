@@ -0,0 +1,101 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	pb "github.com/mwitkow/kedge/_protogen/kedge/config"
+	pb_routes "github.com/mwitkow/kedge/_protogen/kedge/config/tcp/routes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteMatches(t *testing.T) {
+	configJson := `
+{ "routes": [
+	{
+		"backendName": "backend_postgres",
+		"portMatcher": 5432
+	},
+	{
+		"backendName": "backend_db_a",
+		"sniMatcher": "db-a.example.com",
+		"portMatcher": 9443
+	},
+	{
+		"backendName": "backend_wildcard",
+		"sniMatcher": "*.internal.example.com"
+	},
+	{
+		"backendName": "backend_catch_all_tls",
+		"portMatcher": 9443
+	}
+]}`
+	config := &pb.DirectorConfig_Tcp{}
+	require.NoError(t, jsonpb.UnmarshalString(configJson, config))
+	r := NewStatic(config.Routes)
+
+	for _, tcase := range []struct {
+		name            string
+		port            int
+		sni             string
+		expectedBackend string
+		expectedErr     error
+	}{
+		{
+			name:            "MatchesOnPortOnly",
+			port:            5432,
+			expectedBackend: "backend_postgres",
+		},
+		{
+			name:            "MatchesOnPortAndSNI",
+			port:            9443,
+			sni:             "DB-A.example.com",
+			expectedBackend: "backend_db_a",
+		},
+		{
+			name:            "MatchesWildcardSNIOnAnyPort",
+			port:            6379,
+			sni:             "redis.internal.example.com",
+			expectedBackend: "backend_wildcard",
+		},
+		{
+			name:            "FallsBackToPortWhenSNIDoesNotMatch",
+			port:            9443,
+			sni:             "db-b.example.com",
+			expectedBackend: "backend_catch_all_tls",
+		},
+		{
+			name:        "NoMatchForNonTLSOnUnknownPort",
+			port:        6379,
+			expectedErr: ErrRouteNotFound,
+		},
+		{
+			name:        "WildcardDoesNotMatchParentDomain",
+			port:        6379,
+			sni:         "internal.example.com",
+			expectedErr: ErrRouteNotFound,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			backendName, err := r.Route(tcase.port, tcase.sni)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tcase.expectedBackend, backendName)
+		})
+	}
+
+}
+
+func TestMatchesOnSNI(t *testing.T) {
+	r := NewStatic([]*pb_routes.Route{
+		{BackendName: "backend_postgres", PortMatcher: 5432},
+		{BackendName: "backend_db_a", SniMatcher: "db-a.example.com", PortMatcher: 9443},
+	})
+	assert.False(t, r.MatchesOnSNI(5432), "only port routes for 5432, no need to wait for ClientHello")
+	assert.True(t, r.MatchesOnSNI(9443))
+	assert.False(t, r.MatchesOnSNI(6379))
+}
@@ -0,0 +1,60 @@
+package director
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var errClientHelloPeeked = errors.New("tcp: client hello peeked")
+
+// peekSNI reads the TLS ClientHello from the connection and returns the server name the client asked for, together
+// with all the bytes read so far. These need to be replayed to the backend, as TLS is not terminated by us.
+//
+// Connections that are not TLS, or that stay silent for peekTimeout (e.g. server-speaks-first protocols), return an
+// empty server name and no error.
+func peekSNI(conn net.Conn, peekTimeout time.Duration) (sni string, peeked []byte, err error) {
+	if peekTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(peekTimeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+
+	buf := &bytes.Buffer{}
+	var hello *tls.ClientHelloInfo
+	handshakeErr := tls.Server(&recordingConn{Conn: conn, reader: io.TeeReader(conn, buf)}, &tls.Config{
+		GetConfigForClient: func(h *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = h
+			return nil, errClientHelloPeeked
+		},
+	}).Handshake()
+	if hello != nil {
+		return strings.ToLower(hello.ServerName), buf.Bytes(), nil
+	}
+	if buf.Len() == 0 {
+		if netErr, ok := handshakeErr.(net.Error); ok && netErr.Timeout() {
+			return "", nil, nil
+		}
+		return "", nil, handshakeErr
+	}
+	// Not TLS, route without SNI.
+	return "", buf.Bytes(), nil
+}
+
+// recordingConn is a read-only view of the connection used for peeking. Writes (e.g. TLS alerts) are dropped, so the
+// client never notices the peek.
+type recordingConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
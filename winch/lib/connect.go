@@ -0,0 +1,180 @@
+package winch
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mwitkow/go-httpwares/tags"
+	"github.com/mwitkow/kedge/lib/http/ctxtags"
+	"github.com/mwitkow/kedge/lib/http/tripperware"
+	"github.com/mwitkow/kedge/lib/http/tunnel"
+	"github.com/mwitkow/kedge/lib/map"
+	"github.com/mwitkow/kedge/lib/requestid"
+	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	flagConnectIdleTimeout = sharedflags.Set.Duration("winch_connect_idle_timeout", 5*time.Minute,
+		"Maximum time a CONNECT tunnel can stay without any traffic in either direction. If 0, tunnels never time out.")
+	flagConnectDialTimeout = sharedflags.Set.Duration("winch_connect_dial_timeout", 10*time.Second,
+		"Timeout for dialing and establishing a CONNECT tunnel to kedge or the direct destination.")
+)
+
+// serveConnect tunnels CONNECT requests. Kedge destinations are tunneled through the kedge from the matching route,
+// using CONNECT on kedge with the route's proxy auth. Other destinations are dialed directly.
+//
+// NOTE: Backend auth cannot be injected, since the bytes in the tunnel are opaque (usually TLS) for winch.
+func (p *Proxy) serveConnect(resp http.ResponseWriter, req *http.Request) {
+	tags := http_ctxtags.ExtractInbound(req)
+	tags.Set(http_ctxtags.TagForCallService, "winch")
+
+	ctx, cancel := context.WithTimeout(req.Context(), *flagConnectDialTimeout)
+	defer cancel()
+
+	var destConn net.Conn
+	route, err := p.mapper.Map(req.URL.Hostname(), req.URL.Port())
+	if err == kedge_map.ErrNotKedgeDestination {
+		tags.Set(ctxtags.TagForProxyDestURL, "not-kedge-destination")
+		destConn, err = (&net.Dialer{}).DialContext(ctx, "tcp", req.URL.Host)
+	} else if err == nil {
+		tags.Set(ctxtags.TagForProxyDestURL, route.URL)
+		tags.Set(http_ctxtags.TagForHandlerName, route.URL)
+		if route.ProxyAuth != nil {
+			tags.Set(ctxtags.TagForProxyAuth, route.ProxyAuth.Name())
+		}
+		destConn, err = p.dialKedgeTunnel(ctx, req.URL.Host, route, requestid.FromRequest(req))
+	}
+	if err != nil {
+		respondWithConnectError(err, req, resp)
+		return
+	}
+
+	clientConn, buf, err := tunnel.Hijack(resp, *flagConnectIdleTimeout)
+	if err != nil {
+		destConn.Close()
+		respondWithConnectError(err, req, resp)
+		return
+	}
+	if _, err := buf.WriteString("HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		clientConn.Close()
+		destConn.Close()
+		return
+	}
+	if err := buf.Flush(); err != nil {
+		clientConn.Close()
+		destConn.Close()
+		return
+	}
+
+	tunnel.Splice(clientConn, tunnel.WithIdleTimeout(destConn, nil, *flagConnectIdleTimeout))
+}
+
+// dialKedgeTunnel dials the kedge from the route and asks it to CONNECT to the hostPort.
+func (p *Proxy) dialKedgeTunnel(ctx context.Context, hostPort string, route *kedge_map.Route, requestID string) (net.Conn, error) {
+	kedgeAddr := route.URL.Host
+	if route.URL.Port() == "" {
+		if route.URL.Scheme == "https" {
+			kedgeAddr = net.JoinHostPort(route.URL.Hostname(), "443")
+		} else {
+			kedgeAddr = net.JoinHostPort(route.URL.Hostname(), "80")
+		}
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", kedgeAddr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial kedge %s", kedgeAddr)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if route.URL.Scheme == "https" {
+		tlsConfig := &tls.Config{}
+		if p.tlsConfig != nil {
+			tlsConfig = p.tlsConfig.Clone()
+		}
+		tlsConfig.ServerName = route.URL.Hostname()
+		// CONNECT needs HTTP/1.1 to hijack the connection on kedge side.
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, errors.Wrapf(err, "failed TLS handshake with kedge %s", kedgeAddr)
+		}
+		conn = tlsConn
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: hostPort},
+		Host:   hostPort,
+		Header: http.Header{},
+	}
+	if requestID != "" {
+		connectReq.Header.Set(requestid.HeaderName, requestID)
+	}
+	if route.ProxyAuth != nil {
+		token, err := route.ProxyAuth.Token(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, errors.Wrapf(err, "failed to get proxy auth token from %s", route.ProxyAuth.Name())
+		}
+		connectReq.Header.Set(tripperware.ProxyAuthHeader, fmt.Sprintf("Bearer %s", token))
+	}
+
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to send CONNECT to kedge")
+	}
+	reader := bufio.NewReader(conn)
+	connectResp, err := http.ReadResponse(reader, connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to read CONNECT response from kedge")
+	}
+	if connectResp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &kedgeConnectError{status: connectResp.StatusCode, kedgeErr: connectResp.Header.Get("x-kedge-error")}
+	}
+	conn.SetDeadline(time.Time{})
+	// Kedge may have already sent some bytes from the destination.
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+type kedgeConnectError struct {
+	status   int
+	kedgeErr string
+}
+
+func (e *kedgeConnectError) Error() string {
+	return fmt.Sprintf("kedge refused CONNECT with status %d: %s", e.status, e.kedgeErr)
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func respondWithConnectError(err error, req *http.Request, resp http.ResponseWriter) {
+	status := http.StatusBadGateway
+	if kErr, ok := err.(*kedgeConnectError); ok {
+		status = kErr.status
+	}
+	http_ctxtags.ExtractInbound(req).Set(logrus.ErrorKey, err)
+	resp.Header().Set("x-winch-error", err.Error())
+	resp.Header().Set("content-type", "text/plain")
+	resp.WriteHeader(status)
+	fmt.Fprintf(resp, "%v", err.Error())
+}
@@ -0,0 +1,186 @@
+package winch
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/mwitkow/kedge/lib/http/tripperware"
+	"github.com/mwitkow/kedge/lib/map"
+	"github.com/mwitkow/kedge/lib/requestid"
+	"github.com/mwitkow/kedge/lib/tokenauth/sources/direct"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMapper map[string]*kedge_map.Route
+
+func (m testMapper) Map(host string, port string) (*kedge_map.Route, error) {
+	route, ok := m[host]
+	if !ok {
+		return nil, kedge_map.ErrNotKedgeDestination
+	}
+	return route, nil
+}
+
+func startEchoTCPServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+// fakeKedge accepts CONNECT requests and tunnels them to the echo server, or refuses them if refuse is set.
+type fakeKedge struct {
+	echoAddr string
+	refuse   bool
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (k *fakeKedge) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	k.mu.Lock()
+	k.requests = append(k.requests, req)
+	k.mu.Unlock()
+
+	if req.Method != http.MethodConnect {
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if k.refuse {
+		resp.Header().Set("x-kedge-error", "unknown route to service")
+		resp.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	destConn, err := net.Dial("tcp", k.echoAddr)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer destConn.Close()
+	clientConn, buf, err := resp.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer clientConn.Close()
+	buf.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+	buf.Flush()
+	go io.Copy(destConn, buf)
+	io.Copy(clientConn, destConn)
+}
+
+func (k *fakeKedge) lastRequest() *http.Request {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.requests) == 0 {
+		return nil
+	}
+	return k.requests[len(k.requests)-1]
+}
+
+func connectThroughWinch(t *testing.T, winchAddr string, hostPort string, header string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", winchAddr)
+	require.NoError(t, err)
+	_, err = io.WriteString(conn, "CONNECT "+hostPort+" HTTP/1.1\r\nHost: "+hostPort+"\r\n"+header+"\r\n")
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+	return conn, reader, resp
+}
+
+func assertEchoes(t *testing.T, conn net.Conn, reader *bufio.Reader) {
+	for _, msg := range []string{"fake client hello", "some more bytes"} {
+		_, err := io.WriteString(conn, msg)
+		require.NoError(t, err)
+		got := make([]byte, len(msg))
+		_, err = io.ReadFull(reader, got)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(got))
+	}
+}
+
+func TestConnect_TunnelsThroughKedge(t *testing.T) {
+	echo := startEchoTCPServer(t)
+	defer echo.Close()
+	kedge := &fakeKedge{echoAddr: echo.Addr().String()}
+	kedgeServer := httptest.NewTLSServer(kedge)
+	defer kedgeServer.Close()
+
+	kedgeURL, err := url.Parse(kedgeServer.URL)
+	require.NoError(t, err)
+	mapper := testMapper{
+		"backend.ext.example.com": {URL: kedgeURL, ProxyAuth: directauth.New("proxy-access", "proxy-token")},
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(kedgeServer.Certificate())
+	winchServer := httptest.NewServer(New(mapper, &tls.Config{RootCAs: roots}, logrus.NewEntry(logrus.New())))
+	defer winchServer.Close()
+
+	conn, reader, resp := connectThroughWinch(t, winchServer.Listener.Addr().String(), "backend.ext.example.com:443",
+		requestid.HeaderName+": some-request-id\r\n")
+	defer conn.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assertEchoes(t, conn, reader)
+
+	req := kedge.lastRequest()
+	require.NotNil(t, req)
+	assert.Equal(t, "backend.ext.example.com:443", req.Host)
+	assert.Equal(t, "Bearer proxy-token", req.Header.Get(tripperware.ProxyAuthHeader), "proxy auth from route should be injected")
+	assert.Equal(t, "some-request-id", req.Header.Get(requestid.HeaderName), "request ID should be propagated")
+}
+
+func TestConnect_KedgeRefusal_IsReturned(t *testing.T) {
+	kedge := &fakeKedge{refuse: true}
+	kedgeServer := httptest.NewServer(kedge)
+	defer kedgeServer.Close()
+
+	kedgeURL, err := url.Parse(kedgeServer.URL)
+	require.NoError(t, err)
+	winchServer := httptest.NewServer(New(testMapper{"backend.ext.example.com": {URL: kedgeURL}}, nil, logrus.NewEntry(logrus.New())))
+	defer winchServer.Close()
+
+	conn, _, resp := connectThroughWinch(t, winchServer.Listener.Addr().String(), "backend.ext.example.com:443", "")
+	defer conn.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("x-winch-error"), "unknown route to service")
+}
+
+func TestConnect_NotKedgeDestination_IsDialedDirectly(t *testing.T) {
+	echo := startEchoTCPServer(t)
+	defer echo.Close()
+	kedge := &fakeKedge{}
+	kedgeServer := httptest.NewServer(kedge)
+	defer kedgeServer.Close()
+
+	kedgeURL, err := url.Parse(kedgeServer.URL)
+	require.NoError(t, err)
+	winchServer := httptest.NewServer(New(testMapper{"backend.ext.example.com": {URL: kedgeURL}}, nil, logrus.NewEntry(logrus.New())))
+	defer winchServer.Close()
+
+	conn, reader, resp := connectThroughWinch(t, winchServer.Listener.Addr().String(), echo.Addr().String(), "")
+	defer conn.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assertEchoes(t, conn, reader)
+	assert.Nil(t, kedge.lastRequest(), "kedge should not be used for not kedge destinations")
+}
@@ -54,11 +54,10 @@ var (
 	// no proxy for local hosts without domain:
 	if(isPlainHostName(host)) return direct;
 
-	// We only proxy http, not even https.
+	// We only proxy http and https (using CONNECT tunnels).
 	if (
 		url.substring(0, 4) == "ftp:" ||
-		url.substring(0, 6) == "rsync:" ||
-		url.substring(0, 6) == "https:"
+		url.substring(0, 6) == "rsync:"
 	)
 		return direct;
 
@@ -0,0 +1,26 @@
+package winch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePAC_ProxiesHttps(t *testing.T) {
+	pac, err := generatePAC("127.0.0.1:8070", nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(pac), `var proxy = "PROXY 127.0.0.1:8070; DIRECT";`)
+	assert.NotContains(t, string(pac), `"https:"`, "https should go through winch (CONNECT), not DIRECT")
+	assert.Contains(t, string(pac), `url.substring(0, 4) == "ftp:"`)
+	assert.Contains(t, string(pac), "return proxy;\n}")
+}
+
+func TestGeneratePAC_Routes(t *testing.T) {
+	pac, err := generatePAC("127.0.0.1:8070", []string{"*.ext.example.com", "*.internal.example.com"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(pac), `"https:"`)
+	assert.Contains(t, string(pac), `if (shExpMatch(host, "*.ext.example.com")) {`)
+	assert.Contains(t, string(pac), `if (shExpMatch(host, "*.internal.example.com")) {`)
+	assert.Contains(t, string(pac), "return direct;\n}")
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/mwitkow/kedge/lib/http/tripperware"
 	"github.com/mwitkow/kedge/lib/map"
 	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/mwitkow/kedge/lib/tracing"
 	"github.com/oxtoacart/bpool"
 	"github.com/sirupsen/logrus"
 )
@@ -28,13 +29,13 @@ type winchMapper interface {
 
 func New(mapper winchMapper, config *tls.Config, logEntry *logrus.Entry) *Proxy {
 	// Prepare chain of trippers for winch logic. (The last wrapped will be first in the chain of tripperwares)
-	// 5) Last, default transport for communication with our kedges.
+	// 5) Last, default transport for communication with our kedges, traced.
 	// 4) Kedge auth tipper - injects auth for kedge based on route.
 	// 3) Backend auth tripper - injects auth for backend based on route.
 	// 2) Routing tripper - redirects to kedge if specified based on route.
 	// 1) First, mapping tripper - maps dns to route and puts it to request context for rest of the tripperwares.
 
-	parentTransport := tripperware.Default(config)
+	parentTransport := tracing.WrapTransport(tripperware.Default(config), "kedge")
 	parentTransport = tripperware.WrapForProxyAuth(parentTransport)
 	parentTransport = tripperware.WrapForBackendAuth(parentTransport)
 	parentTransport = tripperware.WrapForRouting(parentTransport)
@@ -42,6 +43,8 @@ func New(mapper winchMapper, config *tls.Config, logEntry *logrus.Entry) *Proxy
 
 	bufferpool := bpool.NewBytePool(*flagBufferCount, *flagBufferSizeBytes)
 	return &Proxy{
+		mapper:    mapper,
+		tlsConfig: config,
 		kedgeReverseProxy: &httputil.ReverseProxy{
 			Director:      func(r *http.Request) {},
 			Transport:     parentTransport,
@@ -54,6 +57,9 @@ func New(mapper winchMapper, config *tls.Config, logEntry *logrus.Entry) *Proxy
 
 // Proxy is a forward/reverse proxy that implements Mapper+Kedge forwarding.
 type Proxy struct {
+	mapper    winchMapper
+	tlsConfig *tls.Config
+
 	kedgeReverseProxy *httputil.ReverseProxy
 }
 
@@ -62,6 +68,11 @@ func (p *Proxy) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		panic("the http.ResponseWriter passed must be an http.Flusher")
 	}
 
+	if req.Method == http.MethodConnect {
+		p.serveConnect(resp, req)
+		return
+	}
+
 	if req.URL.Scheme == "" {
 		// Local resource was requested and was not in previous route pattern.
 		http.NotFound(resp, req)
@@ -21,9 +21,15 @@ import (
 	"github.com/mwitkow/go-httpwares/tracing/debug"
 	"github.com/mwitkow/go-proto-validators"
 	pb_config "github.com/mwitkow/kedge/_protogen/winch/config"
+	// Registers dynamic log_level and log_level_overrides flags.
+	"github.com/mwitkow/kedge/lib/loglevel"
+	"github.com/mwitkow/kedge/lib/logsink"
 	"github.com/mwitkow/kedge/lib/map"
+	"github.com/mwitkow/kedge/lib/requestid"
 	"github.com/mwitkow/kedge/lib/sharedflags"
+	"github.com/mwitkow/kedge/lib/tracing"
 	"github.com/mwitkow/kedge/winch/lib"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pressly/chi"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/trace"
@@ -44,7 +50,6 @@ var (
 		&pb_config.AuthConfig{},
 		"Contents of the Winch Auth configuration. Content or read from file if _path suffix.").
 		WithFileFlag("../../misc/winch_auth.json").WithValidator(validateMapper)
-	flagLogLevel = sharedflags.Set.String("log_level", "info", "Log level")
 )
 
 func validateMapper(msg proto.Message) error {
@@ -60,6 +65,12 @@ func main() {
 	if err := sharedflags.Set.Parse(os.Args); err != nil {
 		log.WithError(err).Fatal("failed parsing flags")
 	}
+	tracer, tracerCloser, err := tracing.NewFromFlags("winch")
+	if err != nil {
+		log.WithError(err).Fatal("failed to create tracer")
+	}
+	defer tracerCloser.Close()
+	opentracing.SetGlobalTracer(tracer)
 	if err := flagz.ReadFileFlags(sharedflags.Set); err != nil {
 		log.WithError(err).Fatal("failed reading flagz from files")
 	}
@@ -70,20 +81,26 @@ func main() {
 
 	log.SetOutput(os.Stdout)
 
-	lvl, err := log.ParseLevel(*flagLogLevel)
+	remoteLogHook, err := logsink.NewHookFromFlags("winch")
 	if err != nil {
-		log.WithError(err).Fatal("Cannot parse log level: %s", *flagLogLevel)
+		log.WithError(err).Fatal("failed to create remote log hook")
+	}
+	if remoteLogHook != nil {
+		log.AddHook(remoteLogHook)
 	}
-
-	log.SetLevel(lvl)
 	logEntry := log.NewEntry(log.StandardLogger())
 
 	var httpPlainListener net.Listener
 	httpPlainListener = buildListenerOrFail("http_plain", *flagHttpPort)
 	log.Infof("listening for HTTP Plain on: %v", httpPlainListener.Addr().String())
 
+	// Log levels can be changed at runtime only with the write token, winch has no other authorization of its own.
+	flagzWriteAuth, err := sharedflags.WriteTokenAuthFromFlags()
+	if err != nil {
+		log.WithError(err).Fatal("failed to create flagz write authorization")
+	}
 	mux := http.NewServeMux()
-	mux.Handle("/debug/flagz", http.HandlerFunc(flagz.NewStatusEndpoint(sharedflags.Set).ListFlags))
+	mux.Handle("/debug/flagz", sharedflags.FlagzEndpoint(flagzWriteAuth, loglevel.Flags...))
 	mux.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
 	mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
 	mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
@@ -119,6 +136,9 @@ func main() {
 		ErrorLog:     http_logrus.AsHttpLogger(logEntry),
 		Handler: chi.Chain(
 			http_ctxtags.Middleware("winch"),
+			// Winch is a local proxy, so request IDs from local clients are trusted.
+			requestid.Middleware(requestid.TrustAll),
+			tracing.Middleware("winch"),
 			http_debug.Middleware(),
 			http_logrus.Middleware(logEntry, http_logrus.WithLevels(winchCodeToLevel)),
 		).Handler(mux),